@@ -0,0 +1,283 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// margen tolera pequeñas diferencias de reloj al validar exp/iat/nbf
+const margen = time.Minute
+
+// Errores de verificación
+var (
+	ErrTokenInvalido    = errors.New("token inválido")
+	ErrTokenExpirado    = errors.New("token expirado")
+	ErrClaveDesconocida = errors.New("kid desconocido")
+)
+
+// Usuario es el sujeto autenticado de una petición
+type Usuario struct {
+	UID   string `json:"uid"`
+	Email string `json:"email,omitempty"`
+	// EmailVerificado indica que el proveedor confirmó el correo (claim email_verified)
+	EmailVerificado bool `json:"email_verificado,omitempty"`
+}
+
+// Verifier valida JWT RS256 (por ejemplo los ID tokens de Firebase) con las
+// claves públicas de un archivo JWKS. El archivo se vuelve a leer cuando llega
+// un kid desconocido y su fecha de modificación cambió, para soportar rotación.
+type Verifier struct {
+	archivo  string
+	issuer   string
+	audience string
+	ahora    func() time.Time
+
+	mu     sync.RWMutex
+	claves map[string]*rsa.PublicKey
+	mtime  time.Time
+}
+
+// NewVerifier crea un verificador que lee las claves de archivo. issuer y
+// audience son opcionales; si se indican, los claims iss/aud deben coincidir.
+func NewVerifier(archivo, issuer, audience string) (*Verifier, error) {
+	v := &Verifier{archivo: archivo, issuer: issuer, audience: audience, ahora: time.Now}
+	if err := v.recargar(); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+// NewVerifierFromEnv configura el verificador con variables de entorno:
+//
+//	AUTH_DISABLED=true      desactiva la autenticación (devuelve nil, nil)
+//	AUTH_JWKS_FILE          ruta del archivo JWKS (obligatorio)
+//	AUTH_FIREBASE_PROJECT   id del proyecto Firebase: fija iss y aud como lo hace Firebase
+//	AUTH_ISSUER/AUTH_AUDIENCE  sobreescriben iss/aud esperados
+func NewVerifierFromEnv() (*Verifier, error) {
+	if strings.EqualFold(os.Getenv("AUTH_DISABLED"), "true") {
+		return nil, nil
+	}
+	archivo := os.Getenv("AUTH_JWKS_FILE")
+	if archivo == "" {
+		return nil, errors.New("AUTH_JWKS_FILE no definido (use AUTH_DISABLED=true para desactivar la autenticación)")
+	}
+	var issuer, audience string
+	if proyecto := os.Getenv("AUTH_FIREBASE_PROJECT"); proyecto != "" {
+		issuer = "https://securetoken.google.com/" + proyecto
+		audience = proyecto
+	}
+	if v := os.Getenv("AUTH_ISSUER"); v != "" {
+		issuer = v
+	}
+	if v := os.Getenv("AUTH_AUDIENCE"); v != "" {
+		audience = v
+	}
+	return NewVerifier(archivo, issuer, audience)
+}
+
+type cabecera struct {
+	Alg string `json:"alg"`
+	Kid string `json:"kid"`
+}
+
+type claims struct {
+	Sub      string          `json:"sub"`
+	Iss      string          `json:"iss"`
+	Aud      json.RawMessage `json:"aud"`
+	Exp      *int64          `json:"exp"`
+	Iat      *int64          `json:"iat"`
+	Nbf      *int64          `json:"nbf"`
+	AuthTime *int64          `json:"auth_time"`
+	Email    string          `json:"email"`
+	EmailOK  bool            `json:"email_verified"`
+}
+
+// Verificar valida firma y claims del token y devuelve el usuario
+func (v *Verifier) Verificar(token string) (Usuario, error) {
+	partes := strings.Split(token, ".")
+	if len(partes) != 3 {
+		return Usuario{}, ErrTokenInvalido
+	}
+	var cab cabecera
+	if err := decodificarParte(partes[0], &cab); err != nil {
+		return Usuario{}, err
+	}
+	if cab.Alg != "RS256" {
+		return Usuario{}, fmt.Errorf("%w: algoritmo %q no soportado", ErrTokenInvalido, cab.Alg)
+	}
+	clave, err := v.clave(cab.Kid)
+	if err != nil {
+		return Usuario{}, err
+	}
+	firma, err := base64.RawURLEncoding.DecodeString(partes[2])
+	if err != nil {
+		return Usuario{}, fmt.Errorf("%w: firma mal codificada", ErrTokenInvalido)
+	}
+	digest := sha256.Sum256([]byte(partes[0] + "." + partes[1]))
+	if err := rsa.VerifyPKCS1v15(clave, crypto.SHA256, digest[:], firma); err != nil {
+		return Usuario{}, fmt.Errorf("%w: firma incorrecta", ErrTokenInvalido)
+	}
+
+	var c claims
+	if err := decodificarParte(partes[1], &c); err != nil {
+		return Usuario{}, err
+	}
+	ahora := v.ahora()
+	if c.Exp == nil || ahora.After(time.Unix(*c.Exp, 0).Add(margen)) {
+		return Usuario{}, ErrTokenExpirado
+	}
+	if c.Iat != nil && time.Unix(*c.Iat, 0).After(ahora.Add(margen)) {
+		return Usuario{}, fmt.Errorf("%w: iat en el futuro", ErrTokenInvalido)
+	}
+	if c.Nbf != nil && time.Unix(*c.Nbf, 0).After(ahora.Add(margen)) {
+		return Usuario{}, fmt.Errorf("%w: token aún no válido", ErrTokenInvalido)
+	}
+	if c.AuthTime != nil && time.Unix(*c.AuthTime, 0).After(ahora.Add(margen)) {
+		return Usuario{}, fmt.Errorf("%w: auth_time en el futuro", ErrTokenInvalido)
+	}
+	if v.issuer != "" && c.Iss != v.issuer {
+		return Usuario{}, fmt.Errorf("%w: iss inesperado", ErrTokenInvalido)
+	}
+	if v.audience != "" && !contieneAudiencia(c.Aud, v.audience) {
+		return Usuario{}, fmt.Errorf("%w: aud inesperado", ErrTokenInvalido)
+	}
+	if c.Sub == "" {
+		return Usuario{}, fmt.Errorf("%w: sub vacío", ErrTokenInvalido)
+	}
+	return Usuario{UID: c.Sub, Email: c.Email, EmailVerificado: c.EmailOK}, nil
+}
+
+func (v *Verifier) clave(kid string) (*rsa.PublicKey, error) {
+	v.mu.RLock()
+	k, ok := v.claves[kid]
+	v.mu.RUnlock()
+	if ok {
+		return k, nil
+	}
+	// kid desconocido: quizá rotaron las claves
+	if err := v.recargar(); err != nil {
+		return nil, err
+	}
+	v.mu.RLock()
+	defer v.mu.RUnlock()
+	if k, ok := v.claves[kid]; ok {
+		return k, nil
+	}
+	return nil, ErrClaveDesconocida
+}
+
+// recargar lee el archivo de claves si cambió desde la última lectura
+func (v *Verifier) recargar() error {
+	info, err := os.Stat(v.archivo)
+	if err != nil {
+		return fmt.Errorf("leyendo JWKS: %w", err)
+	}
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if v.claves != nil && info.ModTime().Equal(v.mtime) {
+		return nil
+	}
+	raw, err := os.ReadFile(v.archivo)
+	if err != nil {
+		return fmt.Errorf("leyendo JWKS: %w", err)
+	}
+	claves, err := parsearClaves(raw)
+	if err != nil {
+		return fmt.Errorf("parseando JWKS %s: %w", v.archivo, err)
+	}
+	v.claves = claves
+	v.mtime = info.ModTime()
+	return nil
+}
+
+// parsearClaves acepta un JWKS ({"keys": [...]}) o el formato de certificados
+// de Firebase ({"kid": "-----BEGIN CERTIFICATE-----..."}).
+func parsearClaves(raw []byte) (map[string]*rsa.PublicKey, error) {
+	var jwks struct {
+		Keys []struct {
+			Kty string `json:"kty"`
+			Kid string `json:"kid"`
+			N   string `json:"n"`
+			E   string `json:"e"`
+		} `json:"keys"`
+	}
+	claves := make(map[string]*rsa.PublicKey)
+	if err := json.Unmarshal(raw, &jwks); err == nil && jwks.Keys != nil {
+		for _, k := range jwks.Keys {
+			if k.Kty != "RSA" {
+				continue
+			}
+			n, err := base64.RawURLEncoding.DecodeString(k.N)
+			if err != nil {
+				return nil, fmt.Errorf("clave %s: n inválido", k.Kid)
+			}
+			e, err := base64.RawURLEncoding.DecodeString(k.E)
+			if err != nil {
+				return nil, fmt.Errorf("clave %s: e inválido", k.Kid)
+			}
+			claves[k.Kid] = &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}
+		}
+		return claves, nil
+	}
+
+	var certs map[string]string
+	if err := json.Unmarshal(raw, &certs); err != nil {
+		return nil, errors.New("formato no reconocido")
+	}
+	for kid, p := range certs {
+		bloque, _ := pem.Decode([]byte(p))
+		if bloque == nil {
+			return nil, fmt.Errorf("clave %s: PEM inválido", kid)
+		}
+		cert, err := x509.ParseCertificate(bloque.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("clave %s: %w", kid, err)
+		}
+		pub, ok := cert.PublicKey.(*rsa.PublicKey)
+		if !ok {
+			return nil, fmt.Errorf("clave %s: no es RSA", kid)
+		}
+		claves[kid] = pub
+	}
+	return claves, nil
+}
+
+func decodificarParte(parte string, dest interface{}) error {
+	raw, err := base64.RawURLEncoding.DecodeString(parte)
+	if err != nil {
+		return fmt.Errorf("%w: codificación inválida", ErrTokenInvalido)
+	}
+	if err := json.Unmarshal(raw, dest); err != nil {
+		return fmt.Errorf("%w: JSON inválido", ErrTokenInvalido)
+	}
+	return nil
+}
+
+// contieneAudiencia acepta aud como string o como arreglo de strings
+func contieneAudiencia(raw json.RawMessage, esperada string) bool {
+	var una string
+	if err := json.Unmarshal(raw, &una); err == nil {
+		return una == esperada
+	}
+	var varias []string
+	if err := json.Unmarshal(raw, &varias); err == nil {
+		for _, a := range varias {
+			if a == esperada {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,251 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+var ahoraPrueba = time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+
+func nuevaClave(t *testing.T) *rsa.PrivateKey {
+	t.Helper()
+	k, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return k
+}
+
+func codificar(t *testing.T, v interface{}) string {
+	t.Helper()
+	raw, err := json.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return base64.RawURLEncoding.EncodeToString(raw)
+}
+
+// firmar arma un JWT RS256 con la cabecera y los claims dados
+func firmar(t *testing.T, k *rsa.PrivateKey, cab, c map[string]interface{}) string {
+	t.Helper()
+	contenido := codificar(t, cab) + "." + codificar(t, c)
+	digest := sha256.Sum256([]byte(contenido))
+	firma, err := rsa.SignPKCS1v15(rand.Reader, k, crypto.SHA256, digest[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	return contenido + "." + base64.RawURLEncoding.EncodeToString(firma)
+}
+
+// escribirJWKS guarda las claves públicas en formato JWKS
+func escribirJWKS(t *testing.T, archivo string, claves map[string]*rsa.PrivateKey) {
+	t.Helper()
+	var keys []map[string]string
+	for kid, k := range claves {
+		keys = append(keys, map[string]string{
+			"kty": "RSA",
+			"kid": kid,
+			"n":   base64.RawURLEncoding.EncodeToString(k.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(k.E)).Bytes()),
+		})
+	}
+	raw, err := json.Marshal(map[string]interface{}{"keys": keys})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(archivo, raw, 0o600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestVerificar(t *testing.T) {
+	clave, otra := nuevaClave(t), nuevaClave(t)
+	archivo := filepath.Join(t.TempDir(), "jwks.json")
+	escribirJWKS(t, archivo, map[string]*rsa.PrivateKey{"k1": clave})
+	v, err := NewVerifier(archivo, "https://securetoken.google.com/plan", "plan")
+	if err != nil {
+		t.Fatal(err)
+	}
+	v.ahora = func() time.Time { return ahoraPrueba }
+
+	rs256 := map[string]interface{}{"alg": "RS256", "kid": "k1"}
+	base := func(cambios map[string]interface{}) map[string]interface{} {
+		c := map[string]interface{}{
+			"sub":            "u1",
+			"iss":            "https://securetoken.google.com/plan",
+			"aud":            "plan",
+			"exp":            ahoraPrueba.Add(time.Hour).Unix(),
+			"iat":            ahoraPrueba.Add(-time.Minute).Unix(),
+			"email":          "ana@example.com",
+			"email_verified": true,
+		}
+		for k, val := range cambios {
+			if val == nil {
+				delete(c, k)
+				continue
+			}
+			c[k] = val
+		}
+		return c
+	}
+	alterado := func() string {
+		partes := strings.Split(firmar(t, clave, rs256, base(nil)), ".")
+		partes[1] = codificar(t, base(map[string]interface{}{"sub": "admin"}))
+		return strings.Join(partes, ".")
+	}()
+
+	tests := []struct {
+		nombre string
+		token  string
+		err    error
+		texto  string
+	}{
+		{"válido", firmar(t, clave, rs256, base(nil)), nil, ""},
+		{"aud en arreglo", firmar(t, clave, rs256, base(map[string]interface{}{"aud": []string{"otro", "plan"}})), nil, ""},
+		{"exp dentro del margen", firmar(t, clave, rs256, base(map[string]interface{}{"exp": ahoraPrueba.Add(-30 * time.Second).Unix()})), nil, ""},
+		{"no es JWT", "abc.def", ErrTokenInvalido, ""},
+		{"firma de otra clave", firmar(t, otra, rs256, base(nil)), ErrTokenInvalido, "firma incorrecta"},
+		{"claims alterados", alterado, ErrTokenInvalido, "firma incorrecta"},
+		{"alg HS256", firmar(t, clave, map[string]interface{}{"alg": "HS256", "kid": "k1"}, base(nil)), ErrTokenInvalido, "HS256"},
+		{"alg none", firmar(t, clave, map[string]interface{}{"alg": "none", "kid": "k1"}, base(nil)), ErrTokenInvalido, "none"},
+		{"sin exp", firmar(t, clave, rs256, base(map[string]interface{}{"exp": nil})), ErrTokenExpirado, ""},
+		{"expirado", firmar(t, clave, rs256, base(map[string]interface{}{"exp": ahoraPrueba.Add(-2 * time.Minute).Unix()})), ErrTokenExpirado, ""},
+		{"nbf en el futuro", firmar(t, clave, rs256, base(map[string]interface{}{"nbf": ahoraPrueba.Add(5 * time.Minute).Unix()})), ErrTokenInvalido, "aún no válido"},
+		{"iat en el futuro", firmar(t, clave, rs256, base(map[string]interface{}{"iat": ahoraPrueba.Add(5 * time.Minute).Unix()})), ErrTokenInvalido, "iat"},
+		{"iss de otro proyecto", firmar(t, clave, rs256, base(map[string]interface{}{"iss": "https://securetoken.google.com/otro"})), ErrTokenInvalido, "iss"},
+		{"aud de otro proyecto", firmar(t, clave, rs256, base(map[string]interface{}{"aud": "otro"})), ErrTokenInvalido, "aud"},
+		{"sin sub", firmar(t, clave, rs256, base(map[string]interface{}{"sub": ""})), ErrTokenInvalido, "sub"},
+		{"kid desconocido", firmar(t, clave, map[string]interface{}{"alg": "RS256", "kid": "k9"}, base(nil)), ErrClaveDesconocida, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.nombre, func(t *testing.T) {
+			u, err := v.Verificar(tt.token)
+			if tt.err == nil {
+				if err != nil {
+					t.Fatalf("err = %v", err)
+				}
+				if u != (Usuario{UID: "u1", Email: "ana@example.com", EmailVerificado: true}) {
+					t.Errorf("usuario = %+v", u)
+				}
+				return
+			}
+			if !errors.Is(err, tt.err) || !strings.Contains(err.Error(), tt.texto) {
+				t.Errorf("err = %v, want %v con %q", err, tt.err, tt.texto)
+			}
+		})
+	}
+}
+
+// Un kid desconocido vuelve a leer el archivo si cambió (rotación de claves)
+func TestVerificarRecargaConKidNuevo(t *testing.T) {
+	vieja, nueva := nuevaClave(t), nuevaClave(t)
+	archivo := filepath.Join(t.TempDir(), "jwks.json")
+	escribirJWKS(t, archivo, map[string]*rsa.PrivateKey{"vieja": vieja})
+	v, err := NewVerifier(archivo, "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	v.ahora = func() time.Time { return ahoraPrueba }
+	c := map[string]interface{}{"sub": "u1", "exp": ahoraPrueba.Add(time.Hour).Unix()}
+	token := firmar(t, nueva, map[string]interface{}{"alg": "RS256", "kid": "nueva"}, c)
+
+	if _, err := v.Verificar(token); !errors.Is(err, ErrClaveDesconocida) {
+		t.Fatalf("antes de rotar: err = %v, want %v", err, ErrClaveDesconocida)
+	}
+	escribirJWKS(t, archivo, map[string]*rsa.PrivateKey{"vieja": vieja, "nueva": nueva})
+	// la recarga depende de la fecha de modificación del archivo
+	futuro := time.Now().Add(time.Hour)
+	if err := os.Chtimes(archivo, futuro, futuro); err != nil {
+		t.Fatal(err)
+	}
+	if u, err := v.Verificar(token); err != nil || u.UID != "u1" {
+		t.Fatalf("después de rotar: usuario %+v, err = %v", u, err)
+	}
+	if _, err := v.Verificar(firmar(t, vieja, map[string]interface{}{"alg": "RS256", "kid": "vieja"}, c)); err != nil {
+		t.Errorf("clave vieja: err = %v", err)
+	}
+}
+
+func TestParsearClaves(t *testing.T) {
+	k := nuevaClave(t)
+	plantilla := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "securetoken.system.gserviceaccount.com"},
+		NotBefore:    ahoraPrueba.Add(-time.Hour),
+		NotAfter:     ahoraPrueba.Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, plantilla, plantilla, &k.PublicKey, k)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert := string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}))
+	firebase, err := json.Marshal(map[string]string{"fb1": cert})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		nombre string
+		raw    string
+		kids   []string
+		error  string
+	}{
+		{"certificados de Firebase", string(firebase), []string{"fb1"}, ""},
+		{"JWKS ignora claves que no son RSA", `{"keys":[{"kty":"EC","kid":"ec"},{"kty":"RSA","kid":"r","n":"AQAB","e":"AQAB"}]}`, []string{"r"}, ""},
+		{"n mal codificado", `{"keys":[{"kty":"RSA","kid":"r","n":"%%","e":"AQAB"}]}`, nil, "n inválido"},
+		{"PEM inválido", `{"fb1":"no es un certificado"}`, nil, "PEM inválido"},
+		{"formato desconocido", `[1, 2]`, nil, "formato no reconocido"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.nombre, func(t *testing.T) {
+			claves, err := parsearClaves([]byte(tt.raw))
+			if tt.error != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.error) {
+					t.Fatalf("err = %v, want %q", err, tt.error)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(claves) != len(tt.kids) {
+				t.Fatalf("claves = %v, want %v", claves, tt.kids)
+			}
+			for _, kid := range tt.kids {
+				if claves[kid] == nil {
+					t.Errorf("falta la clave %s", kid)
+				}
+			}
+		})
+	}
+
+	// el verificador acepta tokens firmados con la clave del certificado
+	archivo := filepath.Join(t.TempDir(), "certs.json")
+	if err := os.WriteFile(archivo, firebase, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	v, err := NewVerifier(archivo, "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	v.ahora = func() time.Time { return ahoraPrueba }
+	token := firmar(t, k, map[string]interface{}{"alg": "RS256", "kid": "fb1"},
+		map[string]interface{}{"sub": "u1", "exp": ahoraPrueba.Add(time.Hour).Unix()})
+	if u, err := v.Verificar(token); err != nil || u.UID != "u1" {
+		t.Errorf("usuario %+v, err = %v", u, err)
+	}
+}
@@ -0,0 +1,61 @@
+package auth
+
+import (
+	"context"
+	"net/http"
+	"strings"
+)
+
+type claveContexto struct{}
+
+// ConUsuario devuelve un contexto que lleva el usuario autenticado
+func ConUsuario(ctx context.Context, u Usuario) context.Context {
+	return context.WithValue(ctx, claveContexto{}, u)
+}
+
+// UsuarioDe devuelve el usuario autenticado de la petición, si lo hay
+func UsuarioDe(ctx context.Context) (Usuario, bool) {
+	u, ok := ctx.Value(claveContexto{}).(Usuario)
+	return u, ok
+}
+
+// rutasPublicas no requieren token
+var rutasPublicas = map[string]bool{
+	"/health": true,
+}
+
+// Middleware exige un header "Authorization: Bearer <token>" válido y guarda
+// el usuario en el contexto. Las peticiones OPTIONS (preflight CORS) pasan sin token.
+func (v *Verifier) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodOptions || rutasPublicas[r.URL.Path] {
+			next.ServeHTTP(w, r)
+			return
+		}
+		token, ok := tokenBearer(r)
+		if !ok {
+			noAutorizado(w, "falta token Bearer")
+			return
+		}
+		u, err := v.Verificar(token)
+		if err != nil {
+			noAutorizado(w, err.Error())
+			return
+		}
+		next.ServeHTTP(w, r.WithContext(ConUsuario(r.Context(), u)))
+	})
+}
+
+func tokenBearer(r *http.Request) (string, bool) {
+	h := r.Header.Get("Authorization")
+	const prefijo = "bearer "
+	if len(h) <= len(prefijo) || !strings.EqualFold(h[:len(prefijo)], prefijo) {
+		return "", false
+	}
+	return strings.TrimSpace(h[len(prefijo):]), true
+}
+
+func noAutorizado(w http.ResponseWriter, msg string) {
+	w.Header().Set("WWW-Authenticate", `Bearer error="invalid_token"`)
+	http.Error(w, msg, http.StatusUnauthorized)
+}
@@ -0,0 +1,83 @@
+package controllers
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/JostinAlvaradoS/liveplan_backend_go/internal/auth"
+	"github.com/JostinAlvaradoS/liveplan_backend_go/internal/models"
+	"gorm.io/gorm"
+)
+
+// ErrSinAcceso indica que el usuario autenticado no tiene acceso al plan
+var ErrSinAcceso = errors.New("no tiene acceso a este plan")
+
+// ErrRolInsuficiente indica que el usuario ve el plan pero su rol no permite la operación
+var ErrRolInsuficiente = errors.New("su rol en este plan no permite esta operación")
+
+// RolEnPlan devuelve el rol del usuario en el plan: owner si es el Autor, o el
+// mayor rol de sus filas en plan_colaboradores (por UID o por correo verificado).
+// Devuelve gorm.ErrRecordNotFound si el plan no existe y ErrSinAcceso si no tiene rol.
+func RolEnPlan(db *gorm.DB, u auth.Usuario, planID uint) (string, error) {
+	var plan models.PlanNegocio
+	if err := db.Select("id", "autor").First(&plan, planID).Error; err != nil {
+		return "", err
+	}
+	if plan.Autor == u.UID {
+		return models.RolOwner, nil
+	}
+	var colaboradores []models.PlanColaborador
+	if err := colaboradoresDe(db.Where("plan_negocio_id = ?", planID), u).Find(&colaboradores).Error; err != nil {
+		return "", err
+	}
+	rol := ""
+	for _, c := range colaboradores {
+		if models.NivelRol(c.Rol) > models.NivelRol(rol) {
+			rol = c.Rol
+		}
+	}
+	if rol == "" {
+		return "", ErrSinAcceso
+	}
+	return rol, nil
+}
+
+// VerificarAccesoPlan comprueba que el usuario tenga al menos rolMinimo en el plan
+func VerificarAccesoPlan(db *gorm.DB, u auth.Usuario, planID uint, rolMinimo string) error {
+	rol, err := RolEnPlan(db, u, planID)
+	if err != nil {
+		return err
+	}
+	if models.NivelRol(rol) < models.NivelRol(rolMinimo) {
+		return ErrRolInsuficiente
+	}
+	return nil
+}
+
+// colaboradoresDe filtra las filas de plan_colaboradores que corresponden al usuario.
+// El correo solo cuenta si el proveedor lo verificó.
+func colaboradoresDe(query *gorm.DB, u auth.Usuario) *gorm.DB {
+	if u.Email != "" && u.EmailVerificado {
+		return query.Where("(usuario_uid = ? OR (usuario_uid = '' AND lower(email) = ?))", u.UID, strings.ToLower(u.Email))
+	}
+	return query.Where("usuario_uid = ?", u.UID)
+}
+
+// planesDeUsuario filtra los planes que el usuario creó o que le compartieron
+func planesDeUsuario(db *gorm.DB, u auth.Usuario) *gorm.DB {
+	compartidos := colaboradoresDe(db.Model(&models.PlanColaborador{}).Select("plan_negocio_id"), u)
+	return db.Where("autor = ? OR id IN (?)", u.UID, compartidos)
+}
+
+// PlanDeFila devuelve el plan_negocio_id de la fila id del modelo indicado
+// (por ejemplo &models.Ventas{}). Devuelve gorm.ErrRecordNotFound si la fila no existe.
+func PlanDeFila(db *gorm.DB, modelo interface{}, id uint) (uint, error) {
+	var filas []uint
+	if err := db.Model(modelo).Where("id = ?", id).Limit(1).Pluck("plan_negocio_id", &filas).Error; err != nil {
+		return 0, err
+	}
+	if len(filas) == 0 {
+		return 0, gorm.ErrRecordNotFound
+	}
+	return filas[0], nil
+}
@@ -1,16 +1,34 @@
 package controllers
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"net/http"
+	"strconv"
 
+	"github.com/JostinAlvaradoS/liveplan_backend_go/internal/jobs"
 	"github.com/JostinAlvaradoS/liveplan_backend_go/internal/models"
+	"github.com/JostinAlvaradoS/liveplan_backend_go/internal/procedimientos"
 	"gorm.io/gorm"
 )
 
+// TipoJobSensibilidad identifica los trabajos de cálculo de la matriz de sensibilidad
+const TipoJobSensibilidad = "analisis_sensibilidad"
+
 func ListAnalisisSensibilidad(db *gorm.DB, w http.ResponseWriter, r *http.Request) {
 	var items []models.AnalisisSensibilidad
-	if err := db.Find(&items).Error; err != nil {
+	query := db
+	if pid := r.URL.Query().Get("plan_id"); pid != "" {
+		id, err := strconv.Atoi(pid)
+		if err != nil {
+			http.Error(w, "invalid plan_id", http.StatusBadRequest)
+			return
+		}
+		query = query.Where("plan_negocio_id = ?", id)
+	}
+	if err := query.Find(&items).Error; err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
@@ -108,3 +126,56 @@ func DeleteAnalisisSensibilidad(db *gorm.DB, w http.ResponseWriter, r *http.Requ
 	}
 	w.WriteHeader(http.StatusNoContent)
 }
+
+// IniciarCalculoSensibilidad lanza en segundo plano el cálculo de la matriz de
+// sensibilidad del plan y responde 202 con el trabajo creado. Si ya hay un cálculo
+// en curso para el plan responde 409 con ese trabajo. El trabajo solo existe en
+// la réplica que lo lanzó (ver jobs.Manager).
+func IniciarCalculoSensibilidad(db *gorm.DB, mgr *jobs.Manager, w http.ResponseWriter, r *http.Request, planID uint) {
+	var total int64
+	if err := db.Model(&models.AnalisisSensibilidad{}).Where("plan_negocio_id = ?", planID).Count(&total).Error; err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if total == 0 {
+		http.Error(w, "el plan no tiene matriz de sensibilidad", http.StatusNotFound)
+		return
+	}
+
+	// el cálculo sigue después de responder: no usa el contexto de la petición
+	job, err := mgr.Iniciar(TipoJobSensibilidad, planID, int(total), func(progreso jobs.Progreso) error {
+		return procedimientos.CalcularAnalisisSensibilidadConProgreso(db.WithContext(context.Background()), planID, progreso)
+	})
+	w.Header().Set("Content-Type", "application/json")
+	if errors.Is(err, jobs.ErrEnCurso) {
+		w.WriteHeader(http.StatusConflict)
+		json.NewEncoder(w).Encode(job)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Location", fmt.Sprintf("/analisis_sensibilidad/%d/calcular/%s", planID, job.ID))
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(job)
+}
+
+// GetCalculoSensibilidad devuelve el estado de un trabajo de cálculo de sensibilidad.
+// Con jobID vacío devuelve el trabajo más reciente del plan.
+func GetCalculoSensibilidad(mgr *jobs.Manager, w http.ResponseWriter, r *http.Request, planID uint, jobID string) {
+	var job jobs.Job
+	var ok bool
+	if jobID == "" {
+		job, ok = mgr.Ultimo(TipoJobSensibilidad, planID)
+	} else {
+		job, ok = mgr.Obtener(jobID)
+		ok = ok && job.Tipo == TipoJobSensibilidad && job.PlanNegocioID == planID
+	}
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(job)
+}
@@ -0,0 +1,228 @@
+package controllers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/JostinAlvaradoS/liveplan_backend_go/internal/auth"
+	"github.com/JostinAlvaradoS/liveplan_backend_go/internal/models"
+	"gorm.io/gorm"
+)
+
+// ActorAnonimo es el actor de los cambios hechos sin autenticación (AUTH_DISABLED)
+const ActorAnonimo = "anonimo"
+
+// filaAuditada es una fila de una tabla de entrada con sus columnas como texto JSON
+type filaAuditada struct {
+	tabla  string
+	planID uint
+	id     uint
+	campos map[string]string
+}
+
+// leerFila toma los valores de las columnas de fila (un puntero a un modelo);
+// las asociaciones no se incluyen
+func leerFila(db *gorm.DB, fila interface{}) (filaAuditada, error) {
+	stmt := &gorm.Statement{DB: db}
+	if err := stmt.Parse(fila); err != nil {
+		return filaAuditada{}, err
+	}
+	valor := reflect.Indirect(reflect.ValueOf(fila))
+	out := filaAuditada{tabla: stmt.Schema.Table, campos: make(map[string]string)}
+	for _, f := range stmt.Schema.Fields {
+		if f.DBName == "" {
+			continue
+		}
+		v, _ := f.ValueOf(db.Statement.Context, valor)
+		b, err := json.Marshal(v)
+		if err != nil {
+			return filaAuditada{}, fmt.Errorf("auditoria: %s.%s: %w", out.tabla, f.DBName, err)
+		}
+		out.campos[f.DBName] = string(b)
+	}
+	id, _ := strconv.ParseUint(out.campos["id"], 10, 64)
+	out.id = uint(id)
+	if _, ok := fila.(*models.PlanNegocio); ok {
+		out.planID = out.id
+	} else {
+		planID, _ := strconv.ParseUint(out.campos["plan_negocio_id"], 10, 64)
+		out.planID = uint(planID)
+	}
+	return out, nil
+}
+
+// actorDe devuelve el UID del usuario que hace la petición
+func actorDe(r *http.Request) string {
+	if u, ok := auth.UsuarioDe(r.Context()); ok && u.UID != "" {
+		return u.UID
+	}
+	return ActorAnonimo
+}
+
+// conAuditoria aplica cambio a fila (puntero a un modelo de una tabla de
+// entrada; ya cargado si accion no es crear) en una transacción y registra en
+// auditoria un renglón por columna que cambió. Al crear se registran todas las
+// columnas con su valor nuevo y al eliminar todas con su valor anterior.
+func conAuditoria(db *gorm.DB, r *http.Request, accion string, fila interface{}, cambio func(tx *gorm.DB) error) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		var antes, despues *filaAuditada
+		if accion != models.AuditoriaCrear {
+			f, err := leerFila(tx, fila)
+			if err != nil {
+				return err
+			}
+			antes = &f
+		}
+		if err := cambio(tx); err != nil {
+			return err
+		}
+		if accion != models.AuditoriaEliminar {
+			f, err := leerFila(tx, fila)
+			if err != nil {
+				return err
+			}
+			// releer la fila: Updates con un mapa no deja en el struct los
+			// valores tal como quedaron en la base
+			actual := reflect.New(reflect.Indirect(reflect.ValueOf(fila)).Type()).Interface()
+			if err := tx.First(actual, f.id).Error; err != nil {
+				return err
+			}
+			if f, err = leerFila(tx, actual); err != nil {
+				return err
+			}
+			despues = &f
+		}
+		return registrarAuditoria(tx, actorDe(r), accion, antes, despues)
+	})
+}
+
+// eliminarConAuditoria borra la fila id del modelo dado (p. ej. &models.Supuesto{})
+// y registra sus valores. Devuelve gorm.ErrRecordNotFound si no existe.
+func eliminarConAuditoria(db *gorm.DB, r *http.Request, modelo interface{}, id uint) error {
+	if err := db.First(modelo, id).Error; err != nil {
+		return err
+	}
+	return conAuditoria(db, r, models.AuditoriaEliminar, modelo, func(tx *gorm.DB) error {
+		return tx.Delete(modelo).Error
+	})
+}
+
+// registrarAltas deja en auditoria como altas filas que ya se crearon por otro
+// camino (filas es un puntero a un slice de modelos)
+func registrarAltas(tx *gorm.DB, r *http.Request, filas interface{}) error {
+	lista := reflect.Indirect(reflect.ValueOf(filas))
+	for i := 0; i < lista.Len(); i++ {
+		f, err := leerFila(tx, lista.Index(i).Addr().Interface())
+		if err != nil {
+			return err
+		}
+		if err := registrarAuditoria(tx, actorDe(r), models.AuditoriaCrear, nil, &f); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// registrarAuditoria guarda las columnas que difieren entre antes y despues
+// (nil si la fila no existía o ya no existe)
+func registrarAuditoria(tx *gorm.DB, actor, accion string, antes, despues *filaAuditada) error {
+	fila := despues
+	if fila == nil {
+		fila = antes
+	}
+	if fila == nil {
+		return nil
+	}
+	campos := make([]string, 0, len(fila.campos))
+	for campo := range fila.campos {
+		if campo != "id" {
+			campos = append(campos, campo)
+		}
+	}
+	sort.Strings(campos)
+
+	fecha := time.Now()
+	var registros []models.Auditoria
+	for _, campo := range campos {
+		var anterior, nuevo *string
+		if antes != nil {
+			v := antes.campos[campo]
+			anterior = &v
+		}
+		if despues != nil {
+			v := despues.campos[campo]
+			nuevo = &v
+		}
+		if anterior != nil && nuevo != nil && *anterior == *nuevo {
+			continue
+		}
+		registros = append(registros, models.Auditoria{
+			PlanNegocioID: fila.planID,
+			Entidad:       fila.tabla,
+			RegistroID:    fila.id,
+			Accion:        accion,
+			Campo:         campo,
+			ValorAnterior: anterior,
+			ValorNuevo:    nuevo,
+			Actor:         actor,
+			Fecha:         fecha,
+		})
+	}
+	if len(registros) == 0 {
+		return nil
+	}
+	return tx.Create(&registros).Error
+}
+
+// GetHistorialPlan lista los cambios de las tablas de entrada del plan, del
+// más reciente al más antiguo. Filtros opcionales: ?entidad= (nombre de la
+// tabla, p. ej. supuestos), ?desde= y ?hasta= (RFC 3339 o AAAA-MM-DD; hasta
+// con solo fecha incluye ese día completo).
+func GetHistorialPlan(db *gorm.DB, w http.ResponseWriter, r *http.Request, planID uint) {
+	query := db.Where("plan_negocio_id = ?", planID)
+	q := r.URL.Query()
+	if entidad := q.Get("entidad"); entidad != "" {
+		query = query.Where("entidad = ?", entidad)
+	}
+	if v := q.Get("desde"); v != "" {
+		desde, _, err := parsearFecha(v)
+		if err != nil {
+			http.Error(w, "invalid desde", http.StatusBadRequest)
+			return
+		}
+		query = query.Where("fecha >= ?", desde)
+	}
+	if v := q.Get("hasta"); v != "" {
+		hasta, soloFecha, err := parsearFecha(v)
+		if err != nil {
+			http.Error(w, "invalid hasta", http.StatusBadRequest)
+			return
+		}
+		if soloFecha {
+			query = query.Where("fecha < ?", hasta.AddDate(0, 0, 1))
+		} else {
+			query = query.Where("fecha <= ?", hasta)
+		}
+	}
+	var items []models.Auditoria
+	if err := query.Order("fecha desc, id desc").Find(&items).Error; err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(items)
+}
+
+// parsearFecha acepta RFC 3339 o AAAA-MM-DD; soloFecha indica el segundo formato
+func parsearFecha(s string) (t time.Time, soloFecha bool, err error) {
+	if t, err = time.Parse(time.RFC3339, s); err == nil {
+		return t, false, nil
+	}
+	t, err = time.Parse("2006-01-02", s)
+	return t, true, err
+}
@@ -3,6 +3,7 @@ package controllers
 import (
 	"encoding/json"
 	"net/http"
+	"strconv"
 
 	"github.com/JostinAlvaradoS/liveplan_backend_go/internal/models"
 	"gorm.io/gorm"
@@ -10,7 +11,16 @@ import (
 
 func ListBalanceGeneral(db *gorm.DB, w http.ResponseWriter, r *http.Request) {
 	var items []models.BalanceGeneral
-	if err := db.Find(&items).Error; err != nil {
+	query := db
+	if pid := r.URL.Query().Get("plan_id"); pid != "" {
+		id, err := strconv.Atoi(pid)
+		if err != nil {
+			http.Error(w, "invalid plan_id", http.StatusBadRequest)
+			return
+		}
+		query = query.Where("plan_negocio_id = ?", id)
+	}
+	if err := query.Find(&items).Error; err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
@@ -0,0 +1,111 @@
+package controllers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/JostinAlvaradoS/liveplan_backend_go/internal/auth"
+	"github.com/JostinAlvaradoS/liveplan_backend_go/internal/models"
+	"gorm.io/gorm"
+)
+
+// ListColaboradoresByPlan lista los colaboradores del plan (el Autor no aparece:
+// es owner implícito)
+func ListColaboradoresByPlan(db *gorm.DB, w http.ResponseWriter, r *http.Request, planID uint) {
+	var items []models.PlanColaborador
+	if err := db.Where("plan_negocio_id = ?", planID).Order("id").Find(&items).Error; err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(items)
+}
+
+// InvitarColaborador da acceso al plan a un usuario por UID o por correo.
+// Si el usuario ya colabora en el plan se actualiza su rol.
+// Body: {"usuario_uid": "...", "email": "...", "rol": "viewer|editor|owner"}
+func InvitarColaborador(db *gorm.DB, w http.ResponseWriter, r *http.Request, planID uint) {
+	var body struct {
+		UsuarioUID string `json:"usuario_uid"`
+		Email      string `json:"email"`
+		Rol        string `json:"rol"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	body.UsuarioUID = strings.TrimSpace(body.UsuarioUID)
+	body.Email = strings.ToLower(strings.TrimSpace(body.Email))
+	if body.UsuarioUID == "" && body.Email == "" {
+		http.Error(w, "usuario_uid o email es requerido", http.StatusBadRequest)
+		return
+	}
+	if body.Rol == "" {
+		body.Rol = models.RolViewer
+	}
+	if models.NivelRol(body.Rol) == 0 {
+		http.Error(w, "rol debe ser viewer, editor u owner", http.StatusBadRequest)
+		return
+	}
+
+	var plan models.PlanNegocio
+	if err := db.First(&plan, planID).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			http.NotFound(w, r)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if body.UsuarioUID != "" && body.UsuarioUID == plan.Autor {
+		http.Error(w, "el autor ya es owner del plan", http.StatusBadRequest)
+		return
+	}
+
+	var item models.PlanColaborador
+	query := db.Where("plan_negocio_id = ?", planID)
+	if body.UsuarioUID != "" {
+		query = query.Where("usuario_uid = ?", body.UsuarioUID)
+	} else {
+		query = query.Where("usuario_uid = '' AND email = ?", body.Email)
+	}
+	err := query.First(&item).Error
+	if err != nil && err != gorm.ErrRecordNotFound {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	status := http.StatusOK
+	if err == gorm.ErrRecordNotFound {
+		status = http.StatusCreated
+		item = models.PlanColaborador{PlanNegocioID: planID, UsuarioUID: body.UsuarioUID}
+		if u, ok := auth.UsuarioDe(r.Context()); ok {
+			item.InvitadoPor = u.UID
+		}
+	}
+	item.Rol = body.Rol
+	if body.Email != "" {
+		item.Email = body.Email
+	}
+	if err := db.Save(&item).Error; err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(item)
+}
+
+// RevocarColaborador quita el acceso de un colaborador al plan
+func RevocarColaborador(db *gorm.DB, w http.ResponseWriter, r *http.Request, planID, id uint) {
+	res := db.Where("plan_negocio_id = ?", planID).Delete(&models.PlanColaborador{}, id)
+	if res.Error != nil {
+		http.Error(w, res.Error.Error(), http.StatusInternalServerError)
+		return
+	}
+	if res.RowsAffected == 0 {
+		http.NotFound(w, r)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -5,7 +5,6 @@ import (
 	"net/http"
 
 	"github.com/JostinAlvaradoS/liveplan_backend_go/internal/models"
-	"github.com/JostinAlvaradoS/liveplan_backend_go/internal/procedimientos"
 	"gorm.io/gorm"
 )
 
@@ -54,7 +53,9 @@ func CreateCostoMateriasPrimas(db *gorm.DB, w http.ResponseWriter, r *http.Reque
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
-	if err := db.Create(&item).Error; err != nil {
+	if err := conAuditoria(db, r, models.AuditoriaCrear, &item, func(tx *gorm.DB) error {
+		return tx.Create(&item).Error
+	}); err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
@@ -82,20 +83,30 @@ func UpdateCostoMateriasPrimasPatch(db *gorm.DB, w http.ResponseWriter, r *http.
 	delete(body, "id")
 	delete(body, "ID")
 	delete(body, "recalc")
-	if err := db.Model(&item).Updates(body).Error; err != nil {
+	if err := conAuditoria(db, r, models.AuditoriaActualizar, &item, func(tx *gorm.DB) error {
+		return tx.Model(&item).Updates(body).Error
+	}); err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
 	if recalc {
 		planID := item.PlanNegocioID
-		_ = procedimientos.Recalcular(db, planID)
+		if err := recalcularPlan(db, w, planID, "costo_materias_primas"); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
 	}
 	json.NewEncoder(w).Encode(item)
 }
 
 func DeleteCostoMateriasPrimas(db *gorm.DB, w http.ResponseWriter, r *http.Request, id uint) {
-	if err := db.Delete(&models.CostoMateriasPrimas{}, id).Error; err != nil {
+	err := eliminarConAuditoria(db, r, &models.CostoMateriasPrimas{}, id)
+	if err == gorm.ErrRecordNotFound {
+		http.NotFound(w, r)
+		return
+	}
+	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
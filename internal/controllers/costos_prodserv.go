@@ -6,7 +6,6 @@ import (
 	"strconv"
 
 	"github.com/JostinAlvaradoS/liveplan_backend_go/internal/models"
-	"github.com/JostinAlvaradoS/liveplan_backend_go/internal/procedimientos"
 	"gorm.io/gorm"
 )
 
@@ -59,7 +58,9 @@ func CreateCostosProdServ(db *gorm.DB, w http.ResponseWriter, r *http.Request) {
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
-	if err := db.Create(&item).Error; err != nil {
+	if err := conAuditoria(db, r, models.AuditoriaCrear, &item, func(tx *gorm.DB) error {
+		return tx.Create(&item).Error
+	}); err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
@@ -87,20 +88,30 @@ func UpdateCostosProdServPatch(db *gorm.DB, w http.ResponseWriter, r *http.Reque
 	delete(body, "id")
 	delete(body, "ID")
 	delete(body, "recalc")
-	if err := db.Model(&item).Updates(body).Error; err != nil {
+	if err := conAuditoria(db, r, models.AuditoriaActualizar, &item, func(tx *gorm.DB) error {
+		return tx.Model(&item).Updates(body).Error
+	}); err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
 	if recalc {
 		planID := item.PlanNegocioID
-		_ = procedimientos.Recalcular(db, planID)
+		if err := recalcularPlan(db, w, planID, "costos_prod_serv"); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
 	}
 	json.NewEncoder(w).Encode(item)
 }
 
 func DeleteCostosProdServ(db *gorm.DB, w http.ResponseWriter, r *http.Request, id uint) {
-	if err := db.Delete(&models.CostosProdServ{}, id).Error; err != nil {
+	err := eliminarConAuditoria(db, r, &models.CostosProdServ{}, id)
+	if err == gorm.ErrRecordNotFound {
+		http.NotFound(w, r)
+		return
+	}
+	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
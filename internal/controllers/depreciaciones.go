@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"net/http"
 	"strconv"
+	"strings"
 
 	"github.com/JostinAlvaradoS/liveplan_backend_go/internal/models"
 	"gorm.io/gorm"
@@ -11,7 +12,7 @@ import (
 
 func ListDepreciaciones(db *gorm.DB, w http.ResponseWriter, r *http.Request) {
 	var items []models.Depreciacion
-	query := db.Preload("PlanNegocio").Preload("DetalleInversion").Preload("DetalleInversion.Inversion")
+	query := db.Preload("PlanNegocio").Preload("DetalleInversion").Preload("DetalleInversion.Inversion").Preload("Anios", func(db *gorm.DB) *gorm.DB { return db.Order("anio asc") })
 	if pid := r.URL.Query().Get("plan_id"); pid != "" {
 		id, err := strconv.Atoi(pid)
 		if err != nil {
@@ -30,7 +31,7 @@ func ListDepreciaciones(db *gorm.DB, w http.ResponseWriter, r *http.Request) {
 
 func ListDepreciacionesByPlan(db *gorm.DB, w http.ResponseWriter, r *http.Request, planID uint) {
 	var items []models.Depreciacion
-	if err := db.Preload("DetalleInversion").Preload("DetalleInversion.Inversion").Where("plan_negocio_id = ?", planID).Find(&items).Error; err != nil {
+	if err := db.Preload("DetalleInversion").Preload("DetalleInversion.Inversion").Preload("Anios", func(db *gorm.DB) *gorm.DB { return db.Order("anio asc") }).Where("plan_negocio_id = ?", planID).Find(&items).Error; err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
@@ -40,7 +41,7 @@ func ListDepreciacionesByPlan(db *gorm.DB, w http.ResponseWriter, r *http.Reques
 
 func GetDepreciacion(db *gorm.DB, w http.ResponseWriter, r *http.Request, id uint) {
 	var item models.Depreciacion
-	if err := db.Preload("PlanNegocio").Preload("DetalleInversion").Preload("DetalleInversion.Inversion").First(&item, id).Error; err != nil {
+	if err := db.Preload("PlanNegocio").Preload("DetalleInversion").Preload("DetalleInversion.Inversion").Preload("Anios", func(db *gorm.DB) *gorm.DB { return db.Order("anio asc") }).First(&item, id).Error; err != nil {
 		if err == gorm.ErrRecordNotFound {
 			http.NotFound(w, r)
 			return
@@ -83,7 +84,49 @@ func UpdateDepreciacionPatch(db *gorm.DB, w http.ResponseWriter, r *http.Request
 	}
 	delete(body, "id")
 	delete(body, "ID")
-	if err := db.Model(&item).Updates(body).Error; err != nil {
+	delete(body, "anios")
+	// Claves depreciacion_anioN: se guardan en depreciacion_anuals
+	anuales := map[int]interface{}{}
+	for k, v := range body {
+		if !strings.HasPrefix(k, "depreciacion_anio") {
+			continue
+		}
+		anio, err := strconv.Atoi(strings.TrimPrefix(k, "depreciacion_anio"))
+		if err != nil || anio < 1 {
+			http.Error(w, "invalid key "+k, http.StatusBadRequest)
+			return
+		}
+		anuales[anio] = v
+		delete(body, k)
+	}
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if len(body) > 0 {
+			if err := tx.Model(&item).Updates(body).Error; err != nil {
+				return err
+			}
+		}
+		for anio, v := range anuales {
+			var fila models.DepreciacionAnual
+			err := tx.Where("depreciacion_id = ? AND anio = ?", item.ID, anio).First(&fila).Error
+			if err == gorm.ErrRecordNotFound {
+				fila = models.DepreciacionAnual{DepreciacionID: item.ID, PlanNegocioID: item.PlanNegocioID, Anio: anio}
+				if f, ok := v.(float64); ok {
+					fila.Valor = &f
+				}
+				if err := tx.Create(&fila).Error; err != nil {
+					return err
+				}
+				continue
+			} else if err != nil {
+				return err
+			}
+			if err := tx.Model(&fila).Update("valor", v).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
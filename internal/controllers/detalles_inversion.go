@@ -6,7 +6,6 @@ import (
 	"strconv"
 
 	"github.com/JostinAlvaradoS/liveplan_backend_go/internal/models"
-	"github.com/JostinAlvaradoS/liveplan_backend_go/internal/procedimientos"
 	"gorm.io/gorm"
 )
 
@@ -51,7 +50,9 @@ func CreateDetalle(db *gorm.DB, w http.ResponseWriter, r *http.Request) {
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
-	if err := db.Create(&item).Error; err != nil {
+	if err := conAuditoria(db, r, models.AuditoriaCrear, &item, func(tx *gorm.DB) error {
+		return tx.Create(&item).Error
+	}); err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
@@ -60,11 +61,6 @@ func CreateDetalle(db *gorm.DB, w http.ResponseWriter, r *http.Request) {
 		PlanNegocioID:       item.PlanNegocioID,
 		DetalleInversionID:  item.ID,
 		DepreciacionMensual: nil,
-		DepreciacionAnio1:   nil,
-		DepreciacionAnio2:   nil,
-		DepreciacionAnio3:   nil,
-		DepreciacionAnio4:   nil,
-		DepreciacionAnio5:   nil,
 		ValorRescate:        nil,
 	}
 	// ignore error if it fails; creation should be best-effort but we log to response if necessary
@@ -93,18 +89,28 @@ func UpdateDetallePatch(db *gorm.DB, w http.ResponseWriter, r *http.Request, id
 	delete(body, "id")
 	delete(body, "ID")
 	delete(body, "recalc")
-	if err := db.Model(&item).Updates(body).Error; err != nil {
+	if err := conAuditoria(db, r, models.AuditoriaActualizar, &item, func(tx *gorm.DB) error {
+		return tx.Model(&item).Updates(body).Error
+	}); err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 	if recalc {
-		_ = procedimientos.Recalcular(db, item.PlanNegocioID)
+		if err := recalcularPlan(db, w, item.PlanNegocioID, "detalle_inversion_inicial"); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
 	}
 	json.NewEncoder(w).Encode(item)
 }
 
 func DeleteDetalle(db *gorm.DB, w http.ResponseWriter, r *http.Request, id uint) {
-	if err := db.Delete(&models.DetalleInversionInicial{}, id).Error; err != nil {
+	err := eliminarConAuditoria(db, r, &models.DetalleInversionInicial{}, id)
+	if err == gorm.ErrRecordNotFound {
+		http.NotFound(w, r)
+		return
+	}
+	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
@@ -0,0 +1,229 @@
+package controllers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/JostinAlvaradoS/liveplan_backend_go/internal/models"
+	"github.com/JostinAlvaradoS/liveplan_backend_go/internal/procedimientos"
+	"github.com/JostinAlvaradoS/liveplan_backend_go/internal/reportes"
+	"gorm.io/gorm"
+)
+
+// ListEscenariosByPlan lista los escenarios del plan con su crecimiento por año
+func ListEscenariosByPlan(db *gorm.DB, w http.ResponseWriter, r *http.Request, planID uint) {
+	var items []models.Escenario
+	if err := db.Preload("Anios", func(db *gorm.DB) *gorm.DB { return db.Order("anio asc") }).
+		Where("plan_negocio_id = ?", planID).Order("id asc").Find(&items).Error; err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(items)
+}
+
+// GetEscenario devuelve el escenario id del plan
+func GetEscenario(db *gorm.DB, w http.ResponseWriter, r *http.Request, planID, id uint) {
+	item, err := cargarEscenario(db, planID, id)
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			http.NotFound(w, r)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(item)
+}
+
+// CrearEscenario agrega un escenario al plan. Los campos sin valor toman el
+// del plan; "anios" ([{"anio": 2, "crecimiento": 8}]) reemplaza el
+// crecimiento de ventas de esos años.
+func CrearEscenario(db *gorm.DB, w http.ResponseWriter, r *http.Request, planID uint) {
+	var item models.Escenario
+	if err := json.NewDecoder(r.Body).Decode(&item); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	item.ID = 0
+	item.PlanNegocioID = planID
+	item.PlanNegocio = nil
+	for i := range item.Anios {
+		item.Anios[i].ID = 0
+	}
+	if err := validarEscenario(db, item); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := db.Create(&item).Error; err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(item)
+}
+
+// UpdateEscenarioPatch cambia los campos enviados del escenario; "anios", si
+// viene, reemplaza todos sus años
+func UpdateEscenarioPatch(db *gorm.DB, w http.ResponseWriter, r *http.Request, planID, id uint) {
+	item, err := cargarEscenario(db, planID, id)
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			http.NotFound(w, r)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	var body map[string]json.RawMessage
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	for _, k := range []string{"id", "ID", "plan_negocio_id", "plan_negocio"} {
+		delete(body, k)
+	}
+	raw, _ := json.Marshal(body)
+	// decodificar sobre el escenario actual para validar el resultado completo
+	cambiado := item
+	cambiado.Anios = append([]models.EscenarioAnio(nil), item.Anios...)
+	if err := json.Unmarshal(raw, &cambiado); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := validarEscenario(db, cambiado); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	_, anios := body["anios"]
+	delete(body, "anios")
+	campos := make([]string, 0, len(body))
+	for k := range body {
+		campos = append(campos, k)
+	}
+
+	err = db.Transaction(func(tx *gorm.DB) error {
+		if len(campos) > 0 {
+			if err := tx.Model(&item).Select(campos).Updates(&cambiado).Error; err != nil {
+				return err
+			}
+		}
+		if !anios {
+			return nil
+		}
+		if err := tx.Where("escenario_id = ?", item.ID).Delete(&models.EscenarioAnio{}).Error; err != nil {
+			return err
+		}
+		for i := range cambiado.Anios {
+			cambiado.Anios[i].ID = 0
+			cambiado.Anios[i].EscenarioID = item.ID
+		}
+		if len(cambiado.Anios) == 0 {
+			return nil
+		}
+		return tx.Create(&cambiado.Anios).Error
+	})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if item, err = cargarEscenario(db, planID, id); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	json.NewEncoder(w).Encode(item)
+}
+
+func DeleteEscenario(db *gorm.DB, w http.ResponseWriter, r *http.Request, planID, id uint) {
+	err := db.Transaction(func(tx *gorm.DB) error {
+		res := tx.Where("plan_negocio_id = ?", planID).Delete(&models.Escenario{}, id)
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+		return tx.Where("escenario_id = ?", id).Delete(&models.EscenarioAnio{}).Error
+	})
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			http.NotFound(w, r)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// CompararEscenarios calcula en memoria la base y todos los escenarios del
+// plan y devuelve lado a lado su VAN/TIR y los totales anuales de los estados
+// financieros. Las entradas del plan no se modifican.
+func CompararEscenarios(db *gorm.DB, w http.ResponseWriter, r *http.Request, planID uint) {
+	resultados, err := procedimientos.CalcularEscenarios(db, planID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(reportes.CompararEscenarios(resultados))
+}
+
+// validarEscenario revisa el nombre, los porcentajes de financiamiento, la
+// tasa del préstamo y que los años estén dentro del horizonte del plan
+func validarEscenario(db *gorm.DB, e models.Escenario) error {
+	nombre := strings.TrimSpace(e.Nombre)
+	if nombre == "" {
+		return errors.New("nombre es requerido")
+	}
+	if len(nombre) > 80 {
+		return errors.New("nombre admite hasta 80 caracteres")
+	}
+	for _, p := range []*float64{e.CapitalPorcentaje, e.DeudaPorcentaje} {
+		if p != nil && (*p < 0 || *p > 100) {
+			return errors.New("capital_porcentaje y deuda_porcentaje deben estar entre 0 y 100")
+		}
+	}
+	if e.CapitalPorcentaje != nil && e.DeudaPorcentaje != nil && *e.CapitalPorcentaje+*e.DeudaPorcentaje != 100 {
+		return errors.New("capital_porcentaje y deuda_porcentaje deben sumar 100")
+	}
+	if e.DiasxMes != nil && (*e.DiasxMes < 1 || *e.DiasxMes > 31) {
+		return errors.New("diasxmes debe estar entre 1 y 31")
+	}
+	if e.TasaAnual != nil && *e.TasaAnual <= 0 {
+		return errors.New("tasa_anual debe ser mayor a 0")
+	}
+	if e.PeriodosAmortizacion != nil && *e.PeriodosAmortizacion < 1 {
+		return errors.New("periodos_amortizacion debe ser mayor a 0")
+	}
+	if len(e.Anios) == 0 {
+		return nil
+	}
+	horizonte, err := procedimientos.HorizontePlan(db, e.PlanNegocioID)
+	if err != nil {
+		return err
+	}
+	vistos := make(map[int]bool, len(e.Anios))
+	for _, a := range e.Anios {
+		if a.Anio < 1 || a.Anio > horizonte {
+			return fmt.Errorf("anio %d fuera del horizonte del plan (1..%d)", a.Anio, horizonte)
+		}
+		if vistos[a.Anio] {
+			return fmt.Errorf("anio %d repetido", a.Anio)
+		}
+		vistos[a.Anio] = true
+	}
+	return nil
+}
+
+// cargarEscenario busca el escenario id del plan con sus años
+func cargarEscenario(db *gorm.DB, planID, id uint) (models.Escenario, error) {
+	var item models.Escenario
+	err := db.Preload("Anios", func(db *gorm.DB) *gorm.DB { return db.Order("anio asc") }).
+		Where("plan_negocio_id = ?", planID).First(&item, id).Error
+	return item, err
+}
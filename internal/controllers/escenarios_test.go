@@ -0,0 +1,30 @@
+package controllers
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/JostinAlvaradoS/liveplan_backend_go/internal/models"
+)
+
+// Sin años validarEscenario no consulta la base
+func TestValidarEscenario(t *testing.T) {
+	f := func(v float64) *float64 { return &v }
+	tests := []struct {
+		nombre string
+		e      models.Escenario
+		error  string
+	}{
+		{"válido", models.Escenario{Nombre: "Optimista", TasaAnual: f(15)}, ""},
+		{"sin nombre", models.Escenario{Nombre: " "}, "nombre"},
+		{"porcentajes que no suman 100", models.Escenario{Nombre: "a", CapitalPorcentaje: f(60), DeudaPorcentaje: f(60)}, "sumar 100"},
+		{"tasa cero", models.Escenario{Nombre: "a", TasaAnual: f(0)}, "tasa_anual"},
+		{"tasa negativa", models.Escenario{Nombre: "a", TasaAnual: f(-3)}, "tasa_anual"},
+	}
+	for _, tt := range tests {
+		err := validarEscenario(nil, tt.e)
+		if tt.error == "" && err != nil || tt.error != "" && (err == nil || !strings.Contains(err.Error(), tt.error)) {
+			t.Errorf("%s: err = %v, want %q", tt.nombre, err, tt.error)
+		}
+	}
+}
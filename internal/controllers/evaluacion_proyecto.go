@@ -3,6 +3,7 @@ package controllers
 import (
 	"encoding/json"
 	"net/http"
+	"strconv"
 
 	"github.com/JostinAlvaradoS/liveplan_backend_go/internal/models"
 	"gorm.io/gorm"
@@ -10,7 +11,16 @@ import (
 
 func ListEvaluacionProyecto(db *gorm.DB, w http.ResponseWriter, r *http.Request) {
 	var items []models.EvaluacionProyecto
-	if err := db.Find(&items).Error; err != nil {
+	query := db
+	if pid := r.URL.Query().Get("plan_id"); pid != "" {
+		id, err := strconv.Atoi(pid)
+		if err != nil {
+			http.Error(w, "invalid plan_id", http.StatusBadRequest)
+			return
+		}
+		query = query.Where("plan_negocio_id = ?", id)
+	}
+	if err := query.Find(&items).Error; err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
@@ -40,7 +50,9 @@ func CreateEvaluacionProyecto(db *gorm.DB, w http.ResponseWriter, r *http.Reques
 		return
 	}
 
-	if err := db.Create(&evaluacion).Error; err != nil {
+	if err := conAuditoria(db, r, models.AuditoriaCrear, &evaluacion, func(tx *gorm.DB) error {
+		return tx.Create(&evaluacion).Error
+	}); err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
@@ -82,12 +94,13 @@ func UpdateEvaluacionProyecto(db *gorm.DB, w http.ResponseWriter, r *http.Reques
 		return
 	}
 
-	// Actualizar campos
-	evaluacion.VAN = updateData.VAN
-	evaluacion.TIR = updateData.TIR
-	evaluacion.TREMA = updateData.TREMA
-
-	if err := db.Save(&evaluacion).Error; err != nil {
+	if err := conAuditoria(db, r, models.AuditoriaActualizar, &evaluacion, func(tx *gorm.DB) error {
+		// Actualizar campos
+		evaluacion.VAN = updateData.VAN
+		evaluacion.TIR = updateData.TIR
+		evaluacion.TREMA = updateData.TREMA
+		return tx.Save(&evaluacion).Error
+	}); err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
@@ -97,7 +110,12 @@ func UpdateEvaluacionProyecto(db *gorm.DB, w http.ResponseWriter, r *http.Reques
 }
 
 func DeleteEvaluacionProyecto(db *gorm.DB, w http.ResponseWriter, r *http.Request, id uint) {
-	if err := db.Delete(&models.EvaluacionProyecto{}, id).Error; err != nil {
+	err := eliminarConAuditoria(db, r, &models.EvaluacionProyecto{}, id)
+	if err == gorm.ErrRecordNotFound {
+		http.Error(w, "EvaluacionProyecto not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
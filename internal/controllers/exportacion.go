@@ -0,0 +1,123 @@
+package controllers
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/JostinAlvaradoS/liveplan_backend_go/internal/auth"
+	"github.com/JostinAlvaradoS/liveplan_backend_go/internal/procedimientos"
+	"github.com/JostinAlvaradoS/liveplan_backend_go/internal/reportes"
+	"gorm.io/gorm"
+)
+
+// tamañoMaximoDocumento limita el body de una importación
+const tamañoMaximoDocumento = 32 << 20
+
+// ExportarPlanNegocio devuelve el documento JSON versionado del plan.
+// Con ?calculados=true incluye también los estados financieros.
+func ExportarPlanNegocio(db *gorm.DB, w http.ResponseWriter, r *http.Request, id uint) {
+	calculados := r.URL.Query().Get("calculados") == "true"
+	doc, err := procedimientos.ExportarPlan(db, id, calculados)
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			http.NotFound(w, r)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="plan-%d.json"`, id))
+	json.NewEncoder(w).Encode(doc)
+}
+
+// ExportarPlanExcel devuelve un libro .xlsx con los estados financieros del plan,
+// la amortización del préstamo, las depreciaciones, la evaluación y la matriz de
+// sensibilidad
+func ExportarPlanExcel(db *gorm.DB, w http.ResponseWriter, r *http.Request, id uint) {
+	doc, err := procedimientos.ExportarPlan(db, id, true)
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			http.NotFound(w, r)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	var buf bytes.Buffer
+	if err := reportes.LibroExcel(doc).Escribir(&buf); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="plan-%d.xlsx"`, id))
+	w.Header().Set("Content-Length", strconv.Itoa(buf.Len()))
+	w.Write(buf.Bytes())
+}
+
+// ReportePlanPDF devuelve el dossier del plan en PDF: descripción, inversión
+// inicial, estados financieros anuales, evaluación y mapa de sensibilidad
+func ReportePlanPDF(db *gorm.DB, w http.ResponseWriter, r *http.Request, id uint) {
+	doc, err := procedimientos.ExportarPlan(db, id, true)
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			http.NotFound(w, r)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	var buf bytes.Buffer
+	if err := reportes.ReportePDF(doc).Escribir(&buf); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/pdf")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`inline; filename="plan-%d.pdf"`, id))
+	w.Header().Set("Content-Length", strconv.Itoa(buf.Len()))
+	w.Write(buf.Bytes())
+}
+
+// ImportarPlanNegocio crea un plan nuevo a partir de un documento exportado y
+// lo recalcula. El autor es el usuario autenticado (o ?autor= sin autenticación).
+func ImportarPlanNegocio(db *gorm.DB, w http.ResponseWriter, r *http.Request) {
+	raw, err := io.ReadAll(http.MaxBytesReader(w, r.Body, tamañoMaximoDocumento))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	doc, err := procedimientos.LeerDocumento(raw)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	autor := r.URL.Query().Get("autor")
+	if u, ok := auth.UsuarioDe(r.Context()); ok {
+		autor = u.UID
+	}
+	if autor == "" {
+		http.Error(w, "autor es requerido", http.StatusBadRequest)
+		return
+	}
+	plan, err := procedimientos.ImportarPlan(db, doc, autor)
+	if err != nil {
+		if errors.Is(err, procedimientos.ErrDocumentoInvalido) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if err := recalcularPlan(db, w, plan.ID); err != nil {
+		http.Error(w, fmt.Sprintf("plan %d importado, pero falló el recálculo: %v", plan.ID, err), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(plan)
+}
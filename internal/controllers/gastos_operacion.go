@@ -2,7 +2,9 @@ package controllers
 
 import (
 	"encoding/json"
+	"errors"
 	"net/http"
+	"strconv"
 
 	"github.com/JostinAlvaradoS/liveplan_backend_go/internal/models"
 	"github.com/JostinAlvaradoS/liveplan_backend_go/internal/procedimientos"
@@ -11,7 +13,16 @@ import (
 
 func ListGastosOperacion(db *gorm.DB, w http.ResponseWriter, r *http.Request) {
 	var items []models.GastosOperacion
-	if err := db.Find(&items).Error; err != nil {
+	query := db
+	if pid := r.URL.Query().Get("plan_id"); pid != "" {
+		id, err := strconv.Atoi(pid)
+		if err != nil {
+			http.Error(w, "invalid plan_id", http.StatusBadRequest)
+			return
+		}
+		query = query.Where("plan_negocio_id = ?", id)
+	}
+	if err := query.Find(&items).Error; err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
@@ -34,6 +45,16 @@ func GetGastosOperacion(db *gorm.DB, w http.ResponseWriter, r *http.Request, id
 }
 
 func ListGastosOperacionByPlan(db *gorm.DB, w http.ResponseWriter, r *http.Request, planID uint) {
+	horizonte, err := procedimientos.HorizontePlan(db, planID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			http.NotFound(w, r)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
 	// Obtener gastos operacion base (ahora con anual por año)
 	var gastos []models.GastosOperacion
 	if err := db.Where("plan_negocio_id = ?", planID).Find(&gastos).Error; err != nil {
@@ -79,7 +100,7 @@ func ListGastosOperacionByPlan(db *gorm.DB, w http.ResponseWriter, r *http.Reque
 		if dep.DepreciacionMensual != nil {
 			val = *dep.DepreciacionMensual
 		}
-		for anio := 1; anio <= 5; anio++ {
+		for anio := 1; anio <= horizonte; anio++ {
 			for mes := 1; mes <= 12; mes++ {
 				if tipo == 1 {
 					if _, ok := depreciacionPorMes[anio]; !ok {
@@ -96,7 +117,7 @@ func ListGastosOperacionByPlan(db *gorm.DB, w http.ResponseWriter, r *http.Reque
 		}
 	}
 	// Sumar las mensuales para obtener el anual
-	for anio := 1; anio <= 5; anio++ {
+	for anio := 1; anio <= horizonte; anio++ {
 		for mes := 1; mes <= 12; mes++ {
 			depreciacionPorAnio[anio] += depreciacionPorMes[anio][mes]
 			amortizacionPorAnio[anio] += amortizacionPorMes[anio][mes]
@@ -116,7 +137,7 @@ func ListGastosOperacionByPlan(db *gorm.DB, w http.ResponseWriter, r *http.Reque
 	gastosOperacionPorAnio := make(map[int]float64)
 	gastosOperacionPorMes := make(map[int]map[int]float64)
 	for _, gope := range gastos {
-		for anio := 1; anio <= 5; anio++ {
+		for anio := 1; anio <= horizonte; anio++ {
 			gastosOperacionPorAnio[anio] += gope.Anual
 			if _, ok := gastosOperacionPorMes[anio]; !ok {
 				gastosOperacionPorMes[anio] = make(map[int]float64)
@@ -147,7 +168,7 @@ func ListGastosOperacionByPlan(db *gorm.DB, w http.ResponseWriter, r *http.Reque
 	}
 	var reporteAnual []ReporteAnual
 	var reporteMensual []ReporteMensual
-	for anio := 1; anio <= 5; anio++ {
+	for anio := 1; anio <= horizonte; anio++ {
 		totalAnual := gastosOperacionPorAnio[anio] + interesesPorAnio[anio] + depreciacionPorAnio[anio] + amortizacionPorAnio[anio]
 		reporteAnual = append(reporteAnual, ReporteAnual{
 			Anio:            anio,
@@ -198,20 +219,30 @@ func UpdateGastosOperacionPatch(db *gorm.DB, w http.ResponseWriter, r *http.Requ
 	delete(body, "id")
 	delete(body, "ID")
 	delete(body, "recalc")
-	if err := db.Model(&item).Updates(body).Error; err != nil {
+	if err := conAuditoria(db, r, models.AuditoriaActualizar, &item, func(tx *gorm.DB) error {
+		return tx.Model(&item).Updates(body).Error
+	}); err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
 	if recalc {
 		planID := item.PlanNegocioID
-		_ = procedimientos.Recalcular(db, planID)
+		if err := recalcularPlan(db, w, planID, "gastos_operacion"); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
 	}
 	json.NewEncoder(w).Encode(item)
 }
 
 func DeleteGastosOperacion(db *gorm.DB, w http.ResponseWriter, r *http.Request, id uint) {
-	if err := db.Delete(&models.GastosOperacion{}, id).Error; err != nil {
+	err := eliminarConAuditoria(db, r, &models.GastosOperacion{}, id)
+	if err == gorm.ErrRecordNotFound {
+		http.NotFound(w, r)
+		return
+	}
+	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
@@ -0,0 +1,37 @@
+package controllers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/JostinAlvaradoS/liveplan_backend_go/internal/model"
+	"github.com/JostinAlvaradoS/liveplan_backend_go/internal/procedimientos"
+	"gorm.io/gorm"
+)
+
+// GetIntegridadPlan revisa las conciliaciones contables de los estados guardados
+// del plan (los que dejó el último Recalcular) y lista cada regla que no se cumple
+func GetIntegridadPlan(db *gorm.DB, w http.ResponseWriter, r *http.Request, planID uint) {
+	ds, err := procedimientos.VerificarIntegridad(db, planID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			http.NotFound(w, r)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if ds == nil {
+		ds = []model.Discrepancia{}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"plan_id":       planID,
+		"ok":            len(ds) == 0,
+		"estricto":      procedimientos.IntegridadEstricta,
+		"tolerancia":    model.ToleranciaIntegridad,
+		"reglas":        model.ReglasIntegridad,
+		"discrepancias": ds,
+	})
+}
@@ -0,0 +1,107 @@
+package controllers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/JostinAlvaradoS/liveplan_backend_go/internal/models"
+	"github.com/JostinAlvaradoS/liveplan_backend_go/internal/procedimientos"
+	"gorm.io/gorm"
+)
+
+// simulacionConDatos es una simulación guardada con sus parámetros y resultado
+type simulacionConDatos struct {
+	models.SimulacionMonteCarlo
+	Parametros json.RawMessage `json:"parametros"`
+	Resultado  json.RawMessage `json:"resultado"`
+}
+
+// SimularMonteCarlo ejecuta la simulación Monte Carlo del plan con las
+// distribuciones del body, guarda el resultado y lo devuelve. Ejemplo:
+//
+//	{"simulaciones": 5000, "semilla": 42,
+//	 "distribuciones": {"volumen": {"tipo": "normal", "media": 0, "desviacion": 10},
+//	                    "tasa_interes": {"tipo": "pert", "min": 8, "moda": 10, "max": 15}},
+//	 "correlaciones": [{"a": "volumen", "b": "tasa_interes", "rho": -0.3}]}
+func SimularMonteCarlo(db *gorm.DB, w http.ResponseWriter, r *http.Request, planID uint) {
+	var p procedimientos.ParametrosMonteCarlo
+	if err := json.NewDecoder(r.Body).Decode(&p); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := p.Validar(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	resultado, err := procedimientos.SimularMonteCarlo(db, planID, &p)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	parametros, err := json.Marshal(p)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	datos, err := json.Marshal(resultado)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	item := models.SimulacionMonteCarlo{
+		PlanNegocioID: planID,
+		Simulaciones:  resultado.Simulaciones,
+		Semilla:       resultado.Semilla,
+		CreadoPor:     actorDe(r),
+		Parametros:    string(parametros),
+		Resultado:     string(datos),
+	}
+	if err := db.Create(&item).Error; err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(simulacionConDatos{item, parametros, datos})
+}
+
+// ListSimulacionesByPlan lista las simulaciones guardadas del plan (sin
+// parámetros ni resultado), de la más reciente a la más antigua
+func ListSimulacionesByPlan(db *gorm.DB, w http.ResponseWriter, r *http.Request, planID uint) {
+	var items []models.SimulacionMonteCarlo
+	if err := db.Omit("parametros", "resultado").Where("plan_negocio_id = ?", planID).
+		Order("created_at desc, id desc").Find(&items).Error; err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(items)
+}
+
+// GetSimulacion devuelve una simulación guardada con sus parámetros y resultado
+func GetSimulacion(db *gorm.DB, w http.ResponseWriter, r *http.Request, planID, id uint) {
+	var item models.SimulacionMonteCarlo
+	if err := db.Where("plan_negocio_id = ?", planID).First(&item, id).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			http.NotFound(w, r)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(simulacionConDatos{item, json.RawMessage(item.Parametros), json.RawMessage(item.Resultado)})
+}
+
+func DeleteSimulacion(db *gorm.DB, w http.ResponseWriter, r *http.Request, planID, id uint) {
+	res := db.Where("plan_negocio_id = ?", planID).Delete(&models.SimulacionMonteCarlo{}, id)
+	if res.Error != nil {
+		http.Error(w, res.Error.Error(), http.StatusInternalServerError)
+		return
+	}
+	if res.RowsAffected == 0 {
+		http.NotFound(w, r)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -2,16 +2,24 @@ package controllers
 
 import (
 	"encoding/json"
+	"fmt"
 	"net/http"
 
+	"github.com/JostinAlvaradoS/liveplan_backend_go/internal/auth"
 	"github.com/JostinAlvaradoS/liveplan_backend_go/internal/models"
+	"github.com/JostinAlvaradoS/liveplan_backend_go/internal/procedimientos"
 	"gorm.io/gorm"
 )
 
 // PlanNegocio CRUD
+// Con autenticación solo se listan los planes propios y los compartidos con el usuario
 func ListPlanNegocios(db *gorm.DB, w http.ResponseWriter, r *http.Request) {
 	var items []models.PlanNegocio
-	if err := db.Find(&items).Error; err != nil {
+	query := db
+	if u, ok := auth.UsuarioDe(r.Context()); ok {
+		query = planesDeUsuario(db, u)
+	}
+	if err := query.Find(&items).Error; err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
@@ -39,25 +47,24 @@ func CreatePlanNegocio(db *gorm.DB, w http.ResponseWriter, r *http.Request) {
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
+	// el autor es siempre el usuario autenticado, no lo que diga el body
+	if u, ok := auth.UsuarioDe(r.Context()); ok {
+		item.Autor = u.UID
+	}
+	if item.HorizonteAnios == 0 {
+		item.HorizonteAnios = models.HorizontePorDefecto
+	}
+	if err := validarHorizonte(item.HorizonteAnios); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	horizonte := item.HorizonteAnios
 	// Use transaction to ensure related default records are created atomically
 	err := db.Transaction(func(tx *gorm.DB) error {
 		if err := tx.Create(&item).Error; err != nil {
 			return err
 		}
 
-		// Create default VariacionAnual (all zeros)
-		va := models.VariacionAnual{
-			PlanNegocioID: item.ID,
-			Año1:          0,
-			Año2:          0,
-			Año3:          0,
-			Año4:          0,
-			Año5:          0,
-		}
-		if err := tx.Create(&va).Error; err != nil {
-			return err
-		}
-
 		// Create default VariablesDeSensibilidad (zeros)
 		vs := models.VariablesDeSensibilidad{
 			Cantidad_volumen: 0,
@@ -107,23 +114,23 @@ func CreatePlanNegocio(db *gorm.DB, w http.ResponseWriter, r *http.Request) {
 			return err
 		}
 
-		// Create default DatosPrestamo and PrestamoCuotas for 5 years (60 meses)
+		// Create default DatosPrestamo and PrestamoCuotas for the whole horizon (12 meses por año)
 		dp := models.DatosPrestamo{
 			PlanNegocioID:          item.ID,
 			Monto:                  0,
 			TasaAnual:              12,
 			PeriodosCapitalizacion: 12,
 			TasaMensual:            1,
-			PeriodosAmortizacion:   60,
+			PeriodosAmortizacion:   12 * horizonte,
 			Cuota:                  0,
 		}
 		if err := tx.Create(&dp).Error; err != nil {
 			return err
 		}
 
-		// Create 60 PrestamoCuotas rows, one per month (periodo_mes 1..60)
-		for m := 1; m <= 60; m++ {
-			anio := (m-1)/12 + 1 // 1..5
+		// Create one PrestamoCuotas row per month (periodo_mes 1..12*horizonte)
+		for m := 1; m <= 12*horizonte; m++ {
+			anio := (m-1)/12 + 1 // 1..horizonte
 			mes := (m-1)%12 + 1  // 1..12
 			pc := models.PrestamoCuotas{
 				PlanNegocioID:  item.ID,
@@ -141,166 +148,6 @@ func CreatePlanNegocio(db *gorm.DB, w http.ResponseWriter, r *http.Request) {
 			}
 		}
 
-		// Create default EstadoResultados, FlujoEfectivo, BalanceGeneral rows: 5 years x 12 months (anio 1..5, mes 0..12)
-		for anio := 1; anio <= 5; anio++ {
-			// Solo crear mes 0 en el primer año
-			if anio == 1 {
-				er := models.EstadoResultados{
-					PlanNegocioID:          item.ID,
-					Anio:                   anio,
-					Mes:                    0,
-					Ventas:                 0,
-					CostosVentas:           0,
-					UtilidadBruta:          0,
-					GastosVentaAdm:         0,
-					Depreciacion:           0,
-					Amortizacion:           0,
-					UtilidadprevioIntImp:   0,
-					GastosFinancieros:      0,
-					UtilidadAntesPTU:       0,
-					PTU:                    0,
-					UtilidadAntesImpuestos: 0,
-					ISR:                    0,
-					UtilidadNeta:           0,
-				}
-				if err := tx.Create(&er).Error; err != nil {
-					return err
-				}
-				fe := models.FlujoEfectivo{
-					PlanNegocioID:                item.ID,
-					Anio:                         anio,
-					Mes:                          0,
-					Ingresos_VentaContado:        0,
-					Ingresos_CobrosVentasCredito: 0,
-					Ingresos_OtrosIngresos:       0,
-					Ingresos_Prestamos:           0,
-					Ingresos_AportesCapital:      0,
-					Ingresos:                     0,
-					Egresos_ComprasCostosContado: 0,
-					Egresos_ComprasCostosCredito: 0,
-					Egresos_GastosOperacion:      0,
-					Egresos_Intereses:            0,
-					Egresos_PagosPrestamos:       0,
-					Egresos_PagosSRI:             0,
-					Egresos_PagoPTU:              0,
-					Egresos:                      0,
-					AumentoInventarios:           0,
-					FlujoCaja:                    0,
-					EfectivoInicial:              0,
-					EfectivoFinal:                0,
-				}
-				if err := tx.Create(&fe).Error; err != nil {
-					return err
-				}
-				bg := models.BalanceGeneral{
-					PlanNegocioID:                 item.ID,
-					Anio:                          anio,
-					Mes:                           0,
-					Corrientes_Efectivo:           0,
-					Corrientes_CuentasxCobrar:     0,
-					Corrientes_Inventarios:        0,
-					Corrientes_Otros:              0,
-					Corrientes_Suma:               0,
-					NoCorrientes_Suma:             0,
-					TotalActivo:                   0,
-					PasivoProveedoresCortoPlazo:   0,
-					PasivoPrestamosCortoPlazo:     0,
-					PasivoCuentasxPagarCortoPlazo: 0,
-					PasivoOtrosCortoPlazo:         0,
-					PasivoCortoPlazo_Suma:         0,
-					PasivoPrestamosLargoPlazo:     0,
-					PasivoOtrosLargoPlazo:         0,
-					PasivoLargoPlazo_Suma:         0,
-					TotalPasivo:                   0,
-					CapitalSocial:                 0,
-					CapitalAdicional:              0,
-					UtilidadesRetenidas:           0,
-					UtilidadDelEjercicio:          0,
-					TotalCapitalContable:          0,
-				}
-				if err := tx.Create(&bg).Error; err != nil {
-					return err
-				}
-			}
-			// Meses 1..12
-			for mes := 1; mes <= 12; mes++ {
-				er := models.EstadoResultados{
-					PlanNegocioID:          item.ID,
-					Anio:                   anio,
-					Mes:                    mes,
-					Ventas:                 0,
-					CostosVentas:           0,
-					UtilidadBruta:          0,
-					GastosVentaAdm:         0,
-					Depreciacion:           0,
-					Amortizacion:           0,
-					UtilidadprevioIntImp:   0,
-					GastosFinancieros:      0,
-					UtilidadAntesPTU:       0,
-					PTU:                    0,
-					UtilidadAntesImpuestos: 0,
-					ISR:                    0,
-					UtilidadNeta:           0,
-				}
-				if err := tx.Create(&er).Error; err != nil {
-					return err
-				}
-				fe := models.FlujoEfectivo{
-					PlanNegocioID:                item.ID,
-					Anio:                         anio,
-					Mes:                          mes,
-					Ingresos_VentaContado:        0,
-					Ingresos_CobrosVentasCredito: 0,
-					Ingresos_OtrosIngresos:       0,
-					Ingresos_Prestamos:           0,
-					Ingresos_AportesCapital:      0,
-					Egresos_ComprasCostosContado: 0,
-					Egresos_ComprasCostosCredito: 0,
-					Egresos_GastosOperacion:      0,
-					Egresos_Intereses:            0,
-					Egresos_PagosPrestamos:       0,
-					Egresos_PagosSRI:             0,
-					Egresos_PagoPTU:              0,
-					AumentoInventarios:           0,
-					FlujoCaja:                    0,
-					EfectivoInicial:              0,
-					EfectivoFinal:                0,
-				}
-				if err := tx.Create(&fe).Error; err != nil {
-					return err
-				}
-				bg := models.BalanceGeneral{
-					PlanNegocioID:                 item.ID,
-					Anio:                          anio,
-					Mes:                           mes,
-					Corrientes_Efectivo:           0,
-					Corrientes_CuentasxCobrar:     0,
-					Corrientes_Inventarios:        0,
-					Corrientes_Otros:              0,
-					Corrientes_Suma:               0,
-					NoCorrientes_Suma:             0,
-					TotalActivo:                   0,
-					PasivoProveedoresCortoPlazo:   0,
-					PasivoPrestamosCortoPlazo:     0,
-					PasivoCuentasxPagarCortoPlazo: 0,
-					PasivoOtrosCortoPlazo:         0,
-					PasivoCortoPlazo_Suma:         0,
-					PasivoPrestamosLargoPlazo:     0,
-					PasivoOtrosLargoPlazo:         0,
-					PasivoLargoPlazo_Suma:         0,
-					TotalPasivo:                   0,
-					CapitalSocial:                 0,
-					CapitalAdicional:              0,
-					UtilidadesRetenidas:           0,
-					UtilidadDelEjercicio:          0,
-					TotalCapitalContable:          0,
-				}
-				if err := tx.Create(&bg).Error; err != nil {
-					return err
-				}
-			}
-		}
-
 		// Poblar GastosOperacion desde GastosOperacionBase
 		var gastosBase []models.GastosOperacionBase
 		if err := tx.Find(&gastosBase).Error; err != nil {
@@ -329,21 +176,6 @@ func CreatePlanNegocio(db *gorm.DB, w http.ResponseWriter, r *http.Request) {
 			return err
 		}
 
-		// Create default ConceptosEvaluacion for years 0-5
-		for anio := 0; anio <= 5; anio++ {
-			ce := models.ConceptosEvaluacion{
-				PlanNegocioID:            item.ID,
-				Anio:                     anio,
-				FlujoEfectivoNominal:     "0.00",
-				ValorRescate:             "0.00",
-				TotalFlujoEfectivo:       "0.00",
-				ValorActualFlujosFuturos: "0.00",
-			}
-			if err := tx.Create(&ce).Error; err != nil {
-				return err
-			}
-		}
-
 		// Create default AnalisisSensibilidad matrix (volumen x costo)
 		volumenes := []float64{-15, -10, -5, 0, 5, 10, 15}
 		costos := []float64{-15, -10, -5, 0, 5, 10, 15}
@@ -362,32 +194,9 @@ func CreatePlanNegocio(db *gorm.DB, w http.ResponseWriter, r *http.Request) {
 			}
 		}
 
-		// Crear PoliticasVenta y PoliticasCompra por defecto (80-20) para todos los meses de los 5 años
-		for anio := 1; anio <= 5; anio++ {
-			for mes := 1; mes <= 12; mes++ {
-				pv := models.PoliticasVenta{
-					PlanNegocioID:     item.ID,
-					Anio:              anio,
-					Mes:               mes,
-					PorcentajeCredito: 20,
-					PorcentajeContado: 80,
-				}
-				if err := tx.Create(&pv).Error; err != nil {
-					return err
-				}
-				pc := models.PoliticasCompra{
-					PlanNegocioID:     item.ID,
-					Anio:              anio,
-					Mes:               mes,
-					PorcentajeCredito: 20,
-					PorcentajeContado: 80,
-				}
-				if err := tx.Create(&pc).Error; err != nil {
-					return err
-				}
-			}
-		}
-		return nil
+		// Filas anuales y mensuales (VariacionAnual, EstadoResultados, FlujoEfectivo,
+		// BalanceGeneral, ConceptosEvaluacion, PoliticasVenta/Compra) para los años 1..horizonte
+		return procedimientos.AjustarHorizonte(tx, item.ID, horizonte)
 	})
 
 	if err != nil {
@@ -418,17 +227,108 @@ func UpdatePlanNegocioPatch(db *gorm.DB, w http.ResponseWriter, r *http.Request,
 	}
 	delete(body, "id")
 	delete(body, "ID")
-	delete(body, "recalc")
+	// el autor es el owner del plan: no se cambia por PATCH (un editor se
+	// quedaría con el plan)
+	delete(body, "autor")
+	delete(body, "Autor")
+	recalc := false
+	if v, ok := body["recalc"]; ok {
+		if b, ok2 := v.(bool); ok2 && b {
+			recalc = true
+		}
+		delete(body, "recalc")
+	}
+
+	// Cambio de horizonte: crear o recortar las filas anuales en la misma transacción
+	cambiaHorizonte := false
+	nuevoHorizonte := item.Horizonte()
+	if v, ok := body["horizonte_anios"]; ok {
+		f, ok2 := v.(float64)
+		if !ok2 || f != float64(int(f)) {
+			http.Error(w, "horizonte_anios debe ser un entero", http.StatusBadRequest)
+			return
+		}
+		if err := validarHorizonte(int(f)); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		nuevoHorizonte = int(f)
+		body["horizonte_anios"] = nuevoHorizonte
+		cambiaHorizonte = nuevoHorizonte != item.Horizonte()
+	}
 
-	if err := db.Model(&item).Updates(body).Error; err != nil {
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := conAuditoria(tx, r, models.AuditoriaActualizar, &item, func(tx *gorm.DB) error {
+			return tx.Model(&item).Updates(body).Error
+		}); err != nil {
+			return err
+		}
+		if cambiaHorizonte {
+			item.HorizonteAnios = nuevoHorizonte
+			return procedimientos.AjustarHorizonte(tx, item.ID, nuevoHorizonte)
+		}
+		return nil
+	})
+	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
+	switch {
+	case cambiaHorizonte:
+		// AjustarHorizonte cambia las tablas de entrada de todo el plan
+		if err := recalcularPlan(db, w, item.ID); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	case recalc:
+		if err := recalcularPlan(db, w, item.ID, "plan_negocio"); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
 	json.NewEncoder(w).Encode(item)
 }
 
+// validarHorizonte comprueba que el horizonte pedido esté entre 1 y models.HorizonteMaximo años
+func validarHorizonte(h int) error {
+	if h < 1 || h > models.HorizonteMaximo {
+		return fmt.Errorf("horizonte_anios debe estar entre 1 y %d", models.HorizonteMaximo)
+	}
+	return nil
+}
+
 func DeletePlanNegocio(db *gorm.DB, w http.ResponseWriter, r *http.Request, id uint) {
-	if err := db.Delete(&models.PlanNegocio{}, id).Error; err != nil {
+	err := db.Transaction(func(tx *gorm.DB) error {
+		// sin FK en la base: quitar los accesos compartidos junto con el plan
+		if err := tx.Where("plan_negocio_id = ?", id).Delete(&models.PlanColaborador{}).Error; err != nil {
+			return err
+		}
+		ejecuciones := tx.Model(&models.RecalculoEjecucion{}).Select("id").Where("plan_negocio_id = ?", id)
+		if err := tx.Where("recalculo_ejecucion_id IN (?)", ejecuciones).Delete(&models.RecalculoTarea{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("plan_negocio_id = ?", id).Delete(&models.RecalculoEjecucion{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("plan_negocio_id = ?", id).Delete(&models.Auditoria{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("plan_negocio_id = ?", id).Delete(&models.PlanSnapshot{}).Error; err != nil {
+			return err
+		}
+		escenarios := tx.Model(&models.Escenario{}).Select("id").Where("plan_negocio_id = ?", id)
+		if err := tx.Where("escenario_id IN (?)", escenarios).Delete(&models.EscenarioAnio{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("plan_negocio_id = ?", id).Delete(&models.Escenario{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("plan_negocio_id = ?", id).Delete(&models.SimulacionMonteCarlo{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&models.PlanNegocio{}, id).Error
+	})
+	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
@@ -445,13 +345,48 @@ func ListPlanesByUser(db *gorm.DB, w http.ResponseWriter, r *http.Request, userI
 	json.NewEncoder(w).Encode(items)
 }
 
-// ListPlanesByUserUID lista planes cuyo campo Autor coincide con el UID (string).
+// ListPlanesByUserUID lista planes cuyo campo Autor coincide con el UID (string)
+// y los que se compartieron con ese usuario en plan_colaboradores.
 func ListPlanesByUserUID(db *gorm.DB, w http.ResponseWriter, r *http.Request, userUID string) {
 	var items []models.PlanNegocio
-	if err := db.Where("autor = ?", userUID).Find(&items).Error; err != nil {
+	u, ok := auth.UsuarioDe(r.Context())
+	if !ok || u.UID != userUID {
+		u = auth.Usuario{UID: userUID}
+	}
+	if err := planesDeUsuario(db, u).Find(&items).Error; err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(items)
 }
+
+// ClonarPlanNegocio copia el plan con todas sus tablas a un plan nuevo del
+// usuario autenticado y lo recalcula.
+func ClonarPlanNegocio(db *gorm.DB, w http.ResponseWriter, r *http.Request, id uint) {
+	var origen models.PlanNegocio
+	if err := db.Select("id", "autor").First(&origen, id).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			http.NotFound(w, r)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	autor := origen.Autor
+	if u, ok := auth.UsuarioDe(r.Context()); ok {
+		autor = u.UID
+	}
+	clon, err := procedimientos.ClonarPlan(db, id, autor)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if err := recalcularPlan(db, w, clon.ID); err != nil {
+		http.Error(w, fmt.Sprintf("plan %d clonado, pero falló el recálculo: %v", clon.ID, err), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(clon)
+}
@@ -3,16 +3,25 @@ package controllers
 import (
 	"encoding/json"
 	"net/http"
+	"strconv"
 
 	"github.com/JostinAlvaradoS/liveplan_backend_go/internal/models"
-	"github.com/JostinAlvaradoS/liveplan_backend_go/internal/procedimientos"
 	"gorm.io/gorm"
 )
 
 // DatosPrestamo (prestamos) controllers
 func ListPrestamos(db *gorm.DB, w http.ResponseWriter, r *http.Request) {
 	var items []models.DatosPrestamo
-	if err := db.Find(&items).Error; err != nil {
+	query := db
+	if pid := r.URL.Query().Get("plan_id"); pid != "" {
+		id, err := strconv.Atoi(pid)
+		if err != nil {
+			http.Error(w, "invalid plan_id", http.StatusBadRequest)
+			return
+		}
+		query = query.Where("plan_negocio_id = ?", id)
+	}
+	if err := query.Find(&items).Error; err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
@@ -26,7 +35,9 @@ func CreatePrestamo(db *gorm.DB, w http.ResponseWriter, r *http.Request) {
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
-	if err := db.Create(&item).Error; err != nil {
+	if err := conAuditoria(db, r, models.AuditoriaCrear, &item, func(tx *gorm.DB) error {
+		return tx.Create(&item).Error
+	}); err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
@@ -77,12 +88,14 @@ func UpdatePrestamoPatch(db *gorm.DB, w http.ResponseWriter, r *http.Request, id
 	delete(body, "id")
 	delete(body, "ID")
 	delete(body, "recalc")
-	if err := db.Model(&item).Updates(body).Error; err != nil {
+	if err := conAuditoria(db, r, models.AuditoriaActualizar, &item, func(tx *gorm.DB) error {
+		return tx.Model(&item).Updates(body).Error
+	}); err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 	if recalc {
-		if err := procedimientos.Recalcular(db, item.PlanNegocioID); err != nil {
+		if err := recalcularPlan(db, w, item.PlanNegocioID, "datos_prestamo"); err != nil {
 			http.Error(w, err.Error(), http.StatusInternalServerError)
 			return
 		}
@@ -91,7 +104,12 @@ func UpdatePrestamoPatch(db *gorm.DB, w http.ResponseWriter, r *http.Request, id
 }
 
 func DeletePrestamo(db *gorm.DB, w http.ResponseWriter, r *http.Request, id uint) {
-	if err := db.Delete(&models.DatosPrestamo{}, id).Error; err != nil {
+	err := eliminarConAuditoria(db, r, &models.DatosPrestamo{}, id)
+	if err == gorm.ErrRecordNotFound {
+		http.NotFound(w, r)
+		return
+	}
+	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
@@ -142,12 +160,14 @@ func UpdateDatosPrestamoPatch(db *gorm.DB, w http.ResponseWriter, r *http.Reques
 	delete(body, "id")
 	delete(body, "ID")
 	delete(body, "recalc")
-	if err := db.Model(&item).Updates(body).Error; err != nil {
+	if err := conAuditoria(db, r, models.AuditoriaActualizar, &item, func(tx *gorm.DB) error {
+		return tx.Model(&item).Updates(body).Error
+	}); err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 	if recalc {
-		if err := procedimientos.Recalcular(db, item.PlanNegocioID); err != nil {
+		if err := recalcularPlan(db, w, item.PlanNegocioID, "prestamo_cuotas"); err != nil {
 			http.Error(w, err.Error(), http.StatusInternalServerError)
 			return
 		}
@@ -157,7 +177,12 @@ func UpdateDatosPrestamoPatch(db *gorm.DB, w http.ResponseWriter, r *http.Reques
 }
 
 func DeleteDatosPrestamo(db *gorm.DB, w http.ResponseWriter, r *http.Request, id uint) {
-	if err := db.Delete(&models.PrestamoCuotas{}, id).Error; err != nil {
+	err := eliminarConAuditoria(db, r, &models.PrestamoCuotas{}, id)
+	if err == gorm.ErrRecordNotFound {
+		http.NotFound(w, r)
+		return
+	}
+	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
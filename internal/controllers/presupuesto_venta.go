@@ -58,7 +58,9 @@ func CreatePresupuestoVenta(db *gorm.DB, w http.ResponseWriter, r *http.Request)
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
-	if err := db.Create(&item).Error; err != nil {
+	if err := conAuditoria(db, r, models.AuditoriaCrear, &item, func(tx *gorm.DB) error {
+		return tx.Create(&item).Error
+	}); err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
@@ -83,7 +85,9 @@ func UpdatePresupuestoVentaPatch(db *gorm.DB, w http.ResponseWriter, r *http.Req
 	}
 	delete(body, "id")
 	delete(body, "ID")
-	if err := db.Model(&item).Updates(body).Error; err != nil {
+	if err := conAuditoria(db, r, models.AuditoriaActualizar, &item, func(tx *gorm.DB) error {
+		return tx.Model(&item).Updates(body).Error
+	}); err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
@@ -91,7 +95,12 @@ func UpdatePresupuestoVentaPatch(db *gorm.DB, w http.ResponseWriter, r *http.Req
 }
 
 func DeletePresupuestoVenta(db *gorm.DB, w http.ResponseWriter, r *http.Request, id uint) {
-	if err := db.Delete(&models.PresupuestoVenta{}, id).Error; err != nil {
+	err := eliminarConAuditoria(db, r, &models.PresupuestoVenta{}, id)
+	if err == gorm.ErrRecordNotFound {
+		http.NotFound(w, r)
+		return
+	}
+	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
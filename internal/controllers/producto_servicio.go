@@ -62,7 +62,9 @@ func CreateProductoServicio(db *gorm.DB, w http.ResponseWriter, r *http.Request)
 	}
 	// create producto and related default records in a transaction
 	err := db.Transaction(func(tx *gorm.DB) error {
-		if err := tx.Create(&item).Error; err != nil {
+		if err := conAuditoria(tx, r, models.AuditoriaCrear, &item, func(tx *gorm.DB) error {
+			return tx.Create(&item).Error
+		}); err != nil {
 			return err
 		}
 		// create a VentaDiaria with null VentaDia
@@ -71,7 +73,9 @@ func CreateProductoServicio(db *gorm.DB, w http.ResponseWriter, r *http.Request)
 			ProductoServicioID: item.ID,
 			VentaDia:           nil,
 		}
-		if err := tx.Create(&vd).Error; err != nil {
+		if err := conAuditoria(tx, r, models.AuditoriaCrear, &vd, func(tx *gorm.DB) error {
+			return tx.Create(&vd).Error
+		}); err != nil {
 			return err
 		}
 		// create PreciosProdServ with nil precio and precio_calc
@@ -81,7 +85,9 @@ func CreateProductoServicio(db *gorm.DB, w http.ResponseWriter, r *http.Request)
 			Precio:             nil,
 			PrecioCalc:         nil,
 		}
-		if err := tx.Create(&pp).Error; err != nil {
+		if err := conAuditoria(tx, r, models.AuditoriaCrear, &pp, func(tx *gorm.DB) error {
+			return tx.Create(&pp).Error
+		}); err != nil {
 			return err
 		}
 		// create one CostosProdServ per existing CategoriaCosto (categoria cannot be null)
@@ -97,71 +103,35 @@ func CreateProductoServicio(db *gorm.DB, w http.ResponseWriter, r *http.Request)
 				Costo:              nil,
 				CostoCalc:          nil,
 			}
-			if err := tx.Create(&cp).Error; err != nil {
+			if err := conAuditoria(tx, r, models.AuditoriaCrear, &cp, func(tx *gorm.DB) error {
+				return tx.Create(&cp).Error
+			}); err != nil {
 				return err
 			}
 		}
 
-		// initialize PresupuestoVenta for 5 years with null values
-		for anio := 1; anio <= 5; anio++ {
-			pv := models.PresupuestoVenta{
-				PlanNegocioID: item.PlanNegocioID,
-				ProductoID:    item.ID,
-				Anio:          anio,
-				Crecimiento:   nil,
-				Mensual:       nil,
-				Anual:         nil,
-			}
-			if err := tx.Create(&pv).Error; err != nil {
-				return err
-			}
+		// initialize PresupuestoVenta, VentasDinero, Ventas (per year) and CostosVentas
+		// (per year x 12 months) for the plan horizon with null/zero values
+		horizonte, err := procedimientos.HorizontePlan(tx, item.PlanNegocioID)
+		if err != nil {
+			return err
 		}
-
-		// initialize VentasDinero for 5 years (anio 1..5) with zeros
-		for anio := 1; anio <= 5; anio++ {
-			vd := models.VentasDinero{
-				PlanNegocioID: item.PlanNegocioID,
-				ProductoID:    item.ID,
-				Anio:          anio,
-				Mensual:       0,
-				Anual:         0,
-			}
-			if err := tx.Create(&vd).Error; err != nil {
-				return err
-			}
+		if err := procedimientos.AjustarHorizonteProducto(tx, item.PlanNegocioID, item.ID, horizonte); err != nil {
+			return err
 		}
-
-		// initialize Ventas for 5 years (anio 1..5) with zeros
-		for anio := 1; anio <= 5; anio++ {
-			v := models.Ventas{
-				PlanNegocioID: item.PlanNegocioID,
-				ProductoID:    item.ID,
-				Anio:          anio,
-				Venta:         0,
-			}
-			if err := tx.Create(&v).Error; err != nil {
-				return err
-			}
+		// de las filas anuales solo presupuesto y ventas_dinero son entradas auditadas
+		var pvs []models.PresupuestoVenta
+		if err := tx.Where("producto_id = ?", item.ID).Find(&pvs).Error; err != nil {
+			return err
 		}
-
-		// initialize CostosVentas for 5 years x 12 months with costo=0
-		for anio := 1; anio <= 5; anio++ {
-			for mes := 1; mes <= 12; mes++ {
-				cv := models.CostosVentas{
-					PlanNegocioID: item.PlanNegocioID,
-					ProductoID:    item.ID,
-					Anio:          anio,
-					Mes:           mes,
-					Mensual:      0,
-					Anual:        0,
-				}
-				if err := tx.Create(&cv).Error; err != nil {
-					return err
-				}
-			}
+		var vds []models.VentasDinero
+		if err := tx.Where("producto_id = ?", item.ID).Find(&vds).Error; err != nil {
+			return err
 		}
-
-		return nil
+		if err := registrarAltas(tx, r, &pvs); err != nil {
+			return err
+		}
+		return registrarAltas(tx, r, &vds)
 	})
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
@@ -191,7 +161,9 @@ func UpdateProductoServicioPatch(db *gorm.DB, w http.ResponseWriter, r *http.Req
 	delete(body, "ID")
 	delete(body, "recalc")
 
-	if err := db.Model(&item).Updates(body).Error; err != nil {
+	if err := conAuditoria(db, r, models.AuditoriaActualizar, &item, func(tx *gorm.DB) error {
+		return tx.Model(&item).Updates(body).Error
+	}); err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
@@ -199,13 +171,22 @@ func UpdateProductoServicioPatch(db *gorm.DB, w http.ResponseWriter, r *http.Req
 	if recalc {
 		// Call Recalcular for the associated plan
 		planID := item.PlanNegocioID
-		_ = procedimientos.Recalcular(db, planID)
+		// ningún procedimiento lee producto_servicio: se recalcula todo
+		if err := recalcularPlan(db, w, planID); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
 	}
 	json.NewEncoder(w).Encode(item)
 }
 
 func DeleteProductoServicio(db *gorm.DB, w http.ResponseWriter, r *http.Request, id uint) {
-	if err := db.Delete(&models.ProductoServicio{}, id).Error; err != nil {
+	err := eliminarConAuditoria(db, r, &models.ProductoServicio{}, id)
+	if err == gorm.ErrRecordNotFound {
+		http.NotFound(w, r)
+		return
+	}
+	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
@@ -0,0 +1,22 @@
+package controllers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/JostinAlvaradoS/liveplan_backend_go/internal/procedimientos"
+	"gorm.io/gorm"
+)
+
+// GetPuntoEquilibrioPlan devuelve por año las unidades e ingresos de
+// equilibrio del plan y de cada producto según la mezcla de ventas, y el
+// margen de seguridad contra las ventas proyectadas
+func GetPuntoEquilibrioPlan(db *gorm.DB, w http.ResponseWriter, r *http.Request, planID uint) {
+	anios, err := procedimientos.CalcularPuntoEquilibrio(db, planID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(anios)
+}
@@ -0,0 +1,31 @@
+package controllers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/JostinAlvaradoS/liveplan_backend_go/internal/model"
+	"github.com/JostinAlvaradoS/liveplan_backend_go/internal/procedimientos"
+	"gorm.io/gorm"
+)
+
+// GetRazonesPlan devuelve las razones financieras de cada año del plan con su
+// fórmula. ?umbrales=razon_circulante=1.5..,deuda_capital=..2 marca las que
+// quedan fuera de esos rangos.
+func GetRazonesPlan(db *gorm.DB, w http.ResponseWriter, r *http.Request, planID uint) {
+	var umbrales map[string]model.Umbral
+	if v := r.URL.Query().Get("umbrales"); v != "" {
+		var err error
+		if umbrales, err = model.ParseThresholds(v); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+	anios, err := procedimientos.CalcularRazones(db, planID, umbrales)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(anios)
+}
@@ -0,0 +1,59 @@
+package controllers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/JostinAlvaradoS/liveplan_backend_go/internal/models"
+	"github.com/JostinAlvaradoS/liveplan_backend_go/internal/procedimientos"
+	"gorm.io/gorm"
+)
+
+// Cabeceras con el resultado del recálculo disparado por una escritura
+const (
+	CabeceraRecalculo   = "X-Recalculo"
+	CabeceraRecalculoID = "X-Recalculo-Id"
+)
+
+// GetRecalculoPlan devuelve el estado del recálculo del plan (idle, queued o
+// running) y la última ejecución registrada. desactualizado indica que la
+// última ejecución falló o se canceló y los estados del plan pueden no
+// reflejar sus datos.
+func GetRecalculoPlan(db *gorm.DB, w http.ResponseWriter, r *http.Request, planID uint) {
+	estado, err := procedimientos.EstadoRecalculoPlan(db, planID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	var ultimas []models.RecalculoEjecucion
+	if err := db.Preload("Tareas", func(db *gorm.DB) *gorm.DB { return db.Order("iniciado_en asc, id asc") }).
+		Where("plan_negocio_id = ?", planID).Order("id desc").Limit(1).Find(&ultimas).Error; err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	respuesta := struct {
+		procedimientos.EstadoRecalculo
+		UltimaEjecucion *models.RecalculoEjecucion `json:"ultima_ejecucion"`
+		Desactualizado  bool                       `json:"desactualizado"`
+	}{EstadoRecalculo: estado}
+	if len(ultimas) > 0 {
+		respuesta.UltimaEjecucion = &ultimas[0]
+		respuesta.Desactualizado = ultimas[0].Estado != models.RecalculoOK
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(respuesta)
+}
+
+// recalcularPlan recalcula lo que depende de las tablas modificadas (todo si
+// no se dan tablas) y deja el resultado en las cabeceras X-Recalculo
+// (ok|error|cancelado) y X-Recalculo-Id (registro en recalculo_ejecuciones). Se llama antes de
+// escribir la respuesta; si devuelve error el controlador responde 500 con él.
+func recalcularPlan(db *gorm.DB, w http.ResponseWriter, planID uint, tablas ...string) error {
+	ejecucion, err := procedimientos.RecalcularConEjecucion(db, planID, tablas...)
+	w.Header().Set(CabeceraRecalculo, ejecucion.Estado)
+	if ejecucion.ID != 0 {
+		w.Header().Set(CabeceraRecalculoID, strconv.FormatUint(uint64(ejecucion.ID), 10))
+	}
+	return err
+}
@@ -0,0 +1,179 @@
+package controllers
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/JostinAlvaradoS/liveplan_backend_go/internal/procedimientos"
+)
+
+// Una tabla que ningún procedimiento lee no dispara nada en Recalcular: un
+// recalc:true con esa tabla no recalcularía nada.
+func TestTablasRecalculoLasLeeAlgunProcedimiento(t *testing.T) {
+	leidas := make(map[string]bool)
+	for _, p := range procedimientos.ProcedimientosRecalculo() {
+		for _, tabla := range p.Lee {
+			leidas[tabla] = true
+		}
+	}
+
+	fset := token.NewFileSet()
+	paquetes, err := parser.ParseDir(fset, ".", func(fi os.FileInfo) bool {
+		return !strings.HasSuffix(fi.Name(), "_test.go")
+	}, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	llamadas := 0
+	for _, pkg := range paquetes {
+		for _, f := range pkg.Files {
+			ast.Inspect(f, func(n ast.Node) bool {
+				call, ok := n.(*ast.CallExpr)
+				if !ok {
+					return true
+				}
+				if id, ok := call.Fun.(*ast.Ident); !ok || id.Name != "recalcularPlan" {
+					return true
+				}
+				llamadas++
+				for _, arg := range call.Args {
+					lit, ok := arg.(*ast.BasicLit)
+					if !ok || lit.Kind != token.STRING {
+						continue
+					}
+					tabla, _ := strconv.Unquote(lit.Value)
+					if !leidas[tabla] {
+						t.Errorf("%s: recalcularPlan(%q): ningún procedimiento lee la tabla", fset.Position(lit.Pos()), tabla)
+					}
+				}
+				return true
+			})
+		}
+	}
+	if llamadas == 0 {
+		t.Fatal("no se encontraron llamadas a recalcularPlan")
+	}
+
+	for _, e := range entidadesAuditadas {
+		if e.tabla != "" && !leidas[e.tabla] {
+			t.Errorf("entidadesAuditadas: ningún procedimiento lee la tabla %q", e.tabla)
+		}
+	}
+}
+
+// Los controladores que propagan el crecimiento al presupuesto de ventas
+// (guardarVariacionAnio, propagarCrecimiento) y recalculan solo algunas tablas
+// deben incluir presupuesto_venta.
+func TestRecalculoIncluyePresupuestoAlPropagarCrecimiento(t *testing.T) {
+	fset := token.NewFileSet()
+	paquetes, err := parser.ParseDir(fset, ".", func(fi os.FileInfo) bool {
+		return !strings.HasSuffix(fi.Name(), "_test.go")
+	}, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	revisadas := 0
+	for _, pkg := range paquetes {
+		for _, f := range pkg.Files {
+			for _, decl := range f.Decls {
+				fn, ok := decl.(*ast.FuncDecl)
+				if !ok || fn.Body == nil || fn.Name.Name == "guardarVariacionAnio" {
+					continue
+				}
+				var propaga bool
+				var recalculos []*ast.CallExpr
+				ast.Inspect(fn.Body, func(n ast.Node) bool {
+					call, ok := n.(*ast.CallExpr)
+					if !ok {
+						return true
+					}
+					if id, ok := call.Fun.(*ast.Ident); ok {
+						switch id.Name {
+						case "guardarVariacionAnio", "propagarCrecimiento":
+							propaga = true
+						case "recalcularPlan":
+							recalculos = append(recalculos, call)
+						}
+					}
+					return true
+				})
+				if !propaga {
+					continue
+				}
+				for _, call := range recalculos {
+					var tablas []string
+					for _, arg := range call.Args {
+						if lit, ok := arg.(*ast.BasicLit); ok && lit.Kind == token.STRING {
+							tabla, _ := strconv.Unquote(lit.Value)
+							tablas = append(tablas, tabla)
+						}
+					}
+					revisadas++
+					if len(tablas) > 0 && !contiene(tablas, "presupuesto_venta") {
+						t.Errorf("%s: %s recalcula %v sin presupuesto_venta", fset.Position(call.Pos()), fn.Name.Name, tablas)
+					}
+				}
+			}
+		}
+	}
+	if revisadas == 0 {
+		t.Fatal("no se encontraron recálculos después de propagar el crecimiento")
+	}
+}
+
+// Ningún controlador descarta el error del recálculo: todos responden 500
+func TestRecalculoNoDescartaErrores(t *testing.T) {
+	fset := token.NewFileSet()
+	paquetes, err := parser.ParseDir(fset, ".", func(fi os.FileInfo) bool {
+		return !strings.HasSuffix(fi.Name(), "_test.go")
+	}, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	recalcula := func(e ast.Expr) bool {
+		call, ok := e.(*ast.CallExpr)
+		if !ok {
+			return false
+		}
+		switch fun := call.Fun.(type) {
+		case *ast.Ident:
+			return fun.Name == "recalcularPlan"
+		case *ast.SelectorExpr:
+			return fun.Sel.Name == "recalcular"
+		}
+		return false
+	}
+	for _, pkg := range paquetes {
+		for _, f := range pkg.Files {
+			ast.Inspect(f, func(n ast.Node) bool {
+				switch s := n.(type) {
+				case *ast.ExprStmt:
+					if recalcula(s.X) {
+						t.Errorf("%s: se ignora el error del recálculo", fset.Position(s.Pos()))
+					}
+				case *ast.AssignStmt:
+					if len(s.Rhs) == 1 && recalcula(s.Rhs[0]) {
+						if id, ok := s.Lhs[0].(*ast.Ident); ok && id.Name == "_" {
+							t.Errorf("%s: se descarta el error del recálculo", fset.Position(s.Pos()))
+						}
+					}
+				}
+				return true
+			})
+		}
+	}
+}
+
+func contiene(xs []string, x string) bool {
+	for _, y := range xs {
+		if y == x {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,316 @@
+package controllers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+	"time"
+
+	"github.com/JostinAlvaradoS/liveplan_backend_go/internal/models"
+	"github.com/JostinAlvaradoS/liveplan_backend_go/internal/procedimientos"
+	"gorm.io/gorm"
+)
+
+// ErrFilaEliminada indica que la fila de un cambio ya no existe y no se puede revertir
+var ErrFilaEliminada = errors.New("la fila del cambio ya no existe")
+
+// entidadAuditada es una tabla de entrada que registra auditoria: su modelo y
+// el nombre con que la leen los procedimientos de Recalcular ("" si ninguno la
+// lee: revertirla recalcula todo, como el PATCH de la tabla)
+type entidadAuditada struct {
+	modelo interface{}
+	tabla  string
+}
+
+// entidadesAuditadas son las tablas que se pueden revertir desde el historial.
+// variacion_anual_anios recalcula presupuesto_venta porque su porcentaje se
+// copia a PresupuestoVenta.crecimiento.
+var entidadesAuditadas = []entidadAuditada{
+	{&models.PlanNegocio{}, "plan_negocio"},
+	{&models.Supuesto{}, "supuesto"},
+	{&models.CostoMateriasPrimas{}, "costo_materias_primas"},
+	{&models.GastosOperacion{}, "gastos_operacion"},
+	{&models.ProductoServicio{}, ""},
+	{&models.PreciosProdServ{}, "precios_prod_serv"},
+	{&models.IndicadoresMacro{}, "indicadores_macro"},
+	{&models.CostosProdServ{}, "costos_prod_serv"},
+	{&models.VariablesDeSensibilidad{}, "variables_de_sensibilidad"},
+	{&models.VentaDiaria{}, "venta_diaria"},
+	{&models.VentasDinero{}, "ventas_dinero"},
+	{&models.InversionInicial{}, ""},
+	{&models.DatosPrestamo{}, "datos_prestamo"},
+	{&models.PrestamoCuotas{}, "prestamo_cuotas"},
+	{&models.ComposicionFinanciamiento{}, "composicion_financiamiento"},
+	{&models.DetalleInversionInicial{}, "detalle_inversion_inicial"},
+	{&models.VariacionAnual{}, ""},
+	{&models.VariacionAnualAnio{}, "presupuesto_venta"},
+	{&models.PresupuestoVenta{}, "presupuesto_venta"},
+	{&models.EvaluacionProyecto{}, "evaluacion_proyecto"},
+}
+
+// camposNoRevertibles son columnas (entidad.campo) que el historial no
+// devuelve a su valor anterior: el autor decide quién es owner del plan
+var camposNoRevertibles = map[string]bool{"plan_negocio.autor": true}
+
+// entidadDe busca la tabla de entrada por su nombre en auditoria
+func entidadDe(db *gorm.DB, nombre string) (entidadAuditada, bool) {
+	for _, e := range entidadesAuditadas {
+		stmt := &gorm.Statement{DB: db}
+		if err := stmt.Parse(e.modelo); err == nil && stmt.Schema.Table == nombre {
+			return e, true
+		}
+	}
+	return entidadAuditada{}, false
+}
+
+// cambioAuditado es un alta, cambio o baja de una fila: los registros de
+// auditoria de la misma fila, acción y fecha
+type cambioAuditado struct {
+	entidad    string
+	accion     string
+	registroID uint
+	fecha      time.Time
+	registros  []models.Auditoria
+}
+
+// agruparCambios junta los registros consecutivos (en el orden dado) que forman un mismo cambio
+func agruparCambios(registros []models.Auditoria) []cambioAuditado {
+	var out []cambioAuditado
+	for _, reg := range registros {
+		if n := len(out); n > 0 {
+			c := &out[n-1]
+			if c.entidad == reg.Entidad && c.accion == reg.Accion && c.registroID == reg.RegistroID && c.fecha.Equal(reg.Fecha) {
+				c.registros = append(c.registros, reg)
+				continue
+			}
+		}
+		out = append(out, cambioAuditado{
+			entidad:    reg.Entidad,
+			accion:     reg.Accion,
+			registroID: reg.RegistroID,
+			fecha:      reg.Fecha,
+			registros:  []models.Auditoria{reg},
+		})
+	}
+	return out
+}
+
+// reversion acumula lo que hay que recalcular después de revertir cambios
+type reversion struct {
+	tablas map[string]bool
+	// completo: cambió el horizonte del plan y se recalcula todo
+	completo bool
+}
+
+// recalcular dispara el recálculo de lo revertido
+func (rv *reversion) recalcular(db *gorm.DB, w http.ResponseWriter, planID uint) error {
+	if rv.completo {
+		return recalcularPlan(db, w, planID)
+	}
+	if len(rv.tablas) == 0 {
+		return nil
+	}
+	tablas := make([]string, 0, len(rv.tablas))
+	for t := range rv.tablas {
+		tablas = append(tablas, t)
+	}
+	return recalcularPlan(db, w, planID, tablas...)
+}
+
+// revertir aplica el inverso del cambio: borra la fila creada, vuelve a crear
+// la eliminada con su id original o devuelve las columnas a su valor
+// anterior. La reversión también queda en auditoria.
+func (rv *reversion) revertir(tx *gorm.DB, r *http.Request, c cambioAuditado) error {
+	e, ok := entidadDe(tx, c.entidad)
+	if !ok {
+		return fmt.Errorf("la tabla %s no se puede revertir", c.entidad)
+	}
+	fila := reflect.New(reflect.TypeOf(e.modelo).Elem()).Interface()
+	var err error
+	switch c.accion {
+	case models.AuditoriaCrear:
+		err = eliminarConAuditoria(tx, r, fila, c.registroID)
+		if err == gorm.ErrRecordNotFound {
+			// ya se eliminó después
+			return nil
+		}
+	case models.AuditoriaEliminar:
+		err = conAuditoria(tx, r, models.AuditoriaCrear, fila, func(tx *gorm.DB) error {
+			if _, err := asignarAnteriores(tx, fila, c.registroID, c.registros); err != nil {
+				return err
+			}
+			return tx.Create(fila).Error
+		})
+	case models.AuditoriaActualizar:
+		if err := tx.First(fila, c.registroID).Error; err == gorm.ErrRecordNotFound {
+			return fmt.Errorf("%w: %s %d", ErrFilaEliminada, c.entidad, c.registroID)
+		} else if err != nil {
+			return err
+		}
+		err = conAuditoria(tx, r, models.AuditoriaActualizar, fila, func(tx *gorm.DB) error {
+			campos, err := asignarAnteriores(tx, fila, c.registroID, c.registros)
+			if err != nil || len(campos) == 0 {
+				return err
+			}
+			return tx.Model(fila).Select(campos).Updates(fila).Error
+		})
+	default:
+		return fmt.Errorf("acción desconocida %q", c.accion)
+	}
+	if err != nil {
+		return err
+	}
+	if e.tabla == "" {
+		rv.completo = true
+	} else {
+		rv.tablas[e.tabla] = true
+	}
+	return rv.efectos(tx, r, c, fila)
+}
+
+// efectos repite lo que hace el controlador de la tabla además de guardar la fila
+func (rv *reversion) efectos(tx *gorm.DB, r *http.Request, c cambioAuditado, fila interface{}) error {
+	switch f := fila.(type) {
+	case *models.PlanNegocio:
+		for _, reg := range c.registros {
+			if reg.Campo == "horizonte_anios" {
+				rv.completo = true
+				return procedimientos.AjustarHorizonte(tx, f.ID, f.Horizonte())
+			}
+		}
+	case *models.VariacionAnualAnio:
+		// al revertir un alta la fila ya no existe: el año queda sin valor
+		if c.accion == models.AuditoriaCrear {
+			return propagarCrecimiento(tx, r, f.PlanNegocioID, f.Anio, nil)
+		}
+		return propagarCrecimiento(tx, r, f.PlanNegocioID, f.Anio, &f.Porcentaje)
+	case *models.VariablesDeSensibilidad:
+		// como el PATCH: el volumen pasa al crecimiento del año 1
+		if c.accion != models.AuditoriaActualizar {
+			return nil
+		}
+		rv.tablas["presupuesto_venta"] = true
+		return propagarVolumen(tx, r, *f)
+	}
+	return nil
+}
+
+// asignarAnteriores pone en fila el id y los valores anteriores de los
+// registros y devuelve las columnas asignadas
+func asignarAnteriores(db *gorm.DB, fila interface{}, id uint, registros []models.Auditoria) ([]string, error) {
+	stmt := &gorm.Statement{DB: db}
+	if err := stmt.Parse(fila); err != nil {
+		return nil, err
+	}
+	ctx := db.Statement.Context
+	valor := reflect.ValueOf(fila).Elem()
+	if pk := stmt.Schema.PrioritizedPrimaryField; pk != nil {
+		if err := pk.Set(ctx, valor, id); err != nil {
+			return nil, err
+		}
+	}
+	var campos []string
+	for _, reg := range registros {
+		if camposNoRevertibles[reg.Entidad+"."+reg.Campo] {
+			continue
+		}
+		f := stmt.Schema.LookUpField(reg.Campo)
+		if f == nil || f.DBName == "" || reg.ValorAnterior == nil {
+			continue
+		}
+		v := reflect.New(f.FieldType)
+		if err := json.Unmarshal([]byte(*reg.ValorAnterior), v.Interface()); err != nil {
+			return nil, fmt.Errorf("%s.%s: %w", reg.Entidad, reg.Campo, err)
+		}
+		if err := f.Set(ctx, valor, v.Elem().Interface()); err != nil {
+			return nil, err
+		}
+		campos = append(campos, f.DBName)
+	}
+	return campos, nil
+}
+
+// RevertirCambio revierte un registro del historial del plan. Si es una
+// actualización devuelve esa columna a su valor anterior; si es un alta o una
+// baja revierte la fila completa. Luego recalcula lo que depende de la tabla.
+func RevertirCambio(db *gorm.DB, w http.ResponseWriter, r *http.Request, planID, cambioID uint) {
+	var reg models.Auditoria
+	if err := db.Where("plan_negocio_id = ?", planID).First(&reg, cambioID).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			http.NotFound(w, r)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	registros := []models.Auditoria{reg}
+	if reg.Accion != models.AuditoriaActualizar {
+		if err := db.Where("plan_negocio_id = ? AND entidad = ? AND registro_id = ? AND accion = ? AND fecha = ?",
+			planID, reg.Entidad, reg.RegistroID, reg.Accion, reg.Fecha).Order("id asc").Find(&registros).Error; err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+	revertirCambios(db, w, r, planID, agruparCambios(registros))
+}
+
+// RevertirPlanAFecha deja las tablas de entrada del plan como estaban en la
+// fecha del body ({"fecha": "2024-05-01T10:00:00Z"}): revierte, del más
+// reciente al más antiguo, todos los cambios posteriores.
+func RevertirPlanAFecha(db *gorm.DB, w http.ResponseWriter, r *http.Request, planID uint) {
+	var body struct {
+		Fecha string `json:"fecha"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	fecha, err := time.Parse(time.RFC3339, body.Fecha)
+	if err != nil {
+		http.Error(w, "fecha debe estar en formato RFC 3339", http.StatusBadRequest)
+		return
+	}
+	var registros []models.Auditoria
+	if err := db.Where("plan_negocio_id = ? AND fecha > ?", planID, fecha).
+		Order("fecha desc, id asc").Find(&registros).Error; err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	revertirCambios(db, w, r, planID, agruparCambios(registros))
+}
+
+// revertirCambios revierte los cambios en orden en una sola transacción y recalcula
+func revertirCambios(db *gorm.DB, w http.ResponseWriter, r *http.Request, planID uint, cambios []cambioAuditado) {
+	rv := &reversion{tablas: make(map[string]bool)}
+	err := db.Transaction(func(tx *gorm.DB) error {
+		for _, c := range cambios {
+			if err := rv.revertir(tx, r, c); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if errors.Is(err, ErrFilaEliminada) {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if err := rv.recalcular(db, w, planID); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	revertidos := make([]uint, 0)
+	for _, c := range cambios {
+		for _, reg := range c.registros {
+			revertidos = append(revertidos, reg.ID)
+		}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"revertidos": revertidos})
+}
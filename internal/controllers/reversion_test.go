@@ -0,0 +1,182 @@
+package controllers
+
+import (
+	"context"
+	"database/sql"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/JostinAlvaradoS/liveplan_backend_go/internal/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/schema"
+)
+
+// dialectoPrueba es un dialecto sin conexión; las consultas las contesta el
+// callback que registra baseEnMemoria
+type dialectoPrueba struct{}
+
+func (dialectoPrueba) Name() string                                   { return "prueba" }
+func (dialectoPrueba) Initialize(*gorm.DB) error                      { return nil }
+func (dialectoPrueba) Migrator(*gorm.DB) gorm.Migrator                { return nil }
+func (dialectoPrueba) DataTypeOf(*schema.Field) string                { return "" }
+func (dialectoPrueba) DefaultValueOf(*schema.Field) clause.Expression { return nil }
+func (dialectoPrueba) BindVarTo(w clause.Writer, _ *gorm.Statement, _ interface{}) {
+	w.WriteByte('?')
+}
+func (dialectoPrueba) QuoteTo(w clause.Writer, s string)           { w.WriteString(s) }
+func (dialectoPrueba) Explain(sql string, _ ...interface{}) string { return sql }
+
+// conexionPrueba se presenta como una transacción abierta: con
+// DisableNestedTransaction, db.Transaction solo ejecuta la función
+type conexionPrueba struct{}
+
+func (conexionPrueba) PrepareContext(context.Context, string) (*sql.Stmt, error) { return nil, nil }
+func (conexionPrueba) ExecContext(context.Context, string, ...interface{}) (sql.Result, error) {
+	return nil, nil
+}
+func (conexionPrueba) QueryContext(context.Context, string, ...interface{}) (*sql.Rows, error) {
+	return nil, nil
+}
+func (conexionPrueba) QueryRowContext(context.Context, string, ...interface{}) *sql.Row { return nil }
+func (conexionPrueba) Commit() error                                                    { return nil }
+func (conexionPrueba) Rollback() error                                                  { return nil }
+
+// baseEnMemoria son las filas de un plan con variables de sensibilidad, su
+// variación anual (va nil si no tiene) y el presupuesto de ventas
+type baseEnMemoria struct {
+	variables    models.VariablesDeSensibilidad
+	va           *models.VariacionAnual
+	anio1        *models.VariacionAnualAnio
+	presupuestos []models.PresupuestoVenta
+	auditoria    []models.Auditoria
+}
+
+func (m *baseEnMemoria) abrir(t *testing.T) *gorm.DB {
+	t.Helper()
+	db, err := gorm.Open(dialectoPrueba{}, &gorm.Config{ConnPool: conexionPrueba{}, DisableNestedTransaction: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	noEncontrado := func(tx *gorm.DB) { tx.AddError(gorm.ErrRecordNotFound) }
+	registrar := func(err error) {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	registrar(db.Callback().Query().Register("prueba:query", func(tx *gorm.DB) {
+		switch dest := tx.Statement.Dest.(type) {
+		case *models.VariablesDeSensibilidad:
+			*dest = m.variables
+		case *models.VariacionAnual:
+			if m.va == nil {
+				noEncontrado(tx)
+				return
+			}
+			*dest = *m.va
+		case *models.VariacionAnualAnio:
+			if m.anio1 == nil {
+				noEncontrado(tx)
+				return
+			}
+			*dest = *m.anio1
+		case *[]models.PresupuestoVenta:
+			*dest = append((*dest)[:0], m.presupuestos...)
+		case *models.PresupuestoVenta:
+			for _, p := range m.presupuestos {
+				if p.ID == dest.ID || dest.ID == 0 {
+					*dest = p
+				}
+			}
+		default:
+			t.Errorf("consulta inesperada a %T", dest)
+		}
+	}))
+	registrar(db.Callback().Create().Register("prueba:create", func(tx *gorm.DB) {
+		switch dest := tx.Statement.Dest.(type) {
+		case *models.VariacionAnualAnio:
+			dest.ID = 50
+			fila := *dest
+			m.anio1 = &fila
+		case *[]models.Auditoria:
+			m.auditoria = append(m.auditoria, *dest...)
+		default:
+			t.Errorf("alta inesperada de %T", dest)
+		}
+	}))
+	registrar(db.Callback().Update().Register("prueba:update", func(tx *gorm.DB) {
+		valores, _ := tx.Statement.Dest.(map[string]interface{})
+		switch fila := tx.Statement.Model.(type) {
+		case *models.VariablesDeSensibilidad:
+			m.variables = *fila
+		case *models.VariacionAnualAnio:
+			m.anio1.Porcentaje = valores["porcentaje"].(float64)
+		case *models.PresupuestoVenta:
+			for i := range m.presupuestos {
+				if m.presupuestos[i].ID == fila.ID {
+					v := valores["crecimiento"].(float64)
+					m.presupuestos[i].Crecimiento = &v
+				}
+			}
+		default:
+			t.Errorf("actualización inesperada de %T", fila)
+		}
+	}))
+	return db
+}
+
+// Revertir Cantidad_volumen repite lo que hace el PATCH: el volumen anterior
+// vuelve al año 1 de la variación anual y al crecimiento del presupuesto, y
+// se recalcula presupuesto_venta.
+func TestRevertirVariablesDeSensibilidadPropagaVolumen(t *testing.T) {
+	ocho := 8.0
+	nuevo := func(conVariacion bool) *baseEnMemoria {
+		m := &baseEnMemoria{
+			variables:    models.VariablesDeSensibilidad{ID: 2, PlanNegocioID: 7, Cantidad_volumen: 8},
+			presupuestos: []models.PresupuestoVenta{{ID: 11, PlanNegocioID: 7, Anio: 1, Crecimiento: &ocho}},
+		}
+		if conVariacion {
+			m.va = &models.VariacionAnual{ID: 3, PlanNegocioID: 7}
+			m.anio1 = &models.VariacionAnualAnio{ID: 50, VariacionAnualID: 3, PlanNegocioID: 7, Anio: 1, Porcentaje: 8}
+		}
+		return m
+	}
+
+	for _, conVariacion := range []bool{true, false} {
+		m := nuevo(conVariacion)
+		db := m.abrir(t)
+		stmt := &gorm.Statement{DB: db}
+		if err := stmt.Parse(&models.VariablesDeSensibilidad{}); err != nil {
+			t.Fatal(err)
+		}
+		anterior := "5"
+		c := cambioAuditado{
+			entidad:    stmt.Table,
+			accion:     models.AuditoriaActualizar,
+			registroID: 2,
+			registros:  []models.Auditoria{{Entidad: stmt.Table, Campo: "cantidad_volumen", ValorAnterior: &anterior}},
+		}
+		rv := &reversion{tablas: make(map[string]bool)}
+		if err := rv.revertir(db, httptest.NewRequest("POST", "/", nil), c); err != nil {
+			t.Fatalf("con variación %v: %v", conVariacion, err)
+		}
+
+		if m.variables.Cantidad_volumen != 5 {
+			t.Errorf("con variación %v: cantidad_volumen = %v, want 5", conVariacion, m.variables.Cantidad_volumen)
+		}
+		if !rv.tablas["variables_de_sensibilidad"] || !rv.tablas["presupuesto_venta"] || rv.completo {
+			t.Errorf("con variación %v: recalcula %v (completo %v)", conVariacion, rv.tablas, rv.completo)
+		}
+		crecimiento := *m.presupuestos[0].Crecimiento
+		if !conVariacion {
+			// sin variación anual el PATCH tampoco propaga
+			if crecimiento != 8 {
+				t.Errorf("sin variación: crecimiento = %v, want 8", crecimiento)
+			}
+			continue
+		}
+		if m.anio1.Porcentaje != 5 || crecimiento != 5 {
+			t.Errorf("año 1 = %v, crecimiento = %v, want 5", m.anio1.Porcentaje, crecimiento)
+		}
+	}
+}
@@ -0,0 +1,144 @@
+package controllers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/JostinAlvaradoS/liveplan_backend_go/internal/models"
+	"github.com/JostinAlvaradoS/liveplan_backend_go/internal/procedimientos"
+	"github.com/JostinAlvaradoS/liveplan_backend_go/internal/reportes"
+	"gorm.io/gorm"
+)
+
+// CrearSnapshot congela las entradas y los estados calculados actuales del
+// plan con la etiqueta del body ({"etiqueta": "presentado al banco 2026-09"})
+func CrearSnapshot(db *gorm.DB, w http.ResponseWriter, r *http.Request, planID uint) {
+	var body struct {
+		Etiqueta string `json:"etiqueta"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	body.Etiqueta = strings.TrimSpace(body.Etiqueta)
+	if body.Etiqueta == "" {
+		http.Error(w, "etiqueta es requerida", http.StatusBadRequest)
+		return
+	}
+	if len(body.Etiqueta) > 120 {
+		http.Error(w, "etiqueta admite hasta 120 caracteres", http.StatusBadRequest)
+		return
+	}
+
+	var item models.PlanSnapshot
+	err := db.Transaction(func(tx *gorm.DB) error {
+		// leer entradas y estados en la misma transacción para que sean coherentes
+		doc, err := procedimientos.ExportarPlan(tx, planID, true)
+		if err != nil {
+			return err
+		}
+		documento, err := json.Marshal(doc)
+		if err != nil {
+			return err
+		}
+		var ultimas []models.RecalculoEjecucion
+		if err := tx.Select("estado").Where("plan_negocio_id = ?", planID).Order("id desc").Limit(1).Find(&ultimas).Error; err != nil {
+			return err
+		}
+		item = models.PlanSnapshot{
+			PlanNegocioID:  planID,
+			Etiqueta:       body.Etiqueta,
+			CreadoPor:      actorDe(r),
+			Desactualizado: len(ultimas) > 0 && ultimas[0].Estado != models.RecalculoOK,
+			Documento:      string(documento),
+		}
+		return tx.Create(&item).Error
+	})
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			http.NotFound(w, r)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(item)
+}
+
+// ListSnapshotsByPlan lista las snapshots del plan (sin el documento), de la más reciente a la más antigua
+func ListSnapshotsByPlan(db *gorm.DB, w http.ResponseWriter, r *http.Request, planID uint) {
+	var items []models.PlanSnapshot
+	if err := db.Omit("documento").Where("plan_negocio_id = ?", planID).Order("created_at desc, id desc").Find(&items).Error; err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(items)
+}
+
+// GetSnapshot devuelve la snapshot con su documento
+func GetSnapshot(db *gorm.DB, w http.ResponseWriter, r *http.Request, planID, id uint) {
+	item, err := cargarSnapshot(db, planID, id)
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			http.NotFound(w, r)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(struct {
+		models.PlanSnapshot
+		Documento json.RawMessage `json:"documento"`
+	}{item, json.RawMessage(item.Documento)})
+}
+
+func DeleteSnapshot(db *gorm.DB, w http.ResponseWriter, r *http.Request, planID, id uint) {
+	res := db.Where("plan_negocio_id = ?", planID).Delete(&models.PlanSnapshot{}, id)
+	if res.Error != nil {
+		http.Error(w, res.Error.Error(), http.StatusInternalServerError)
+		return
+	}
+	if res.RowsAffected == 0 {
+		http.NotFound(w, r)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// CompararSnapshots devuelve las diferencias (b - a) por renglón y año de los
+// estados financieros, de VAN/TIR/TREMA y de la matriz de sensibilidad
+func CompararSnapshots(db *gorm.DB, w http.ResponseWriter, r *http.Request, planID, a, b uint) {
+	var docs [2]procedimientos.DocumentoPlan
+	for i, id := range []uint{a, b} {
+		item, err := cargarSnapshot(db, planID, id)
+		if err != nil {
+			if err == gorm.ErrRecordNotFound {
+				http.NotFound(w, r)
+				return
+			}
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if docs[i], err = procedimientos.LeerDocumento([]byte(item.Documento)); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(struct {
+		A uint `json:"a"`
+		B uint `json:"b"`
+		reportes.Comparacion
+	}{a, b, reportes.Comparar(docs[0], docs[1])})
+}
+
+// cargarSnapshot busca la snapshot id del plan
+func cargarSnapshot(db *gorm.DB, planID, id uint) (models.PlanSnapshot, error) {
+	var item models.PlanSnapshot
+	err := db.Where("plan_negocio_id = ?", planID).First(&item, id).Error
+	return item, err
+}
@@ -0,0 +1,35 @@
+package controllers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/JostinAlvaradoS/liveplan_backend_go/internal/procedimientos"
+	"gorm.io/gorm"
+)
+
+// VariacionTornadoPorDefecto es el ±% del análisis de tornado si no se indica ?variacion=
+const VariacionTornadoPorDefecto = 10.0
+
+// GetTornadoPlan devuelve cuánto se mueven el VAN y la TIR al variar cada
+// entrada del plan ±variacion% (por defecto 10) con las demás fijas,
+// ordenado para una gráfica de tornado
+func GetTornadoPlan(db *gorm.DB, w http.ResponseWriter, r *http.Request, planID uint) {
+	variacion := VariacionTornadoPorDefecto
+	if v := r.URL.Query().Get("variacion"); v != "" {
+		f, err := strconv.ParseFloat(v, 64)
+		if err != nil || f <= 0 || f >= 100 {
+			http.Error(w, "variacion debe ser un porcentaje mayor a 0 y menor a 100", http.StatusBadRequest)
+			return
+		}
+		variacion = f
+	}
+	resultado, err := procedimientos.CalcularTornado(db, planID, variacion)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resultado)
+}
@@ -6,7 +6,6 @@ import (
 	"strconv"
 
 	"github.com/JostinAlvaradoS/liveplan_backend_go/internal/models"
-	"github.com/JostinAlvaradoS/liveplan_backend_go/internal/procedimientos"
 	"gorm.io/gorm"
 )
 
@@ -59,7 +58,9 @@ func CreateVariablesDeSensibilidad(db *gorm.DB, w http.ResponseWriter, r *http.R
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
-	if err := db.Create(&item).Error; err != nil {
+	if err := conAuditoria(db, r, models.AuditoriaCrear, &item, func(tx *gorm.DB) error {
+		return tx.Create(&item).Error
+	}); err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
@@ -88,24 +89,16 @@ func UpdateVariablesDeSensibilidadPatch(db *gorm.DB, w http.ResponseWriter, r *h
 	delete(body, "ID")
 	delete(body, "recalc")
 
-	if err := db.Model(&item).Updates(body).Error; err != nil {
+	if err := conAuditoria(db, r, models.AuditoriaActualizar, &item, func(tx *gorm.DB) error {
+		return tx.Model(&item).Updates(body).Error
+	}); err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 	// After updating variables, set VariacionAnual.anio1 from Cantidad_volumen
 	// reload item to get updated Cantidad_volumen (in case it was part of the patch)
 	if err := db.First(&item, item.ID).Error; err == nil {
-		// update variacion_anual.anio1 for this plan
-		d := map[string]interface{}{"anio1": item.Cantidad_volumen}
-		if err := db.Model(&models.VariacionAnual{}).Where("plan_negocio_id = ?", item.PlanNegocioID).Updates(d).Error; err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
-			return
-		}
-
-		// Also update PresupuestoVenta.crecimiento for year 1 to match Cantidad_volumen
-		if err := db.Model(&models.PresupuestoVenta{}).
-			Where("plan_negocio_id = ? AND anio = ?", item.PlanNegocioID, 1).
-			Updates(map[string]interface{}{"crecimiento": item.Cantidad_volumen}).Error; err != nil {
+		if err := propagarVolumen(db, r, item); err != nil {
 			http.Error(w, err.Error(), http.StatusInternalServerError)
 			return
 		}
@@ -113,7 +106,7 @@ func UpdateVariablesDeSensibilidadPatch(db *gorm.DB, w http.ResponseWriter, r *h
 
 	if recalc {
 		planID := item.PlanNegocioID
-		if err := procedimientos.Recalcular(db, planID); err != nil {
+		if err := recalcularPlan(db, w, planID, "variables_de_sensibilidad", "presupuesto_venta"); err != nil {
 			http.Error(w, err.Error(), http.StatusInternalServerError)
 			return
 		}
@@ -121,8 +114,27 @@ func UpdateVariablesDeSensibilidadPatch(db *gorm.DB, w http.ResponseWriter, r *h
 	json.NewEncoder(w).Encode(item)
 }
 
+// propagarVolumen copia Cantidad_volumen al año 1 de la VariacionAnual del
+// plan; guardarVariacionAnio lo lleva también a PresupuestoVenta.crecimiento
+// del año 1. Sin VariacionAnual no hay nada que propagar.
+func propagarVolumen(tx *gorm.DB, r *http.Request, item models.VariablesDeSensibilidad) error {
+	var va models.VariacionAnual
+	if err := tx.Where("plan_negocio_id = ?", item.PlanNegocioID).First(&va).Error; err == gorm.ErrRecordNotFound {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	cv := item.Cantidad_volumen
+	return guardarVariacionAnio(tx, r, va, 1, &cv)
+}
+
 func DeleteVariablesDeSensibilidad(db *gorm.DB, w http.ResponseWriter, r *http.Request, id uint) {
-	if err := db.Delete(&models.VariablesDeSensibilidad{}, id).Error; err != nil {
+	err := eliminarConAuditoria(db, r, &models.VariablesDeSensibilidad{}, id)
+	if err == gorm.ErrRecordNotFound {
+		http.NotFound(w, r)
+		return
+	}
+	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
@@ -2,8 +2,10 @@ package controllers
 
 import (
 	"encoding/json"
+	"fmt"
 	"net/http"
 	"strconv"
+	"strings"
 
 	"github.com/JostinAlvaradoS/liveplan_backend_go/internal/models"
 	"github.com/JostinAlvaradoS/liveplan_backend_go/internal/procedimientos"
@@ -12,7 +14,7 @@ import (
 
 func ListVariacionAnual(db *gorm.DB, w http.ResponseWriter, r *http.Request) {
 	var items []models.VariacionAnual
-	query := db.Preload("PlanNegocio")
+	query := db.Preload("PlanNegocio").Preload("Anios", func(db *gorm.DB) *gorm.DB { return db.Order("anio asc") })
 	if pid := r.URL.Query().Get("plan_id"); pid != "" {
 		id, err := strconv.Atoi(pid)
 		if err != nil {
@@ -31,7 +33,7 @@ func ListVariacionAnual(db *gorm.DB, w http.ResponseWriter, r *http.Request) {
 
 func ListVariacionAnualByPlan(db *gorm.DB, w http.ResponseWriter, r *http.Request, planID uint) {
 	var items []models.VariacionAnual
-	if err := db.Preload("PlanNegocio").Where("plan_negocio_id = ?", planID).Find(&items).Error; err != nil {
+	if err := db.Preload("PlanNegocio").Preload("Anios", func(db *gorm.DB) *gorm.DB { return db.Order("anio asc") }).Where("plan_negocio_id = ?", planID).Find(&items).Error; err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
@@ -41,7 +43,7 @@ func ListVariacionAnualByPlan(db *gorm.DB, w http.ResponseWriter, r *http.Reques
 
 func GetVariacionAnual(db *gorm.DB, w http.ResponseWriter, r *http.Request, id uint) {
 	var item models.VariacionAnual
-	if err := db.Preload("PlanNegocio").First(&item, id).Error; err != nil {
+	if err := db.Preload("PlanNegocio").Preload("Anios", func(db *gorm.DB) *gorm.DB { return db.Order("anio asc") }).First(&item, id).Error; err != nil {
 		if err == gorm.ErrRecordNotFound {
 			http.NotFound(w, r)
 			return
@@ -59,7 +61,12 @@ func CreateVariacionAnual(db *gorm.DB, w http.ResponseWriter, r *http.Request) {
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
-	if err := db.Create(&item).Error; err != nil {
+	for i := range item.Anios {
+		item.Anios[i].PlanNegocioID = item.PlanNegocioID
+	}
+	if err := conAuditoria(db, r, models.AuditoriaCrear, &item, func(tx *gorm.DB) error {
+		return tx.Create(&item).Error
+	}); err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
@@ -86,57 +93,164 @@ func UpdateVariacionAnualPatch(db *gorm.DB, w http.ResponseWriter, r *http.Reque
 	delete(body, "id")
 	delete(body, "ID")
 	delete(body, "recalc")
-	if err := db.Model(&item).Updates(body).Error; err != nil {
+
+	horizonte, err := procedimientos.HorizontePlan(db, item.PlanNegocioID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	valores, err := porcentajesPorAnio(body, horizonte)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	err = db.Transaction(func(tx *gorm.DB) error {
+		if len(body) > 0 {
+			if err := conAuditoria(tx, r, models.AuditoriaActualizar, &item, func(tx *gorm.DB) error {
+				return tx.Model(&item).Updates(body).Error
+			}); err != nil {
+				return err
+			}
+		}
+		for year, valor := range valores {
+			if err := guardarVariacionAnio(tx, r, item, year, valor); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 	if recalc {
 		planID := item.PlanNegocioID
-		_ = procedimientos.Recalcular(db, planID)
+		// los porcentajes se copian a PresupuestoVenta.crecimiento
+		if err := recalcularPlan(db, w, planID, "presupuesto_venta"); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+	if err := db.Preload("Anios", func(db *gorm.DB) *gorm.DB { return db.Order("anio asc") }).First(&item, item.ID).Error; err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	json.NewEncoder(w).Encode(item)
+}
+
+// porcentajesPorAnio saca de body los porcentajes por año: claves planas
+// anio1..anioN o el arreglo "anios" ([{"anio": 1, "porcentaje": 5}, ...]).
+// Un porcentaje null deja el año sin valor; uno que no es número o un año
+// fuera del horizonte es un error. Cada valor se propaga a
+// PresupuestoVenta.crecimiento de ese año.
+func porcentajesPorAnio(body map[string]interface{}, horizonte int) (map[int]*float64, error) {
+	crudos := map[int]interface{}{}
+	if v, ok := body["anios"]; ok {
+		arr, ok := v.([]interface{})
+		if !ok {
+			return nil, fmt.Errorf("anios debe ser un arreglo")
+		}
+		for i, e := range arr {
+			m, ok := e.(map[string]interface{})
+			if !ok {
+				return nil, fmt.Errorf("anios[%d] debe ser un objeto", i)
+			}
+			a, ok := m["anio"].(float64)
+			if !ok || a != float64(int(a)) {
+				return nil, fmt.Errorf("anios[%d].anio debe ser un número entero", i)
+			}
+			crudos[int(a)] = m["porcentaje"]
+		}
+		delete(body, "anios")
 	}
-	// If any of the anio1..anio5 fields were updated, propagate the value to
-	// PresupuestoVenta.crecimiento for that plan and year.
 	for k, v := range body {
-		var year int
-		switch k {
-		case "anio1":
-			year = 1
-		case "anio2":
-			year = 2
-		case "anio3":
-			year = 3
-		case "anio4":
-			year = 4
-		case "anio5":
-			year = 5
-		default:
+		if !strings.HasPrefix(k, "anio") {
 			continue
 		}
-		// v will be float64 when decoded from JSON numbers, or nil if null
-		if v == nil {
-			if err := db.Model(&models.PresupuestoVenta{}).
-				Where("plan_negocio_id = ? AND anio = ?", item.PlanNegocioID, year).
-				Update("crecimiento", nil).Error; err != nil {
-				http.Error(w, err.Error(), http.StatusInternalServerError)
-				return
-			}
+		year, err := strconv.Atoi(strings.TrimPrefix(k, "anio"))
+		if err != nil {
 			continue
 		}
-		// try numeric
-		if f, ok := v.(float64); ok {
-			if err := db.Model(&models.PresupuestoVenta{}).
-				Where("plan_negocio_id = ? AND anio = ?", item.PlanNegocioID, year).
-				Update("crecimiento", f).Error; err != nil {
-				http.Error(w, err.Error(), http.StatusInternalServerError)
-				return
-			}
+		crudos[year] = v
+		delete(body, k)
+	}
+
+	valores := map[int]*float64{}
+	for year, v := range crudos {
+		if year < 1 || year > horizonte {
+			return nil, fmt.Errorf("anio %d fuera del horizonte del plan (1..%d)", year, horizonte)
+		}
+		switch f := v.(type) {
+		case nil:
+			valores[year] = nil
+		case float64:
+			valores[year] = &f
+		default:
+			return nil, fmt.Errorf("el porcentaje del anio %d debe ser un número o null", year)
 		}
 	}
-	json.NewEncoder(w).Encode(item)
+	return valores, nil
+}
+
+// guardarVariacionAnio fija el porcentaje de crecimiento de un año (nil = sin valor)
+// y lo propaga a PresupuestoVenta.crecimiento para ese plan y año. El cambio
+// del año queda en auditoria a nombre de quien hace la petición r.
+func guardarVariacionAnio(tx *gorm.DB, r *http.Request, va models.VariacionAnual, year int, valor *float64) error {
+	porcentaje := 0.0
+	if valor != nil {
+		porcentaje = *valor
+	}
+	var fila models.VariacionAnualAnio
+	err := tx.Where("variacion_anual_id = ? AND anio = ?", va.ID, year).First(&fila).Error
+	if err == gorm.ErrRecordNotFound {
+		fila = models.VariacionAnualAnio{VariacionAnualID: va.ID, PlanNegocioID: va.PlanNegocioID, Anio: year, Porcentaje: porcentaje}
+		if err := conAuditoria(tx, r, models.AuditoriaCrear, &fila, func(tx *gorm.DB) error {
+			return tx.Create(&fila).Error
+		}); err != nil {
+			return err
+		}
+	} else if err != nil {
+		return err
+	} else if err := conAuditoria(tx, r, models.AuditoriaActualizar, &fila, func(tx *gorm.DB) error {
+		return tx.Model(&fila).Update("porcentaje", porcentaje).Error
+	}); err != nil {
+		return err
+	}
+
+	return propagarCrecimiento(tx, r, va.PlanNegocioID, year, valor)
+}
+
+// propagarCrecimiento fija PresupuestoVenta.crecimiento del plan para un año
+// (nil = sin valor); cada fila que cambia queda en auditoria
+func propagarCrecimiento(tx *gorm.DB, r *http.Request, planID uint, year int, valor *float64) error {
+	var crecimiento interface{}
+	if valor != nil {
+		crecimiento = *valor
+	}
+	var filas []models.PresupuestoVenta
+	if err := tx.Where("plan_negocio_id = ? AND anio = ?", planID, year).Find(&filas).Error; err != nil {
+		return err
+	}
+	for i := range filas {
+		fila := &filas[i]
+		if err := conAuditoria(tx, r, models.AuditoriaActualizar, fila, func(tx *gorm.DB) error {
+			return tx.Model(fila).Update("crecimiento", crecimiento).Error
+		}); err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
 func DeleteVariacionAnual(db *gorm.DB, w http.ResponseWriter, r *http.Request, id uint) {
-	if err := db.Delete(&models.VariacionAnual{}, id).Error; err != nil {
+	err := eliminarConAuditoria(db, r, &models.VariacionAnual{}, id)
+	if err == gorm.ErrRecordNotFound {
+		http.NotFound(w, r)
+		return
+	}
+	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
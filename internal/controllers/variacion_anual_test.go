@@ -0,0 +1,51 @@
+package controllers
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestPorcentajesPorAnio(t *testing.T) {
+	f := func(v float64) *float64 { return &v }
+	tests := []struct {
+		nombre string
+		body   string
+		want   map[int]*float64
+		error  string
+	}{
+		{"claves planas", `{"anio1": 5, "anio2": null, "nombre": "x"}`, map[int]*float64{1: f(5), 2: nil}, ""},
+		{"arreglo", `{"anios": [{"anio": 1, "porcentaje": 5}, {"anio": 3, "porcentaje": null}]}`, map[int]*float64{1: f(5), 3: nil}, ""},
+		{"porcentaje texto", `{"anio1": "5"}`, nil, "anio 1 debe ser un número"},
+		{"porcentaje booleano en el arreglo", `{"anios": [{"anio": 2, "porcentaje": true}]}`, nil, "anio 2 debe ser un número"},
+		{"anio no numérico en el arreglo", `{"anios": [{"anio": "1", "porcentaje": 5}]}`, nil, "anios[0].anio"},
+		{"anio fraccionario", `{"anios": [{"anio": 1.5, "porcentaje": 5}]}`, nil, "anios[0].anio"},
+		{"entrada que no es objeto", `{"anios": [5]}`, nil, "anios[0] debe ser un objeto"},
+		{"anios no es arreglo", `{"anios": {"anio": 1}}`, nil, "anios debe ser un arreglo"},
+		{"fuera del horizonte", `{"anio4": 5}`, nil, "fuera del horizonte"},
+		{"anio cero", `{"anios": [{"anio": 0, "porcentaje": 5}]}`, nil, "fuera del horizonte"},
+	}
+	for _, tt := range tests {
+		var body map[string]interface{}
+		if err := json.Unmarshal([]byte(tt.body), &body); err != nil {
+			t.Fatal(err)
+		}
+		got, err := porcentajesPorAnio(body, 3)
+		if tt.error != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.error) {
+				t.Errorf("%s: err = %v, want %q", tt.nombre, err, tt.error)
+			}
+			continue
+		}
+		if err != nil || !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: %v, err = %v, want %v", tt.nombre, got, err, tt.want)
+		}
+		// los años salen del body; el resto de los campos se queda
+		for k := range body {
+			if strings.HasPrefix(k, "anio") {
+				t.Errorf("%s: %s sigue en el body", tt.nombre, k)
+			}
+		}
+	}
+}
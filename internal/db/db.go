@@ -39,7 +39,7 @@ func Connect() (*gorm.DB, error) {
 }
 
 func Migrate(gdb *gorm.DB) error {
-	return gdb.AutoMigrate(
+	if err := gdb.AutoMigrate(
 		&models.PlanNegocio{},
 		&models.TipoInversionInicial{},
 		&models.InversionInicial{},
@@ -73,5 +73,55 @@ func Migrate(gdb *gorm.DB) error {
 		&models.AnalisisSensibilidad{},
 		&models.EvaluacionProyecto{},
 		&models.ConceptosEvaluacion{},
-	)
+		&models.VariacionAnualAnio{},
+		&models.DepreciacionAnual{},
+		&models.PlanColaborador{},
+		&models.RecalculoEjecucion{},
+		&models.RecalculoTarea{},
+		&models.Auditoria{},
+		&models.PlanSnapshot{},
+		&models.Escenario{},
+		&models.EscenarioAnio{},
+		&models.SimulacionMonteCarlo{},
+	); err != nil {
+		return err
+	}
+	return migrarColumnasAnuales(gdb)
+}
+
+// migrarColumnasAnuales copia las antiguas columnas fijas anio1..anio5 de
+// variacion_anuals y depreciacion_anio1..5 de depreciacions a sus tablas
+// indexadas por año y luego elimina las columnas.
+func migrarColumnasAnuales(gdb *gorm.DB) error {
+	m := gdb.Migrator()
+	return gdb.Transaction(func(tx *gorm.DB) error {
+		for anio := 1; anio <= models.HorizontePorDefecto; anio++ {
+			col := models.ClaveAnio("anio", anio)
+			if m.HasColumn(&models.VariacionAnual{}, col) {
+				sql := fmt.Sprintf(`INSERT INTO variacion_anual_anios (variacion_anual_id, plan_negocio_id, anio, porcentaje)
+					SELECT va.id, va.plan_negocio_id, %d, COALESCE(va.%s, 0) FROM variacion_anuals va
+					WHERE NOT EXISTS (SELECT 1 FROM variacion_anual_anios x WHERE x.variacion_anual_id = va.id AND x.anio = %d)`, anio, col, anio)
+				if err := tx.Exec(sql).Error; err != nil {
+					return fmt.Errorf("migrando %s de variacion_anuals: %w", col, err)
+				}
+				if err := tx.Migrator().DropColumn(&models.VariacionAnual{}, col); err != nil {
+					return err
+				}
+			}
+
+			col = models.ClaveAnio("depreciacion_anio", anio)
+			if m.HasColumn(&models.Depreciacion{}, col) {
+				sql := fmt.Sprintf(`INSERT INTO depreciacion_anuals (depreciacion_id, plan_negocio_id, anio, valor)
+					SELECT d.id, d.plan_negocio_id, %d, d.%s FROM depreciacions d
+					WHERE NOT EXISTS (SELECT 1 FROM depreciacion_anuals x WHERE x.depreciacion_id = d.id AND x.anio = %d)`, anio, col, anio)
+				if err := tx.Exec(sql).Error; err != nil {
+					return fmt.Errorf("migrando %s de depreciacions: %w", col, err)
+				}
+				if err := tx.Migrator().DropColumn(&models.Depreciacion{}, col); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
 }
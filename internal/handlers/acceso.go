@@ -0,0 +1,231 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/JostinAlvaradoS/liveplan_backend_go/internal/auth"
+	"github.com/JostinAlvaradoS/liveplan_backend_go/internal/controllers"
+	"github.com/JostinAlvaradoS/liveplan_backend_go/internal/models"
+	"gorm.io/gorm"
+)
+
+// recursosPorPlan asocia el primer segmento de la ruta con el modelo de sus filas,
+// para resolver el plan de /{recurso}/item/{id}.
+var recursosPorPlan = map[string]interface{}{
+	"analisis_sensibilidad":      &models.AnalisisSensibilidad{},
+	"balance_general":            &models.BalanceGeneral{},
+	"composicion_financiamiento": &models.ComposicionFinanciamiento{},
+	"conceptos_evaluacion":       &models.ConceptosEvaluacion{},
+	"costo_materias_primas":      &models.CostoMateriasPrimas{},
+	"costos_prodserv":            &models.CostosProdServ{},
+	"costos_ventas":              &models.CostosVentas{},
+	"depreciaciones":             &models.Depreciacion{},
+	"detalles_inversion":         &models.DetalleInversionInicial{},
+	"estado_resultados":          &models.EstadoResultados{},
+	"evaluacion_proyecto":        &models.EvaluacionProyecto{},
+	"flujo_efectivo":             &models.FlujoEfectivo{},
+	"gastos_operacion":           &models.GastosOperacion{},
+	"indicadores_macro":          &models.IndicadoresMacro{},
+	"inversiones":                &models.InversionInicial{},
+	"precios_prodserv":           &models.PreciosProdServ{},
+	"prestamos":                  &models.DatosPrestamo{},
+	"datos_prestamos":            &models.PrestamoCuotas{}, // las cuotas del préstamo
+	"presupuestos_venta":         &models.PresupuestoVenta{},
+	"producto_servicio":          &models.ProductoServicio{},
+	"supuestos":                  &models.Supuesto{},
+	"variables_sensibilidad":     &models.VariablesDeSensibilidad{},
+	"variacion_anual":            &models.VariacionAnual{},
+	"ventas_diarias":             &models.VentaDiaria{},
+	"ventas_dinero":              &models.VentasDinero{},
+}
+
+// accesoPlan exige que el usuario autenticado tenga acceso al plan al que apunta
+// la petición: viewer para leer, editor para modificar (POST/PATCH/PUT/DELETE,
+// incluido recalc) y owner para borrar el plan o administrar colaboradores.
+// El plan se obtiene de la ruta (/{recurso}/{plan}, /plan/{id}/...), de la fila
+// (/{recurso}/item/{id}), del query ?plan_id en los listados o del campo
+// plan_negocio_id del body en POST/PATCH/PUT. Los catálogos no se filtran.
+func accesoPlan(db *gorm.DB, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		db := db.WithContext(r.Context())
+		u, ok := auth.UsuarioDe(r.Context())
+		if !ok {
+			next.ServeHTTP(w, r)
+			return
+		}
+		segs := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+		rol := rolRequerido(r.Method)
+
+		if segs[0] == "plan" {
+			switch {
+			case len(segs) == 1, len(segs) == 2 && segs[1] == "import":
+				// GET lista solo los planes visibles para el usuario; POST e import asignan el autor
+				next.ServeHTTP(w, r)
+			case len(segs) == 2 && r.Method == http.MethodGet:
+				// /plan/{uid}: solo se pueden listar los planes propios
+				if segs[1] != u.UID {
+					http.Error(w, controllers.ErrSinAcceso.Error(), http.StatusForbidden)
+					return
+				}
+				next.ServeHTTP(w, r)
+			default:
+				id, err := strconv.ParseUint(segs[1], 10, 64)
+				if err != nil {
+					http.Error(w, "invalid id", http.StatusBadRequest)
+					return
+				}
+				if len(segs) == 2 && r.Method == http.MethodDelete {
+					rol = models.RolOwner
+				}
+				if len(segs) >= 3 && segs[2] == "colaboradores" && r.Method != http.MethodGet {
+					rol = models.RolOwner
+				}
+				if len(segs) == 3 && segs[2] == "clone" {
+					// clonar solo lee el plan: el clon pertenece a quien lo pide
+					rol = models.RolViewer
+				}
+				if verificarPlan(db, w, r, u, uint(id), rol) {
+					next.ServeHTTP(w, r)
+				}
+			}
+			return
+		}
+
+		modelo, ok := recursosPorPlan[segs[0]]
+		if !ok {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		var planes []uint
+		switch {
+		case len(segs) == 1 && r.Method == http.MethodGet:
+			pid := r.URL.Query().Get("plan_id")
+			if pid == "" {
+				http.Error(w, "plan_id es requerido", http.StatusBadRequest)
+				return
+			}
+			id, err := strconv.ParseUint(pid, 10, 64)
+			if err != nil {
+				http.Error(w, "invalid plan_id", http.StatusBadRequest)
+				return
+			}
+			planes = append(planes, uint(id))
+		case len(segs) == 1:
+			id, ok, err := planDelBody(r)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			if !ok {
+				http.Error(w, "plan_negocio_id es requerido", http.StatusBadRequest)
+				return
+			}
+			planes = append(planes, id)
+		case segs[1] == "item" && len(segs) == 3:
+			id, err := strconv.ParseUint(segs[2], 10, 64)
+			if err != nil {
+				http.Error(w, "invalid id", http.StatusBadRequest)
+				return
+			}
+			planID, err := controllers.PlanDeFila(db, modelo, uint(id))
+			if err == gorm.ErrRecordNotFound {
+				http.NotFound(w, r)
+				return
+			}
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			planes = append(planes, planID)
+			// un PATCH no puede mover la fila a un plan donde no se es editor
+			if r.Method == http.MethodPatch || r.Method == http.MethodPut {
+				destino, ok, err := planDelBody(r)
+				if err != nil {
+					http.Error(w, err.Error(), http.StatusBadRequest)
+					return
+				}
+				if ok && destino != planID {
+					planes = append(planes, destino)
+				}
+			}
+		case segs[1] == "report_by_plan" && len(segs) == 3:
+			id, err := strconv.ParseUint(segs[2], 10, 64)
+			if err != nil {
+				http.Error(w, "invalid id", http.StatusBadRequest)
+				return
+			}
+			planes = append(planes, uint(id))
+		default:
+			id, err := strconv.ParseUint(segs[1], 10, 64)
+			if err != nil {
+				http.Error(w, "invalid id", http.StatusBadRequest)
+				return
+			}
+			planes = append(planes, uint(id))
+		}
+
+		for _, planID := range planes {
+			if !verificarPlan(db, w, r, u, planID, rol) {
+				return
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// rolRequerido es el rol mínimo según el método: leer basta con viewer,
+// cualquier escritura (incluido disparar un recálculo) exige editor
+func rolRequerido(method string) string {
+	switch method {
+	case http.MethodGet, http.MethodHead:
+		return models.RolViewer
+	}
+	return models.RolEditor
+}
+
+// verificarPlan escribe 404/403 y devuelve false si el usuario no tiene rol en el plan
+func verificarPlan(db *gorm.DB, w http.ResponseWriter, r *http.Request, u auth.Usuario, planID uint, rol string) bool {
+	err := controllers.VerificarAccesoPlan(db, u, planID, rol)
+	switch {
+	case err == nil:
+		return true
+	case err == gorm.ErrRecordNotFound:
+		http.NotFound(w, r)
+	case err == controllers.ErrSinAcceso, err == controllers.ErrRolInsuficiente:
+		http.Error(w, err.Error(), http.StatusForbidden)
+	default:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+	return false
+}
+
+// planDelBody lee plan_negocio_id del body JSON sin consumirlo para el controlador
+func planDelBody(r *http.Request) (uint, bool, error) {
+	if r.Body == nil {
+		return 0, false, nil
+	}
+	raw, err := io.ReadAll(r.Body)
+	if err != nil {
+		return 0, false, err
+	}
+	r.Body = io.NopCloser(bytes.NewReader(raw))
+	var body struct {
+		PlanNegocioID *uint `json:"plan_negocio_id"`
+	}
+	if len(bytes.TrimSpace(raw)) == 0 {
+		return 0, false, nil
+	}
+	if err := json.Unmarshal(raw, &body); err != nil {
+		return 0, false, err
+	}
+	if body.PlanNegocioID == nil {
+		return 0, false, nil
+	}
+	return *body.PlanNegocioID, true, nil
+}
@@ -0,0 +1,141 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/JostinAlvaradoS/liveplan_backend_go/internal/auth"
+	"github.com/JostinAlvaradoS/liveplan_backend_go/internal/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/schema"
+)
+
+// dialectoPrueba es un dialecto sin conexión; las consultas las contesta el
+// callback que registra dbDePrueba
+type dialectoPrueba struct{}
+
+func (dialectoPrueba) Name() string                                   { return "prueba" }
+func (dialectoPrueba) Initialize(*gorm.DB) error                      { return nil }
+func (dialectoPrueba) Migrator(*gorm.DB) gorm.Migrator                { return nil }
+func (dialectoPrueba) DataTypeOf(*schema.Field) string                { return "" }
+func (dialectoPrueba) DefaultValueOf(*schema.Field) clause.Expression { return nil }
+func (dialectoPrueba) BindVarTo(w clause.Writer, _ *gorm.Statement, _ interface{}) {
+	w.WriteByte('?')
+}
+func (dialectoPrueba) QuoteTo(w clause.Writer, s string)           { w.WriteString(s) }
+func (dialectoPrueba) Explain(sql string, _ ...interface{}) string { return sql }
+
+// dbDePrueba contesta las consultas por id: planDeFila[tabla][id] es el
+// plan_negocio_id de la fila y autores[plan] el autor del plan. No hay
+// colaboradores.
+func dbDePrueba(t *testing.T, planDeFila map[string]map[uint]uint, autores map[uint]string) *gorm.DB {
+	t.Helper()
+	db, err := gorm.Open(dialectoPrueba{}, &gorm.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = db.Callback().Query().Register("prueba:query", func(tx *gorm.DB) {
+		id := idConsultado(tx.Statement)
+		switch dest := tx.Statement.Dest.(type) {
+		case *[]uint:
+			if plan, ok := planDeFila[tx.Statement.Table][id]; ok {
+				*dest = append(*dest, plan)
+			}
+		case *models.PlanNegocio:
+			autor, ok := autores[id]
+			if !ok {
+				tx.AddError(gorm.ErrRecordNotFound)
+				return
+			}
+			*dest = models.PlanNegocio{ID: id, Autor: autor}
+		}
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+// idConsultado es el primer valor de la condición WHERE de la consulta
+func idConsultado(stmt *gorm.Statement) uint {
+	where, ok := stmt.Clauses["WHERE"].Expression.(clause.Where)
+	if !ok || len(where.Exprs) == 0 {
+		return 0
+	}
+	var v interface{}
+	switch e := where.Exprs[0].(type) {
+	case clause.Expr:
+		v = e.Vars[0]
+	case clause.IN:
+		v = e.Values[0]
+	case clause.Eq:
+		v = e.Value
+	}
+	var id uint
+	fmt.Sscan(fmt.Sprint(v), &id)
+	return id
+}
+
+// tabla devuelve el nombre de la tabla de un modelo
+func tabla(t *testing.T, db *gorm.DB, modelo interface{}) string {
+	t.Helper()
+	stmt := &gorm.Statement{DB: db}
+	if err := stmt.Parse(modelo); err != nil {
+		t.Fatal(err)
+	}
+	return stmt.Table
+}
+
+// El plan de /{recurso}/item/{id} se resuelve con la tabla que modifica el
+// controlador de la ruta, no con otra que comparta el id.
+func TestAccesoPlanResuelveItem(t *testing.T) {
+	db := dbDePrueba(t, nil, nil)
+	tests := []struct {
+		ruta   string
+		modelo interface{}
+	}{
+		{"datos_prestamos", &models.PrestamoCuotas{}},
+		{"prestamos", &models.DatosPrestamo{}},
+		{"presupuestos_venta", &models.PresupuestoVenta{}},
+		{"variables_sensibilidad", &models.VariablesDeSensibilidad{}},
+	}
+	for _, tt := range tests {
+		modelo, ok := recursosPorPlan[tt.ruta]
+		if !ok {
+			t.Errorf("%s: sin modelo", tt.ruta)
+			continue
+		}
+		if got, want := tabla(t, db, modelo), tabla(t, db, tt.modelo); got != want {
+			t.Errorf("%s/item/{id} resuelve el plan con %s, want %s", tt.ruta, got, want)
+		}
+	}
+
+	// la cuota 5 es del plan 9 (de otro usuario); el préstamo 5 del plan 7 (propio)
+	db = dbDePrueba(t, map[string]map[uint]uint{
+		tabla(t, db, &models.PrestamoCuotas{}): {5: 9},
+		tabla(t, db, &models.DatosPrestamo{}):  {5: 7},
+	}, map[uint]string{7: "yo", 9: "otro"})
+	siguiente := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusOK) })
+	h := accesoPlan(db, siguiente)
+	for _, tt := range []struct {
+		metodo, ruta string
+		want         int
+	}{
+		{http.MethodGet, "/datos_prestamos/item/5", http.StatusForbidden},
+		{http.MethodPatch, "/datos_prestamos/item/5", http.StatusForbidden},
+		{http.MethodDelete, "/datos_prestamos/item/5", http.StatusForbidden},
+		{http.MethodGet, "/prestamos/item/5", http.StatusOK},
+		{http.MethodGet, "/datos_prestamos/item/6", http.StatusNotFound},
+	} {
+		r := httptest.NewRequest(tt.metodo, tt.ruta, nil)
+		r = r.WithContext(auth.ConUsuario(r.Context(), auth.Usuario{UID: "yo"}))
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		if w.Code != tt.want {
+			t.Errorf("%s %s = %d, want %d", tt.metodo, tt.ruta, w.Code, tt.want)
+		}
+	}
+}
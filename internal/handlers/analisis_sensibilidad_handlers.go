@@ -2,13 +2,19 @@ package handlers
 
 import (
 	"net/http"
+	"strconv"
+	"strings"
 
 	"github.com/JostinAlvaradoS/liveplan_backend_go/internal/controllers"
+	"github.com/JostinAlvaradoS/liveplan_backend_go/internal/jobs"
 	"gorm.io/gorm"
 )
 
 func RegisterAnalisisSensibilidadRoutes(mux *http.ServeMux, db *gorm.DB) {
+	calculos := jobs.NewManager()
+
 	mux.HandleFunc("/analisis_sensibilidad", func(w http.ResponseWriter, r *http.Request) {
+		db := db.WithContext(r.Context())
 		switch r.Method {
 		case http.MethodGet:
 			controllers.ListAnalisisSensibilidad(db, w, r)
@@ -20,6 +26,28 @@ func RegisterAnalisisSensibilidadRoutes(mux *http.ServeMux, db *gorm.DB) {
 	})
 
 	mux.HandleFunc("/analisis_sensibilidad/", func(w http.ResponseWriter, r *http.Request) {
+		db := db.WithContext(r.Context())
+		// /analisis_sensibilidad/{plan}/calcular[/{job}]
+		segs := strings.Split(strings.Trim(strings.TrimPrefix(r.URL.Path, "/analisis_sensibilidad/"), "/"), "/")
+		if len(segs) >= 2 && segs[1] == "calcular" {
+			planID, err := strconv.ParseUint(segs[0], 10, 64)
+			if err != nil || len(segs) > 3 {
+				http.Error(w, "invalid id", http.StatusBadRequest)
+				return
+			}
+			switch {
+			case len(segs) == 2 && r.Method == http.MethodPost:
+				controllers.IniciarCalculoSensibilidad(db, calculos, w, r, uint(planID))
+			case len(segs) == 2 && r.Method == http.MethodGet:
+				controllers.GetCalculoSensibilidad(calculos, w, r, uint(planID), "")
+			case len(segs) == 3 && r.Method == http.MethodGet:
+				controllers.GetCalculoSensibilidad(calculos, w, r, uint(planID), segs[2])
+			default:
+				w.WriteHeader(http.StatusMethodNotAllowed)
+			}
+			return
+		}
+
 		id, err := controllers.ParseUintFromPath(r.URL.Path)
 		if err != nil {
 			http.Error(w, "invalid id", http.StatusBadRequest)
@@ -34,6 +62,7 @@ func RegisterAnalisisSensibilidadRoutes(mux *http.ServeMux, db *gorm.DB) {
 	})
 
 	mux.HandleFunc("/analisis_sensibilidad/item/", func(w http.ResponseWriter, r *http.Request) {
+		db := db.WithContext(r.Context())
 		id, err := controllers.ParseUintFromPath(r.URL.Path)
 		if err != nil {
 			http.Error(w, "invalid id", http.StatusBadRequest)
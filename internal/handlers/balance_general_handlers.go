@@ -9,6 +9,7 @@ import (
 
 func RegisterBalanceGeneralRoutes(mux *http.ServeMux, db *gorm.DB) {
 	mux.HandleFunc("/balance_general", func(w http.ResponseWriter, r *http.Request) {
+		db := db.WithContext(r.Context())
 		switch r.Method {
 		case http.MethodGet:
 			controllers.ListBalanceGeneral(db, w, r)
@@ -19,6 +20,7 @@ func RegisterBalanceGeneralRoutes(mux *http.ServeMux, db *gorm.DB) {
 		}
 	})
 	mux.HandleFunc("/balance_general/", func(w http.ResponseWriter, r *http.Request) {
+		db := db.WithContext(r.Context())
 		id, err := controllers.ParseUintFromPath(r.URL.Path)
 		if err != nil {
 			http.Error(w, "invalid id", http.StatusBadRequest)
@@ -32,6 +34,7 @@ func RegisterBalanceGeneralRoutes(mux *http.ServeMux, db *gorm.DB) {
 		}
 	})
 	mux.HandleFunc("/balance_general/item/", func(w http.ResponseWriter, r *http.Request) {
+		db := db.WithContext(r.Context())
 		id, err := controllers.ParseUintFromPath(r.URL.Path)
 		if err != nil {
 			http.Error(w, "invalid id", http.StatusBadRequest)
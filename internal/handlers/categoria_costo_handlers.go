@@ -9,6 +9,7 @@ import (
 
 func RegisterCategoriaCostoRoutes(mux *http.ServeMux, db *gorm.DB) {
 	mux.HandleFunc("/categoria_costo", func(w http.ResponseWriter, r *http.Request) {
+		db := db.WithContext(r.Context())
 		switch r.Method {
 		case http.MethodGet:
 			controllers.ListCategoriaCosto(db, w, r)
@@ -19,6 +20,7 @@ func RegisterCategoriaCostoRoutes(mux *http.ServeMux, db *gorm.DB) {
 		}
 	})
 	mux.HandleFunc("/categoria_costo/item/", func(w http.ResponseWriter, r *http.Request) {
+		db := db.WithContext(r.Context())
 		id, err := controllers.ParseUintFromPath(r.URL.Path)
 		if err != nil {
 			http.Error(w, "invalid id", http.StatusBadRequest)
@@ -9,6 +9,7 @@ import (
 
 func RegisterConceptosEvaluacionRoutes(mux *http.ServeMux, db *gorm.DB) {
 	mux.HandleFunc("/conceptos_evaluacion", func(w http.ResponseWriter, r *http.Request) {
+		db := db.WithContext(r.Context())
 		switch r.Method {
 		case http.MethodGet:
 			controllers.ListConceptosEvaluacion(db, w, r)
@@ -20,6 +21,7 @@ func RegisterConceptosEvaluacionRoutes(mux *http.ServeMux, db *gorm.DB) {
 	})
 
 	mux.HandleFunc("/conceptos_evaluacion/", func(w http.ResponseWriter, r *http.Request) {
+		db := db.WithContext(r.Context())
 		id, err := controllers.ParseUintFromPath(r.URL.Path)
 		if err != nil {
 			http.Error(w, "invalid id", http.StatusBadRequest)
@@ -34,6 +36,7 @@ func RegisterConceptosEvaluacionRoutes(mux *http.ServeMux, db *gorm.DB) {
 	})
 
 	mux.HandleFunc("/conceptos_evaluacion/item/", func(w http.ResponseWriter, r *http.Request) {
+		db := db.WithContext(r.Context())
 		id, err := controllers.ParseUintFromPath(r.URL.Path)
 		if err != nil {
 			http.Error(w, "invalid id", http.StatusBadRequest)
@@ -9,6 +9,7 @@ import (
 
 func RegisterCostosProdServRoutes(mux *http.ServeMux, db *gorm.DB) {
 	mux.HandleFunc("/costos_prodserv", func(w http.ResponseWriter, r *http.Request) {
+		db := db.WithContext(r.Context())
 		switch r.Method {
 		case http.MethodGet:
 			controllers.ListCostosProdServ(db, w, r)
@@ -19,6 +20,7 @@ func RegisterCostosProdServRoutes(mux *http.ServeMux, db *gorm.DB) {
 		}
 	})
 	mux.HandleFunc("/costos_prodserv/", func(w http.ResponseWriter, r *http.Request) {
+		db := db.WithContext(r.Context())
 		id, err := controllers.ParseUintFromPath(r.URL.Path)
 		if err != nil {
 			http.Error(w, "invalid id", http.StatusBadRequest)
@@ -32,6 +34,7 @@ func RegisterCostosProdServRoutes(mux *http.ServeMux, db *gorm.DB) {
 		}
 	})
 	mux.HandleFunc("/costos_prodserv/item/", func(w http.ResponseWriter, r *http.Request) {
+		db := db.WithContext(r.Context())
 		id, err := controllers.ParseUintFromPath(r.URL.Path)
 		if err != nil {
 			http.Error(w, "invalid id", http.StatusBadRequest)
@@ -50,6 +53,7 @@ func RegisterCostosProdServRoutes(mux *http.ServeMux, db *gorm.DB) {
 	})
 
 	mux.HandleFunc("/costos_prodserv/report_by_plan/", func(w http.ResponseWriter, r *http.Request) {
+		db := db.WithContext(r.Context())
 		// path expects plan id after the trailing slash
 		id, err := controllers.ParseUintFromPath(r.URL.Path)
 		if err != nil {
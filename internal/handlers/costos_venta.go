@@ -9,6 +9,7 @@ import (
 
 func RegisterCostosVentas(mux *http.ServeMux, db *gorm.DB) {
 	mux.HandleFunc("/costos_ventas/", func(w http.ResponseWriter, r *http.Request) {
+		db := db.WithContext(r.Context())
 		id, err := controllers.ParseUintFromPath(r.URL.Path)
 		if err != nil {
 			http.Error(w, "invalid id", http.StatusBadRequest)
@@ -9,6 +9,7 @@ import (
 
 func RegisterDepreciacionesRoutes(mux *http.ServeMux, db *gorm.DB) {
 	mux.HandleFunc("/depreciaciones", func(w http.ResponseWriter, r *http.Request) {
+		db := db.WithContext(r.Context())
 		switch r.Method {
 		case http.MethodGet:
 			controllers.ListDepreciaciones(db, w, r)
@@ -20,6 +21,7 @@ func RegisterDepreciacionesRoutes(mux *http.ServeMux, db *gorm.DB) {
 	})
 
 	mux.HandleFunc("/depreciaciones/", func(w http.ResponseWriter, r *http.Request) {
+		db := db.WithContext(r.Context())
 		// expects /depreciaciones/plan/{plan_id}
 		pid, err := controllers.ParseUintFromPath(r.URL.Path)
 		if err != nil {
@@ -34,6 +36,7 @@ func RegisterDepreciacionesRoutes(mux *http.ServeMux, db *gorm.DB) {
 	})
 
 	mux.HandleFunc("/depreciaciones/item/", func(w http.ResponseWriter, r *http.Request) {
+		db := db.WithContext(r.Context())
 		// expects /depreciaciones/item/{id}
 		id, err := controllers.ParseUintFromPath(r.URL.Path)
 		if err != nil {
@@ -9,6 +9,7 @@ import (
 
 func RegisterEvaluacionProyectoRoutes(mux *http.ServeMux, db *gorm.DB) {
 	mux.HandleFunc("/evaluacion_proyecto", func(w http.ResponseWriter, r *http.Request) {
+		db := db.WithContext(r.Context())
 		switch r.Method {
 		case http.MethodGet:
 			controllers.ListEvaluacionProyecto(db, w, r)
@@ -20,6 +21,7 @@ func RegisterEvaluacionProyectoRoutes(mux *http.ServeMux, db *gorm.DB) {
 	})
 
 	mux.HandleFunc("/evaluacion_proyecto/", func(w http.ResponseWriter, r *http.Request) {
+		db := db.WithContext(r.Context())
 		id, err := controllers.ParseUintFromPath(r.URL.Path)
 		if err != nil {
 			http.Error(w, "invalid id", http.StatusBadRequest)
@@ -34,6 +36,7 @@ func RegisterEvaluacionProyectoRoutes(mux *http.ServeMux, db *gorm.DB) {
 	})
 
 	mux.HandleFunc("/evaluacion_proyecto/item/", func(w http.ResponseWriter, r *http.Request) {
+		db := db.WithContext(r.Context())
 		id, err := controllers.ParseUintFromPath(r.URL.Path)
 		if err != nil {
 			http.Error(w, "invalid id", http.StatusBadRequest)
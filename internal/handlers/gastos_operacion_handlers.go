@@ -9,6 +9,7 @@ import (
 
 func RegisterGastosOperacionRoutes(mux *http.ServeMux, db *gorm.DB) {
 	mux.HandleFunc("/gastos_operacion", func(w http.ResponseWriter, r *http.Request) {
+		db := db.WithContext(r.Context())
 		switch r.Method {
 		case http.MethodGet:
 			controllers.ListGastosOperacion(db, w, r)
@@ -17,6 +18,7 @@ func RegisterGastosOperacionRoutes(mux *http.ServeMux, db *gorm.DB) {
 		}
 	})
 	mux.HandleFunc("/gastos_operacion/", func(w http.ResponseWriter, r *http.Request) {
+		db := db.WithContext(r.Context())
 		// this endpoint expects a plan id (e.g. /gastos_operacion/123) and returns
 		// gastos operacion for that plan
 		id, err := controllers.ParseUintFromPath(r.URL.Path)
@@ -33,6 +35,7 @@ func RegisterGastosOperacionRoutes(mux *http.ServeMux, db *gorm.DB) {
 	})
 
 	mux.HandleFunc("/gastos_operacion/item/", func(w http.ResponseWriter, r *http.Request) {
+		db := db.WithContext(r.Context())
 		// item-level operations: GET, PATCH, DELETE
 		id, err := controllers.ParseUintFromPath(r.URL.Path)
 		if err != nil {
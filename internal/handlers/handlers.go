@@ -6,11 +6,14 @@ import (
 	"os"
 	"strings"
 
+	"github.com/JostinAlvaradoS/liveplan_backend_go/internal/auth"
 	"gorm.io/gorm"
 )
 
 type App struct {
 	DB *gorm.DB
+	// Auth verifica los tokens Bearer; nil desactiva la autenticación
+	Auth *auth.Verifier
 }
 
 func corsMiddleware(next http.Handler) http.Handler {
@@ -38,6 +41,7 @@ func corsMiddleware(next http.Handler) http.Handler {
 		w.Header().Set("Access-Control-Allow-Credentials", "true")
 		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization, X-Requested-With, X-Custom-Header")
 		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS, PATCH")
+		w.Header().Set("Access-Control-Expose-Headers", "X-Recalculo, X-Recalculo-Id")
 
 		if r.Method == http.MethodOptions {
 			w.WriteHeader(http.StatusNoContent)
@@ -56,7 +60,9 @@ func (a *App) Routes() http.Handler {
 		w.Write([]byte("ok"))
 	})
 
-	// Delegate registration to per-table handler registrars
+	// Delegate registration to per-table handler registrars. Cada ruta usa
+	// db.WithContext(r.Context()): si el cliente se desconecta o vence el plazo
+	// del proxy, las consultas y el recálculo de la petición se cancelan.
 	RegisterPlanNegocioRoutes(mux, a.DB)
 	RegisterTiposInversionRoutes(mux, a.DB)
 	RegisterProductoServicioRoutes(mux, a.DB)
@@ -85,5 +91,10 @@ func (a *App) Routes() http.Handler {
 	RegisterEvaluacionProyectoRoutes(mux, a.DB)
 	RegisterAnalisisSensibilidadRoutes(mux, a.DB)
 
-	return corsMiddleware(mux)
+	var handler http.Handler = mux
+	if a.Auth != nil {
+		// token Bearer obligatorio y acceso solo a los planes propios
+		handler = a.Auth.Middleware(accesoPlan(a.DB, mux))
+	}
+	return corsMiddleware(handler)
 }
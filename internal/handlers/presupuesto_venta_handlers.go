@@ -9,6 +9,7 @@ import (
 
 func RegisterPresupuestoVentaRoutes(mux *http.ServeMux, db *gorm.DB) {
 	mux.HandleFunc("/presupuestos_venta", func(w http.ResponseWriter, r *http.Request) {
+		db := db.WithContext(r.Context())
 		switch r.Method {
 		case http.MethodGet:
 			controllers.ListPresupuestosVenta(db, w, r)
@@ -20,6 +21,7 @@ func RegisterPresupuestoVentaRoutes(mux *http.ServeMux, db *gorm.DB) {
 	})
 
 	mux.HandleFunc("/presupuestos_venta/", func(w http.ResponseWriter, r *http.Request) {
+		db := db.WithContext(r.Context())
 		pid, err := controllers.ParseUintFromPath(r.URL.Path)
 		if err != nil {
 			http.NotFound(w, r)
@@ -33,6 +35,7 @@ func RegisterPresupuestoVentaRoutes(mux *http.ServeMux, db *gorm.DB) {
 	})
 
 	mux.HandleFunc("/presupuestos_venta/item/", func(w http.ResponseWriter, r *http.Request) {
+		db := db.WithContext(r.Context())
 		id, err := controllers.ParseUintFromPath(r.URL.Path)
 		if err != nil {
 			http.NotFound(w, r)
@@ -2,6 +2,8 @@ package handlers
 
 import (
 	"net/http"
+	"strconv"
+	"strings"
 
 	"github.com/JostinAlvaradoS/liveplan_backend_go/internal/controllers"
 	"gorm.io/gorm"
@@ -9,6 +11,7 @@ import (
 
 func RegisterTiposInversionRoutes(mux *http.ServeMux, db *gorm.DB) {
 	mux.HandleFunc("/tipos_inversion", func(w http.ResponseWriter, r *http.Request) {
+		db := db.WithContext(r.Context())
 		switch r.Method {
 		case http.MethodGet:
 			controllers.ListTipos(db, w, r)
@@ -22,6 +25,7 @@ func RegisterTiposInversionRoutes(mux *http.ServeMux, db *gorm.DB) {
 
 func RegisterPlanNegocioRoutes(mux *http.ServeMux, db *gorm.DB) {
 	mux.HandleFunc("/plan", func(w http.ResponseWriter, r *http.Request) {
+		db := db.WithContext(r.Context())
 		switch r.Method {
 		case http.MethodGet:
 			controllers.ListPlanNegocios(db, w, r)
@@ -32,12 +36,30 @@ func RegisterPlanNegocioRoutes(mux *http.ServeMux, db *gorm.DB) {
 		}
 	})
 	mux.HandleFunc("/plan/", func(w http.ResponseWriter, r *http.Request) {
-		// path is /plan/{idOrUid}
+		db := db.WithContext(r.Context())
+		// path is /plan/{idOrUid} or /plan/{id}/{subrecurso}/...
 		seg := r.URL.Path[len("/plan/"):]
 		if seg == "" {
 			http.Error(w, "missing id", http.StatusBadRequest)
 			return
 		}
+		if seg == "import" {
+			if r.Method != http.MethodPost {
+				w.WriteHeader(http.StatusMethodNotAllowed)
+				return
+			}
+			controllers.ImportarPlanNegocio(db, w, r)
+			return
+		}
+		if segs := strings.Split(strings.Trim(seg, "/"), "/"); len(segs) > 1 {
+			planID, err := strconv.ParseUint(segs[0], 10, 64)
+			if err != nil {
+				http.Error(w, "invalid id", http.StatusBadRequest)
+				return
+			}
+			subrutasPlan(db, w, r, uint(planID), segs[1:])
+			return
+		}
 
 		switch r.Method {
 		case http.MethodGet:
@@ -68,3 +90,182 @@ func RegisterPlanNegocioRoutes(mux *http.ServeMux, db *gorm.DB) {
 		}
 	})
 }
+
+// subrutasPlan atiende /plan/{id}/{subrecurso}/...
+func subrutasPlan(db *gorm.DB, w http.ResponseWriter, r *http.Request, planID uint, segs []string) {
+	switch segs[0] {
+	case "colaboradores":
+		// /plan/{id}/colaboradores[/{colaborador}]
+		switch {
+		case len(segs) == 1 && r.Method == http.MethodGet:
+			controllers.ListColaboradoresByPlan(db, w, r, planID)
+		case len(segs) == 1 && r.Method == http.MethodPost:
+			controllers.InvitarColaborador(db, w, r, planID)
+		case len(segs) == 2 && r.Method == http.MethodDelete:
+			id, err := strconv.ParseUint(segs[1], 10, 64)
+			if err != nil {
+				http.Error(w, "invalid id", http.StatusBadRequest)
+				return
+			}
+			controllers.RevocarColaborador(db, w, r, planID, uint(id))
+		default:
+			w.WriteHeader(http.StatusMethodNotAllowed)
+		}
+	case "clone":
+		if len(segs) != 1 || r.Method != http.MethodPost {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		controllers.ClonarPlanNegocio(db, w, r, planID)
+	case "export":
+		if len(segs) != 1 || r.Method != http.MethodGet {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		controllers.ExportarPlanNegocio(db, w, r, planID)
+	case "export.xlsx":
+		if len(segs) != 1 || r.Method != http.MethodGet {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		controllers.ExportarPlanExcel(db, w, r, planID)
+	case "report.pdf":
+		if len(segs) != 1 || r.Method != http.MethodGet {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		controllers.ReportePlanPDF(db, w, r, planID)
+	case "integridad":
+		if len(segs) != 1 || r.Method != http.MethodGet {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		controllers.GetIntegridadPlan(db, w, r, planID)
+	case "tornado":
+		if len(segs) != 1 || r.Method != http.MethodGet {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		controllers.GetTornadoPlan(db, w, r, planID)
+	case "punto_equilibrio":
+		if len(segs) != 1 || r.Method != http.MethodGet {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		controllers.GetPuntoEquilibrioPlan(db, w, r, planID)
+	case "razones":
+		if len(segs) != 1 || r.Method != http.MethodGet {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		controllers.GetRazonesPlan(db, w, r, planID)
+	case "recalculo":
+		if len(segs) != 1 || r.Method != http.MethodGet {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		controllers.GetRecalculoPlan(db, w, r, planID)
+	case "snapshots":
+		// /plan/{id}/snapshots[/{snapshot}[/compare/{otra}]]
+		ids := make([]uint, 0, 2)
+		for _, i := range []int{1, 3} {
+			if len(segs) > i {
+				id, err := strconv.ParseUint(segs[i], 10, 64)
+				if err != nil {
+					http.Error(w, "invalid id", http.StatusBadRequest)
+					return
+				}
+				ids = append(ids, uint(id))
+			}
+		}
+		switch {
+		case len(segs) == 1 && r.Method == http.MethodGet:
+			controllers.ListSnapshotsByPlan(db, w, r, planID)
+		case len(segs) == 1 && r.Method == http.MethodPost:
+			controllers.CrearSnapshot(db, w, r, planID)
+		case len(segs) == 2 && r.Method == http.MethodGet:
+			controllers.GetSnapshot(db, w, r, planID, ids[0])
+		case len(segs) == 2 && r.Method == http.MethodDelete:
+			controllers.DeleteSnapshot(db, w, r, planID, ids[0])
+		case len(segs) == 4 && segs[2] == "compare" && r.Method == http.MethodGet:
+			controllers.CompararSnapshots(db, w, r, planID, ids[0], ids[1])
+		default:
+			w.WriteHeader(http.StatusMethodNotAllowed)
+		}
+	case "escenarios":
+		// /plan/{id}/escenarios[/{escenario}], /plan/{id}/escenarios/comparar
+		if len(segs) == 2 && segs[1] == "comparar" {
+			if r.Method != http.MethodGet {
+				w.WriteHeader(http.StatusMethodNotAllowed)
+				return
+			}
+			controllers.CompararEscenarios(db, w, r, planID)
+			return
+		}
+		var id uint
+		if len(segs) == 2 {
+			v, err := strconv.ParseUint(segs[1], 10, 64)
+			if err != nil {
+				http.Error(w, "invalid id", http.StatusBadRequest)
+				return
+			}
+			id = uint(v)
+		}
+		switch {
+		case len(segs) == 1 && r.Method == http.MethodGet:
+			controllers.ListEscenariosByPlan(db, w, r, planID)
+		case len(segs) == 1 && r.Method == http.MethodPost:
+			controllers.CrearEscenario(db, w, r, planID)
+		case len(segs) == 2 && r.Method == http.MethodGet:
+			controllers.GetEscenario(db, w, r, planID, id)
+		case len(segs) == 2 && r.Method == http.MethodPatch:
+			controllers.UpdateEscenarioPatch(db, w, r, planID, id)
+		case len(segs) == 2 && r.Method == http.MethodDelete:
+			controllers.DeleteEscenario(db, w, r, planID, id)
+		default:
+			w.WriteHeader(http.StatusMethodNotAllowed)
+		}
+	case "montecarlo":
+		// /plan/{id}/montecarlo[/{simulacion}]
+		var id uint
+		if len(segs) == 2 {
+			v, err := strconv.ParseUint(segs[1], 10, 64)
+			if err != nil {
+				http.Error(w, "invalid id", http.StatusBadRequest)
+				return
+			}
+			id = uint(v)
+		}
+		switch {
+		case len(segs) == 1 && r.Method == http.MethodGet:
+			controllers.ListSimulacionesByPlan(db, w, r, planID)
+		case len(segs) == 1 && r.Method == http.MethodPost:
+			controllers.SimularMonteCarlo(db, w, r, planID)
+		case len(segs) == 2 && r.Method == http.MethodGet:
+			controllers.GetSimulacion(db, w, r, planID, id)
+		case len(segs) == 2 && r.Method == http.MethodDelete:
+			controllers.DeleteSimulacion(db, w, r, planID, id)
+		default:
+			w.WriteHeader(http.StatusMethodNotAllowed)
+		}
+	case "historial":
+		// /plan/{id}/historial, /plan/{id}/historial/revertir, /plan/{id}/historial/{cambio}/revertir
+		switch {
+		case len(segs) == 1 && r.Method == http.MethodGet:
+			controllers.GetHistorialPlan(db, w, r, planID)
+		case len(segs) == 2 && segs[1] == "revertir" && r.Method == http.MethodPost:
+			controllers.RevertirPlanAFecha(db, w, r, planID)
+		case len(segs) == 3 && segs[2] == "revertir" && r.Method == http.MethodPost:
+			id, err := strconv.ParseUint(segs[1], 10, 64)
+			if err != nil {
+				http.Error(w, "invalid id", http.StatusBadRequest)
+				return
+			}
+			controllers.RevertirCambio(db, w, r, planID, uint(id))
+		default:
+			w.WriteHeader(http.StatusMethodNotAllowed)
+		}
+	default:
+		http.NotFound(w, r)
+	}
+}
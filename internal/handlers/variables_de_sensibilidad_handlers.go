@@ -9,6 +9,7 @@ import (
 
 func RegisterVariablesDeSensibilidadRoutes(mux *http.ServeMux, db *gorm.DB) {
 	mux.HandleFunc("/variables_sensibilidad", func(w http.ResponseWriter, r *http.Request) {
+		db := db.WithContext(r.Context())
 		switch r.Method {
 		case http.MethodGet:
 			controllers.ListVariablesDeSensibilidad(db, w, r)
@@ -19,6 +20,7 @@ func RegisterVariablesDeSensibilidadRoutes(mux *http.ServeMux, db *gorm.DB) {
 		}
 	})
 	mux.HandleFunc("/variables_sensibilidad/", func(w http.ResponseWriter, r *http.Request) {
+		db := db.WithContext(r.Context())
 		id, err := controllers.ParseUintFromPath(r.URL.Path)
 		if err != nil {
 			http.Error(w, "invalid id", http.StatusBadRequest)
@@ -32,6 +34,7 @@ func RegisterVariablesDeSensibilidadRoutes(mux *http.ServeMux, db *gorm.DB) {
 		}
 	})
 	mux.HandleFunc("/variables_sensibilidad/item/", func(w http.ResponseWriter, r *http.Request) {
+		db := db.WithContext(r.Context())
 		id, err := controllers.ParseUintFromPath(r.URL.Path)
 		if err != nil {
 			http.Error(w, "invalid id", http.StatusBadRequest)
@@ -0,0 +1,180 @@
+package jobs
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+)
+
+// Estados posibles de un trabajo
+const (
+	EstadoPendiente  = "pendiente"
+	EstadoEjecutando = "ejecutando"
+	EstadoCompletado = "completado"
+	EstadoError      = "error"
+)
+
+// retencion indica cuánto tiempo se conserva un trabajo terminado para consultar su estado
+const retencion = time.Hour
+
+// ErrEnCurso se devuelve cuando ya hay un trabajo del mismo tipo en curso para el plan
+var ErrEnCurso = errors.New("ya hay un trabajo en curso para este plan")
+
+// Job es el estado público de un trabajo en segundo plano
+type Job struct {
+	ID            string     `json:"id"`
+	Tipo          string     `json:"tipo"`
+	PlanNegocioID uint       `json:"plan_negocio_id"`
+	Estado        string     `json:"estado"`
+	Hechas        int        `json:"hechas"`
+	Total         int        `json:"total"`
+	Progreso      float64    `json:"progreso"`
+	Error         string     `json:"error,omitempty"`
+	Creado        time.Time  `json:"creado"`
+	Iniciado      *time.Time `json:"iniciado,omitempty"`
+	Terminado     *time.Time `json:"terminado,omitempty"`
+}
+
+// Progreso es la función que el trabajo llama para reportar avance (hechas de total)
+type Progreso func(hechas, total int)
+
+// Manager mantiene en memoria los trabajos lanzados por el proceso. No se
+// comparten entre réplicas ni sobreviven a un reinicio: cada réplica solo
+// conoce y limita (ErrEnCurso) los trabajos que lanzó ella.
+type Manager struct {
+	mu   sync.Mutex
+	jobs map[string]*Job
+}
+
+// NewManager crea un Manager vacío
+func NewManager() *Manager {
+	return &Manager{jobs: make(map[string]*Job)}
+}
+
+// Iniciar lanza fn en una goroutine y devuelve el trabajo creado. Solo se permite
+// un trabajo del mismo tipo en curso por plan; si ya existe devuelve ErrEnCurso
+// junto con el trabajo existente.
+func (m *Manager) Iniciar(tipo string, planID uint, total int, fn func(progreso Progreso) error) (Job, error) {
+	m.mu.Lock()
+	m.purgar()
+	for _, j := range m.jobs {
+		if j.Tipo == tipo && j.PlanNegocioID == planID && (j.Estado == EstadoPendiente || j.Estado == EstadoEjecutando) {
+			actual := *j
+			m.mu.Unlock()
+			return actual, ErrEnCurso
+		}
+	}
+	id, err := nuevoID()
+	if err != nil {
+		m.mu.Unlock()
+		return Job{}, err
+	}
+	j := &Job{
+		ID:            id,
+		Tipo:          tipo,
+		PlanNegocioID: planID,
+		Estado:        EstadoPendiente,
+		Total:         total,
+		Creado:        time.Now(),
+	}
+	m.jobs[id] = j
+	creado := *j
+	m.mu.Unlock()
+
+	go m.ejecutar(j, fn)
+	return creado, nil
+}
+
+func (m *Manager) ejecutar(j *Job, fn func(progreso Progreso) error) {
+	m.mu.Lock()
+	ahora := time.Now()
+	j.Estado = EstadoEjecutando
+	j.Iniciado = &ahora
+	m.mu.Unlock()
+
+	progreso := func(hechas, total int) {
+		m.mu.Lock()
+		defer m.mu.Unlock()
+		j.Hechas = hechas
+		if total > 0 {
+			j.Total = total
+			j.Progreso = float64(hechas) / float64(total)
+		}
+	}
+
+	var err error
+	func() {
+		defer func() {
+			if rec := recover(); rec != nil {
+				err = fmt.Errorf("panic: %v", rec)
+			}
+		}()
+		err = fn(progreso)
+	}()
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	fin := time.Now()
+	j.Terminado = &fin
+	if err != nil {
+		log.Printf("jobs: %s %s plan %d terminó con error: %v", j.Tipo, j.ID, j.PlanNegocioID, err)
+		j.Estado = EstadoError
+		j.Error = err.Error()
+		return
+	}
+	j.Estado = EstadoCompletado
+	j.Hechas = j.Total
+	j.Progreso = 1
+}
+
+// Obtener devuelve una copia del trabajo con el id dado
+func (m *Manager) Obtener(id string) (Job, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	j, ok := m.jobs[id]
+	if !ok {
+		return Job{}, false
+	}
+	return *j, true
+}
+
+// Ultimo devuelve el trabajo más reciente del tipo indicado para el plan
+func (m *Manager) Ultimo(tipo string, planID uint) (Job, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var ultimo *Job
+	for _, j := range m.jobs {
+		if j.Tipo != tipo || j.PlanNegocioID != planID {
+			continue
+		}
+		if ultimo == nil || j.Creado.After(ultimo.Creado) {
+			ultimo = j
+		}
+	}
+	if ultimo == nil {
+		return Job{}, false
+	}
+	return *ultimo, true
+}
+
+// purgar elimina los trabajos terminados hace más de la retención. Requiere m.mu.
+func (m *Manager) purgar() {
+	limite := time.Now().Add(-retencion)
+	for id, j := range m.jobs {
+		if j.Terminado != nil && j.Terminado.Before(limite) {
+			delete(m.jobs, id)
+		}
+	}
+}
+
+func nuevoID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
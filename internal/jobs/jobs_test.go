@@ -0,0 +1,131 @@
+package jobs
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+// esperarEstado espera a que el trabajo llegue a estado o falla tras un segundo
+func esperarEstado(t *testing.T, m *Manager, id, estado string) Job {
+	t.Helper()
+	limite := time.Now().Add(time.Second)
+	for {
+		j, ok := m.Obtener(id)
+		if !ok {
+			t.Fatalf("trabajo %s no encontrado", id)
+		}
+		if j.Estado == estado {
+			return j
+		}
+		if time.Now().After(limite) {
+			t.Fatalf("trabajo %s en estado %s, want %s", id, j.Estado, estado)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestIniciar(t *testing.T) {
+	m := NewManager()
+	avanzo, seguir := make(chan struct{}), make(chan struct{})
+	j, err := m.Iniciar("sensibilidad", 7, 4, func(progreso Progreso) error {
+		progreso(1, 4)
+		close(avanzo)
+		<-seguir
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if j.ID == "" || j.Tipo != "sensibilidad" || j.PlanNegocioID != 7 || j.Total != 4 || j.Creado.IsZero() {
+		t.Errorf("trabajo creado = %+v", j)
+	}
+
+	<-avanzo
+	en := esperarEstado(t, m, j.ID, EstadoEjecutando)
+	if en.Hechas != 1 || en.Progreso != 0.25 || en.Iniciado == nil || en.Terminado != nil {
+		t.Errorf("en curso = %+v", en)
+	}
+	if u, ok := m.Ultimo("sensibilidad", 7); !ok || u.ID != j.ID {
+		t.Errorf("Ultimo = %+v, %v", u, ok)
+	}
+
+	close(seguir)
+	fin := esperarEstado(t, m, j.ID, EstadoCompletado)
+	if fin.Hechas != 4 || fin.Progreso != 1 || fin.Terminado == nil || fin.Error != "" {
+		t.Errorf("terminado = %+v", fin)
+	}
+}
+
+func TestIniciarErrores(t *testing.T) {
+	m := NewManager()
+	tests := []struct {
+		nombre string
+		fn     func(Progreso) error
+		error  string
+	}{
+		{"error", func(Progreso) error { return errors.New("sin matriz") }, "sin matriz"},
+		{"panic", func(Progreso) error { panic("índice fuera de rango") }, "panic: índice fuera de rango"},
+	}
+	for i, tt := range tests {
+		j, err := m.Iniciar("sensibilidad", uint(i+1), 1, tt.fn)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if fin := esperarEstado(t, m, j.ID, EstadoError); fin.Error != tt.error || fin.Terminado == nil {
+			t.Errorf("%s: %+v", tt.nombre, fin)
+		}
+	}
+}
+
+// Solo un trabajo del mismo tipo en curso por plan
+func TestIniciarEnCurso(t *testing.T) {
+	m := NewManager()
+	seguir := make(chan struct{})
+	bloqueado := func(Progreso) error { <-seguir; return nil }
+	primero, err := m.Iniciar("sensibilidad", 7, 1, bloqueado)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	otro, err := m.Iniciar("sensibilidad", 7, 1, bloqueado)
+	if !errors.Is(err, ErrEnCurso) || otro.ID != primero.ID {
+		t.Errorf("mismo plan: %+v, err = %v, want el trabajo %s y ErrEnCurso", otro, err, primero.ID)
+	}
+	for _, c := range []struct {
+		tipo string
+		plan uint
+	}{{"sensibilidad", 8}, {"montecarlo", 7}} {
+		if _, err := m.Iniciar(c.tipo, c.plan, 1, func(Progreso) error { return nil }); err != nil {
+			t.Errorf("%s plan %d: err = %v", c.tipo, c.plan, err)
+		}
+	}
+
+	close(seguir)
+	esperarEstado(t, m, primero.ID, EstadoCompletado)
+	nuevo, err := m.Iniciar("sensibilidad", 7, 1, func(Progreso) error { return nil })
+	if err != nil || nuevo.ID == primero.ID {
+		t.Errorf("después de terminar: %+v, err = %v", nuevo, err)
+	}
+}
+
+// Iniciar olvida los trabajos terminados hace más de la retención
+func TestPurgar(t *testing.T) {
+	m := NewManager()
+	hace := func(d time.Duration) *time.Time {
+		f := time.Now().Add(-d)
+		return &f
+	}
+	m.jobs["viejo"] = &Job{ID: "viejo", Tipo: "sensibilidad", PlanNegocioID: 1, Estado: EstadoCompletado, Terminado: hace(retencion + time.Minute)}
+	m.jobs["reciente"] = &Job{ID: "reciente", Tipo: "sensibilidad", PlanNegocioID: 1, Estado: EstadoError, Terminado: hace(retencion - time.Minute)}
+	m.jobs["colgado"] = &Job{ID: "colgado", Tipo: "sensibilidad", PlanNegocioID: 2, Estado: EstadoEjecutando, Creado: *hace(3 * retencion)}
+
+	if _, err := m.Iniciar("sensibilidad", 1, 1, func(Progreso) error { return nil }); err != nil {
+		t.Fatal(err)
+	}
+	for id, want := range map[string]bool{"viejo": false, "reciente": true, "colgado": true} {
+		if _, ok := m.Obtener(id); ok != want {
+			t.Errorf("%s: existe = %v, want %v", id, ok, want)
+		}
+	}
+}
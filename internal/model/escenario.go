@@ -0,0 +1,90 @@
+package model
+
+import "github.com/JostinAlvaradoS/liveplan_backend_go/internal/models"
+
+// ApplyScenario devuelve una copia de in con los reemplazos del escenario y los
+// choques de volumen, precio y costo que hay que pasar a Compute. in no se
+// modifica:
+//
+//   - Anios reemplaza PresupuestoVenta.Crecimiento de esos años en todos los productos
+//   - los indicadores, la composición, el préstamo y la TREMA con valor reemplazan los del plan
+//   - si solo se da capital o deuda, el otro porcentaje es el complemento a 100
+//
+// Al cambiar el financiamiento se descarta la cuota guardada del préstamo para
+// que ComputeLoan la vuelva a calcular.
+func ApplyScenario(in Inputs, e models.Escenario) (Inputs, Shocks) {
+	out := in
+
+	if len(e.Anios) > 0 {
+		crecimiento := make(map[int]float64, len(e.Anios))
+		for _, a := range e.Anios {
+			crecimiento[a.Anio] = a.Crecimiento
+		}
+		out.Presupuestos = append([]models.PresupuestoVenta(nil), in.Presupuestos...)
+		for i, p := range out.Presupuestos {
+			if c, ok := crecimiento[p.Anio]; ok {
+				out.Presupuestos[i].Crecimiento = ptr(c)
+			}
+		}
+	}
+
+	if e.Inflacion != nil {
+		out.Indicadores.Inflacion = *e.Inflacion
+	}
+	if e.TasaImpuesto != nil {
+		out.Indicadores.TasaImpuesto = *e.TasaImpuesto
+	}
+	if e.PTU != nil {
+		out.Indicadores.PTU = *e.PTU
+	}
+	if e.DiasxMes != nil {
+		out.Indicadores.DiasxMes = *e.DiasxMes
+	}
+
+	financiamiento := false
+	switch {
+	case e.CapitalPorcentaje != nil && e.DeudaPorcentaje != nil:
+		out.Composicion.CapitalPorcentaje = *e.CapitalPorcentaje
+		out.Composicion.DeudaPorcentaje = *e.DeudaPorcentaje
+		financiamiento = true
+	case e.CapitalPorcentaje != nil:
+		out.Composicion.CapitalPorcentaje = *e.CapitalPorcentaje
+		out.Composicion.DeudaPorcentaje = 100 - *e.CapitalPorcentaje
+		financiamiento = true
+	case e.DeudaPorcentaje != nil:
+		out.Composicion.DeudaPorcentaje = *e.DeudaPorcentaje
+		out.Composicion.CapitalPorcentaje = 100 - *e.DeudaPorcentaje
+		financiamiento = true
+	}
+	if financiamiento && out.Composicion.DeudaPorcentaje <= 0 {
+		// sin deuda ComputeLoan conservaría el monto capturado
+		out.Prestamo.Monto = 0
+	}
+	if e.TasaAnual != nil {
+		fijarTasaAnual(&out.Prestamo, *e.TasaAnual)
+		financiamiento = true
+	}
+	if e.PeriodosAmortizacion != nil {
+		out.Prestamo.PeriodosAmortizacion = *e.PeriodosAmortizacion
+		financiamiento = true
+	}
+	if financiamiento {
+		out.Prestamo.Cuota = 0
+	}
+
+	if e.TREMA != nil {
+		out.Evaluacion.TREMA = *e.TREMA
+	}
+	return out, Shocks{Volumen: e.Volumen, Precio: e.Precio, Costo: e.Costo}
+}
+
+// fijarTasaAnual cambia la tasa anual del préstamo. La tasa mensual guardada se
+// vuelve a derivar de la anual; sin periodos de capitalización se usan 12.
+func fijarTasaAnual(dp *models.DatosPrestamo, tasa float64) {
+	dp.TasaAnual = tasa
+	if dp.PeriodosCapitalizacion <= 0 {
+		dp.PeriodosCapitalizacion = 12
+	}
+	dp.TasaMensual = tasa / float64(dp.PeriodosCapitalizacion)
+	dp.Cuota = 0
+}
@@ -0,0 +1,76 @@
+package model
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/JostinAlvaradoS/liveplan_backend_go/internal/models"
+)
+
+func TestApplyScenarioFinanciamiento(t *testing.T) {
+	tests := []struct {
+		nombre         string
+		e              models.Escenario
+		capital, deuda float64
+		monto, cuota   float64
+		tasa, mensual  float64
+		periodos       int
+	}{
+		{"sin cambios conserva la cuota", models.Escenario{}, 50, 50, 7500, 250, 12, 1, 24},
+		{"solo capital: la deuda es el complemento", models.Escenario{CapitalPorcentaje: ptr(70)}, 70, 30, 7500, 0, 12, 1, 24},
+		{"solo deuda: el capital es el complemento", models.Escenario{DeudaPorcentaje: ptr(80)}, 20, 80, 7500, 0, 12, 1, 24},
+		{"ambos porcentajes", models.Escenario{CapitalPorcentaje: ptr(40), DeudaPorcentaje: ptr(60)}, 40, 60, 7500, 0, 12, 1, 24},
+		// sin deuda ComputeLoan conservaría el monto capturado
+		{"deuda cero vacía el monto", models.Escenario{CapitalPorcentaje: ptr(100)}, 100, 0, 0, 0, 12, 1, 24},
+		{"deuda cero explícita", models.Escenario{DeudaPorcentaje: ptr(0)}, 100, 0, 0, 0, 12, 1, 24},
+		{"tasa anual", models.Escenario{TasaAnual: ptr(18)}, 50, 50, 7500, 0, 18, 1.5, 24},
+		{"periodos de amortización", models.Escenario{PeriodosAmortizacion: intPtr(36)}, 50, 50, 7500, 0, 12, 1, 36},
+	}
+	for _, tt := range tests {
+		t.Run(tt.nombre, func(t *testing.T) {
+			in := planDePrueba(2)
+			in.Prestamo.Monto = 7500
+			in.Prestamo.Cuota = 250
+			in.Prestamo.TasaMensual = 1
+			in.Prestamo.PeriodosAmortizacion = 24
+			out, _ := ApplyScenario(in, tt.e)
+			c, p := out.Composicion, out.Prestamo
+			if c.CapitalPorcentaje != tt.capital || c.DeudaPorcentaje != tt.deuda {
+				t.Errorf("capital/deuda = %v/%v, want %v/%v", c.CapitalPorcentaje, c.DeudaPorcentaje, tt.capital, tt.deuda)
+			}
+			if p.Monto != tt.monto || p.Cuota != tt.cuota || p.TasaAnual != tt.tasa || !cerca(p.TasaMensual, tt.mensual) || p.PeriodosAmortizacion != tt.periodos {
+				t.Errorf("préstamo = %+v", p)
+			}
+		})
+	}
+}
+
+func TestApplyScenarioNoModificaEntradas(t *testing.T) {
+	in := planDePrueba(2)
+	e := models.Escenario{
+		Volumen: 10, Precio: -5, Costo: 3,
+		Inflacion: ptr(7), TasaImpuesto: ptr(30), PTU: ptr(0), DiasxMes: intPtr(26),
+		DeudaPorcentaje: ptr(0), TasaAnual: ptr(20), PeriodosAmortizacion: intPtr(12), TREMA: ptr(15),
+		Anios: []models.EscenarioAnio{{Anio: 2, Crecimiento: 25}},
+	}
+	out, shocks := ApplyScenario(in, e)
+	if !reflect.DeepEqual(in, planDePrueba(2)) {
+		t.Error("ApplyScenario modificó las entradas")
+	}
+	if !reflect.DeepEqual(shocks, Shocks{Volumen: 10, Precio: -5, Costo: 3}) {
+		t.Errorf("choques = %+v", shocks)
+	}
+	ind := out.Indicadores
+	if ind.Inflacion != 7 || ind.TasaImpuesto != 30 || ind.PTU != 0 || ind.DiasxMes != 26 || out.Evaluacion.TREMA != 15 {
+		t.Errorf("indicadores = %+v, trema = %v", ind, out.Evaluacion.TREMA)
+	}
+	for _, p := range out.Presupuestos {
+		want := 5.0
+		if p.Anio == 2 {
+			want = 25
+		}
+		if p.Crecimiento == nil || *p.Crecimiento != want {
+			t.Errorf("crecimiento del año %d = %v, want %v", p.Anio, p.Crecimiento, want)
+		}
+	}
+}
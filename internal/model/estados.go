@@ -0,0 +1,447 @@
+package model
+
+import (
+	"sort"
+
+	"github.com/JostinAlvaradoS/liveplan_backend_go/internal/models"
+)
+
+// IncomeStatementInputs agrupa lo necesario para calcular el estado de resultados
+type IncomeStatementInputs struct {
+	PlanID         uint
+	Horizonte      int
+	Existentes     []models.EstadoResultados
+	Ventas         []models.Ventas
+	CostosVentas   []models.CostosVentas
+	Gastos         []models.GastosOperacion
+	Depreciaciones []models.Depreciacion
+	Detalles       []models.DetalleInversionInicial
+	Cuotas         []models.PrestamoCuotas
+	Indicadores    models.IndicadoresMacro
+}
+
+// ComputeIncomeStatement calcula el estado de resultados de los meses 1..12 de
+// cada año del horizonte:
+//
+//	utilidad_bruta = ventas del año - costos de ventas del mes
+//	utilidad_previo_int_imp = utilidad_bruta - gastos - depreciación - amortización
+//	utilidad_antes_ptu = utilidad_previo_int_imp - intereses del préstamo
+//	ptu = utilidad_antes_ptu * ptu%, isr = (utilidad_antes_ptu - ptu) * tasa_impuesto%
+//
+// La depreciación suma las depreciaciones mensuales de los detalles de tipo 1 y
+// la amortización las de tipo 2. Las filas existentes fuera de esos meses (por
+// ejemplo el mes 0) se devuelven sin cambios.
+func ComputeIncomeStatement(in IncomeStatementInputs) []models.EstadoResultados {
+	out := append([]models.EstadoResultados(nil), in.Existentes...)
+	idx := indicePeriodo(out, func(er models.EstadoResultados) periodo { return periodo{er.Anio, er.Mes} })
+
+	interes := make(map[periodo]float64)
+	for _, c := range in.Cuotas {
+		interes[periodo{c.Anio, c.Mes}] += c.Interes
+	}
+	costos := make(map[periodo]float64)
+	for _, cv := range in.CostosVentas {
+		costos[periodo{cv.Anio, cv.Mes}] += cv.Mensual
+	}
+	ventasPorAnio := make(map[int]float64)
+	for _, v := range in.Ventas {
+		ventasPorAnio[v.Anio] += v.Venta
+	}
+	var gastos float64
+	for _, g := range in.Gastos {
+		gastos += g.Mensual
+	}
+	tipoDetalle := make(map[uint]uint, len(in.Detalles))
+	for _, d := range in.Detalles {
+		tipoDetalle[d.ID] = d.TipoID
+	}
+	var depreciacion, amortizacion float64
+	for _, dep := range in.Depreciaciones {
+		switch tipoDetalle[dep.DetalleInversionID] {
+		case 1:
+			depreciacion += valor(dep.DepreciacionMensual)
+		case 2:
+			amortizacion += valor(dep.DepreciacionMensual)
+		}
+	}
+
+	for anio := 1; anio <= in.Horizonte; anio++ {
+		ventas := ventasPorAnio[anio]
+		for mes := 1; mes <= 12; mes++ {
+			p := periodo{anio, mes}
+			er := models.EstadoResultados{PlanNegocioID: in.PlanID, Anio: anio, Mes: mes}
+			i, ok := idx[p]
+			if ok {
+				er = out[i]
+			}
+			er.Ventas = ventas
+			er.CostosVentas = costos[p]
+			er.UtilidadBruta = ventas - er.CostosVentas
+			er.GastosVentaAdm = gastos
+			er.Depreciacion = depreciacion
+			er.Amortizacion = amortizacion
+			er.UtilidadprevioIntImp = er.UtilidadBruta - gastos - depreciacion - amortizacion
+			er.GastosFinancieros = interes[p]
+			er.UtilidadAntesPTU = er.UtilidadprevioIntImp - er.GastosFinancieros
+			er.PTU = er.UtilidadAntesPTU * (in.Indicadores.PTU / 100.0)
+			er.UtilidadAntesImpuestos = er.UtilidadAntesPTU - er.PTU
+			er.ISR = er.UtilidadAntesImpuestos * (in.Indicadores.TasaImpuesto / 100.0)
+			er.UtilidadNeta = er.UtilidadAntesImpuestos - er.ISR
+			if ok {
+				out[i] = er
+				continue
+			}
+			idx[p] = len(out)
+			out = append(out, er)
+		}
+	}
+	return out
+}
+
+// CashFlowInputs agrupa lo necesario para calcular el flujo de efectivo
+type CashFlowInputs struct {
+	PlanID              uint
+	Horizonte           int
+	Existentes          []models.FlujoEfectivo
+	EstadoResultados    []models.EstadoResultados
+	CostoMateriasPrimas []models.CostoMateriasPrimas
+	PoliticasVenta      []models.PoliticasVenta
+	PoliticasCompra     []models.PoliticasCompra
+	Gastos              []models.GastosOperacion
+	Cuotas              []models.PrestamoCuotas
+	Detalles            []models.DetalleInversionInicial
+}
+
+// ComputeCashFlow calcula el flujo de efectivo de cada mes del estado de
+// resultados dentro del horizonte (el mes 0 solo en el año 1):
+//
+//	ventas de contado = ventas * %contado del mes
+//	cobros de crédito = ventas del mes anterior * %crédito del mes anterior
+//	compras = (costos de ventas - materias primas mensuales del año) * %contado | %crédito del mes anterior
+//	pagos SRI = ISR del mes anterior
+//	efectivo inicial = efectivo final del mes anterior (el mes 0 del año 1 parte
+//	del efectivo de la inversión inicial)
+//
+// Los campos capturados a mano (otros ingresos, préstamos, aportes, pago PTU,
+// aumento de inventarios) se conservan de las filas existentes.
+func ComputeCashFlow(in CashFlowInputs) []models.FlujoEfectivo {
+	out := append([]models.FlujoEfectivo(nil), in.Existentes...)
+	idx := indicePeriodo(out, func(fe models.FlujoEfectivo) periodo { return periodo{fe.Anio, fe.Mes} })
+
+	ers := make([]models.EstadoResultados, 0, len(in.EstadoResultados))
+	for _, er := range in.EstadoResultados {
+		if er.Anio <= in.Horizonte {
+			ers = append(ers, er)
+		}
+	}
+	// el efectivo se encadena mes a mes: los meses se calculan en orden
+	sort.SliceStable(ers, func(i, j int) bool {
+		if ers[i].Anio != ers[j].Anio {
+			return ers[i].Anio < ers[j].Anio
+		}
+		return ers[i].Mes < ers[j].Mes
+	})
+	erIdx := indicePeriodo(ers, func(er models.EstadoResultados) periodo { return periodo{er.Anio, er.Mes} })
+	pv := politicasPorPeriodo(in.PoliticasVenta, func(p models.PoliticasVenta) (periodo, float64, float64) {
+		return periodo{p.Anio, p.Mes}, p.PorcentajeContado, p.PorcentajeCredito
+	})
+	pc := politicasPorPeriodo(in.PoliticasCompra, func(p models.PoliticasCompra) (periodo, float64, float64) {
+		return periodo{p.Anio, p.Mes}, p.PorcentajeContado, p.PorcentajeCredito
+	})
+	cuotas := cuotasPorPeriodo(in.Cuotas)
+
+	var gastos float64
+	for _, g := range in.Gastos {
+		gastos += g.Mensual
+	}
+	mpPorAnio := make(map[int]float64)
+	for _, c := range in.CostoMateriasPrimas {
+		mpPorAnio[c.Anio] += c.CostoMensual
+	}
+	var efectivo float64
+	for _, d := range in.Detalles {
+		if d.TipoID == 3 && d.Elemento == "Efectivo" {
+			efectivo += d.Importe
+		}
+	}
+
+	for _, er := range ers {
+		if er.Mes == 0 && er.Anio != 1 {
+			continue
+		}
+		p := periodo{er.Anio, er.Mes}
+		ant := p.anterior()
+
+		var ventasAnt, isrAnt float64
+		if i, ok := erIdx[ant]; ok {
+			ventasAnt = ers[i].Ventas
+			isrAnt = ers[i].ISR
+		}
+
+		fe := models.FlujoEfectivo{PlanNegocioID: in.PlanID, Anio: er.Anio, Mes: er.Mes}
+		i, ok := idx[p]
+		if ok {
+			fe = out[i]
+		}
+		fe.Ingresos_VentaContado = er.Ventas * pv[p].contado / 100.0
+		fe.Ingresos_CobrosVentasCredito = ventasAnt * pv[ant].credito / 100.0
+		fe.Egresos_GastosOperacion = gastos
+		fe.Egresos_Intereses = 0
+		fe.Egresos_PagosPrestamos = 0
+		if c, ok := cuotas[p]; ok {
+			fe.Egresos_Intereses = c.Interes
+			fe.Egresos_PagosPrestamos = c.Amortizacion
+		}
+		fe.Egresos_PagosSRI = 0
+		fe.Egresos_ComprasCostosCredito = 0
+		compras := er.CostosVentas - mpPorAnio[er.Anio]
+		fe.Egresos_ComprasCostosContado = compras * (pc[p].contado / 100.0)
+		if er.Mes != 0 {
+			fe.Egresos_PagosSRI = isrAnt
+			fe.Egresos_ComprasCostosCredito = compras * (pc[ant].credito / 100.0)
+		}
+
+		fe.Ingresos = fe.Ingresos_VentaContado + fe.Ingresos_CobrosVentasCredito + fe.Ingresos_OtrosIngresos + fe.Ingresos_Prestamos + fe.Ingresos_AportesCapital
+		fe.Egresos = fe.Egresos_ComprasCostosContado + fe.Egresos_ComprasCostosCredito + fe.Egresos_GastosOperacion + fe.Egresos_Intereses + fe.Egresos_PagosPrestamos + fe.Egresos_PagosSRI + fe.Egresos_PagoPTU
+		fe.FlujoCaja = fe.Ingresos - fe.Egresos
+		fe.EfectivoInicial = efectivo
+		fe.EfectivoFinal = fe.FlujoCaja + fe.EfectivoInicial
+		efectivo = fe.EfectivoFinal
+		if ok {
+			out[i] = fe
+			continue
+		}
+		idx[p] = len(out)
+		out = append(out, fe)
+	}
+	return out
+}
+
+// BalanceSheetInputs agrupa lo necesario para calcular el balance general
+type BalanceSheetInputs struct {
+	PlanID              uint
+	Horizonte           int
+	Existentes          []models.BalanceGeneral
+	EstadoResultados    []models.EstadoResultados
+	FlujoEfectivo       []models.FlujoEfectivo
+	PoliticasVenta      []models.PoliticasVenta
+	PoliticasCompra     []models.PoliticasCompra
+	CostoMateriasPrimas []models.CostoMateriasPrimas
+	Cuotas              []models.PrestamoCuotas
+	Depreciaciones      []models.Depreciacion
+	Detalles            []models.DetalleInversionInicial
+	Supuesto            models.Supuesto
+	Composicion         models.ComposicionFinanciamiento
+}
+
+// ComputeBalanceSheet calcula el balance general mes a mes, encadenando cada
+// mes con el anterior. El mes 0 del año 1 toma los saldos de apertura de la
+// inversión inicial (efectivo, inventario de materias primas, activos de tipo
+// 1 y 2) y la amortización del préstamo del primer año. Además:
+//
+//	préstamos de largo plazo = saldo pendiente del préstamo - préstamos de corto plazo
+//	capital social = capital% * total_inversion
+//	utilidad del ejercicio = utilidad neta del año hasta el mes
+//	utilidades retenidas = utilidad neta de los años anteriores
+//
+// y los totales de pasivo y capital suman sus componentes. Los demás campos
+// (otros de largo plazo, capital adicional) se conservan. El efectivo es el
+// efectivo final del flujo del mismo mes (en el mes 0, si el flujo no lo
+// tiene, el de la inversión inicial). El activo no se cuadra contra pasivo +
+// capital: los inventarios son un porcentaje de las ventas, así que
+// CheckIntegrity reporta balance.ecuacion_contable.
+func ComputeBalanceSheet(in BalanceSheetInputs) []models.BalanceGeneral {
+	out := append([]models.BalanceGeneral(nil), in.Existentes...)
+	idx := indicePeriodo(out, func(bg models.BalanceGeneral) periodo { return periodo{bg.Anio, bg.Mes} })
+	erIdx := indicePeriodo(in.EstadoResultados, func(er models.EstadoResultados) periodo { return periodo{er.Anio, er.Mes} })
+	feIdx := indicePeriodo(in.FlujoEfectivo, func(fe models.FlujoEfectivo) periodo { return periodo{fe.Anio, fe.Mes} })
+	pv := politicasPorPeriodo(in.PoliticasVenta, func(p models.PoliticasVenta) (periodo, float64, float64) {
+		return periodo{p.Anio, p.Mes}, p.PorcentajeContado, p.PorcentajeCredito
+	})
+	pc := politicasPorPeriodo(in.PoliticasCompra, func(p models.PoliticasCompra) (periodo, float64, float64) {
+		return periodo{p.Anio, p.Mes}, p.PorcentajeContado, p.PorcentajeCredito
+	})
+	cuotas := cuotasPorPeriodo(in.Cuotas)
+
+	mpPorAnio := make(map[int]float64)
+	for _, c := range in.CostoMateriasPrimas {
+		mpPorAnio[c.Anio] += c.CostoMensual
+	}
+	var depMensual float64
+	for _, d := range in.Depreciaciones {
+		depMensual += valor(d.DepreciacionMensual)
+	}
+	utilidad := make(map[periodo]float64)
+	for _, er := range in.EstadoResultados {
+		if er.Mes >= 1 {
+			utilidad[periodo{er.Anio, er.Mes}] += er.UtilidadNeta
+		}
+	}
+	capitalSocial := in.Composicion.CapitalPorcentaje / 100.0 * in.Composicion.Total_Inversion
+	// saldo del préstamo y utilidades acumuladas al cierre del mes que se
+	// calcula: los meses se calculan en orden
+	saldoPrestamo := cuotas[periodo{1, 1}].SaldoInicial
+	var retenidas, delEjercicio float64
+
+	calcular := func(p periodo) {
+		bg := models.BalanceGeneral{PlanNegocioID: in.PlanID, Anio: p.anio, Mes: p.mes}
+		i, ok := idx[p]
+		if ok {
+			bg = out[i]
+		}
+
+		var efectivo, cxc, inventarios, noCorrientes, proveedores, prestamos, cxp, otros float64
+		if p.anio == 1 && p.mes == 0 {
+			efectivo = primerDetalle(in.Detalles, func(d models.DetalleInversionInicial) bool {
+				return d.TipoID == 3 && d.Elemento == "Efectivo"
+			})
+			if j, ok := feIdx[p]; ok {
+				efectivo = in.FlujoEfectivo[j].EfectivoFinal
+			}
+			inventarios = primerDetalle(in.Detalles, func(d models.DetalleInversionInicial) bool {
+				return d.Elemento == "Inventario de materias primas"
+			})
+			for _, d := range in.Detalles {
+				if d.TipoID == 1 || d.TipoID == 2 {
+					noCorrientes += d.Importe
+				}
+			}
+			for _, c := range in.Cuotas {
+				if c.Anio == 1 {
+					prestamos += c.Amortizacion
+				}
+			}
+		} else {
+			ant := p.anteriorBalance()
+			var prev models.BalanceGeneral
+			if j, ok := idx[ant]; ok {
+				prev = out[j]
+			}
+			var fe models.FlujoEfectivo
+			efectivo = prev.Corrientes_Efectivo
+			if j, ok := feIdx[p]; ok {
+				fe = in.FlujoEfectivo[j]
+				efectivo = fe.EfectivoFinal
+			}
+			var er models.EstadoResultados
+			j, hayER := erIdx[p]
+			if hayER {
+				er = in.EstadoResultados[j]
+			}
+
+			cxc = prev.Corrientes_CuentasxCobrar
+			if _, ok := pv[p]; ok && hayER {
+				cxc += er.Ventas * (pv[p].credito / 100.0)
+			}
+			cxc -= fe.Ingresos_CobrosVentasCredito
+
+			inventarios = er.Ventas * (in.Supuesto.PorcenVentas / 100.0)
+
+			noCorrientes = prev.NoCorrientes_Suma - depMensual
+
+			proveedores = prev.PasivoProveedoresCortoPlazo
+			if _, ok := pc[p]; ok {
+				proveedores += mpPorAnio[p.anio] * (pc[p].credito / 100.0)
+			}
+			proveedores -= fe.Egresos_ComprasCostosCredito
+
+			prestamos = prev.PasivoPrestamosCortoPlazo - cuotas[p].Amortizacion
+			cxp = prev.PasivoCuentasxPagarCortoPlazo + er.ISR - fe.Egresos_PagosSRI
+			otros = prev.PasivoOtrosCortoPlazo + er.PTU - fe.Egresos_PagoPTU
+
+			if c, ok := cuotas[p]; ok {
+				saldoPrestamo = c.SaldoPendiente
+			}
+			if p.mes == 1 && p.anio > 1 {
+				retenidas += delEjercicio
+				delEjercicio = 0
+			}
+			delEjercicio += utilidad[p]
+		}
+
+		bg.Corrientes_Efectivo = efectivo
+		bg.Corrientes_CuentasxCobrar = cxc
+		bg.Corrientes_Inventarios = inventarios
+		bg.Corrientes_Otros = 0
+		bg.Corrientes_Suma = efectivo + cxc + inventarios
+		bg.NoCorrientes_Suma = noCorrientes
+		bg.TotalActivo = bg.Corrientes_Suma + noCorrientes
+		bg.PasivoProveedoresCortoPlazo = proveedores
+		bg.PasivoPrestamosCortoPlazo = prestamos
+		bg.PasivoCuentasxPagarCortoPlazo = cxp
+		bg.PasivoOtrosCortoPlazo = otros
+		bg.PasivoCortoPlazo_Suma = proveedores + prestamos + cxp + otros
+		bg.PasivoPrestamosLargoPlazo = saldoPrestamo - prestamos
+		bg.PasivoLargoPlazo_Suma = bg.PasivoPrestamosLargoPlazo + bg.PasivoOtrosLargoPlazo
+		bg.TotalPasivo = bg.PasivoCortoPlazo_Suma + bg.PasivoLargoPlazo_Suma
+		bg.CapitalSocial = capitalSocial
+		bg.UtilidadesRetenidas = retenidas
+		bg.UtilidadDelEjercicio = delEjercicio
+		bg.TotalCapitalContable = bg.CapitalSocial + bg.CapitalAdicional + retenidas + delEjercicio
+		if ok {
+			out[i] = bg
+			return
+		}
+		idx[p] = len(out)
+		out = append(out, bg)
+	}
+
+	for anio := 1; anio <= in.Horizonte; anio++ {
+		if anio == 1 {
+			calcular(periodo{1, 0})
+		}
+		for mes := 1; mes <= 12; mes++ {
+			calcular(periodo{anio, mes})
+		}
+	}
+	return out
+}
+
+// indicePeriodo indexa filas por (anio, mes) quedándose con la primera aparición,
+// igual que First en los procedimientos.
+func indicePeriodo[T any](rows []T, key func(T) periodo) map[periodo]int {
+	idx := make(map[periodo]int, len(rows))
+	for i, r := range rows {
+		k := key(r)
+		if _, ok := idx[k]; !ok {
+			idx[k] = i
+		}
+	}
+	return idx
+}
+
+type politica struct {
+	contado float64
+	credito float64
+}
+
+func politicasPorPeriodo[T any](rows []T, key func(T) (periodo, float64, float64)) map[periodo]politica {
+	m := make(map[periodo]politica, len(rows))
+	for _, r := range rows {
+		p, contado, credito := key(r)
+		if _, ok := m[p]; !ok {
+			m[p] = politica{contado: contado, credito: credito}
+		}
+	}
+	return m
+}
+
+func cuotasPorPeriodo(cuotas []models.PrestamoCuotas) map[periodo]models.PrestamoCuotas {
+	m := make(map[periodo]models.PrestamoCuotas, len(cuotas))
+	for _, c := range cuotas {
+		p := periodo{c.Anio, c.Mes}
+		if _, ok := m[p]; !ok {
+			m[p] = c
+		}
+	}
+	return m
+}
+
+func primerDetalle(detalles []models.DetalleInversionInicial, match func(models.DetalleInversionInicial) bool) float64 {
+	for _, d := range detalles {
+		if match(d) {
+			return d.Importe
+		}
+	}
+	return 0
+}
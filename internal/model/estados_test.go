@@ -0,0 +1,258 @@
+package model
+
+import (
+	"testing"
+
+	"github.com/JostinAlvaradoS/liveplan_backend_go/internal/models"
+)
+
+func TestComputeIncomeStatement(t *testing.T) {
+	out := ComputeIncomeStatement(IncomeStatementInputs{
+		PlanID:    1,
+		Horizonte: 2,
+		Existentes: []models.EstadoResultados{
+			{ID: 5, PlanNegocioID: 1, Anio: 1, Mes: 0, Ventas: 77},
+			{ID: 6, PlanNegocioID: 1, Anio: 1, Mes: 1},
+		},
+		Ventas: []models.Ventas{
+			{Anio: 1, Venta: 1000}, {Anio: 1, Venta: 200},
+			{Anio: 2, Venta: 1500},
+		},
+		CostosVentas: []models.CostosVentas{{Anio: 1, Mes: 1, Mensual: 300}},
+		Gastos:       []models.GastosOperacion{{Mensual: 60}, {Mensual: 40}},
+		Depreciaciones: []models.Depreciacion{
+			{DetalleInversionID: 1, DepreciacionMensual: ptr(20)},
+			{DetalleInversionID: 2, DepreciacionMensual: ptr(10)},
+			{DetalleInversionID: 3, DepreciacionMensual: ptr(99)},
+		},
+		Detalles: []models.DetalleInversionInicial{
+			{ID: 1, TipoID: 1}, {ID: 2, TipoID: 2}, {ID: 3, TipoID: 3},
+		},
+		Cuotas:      []models.PrestamoCuotas{{Anio: 1, Mes: 1, Interes: 50}},
+		Indicadores: models.IndicadoresMacro{PTU: 10, TasaImpuesto: 25},
+	})
+	// el mes 0 existente más 12 meses por año
+	if len(out) != 25 {
+		t.Fatalf("filas = %d, want 25", len(out))
+	}
+	idx := indicePeriodo(out, func(er models.EstadoResultados) periodo { return periodo{er.Anio, er.Mes} })
+
+	tests := []struct {
+		p                                periodo
+		id                               uint
+		ventas, bruta, operativa, financ float64
+		ptu, isr, neta                   float64
+	}{
+		// el mes 0 no se toca
+		{periodo{1, 0}, 5, 77, 0, 0, 0, 0, 0, 0},
+		// 1200 - 300 = 900; 900 - 100 - 20 - 10 = 770; 770 - 50 = 720; ptu 72; isr (720-72)*25%
+		{periodo{1, 1}, 6, 1200, 900, 770, 50, 72, 162, 486},
+		{periodo{1, 2}, 0, 1200, 1200, 1070, 0, 107, 240.75, 722.25},
+		{periodo{2, 12}, 0, 1500, 1500, 1370, 0, 137, 308.25, 924.75},
+	}
+	for _, tt := range tests {
+		i, ok := idx[tt.p]
+		if !ok {
+			t.Errorf("%v: falta la fila", tt.p)
+			continue
+		}
+		er := out[i]
+		if er.ID != tt.id || er.PlanNegocioID != 1 {
+			t.Errorf("%v: id = %d plan = %d, want %d 1", tt.p, er.ID, er.PlanNegocioID, tt.id)
+		}
+		got := []float64{er.Ventas, er.UtilidadBruta, er.UtilidadprevioIntImp, er.GastosFinancieros, er.PTU, er.ISR, er.UtilidadNeta}
+		want := []float64{tt.ventas, tt.bruta, tt.operativa, tt.financ, tt.ptu, tt.isr, tt.neta}
+		for k := range got {
+			if !cerca(got[k], want[k]) {
+				t.Errorf("%v: %+v, want ventas/bruta/operativa/financieros/ptu/isr/neta = %v", tt.p, er, want)
+				break
+			}
+		}
+	}
+}
+
+func TestComputeCashFlow(t *testing.T) {
+	out := ComputeCashFlow(CashFlowInputs{
+		PlanID:    1,
+		Horizonte: 1,
+		Existentes: []models.FlujoEfectivo{
+			{ID: 9, PlanNegocioID: 1, Anio: 1, Mes: 2, Ingresos_OtrosIngresos: 25},
+		},
+		// desordenados: el efectivo se encadena en orden de mes
+		EstadoResultados: []models.EstadoResultados{
+			{Anio: 1, Mes: 2, Ventas: 1000, CostosVentas: 400, ISR: 40},
+			{Anio: 1, Mes: 0},
+			{Anio: 1, Mes: 1, Ventas: 1000, CostosVentas: 400, ISR: 30},
+			// fuera del horizonte
+			{Anio: 2, Mes: 1, Ventas: 5000},
+		},
+		CostoMateriasPrimas: []models.CostoMateriasPrimas{{Anio: 1, CostoMensual: 100}},
+		PoliticasVenta: []models.PoliticasVenta{
+			{Anio: 1, Mes: 1, PorcentajeContado: 60, PorcentajeCredito: 40},
+			{Anio: 1, Mes: 2, PorcentajeContado: 50, PorcentajeCredito: 50},
+		},
+		PoliticasCompra: []models.PoliticasCompra{
+			{Anio: 1, Mes: 1, PorcentajeContado: 70, PorcentajeCredito: 30},
+			{Anio: 1, Mes: 2, PorcentajeContado: 80, PorcentajeCredito: 20},
+		},
+		Gastos: []models.GastosOperacion{{Mensual: 200}},
+		Cuotas: []models.PrestamoCuotas{
+			{Anio: 1, Mes: 1, Interes: 10, Amortizacion: 90},
+			{Anio: 1, Mes: 2, Interes: 9, Amortizacion: 91},
+		},
+		Detalles: []models.DetalleInversionInicial{{TipoID: 3, Elemento: "Efectivo", Importe: 500}},
+	})
+	if len(out) != 3 {
+		t.Fatalf("filas = %d, want 3", len(out))
+	}
+	idx := indicePeriodo(out, func(fe models.FlujoEfectivo) periodo { return periodo{fe.Anio, fe.Mes} })
+
+	tests := []struct {
+		p                                  periodo
+		id                                 uint
+		contado, cobros                    float64
+		comprasContado, comprasCredito     float64
+		sri, ingresos, egresos, flujo, fin float64
+		inicial                            float64
+	}{
+		// el mes 0 no paga SRI ni compras a crédito y parte del efectivo de la inversión
+		{periodo{1, 0}, 0, 0, 0, 0, 0, 0, 0, 200, -200, 300, 500},
+		// compras = 400 - 100 de materias primas; sin mes anterior no hay cobros ni compras a crédito
+		{periodo{1, 1}, 0, 600, 0, 210, 0, 0, 600, 510, 90, 390, 300},
+		// cobra el 40 % del mes 1, paga el 30 % de sus compras y su ISR; conserva otros ingresos
+		{periodo{1, 2}, 9, 500, 400, 240, 90, 30, 925, 660, 265, 655, 390},
+	}
+	for _, tt := range tests {
+		i, ok := idx[tt.p]
+		if !ok {
+			t.Errorf("%v: falta la fila", tt.p)
+			continue
+		}
+		fe := out[i]
+		if fe.ID != tt.id {
+			t.Errorf("%v: id = %d, want %d", tt.p, fe.ID, tt.id)
+		}
+		got := []float64{fe.Ingresos_VentaContado, fe.Ingresos_CobrosVentasCredito, fe.Egresos_ComprasCostosContado,
+			fe.Egresos_ComprasCostosCredito, fe.Egresos_PagosSRI, fe.Ingresos, fe.Egresos, fe.FlujoCaja, fe.EfectivoFinal, fe.EfectivoInicial}
+		want := []float64{tt.contado, tt.cobros, tt.comprasContado, tt.comprasCredito, tt.sri, tt.ingresos, tt.egresos, tt.flujo, tt.fin, tt.inicial}
+		for k := range got {
+			if !cerca(got[k], want[k]) {
+				t.Errorf("%v: got %v, want %v", tt.p, got, want)
+				break
+			}
+		}
+	}
+}
+
+func TestComputeBalanceSheet(t *testing.T) {
+	out := ComputeBalanceSheet(BalanceSheetInputs{
+		PlanID:    1,
+		Horizonte: 1,
+		Existentes: []models.BalanceGeneral{
+			{ID: 4, PlanNegocioID: 1, Anio: 1, Mes: 2, CapitalAdicional: 50},
+		},
+		EstadoResultados: []models.EstadoResultados{
+			{Anio: 1, Mes: 1, Ventas: 1000, ISR: 30, PTU: 12, UtilidadNeta: 100},
+			{Anio: 1, Mes: 2, Ventas: 1000, ISR: 40, PTU: 16, UtilidadNeta: 120},
+		},
+		FlujoEfectivo: []models.FlujoEfectivo{
+			{Anio: 1, Mes: 0, EfectivoFinal: 500},
+			{Anio: 1, Mes: 1, EfectivoFinal: 590},
+			{Anio: 1, Mes: 2, EfectivoFinal: 765, Ingresos_CobrosVentasCredito: 400, Egresos_PagosSRI: 30, Egresos_ComprasCostosCredito: 90},
+		},
+		PoliticasVenta: []models.PoliticasVenta{
+			{Anio: 1, Mes: 1, PorcentajeCredito: 40},
+			{Anio: 1, Mes: 2, PorcentajeCredito: 50},
+		},
+		PoliticasCompra: []models.PoliticasCompra{
+			{Anio: 1, Mes: 1, PorcentajeCredito: 30},
+			{Anio: 1, Mes: 2, PorcentajeCredito: 20},
+		},
+		CostoMateriasPrimas: []models.CostoMateriasPrimas{{Anio: 1, CostoMensual: 100}},
+		Cuotas: []models.PrestamoCuotas{
+			{Anio: 1, Mes: 1, SaldoInicial: 1000, Amortizacion: 90, SaldoPendiente: 910},
+			{Anio: 1, Mes: 2, SaldoInicial: 910, Amortizacion: 91, SaldoPendiente: 819},
+		},
+		Depreciaciones: []models.Depreciacion{{DepreciacionMensual: ptr(20)}},
+		Detalles: []models.DetalleInversionInicial{
+			{TipoID: 3, Elemento: "Efectivo", Importe: 500},
+			{TipoID: 3, Elemento: "Inventario de materias primas", Importe: 200},
+			{TipoID: 1, Elemento: "Equipo", Importe: 1200},
+		},
+		Supuesto:    models.Supuesto{PorcenVentas: 10},
+		Composicion: models.ComposicionFinanciamiento{CapitalPorcentaje: 50, Total_Inversion: 2000},
+	})
+	if len(out) != 13 {
+		t.Fatalf("filas = %d, want 13", len(out))
+	}
+	idx := indicePeriodo(out, func(bg models.BalanceGeneral) periodo { return periodo{bg.Anio, bg.Mes} })
+
+	tests := []struct {
+		p                                      periodo
+		efectivo, cxc, inventarios, noCorr     float64
+		activo                                 float64
+		proveedores, prestamosCP, cxp, otrosCP float64
+		prestamosLP, pasivo                    float64
+		ejercicio, capital                     float64
+	}{
+		// apertura: saldos de la inversión y la amortización del primer año como corto plazo
+		{periodo{1, 0}, 500, 0, 200, 1200, 1900, 0, 181, 0, 0, 819, 1000, 0, 1000},
+		// el efectivo es el efectivo final del flujo del mismo mes
+		{periodo{1, 1}, 590, 400, 100, 1180, 2270, 30, 91, 30, 12, 819, 982, 100, 1100},
+		// conserva el capital adicional capturado
+		{periodo{1, 2}, 765, 500, 100, 1160, 2525, -40, 0, 40, 28, 819, 847, 220, 1270},
+	}
+	for _, tt := range tests {
+		i, ok := idx[tt.p]
+		if !ok {
+			t.Errorf("%v: falta la fila", tt.p)
+			continue
+		}
+		bg := out[i]
+		got := []float64{bg.Corrientes_Efectivo, bg.Corrientes_CuentasxCobrar, bg.Corrientes_Inventarios, bg.NoCorrientes_Suma,
+			bg.TotalActivo, bg.PasivoProveedoresCortoPlazo, bg.PasivoPrestamosCortoPlazo, bg.PasivoCuentasxPagarCortoPlazo,
+			bg.PasivoOtrosCortoPlazo, bg.PasivoPrestamosLargoPlazo, bg.TotalPasivo, bg.UtilidadDelEjercicio, bg.TotalCapitalContable}
+		want := []float64{tt.efectivo, tt.cxc, tt.inventarios, tt.noCorr, tt.activo, tt.proveedores, tt.prestamosCP, tt.cxp,
+			tt.otrosCP, tt.prestamosLP, tt.pasivo, tt.ejercicio, tt.capital}
+		for k := range got {
+			if !cerca(got[k], want[k]) {
+				t.Errorf("%v: got %v, want %v", tt.p, got, want)
+				break
+			}
+		}
+	}
+	if i := idx[periodo{1, 2}]; out[i].ID != 4 {
+		t.Errorf("id del mes 2 = %d, want 4", out[i].ID)
+	}
+}
+
+// La utilidad del ejercicio se acumula en el año y pasa a retenidas en el mes 1
+// del año siguiente.
+func TestComputeBalanceSheetUtilidades(t *testing.T) {
+	out, err := Compute(planDePrueba(2), Shocks{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	netaPorAnio := make(map[int]float64)
+	for _, er := range out.EstadoResultados {
+		netaPorAnio[er.Anio] += er.UtilidadNeta
+	}
+	idx := indicePeriodo(out.BalanceGeneral, func(bg models.BalanceGeneral) periodo { return periodo{bg.Anio, bg.Mes} })
+	tests := []struct {
+		p                    periodo
+		retenidas, ejercicio float64
+	}{
+		{periodo{1, 0}, 0, 0},
+		{periodo{1, 12}, 0, netaPorAnio[1]},
+		{periodo{2, 12}, netaPorAnio[1], netaPorAnio[2]},
+	}
+	for _, tt := range tests {
+		bg := out.BalanceGeneral[idx[tt.p]]
+		if !cerca(bg.UtilidadesRetenidas, tt.retenidas) || !cerca(bg.UtilidadDelEjercicio, tt.ejercicio) {
+			t.Errorf("%v: retenidas %v ejercicio %v, want %v %v", tt.p, bg.UtilidadesRetenidas, bg.UtilidadDelEjercicio, tt.retenidas, tt.ejercicio)
+		}
+		if !cerca(bg.TotalCapitalContable, 7500+tt.retenidas+tt.ejercicio) {
+			t.Errorf("%v: capital contable = %v", tt.p, bg.TotalCapitalContable)
+		}
+	}
+}
@@ -0,0 +1,119 @@
+package model
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/JostinAlvaradoS/liveplan_backend_go/internal/models"
+)
+
+// EvaluationInputs agrupa lo necesario para la evaluación financiera del plan
+type EvaluationInputs struct {
+	PlanID         uint
+	Horizonte      int
+	Existentes     []models.ConceptosEvaluacion
+	Composicion    models.ComposicionFinanciamiento
+	Evaluacion     models.EvaluacionProyecto
+	BalanceGeneral []models.BalanceGeneral
+	FlujoEfectivo  []models.FlujoEfectivo
+}
+
+// ComputeEvaluation calcula los conceptos de evaluación de los años 0..H y el
+// VAN/TIR del proyecto:
+//
+//	año 0: flujo nominal = -(capital% * total_inversion)
+//	años 1..H: flujo nominal = suma de FlujoCaja del año
+//	valor de rescate (solo año H) = CxC + inventarios + no corrientes - CxP - otros CP del año H
+//	valor actual = total / (1 + TREMA/100)^anio, y en el año 0 la suma de los años 1..H
+//	VAN = total año 0 + valor actual año 0
+//
+// Los conceptos de años mayores a H no se devuelven.
+func ComputeEvaluation(in EvaluationInputs) ([]models.ConceptosEvaluacion, models.EvaluacionProyecto) {
+	h := in.Horizonte
+
+	var rescate float64
+	for _, b := range in.BalanceGeneral {
+		if b.Anio != h {
+			continue
+		}
+		rescate += b.Corrientes_CuentasxCobrar + b.Corrientes_Inventarios + b.NoCorrientes_Suma - b.PasivoCuentasxPagarCortoPlazo - b.PasivoOtrosCortoPlazo
+	}
+	valorRescate := make([]float64, h+1)
+	valorRescate[h] = rescate
+
+	nominal := make([]float64, h+1)
+	nominal[0] = -((in.Composicion.CapitalPorcentaje / 100.0) * in.Composicion.Total_Inversion)
+	for _, fe := range in.FlujoEfectivo {
+		if fe.Anio >= 1 && fe.Anio <= h {
+			nominal[fe.Anio] += fe.FlujoCaja
+		}
+	}
+
+	total := make([]float64, h+1)
+	actual := make([]float64, h+1)
+	var sumaActual float64
+	for anio := 1; anio <= h; anio++ {
+		total[anio] = nominal[anio] + valorRescate[anio]
+		actual[anio] = total[anio] / math.Pow(1.0+(in.Evaluacion.TREMA/100.0), float64(anio))
+		sumaActual += actual[anio]
+	}
+	total[0] = nominal[0] + valorRescate[0]
+	actual[0] = sumaActual
+
+	existentes := make(map[int]models.ConceptosEvaluacion, len(in.Existentes))
+	for _, ce := range in.Existentes {
+		if _, ok := existentes[ce.Anio]; !ok {
+			existentes[ce.Anio] = ce
+		}
+	}
+	conceptos := make([]models.ConceptosEvaluacion, 0, h+1)
+	for anio := 0; anio <= h; anio++ {
+		ce, ok := existentes[anio]
+		if !ok {
+			ce = models.ConceptosEvaluacion{PlanNegocioID: in.PlanID, Anio: anio}
+		}
+		ce.FlujoEfectivoNominal = fmt.Sprintf("%.2f", nominal[anio])
+		ce.ValorRescate = fmt.Sprintf("%.2f", valorRescate[anio])
+		ce.TotalFlujoEfectivo = fmt.Sprintf("%.2f", total[anio])
+		ce.ValorActualFlujosFuturos = fmt.Sprintf("%.2f", actual[anio])
+		conceptos = append(conceptos, ce)
+	}
+
+	eval := in.Evaluacion
+	eval.VAN = total[0] + actual[0]
+	eval.TIR = ComputeIRR(total)
+	return conceptos, eval
+}
+
+// ComputeIRR calcula la TIR (en porcentaje) de una serie de flujos con
+// Newton-Raphson, equivalente a la función IRR de Excel.
+func ComputeIRR(flujos []float64) float64 {
+	tasa := 0.1
+	precision := 0.0001
+	maxIteraciones := 100
+
+	for i := 0; i < maxIteraciones; i++ {
+		vpn := 0.0
+		derivada := 0.0
+		for j, flujo := range flujos {
+			vpn += flujo / math.Pow(1+tasa, float64(j))
+			if j > 0 {
+				derivada -= float64(j) * flujo / math.Pow(1+tasa, float64(j+1))
+			}
+		}
+		if math.Abs(vpn) < precision {
+			return tasa * 100
+		}
+		if math.Abs(derivada) < precision {
+			break
+		}
+		nuevaTasa := tasa - vpn/derivada
+		if nuevaTasa < -0.99 {
+			nuevaTasa = -0.99
+		} else if nuevaTasa > 10 {
+			nuevaTasa = 10
+		}
+		tasa = nuevaTasa
+	}
+	return tasa * 100
+}
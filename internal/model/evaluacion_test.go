@@ -0,0 +1,101 @@
+package model
+
+import (
+	"math"
+	"testing"
+
+	"github.com/JostinAlvaradoS/liveplan_backend_go/internal/models"
+)
+
+func TestComputeEvaluation(t *testing.T) {
+	conceptos, eval := ComputeEvaluation(EvaluationInputs{
+		PlanID:    1,
+		Horizonte: 2,
+		Existentes: []models.ConceptosEvaluacion{
+			{ID: 3, PlanNegocioID: 1, Anio: 1},
+			// fuera del horizonte: no se devuelve
+			{ID: 4, PlanNegocioID: 1, Anio: 5},
+		},
+		Composicion: models.ComposicionFinanciamiento{CapitalPorcentaje: 50, Total_Inversion: 2000},
+		Evaluacion:  models.EvaluacionProyecto{ID: 2, TREMA: 10},
+		BalanceGeneral: []models.BalanceGeneral{
+			{Anio: 1, Mes: 12, NoCorrientes_Suma: 999},
+			{Anio: 2, Mes: 12, Corrientes_CuentasxCobrar: 100, Corrientes_Inventarios: 50, NoCorrientes_Suma: 300,
+				PasivoCuentasxPagarCortoPlazo: 40, PasivoOtrosCortoPlazo: 10},
+		},
+		FlujoEfectivo: []models.FlujoEfectivo{
+			{Anio: 1, Mes: 0, FlujoCaja: 7},
+			{Anio: 1, Mes: 1, FlujoCaja: 300},
+			{Anio: 1, Mes: 2, FlujoCaja: 300},
+			{Anio: 2, Mes: 1, FlujoCaja: 700},
+			{Anio: 3, Mes: 1, FlujoCaja: 5000},
+		},
+	})
+
+	tests := []struct {
+		anio                            int
+		id                              uint
+		nominal, rescate, total, actual string
+	}{
+		// año 0: aporte de capital y suma de los valores actuales
+		{0, 0, "-1000.00", "0.00", "-1000.00", "1460.91"},
+		// el mes 0 del año 1 también suma al flujo del año
+		{1, 3, "607.00", "0.00", "607.00", "551.82"},
+		{2, 0, "700.00", "400.00", "1100.00", "909.09"},
+	}
+	if len(conceptos) != len(tests) {
+		t.Fatalf("conceptos = %d, want %d", len(conceptos), len(tests))
+	}
+	for i, tt := range tests {
+		ce := conceptos[i]
+		if ce.Anio != tt.anio || ce.ID != tt.id || ce.PlanNegocioID != 1 {
+			t.Errorf("concepto %d: año %d id %d plan %d", i, ce.Anio, ce.ID, ce.PlanNegocioID)
+		}
+		got := []string{ce.FlujoEfectivoNominal, ce.ValorRescate, ce.TotalFlujoEfectivo, ce.ValorActualFlujosFuturos}
+		want := []string{tt.nominal, tt.rescate, tt.total, tt.actual}
+		for k := range got {
+			if got[k] != want[k] {
+				t.Errorf("año %d: %v, want %v", tt.anio, got, want)
+				break
+			}
+		}
+	}
+
+	van := -1000 + 607/1.1 + 1100/1.21
+	if eval.ID != 2 || eval.TREMA != 10 || !cerca(eval.VAN, van) {
+		t.Errorf("evaluación = %+v, want VAN %v", eval, van)
+	}
+	if !cerca(eval.TIR, ComputeIRR([]float64{-1000, 607, 1100})) {
+		t.Errorf("TIR = %v", eval.TIR)
+	}
+}
+
+func TestComputeIRR(t *testing.T) {
+	tests := []struct {
+		nombre string
+		flujos []float64
+		want   float64
+	}{
+		{"un periodo", []float64{-100, 110}, 10},
+		{"dos periodos", []float64{-100, 0, 121}, 10},
+		{"cuadrática", []float64{-1000, 600, 1100}, 39.08712114635715},
+		{"tasa cero", []float64{-100, 50, 50}, 0},
+		{"negativa", []float64{-100, 50, 30}, -14.792027106038507},
+	}
+	for _, tt := range tests {
+		t.Run(tt.nombre, func(t *testing.T) {
+			got := ComputeIRR(tt.flujos)
+			if math.Abs(got-tt.want) > 1e-3 {
+				t.Errorf("ComputeIRR(%v) = %v, want %v", tt.flujos, got, tt.want)
+			}
+			// con la TIR el valor presente neto es 0
+			var vpn float64
+			for j, f := range tt.flujos {
+				vpn += f / math.Pow(1+got/100, float64(j))
+			}
+			if math.Abs(vpn) > 1e-3 {
+				t.Errorf("VPN a la TIR = %v", vpn)
+			}
+		})
+	}
+}
@@ -0,0 +1,145 @@
+package model
+
+import (
+	"math"
+	"sort"
+
+	"github.com/JostinAlvaradoS/liveplan_backend_go/internal/models"
+)
+
+// ToleranciaIntegridad es la diferencia máxima aceptada entre el valor esperado
+// y el guardado (los importes se redondean a centavos)
+const ToleranciaIntegridad = 0.01
+
+// Discrepancia es una regla contable que no se cumple en un mes
+type Discrepancia struct {
+	Anio       int     `json:"anio"`
+	Mes        int     `json:"mes"`
+	Regla      string  `json:"regla"`
+	Esperado   float64 `json:"esperado"`
+	Actual     float64 `json:"actual"`
+	Diferencia float64 `json:"diferencia"`
+}
+
+// IntegrityInputs son los estados guardados de un plan. Las filas de años
+// mayores a Horizonte (si es > 0) no se revisan: Recalcular no las actualiza.
+type IntegrityInputs struct {
+	Horizonte        int
+	EstadoResultados []models.EstadoResultados
+	FlujoEfectivo    []models.FlujoEfectivo
+	BalanceGeneral   []models.BalanceGeneral
+}
+
+// ReglasIntegridad describe cada regla que revisa CheckIntegrity
+var ReglasIntegridad = map[string]string{
+	"resultados.utilidad_bruta":               "utilidad_bruta = ventas - costos_ventas",
+	"resultados.utilidad_previo_int_imp":      "utilidad_previo_int_imp = utilidad_bruta - gastos_venta_adm - depreciacion - amortizacion",
+	"resultados.utilidad_antes_ptu":           "utilidad_antes_ptu = utilidad_previo_int_imp - gastos_financieros",
+	"resultados.utilidad_antes_impuestos":     "utilidad_antes_impuestos = utilidad_antes_ptu - ptu",
+	"resultados.utilidad_neta":                "utilidad_neta = utilidad_antes_impuestos - isr",
+	"flujo.ingresos":                          "ingresos = suma de ingresos_*",
+	"flujo.egresos":                           "egresos = suma de egresos_*",
+	"flujo.flujo_caja":                        "flujo_caja = ingresos - egresos",
+	"flujo.efectivo_final":                    "efectivo_final = efectivo_inicial + flujo_caja",
+	"flujo.continuidad_efectivo":              "efectivo_inicial del mes = efectivo_final del mes anterior",
+	"balance.activo_corriente":                "corrientes_suma = efectivo + cuentas por cobrar + inventarios + otros",
+	"balance.total_activo":                    "total_activo = corrientes_suma + no_corrientes_suma",
+	"balance.pasivo_corto_plazo":              "pasivo_corto_plazo_suma = proveedores + préstamos + cuentas por pagar + otros",
+	"balance.pasivo_largo_plazo":              "pasivo_largo_plazo_suma = préstamos + otros de largo plazo",
+	"balance.total_pasivo":                    "total_pasivo = pasivo_corto_plazo_suma + pasivo_largo_plazo_suma",
+	"balance.capital_contable":                "total_capital_contable = capital social + adicional + utilidades retenidas + utilidad del ejercicio",
+	"balance.ecuacion_contable":               "total_activo = total_pasivo + total_capital_contable",
+	"flujo_balance.efectivo":                  "corrientes_efectivo del balance = efectivo_final del flujo del mismo mes",
+	"flujo_balance.mes_sin_balance":           "todo mes del flujo de efectivo tiene balance general",
+	"resultados_flujo.mes_sin_flujo_efectivo": "todo mes del estado de resultados tiene flujo de efectivo",
+}
+
+// CheckIntegrity revisa las identidades contables de cada estado y las
+// conciliaciones entre ellos, y devuelve todas las reglas que no se cumplen
+// ordenadas por año, mes y regla. En las reglas de existencia (mes sin
+// balance o sin flujo) esperado es 1 y actual 0.
+func CheckIntegrity(in IntegrityInputs) []Discrepancia {
+	if in.Horizonte > 0 {
+		in.EstadoResultados = dentroDelHorizonte(in.EstadoResultados, in.Horizonte, func(er models.EstadoResultados) int { return er.Anio })
+		in.FlujoEfectivo = dentroDelHorizonte(in.FlujoEfectivo, in.Horizonte, func(fe models.FlujoEfectivo) int { return fe.Anio })
+		in.BalanceGeneral = dentroDelHorizonte(in.BalanceGeneral, in.Horizonte, func(bg models.BalanceGeneral) int { return bg.Anio })
+	}
+	var out []Discrepancia
+	comparar := func(anio, mes int, regla string, esperado, actual float64) {
+		if d := actual - esperado; math.Abs(d) > ToleranciaIntegridad || math.IsNaN(d) {
+			out = append(out, Discrepancia{anio, mes, regla, esperado, actual, d})
+		}
+	}
+
+	for _, er := range in.EstadoResultados {
+		a, m := er.Anio, er.Mes
+		comparar(a, m, "resultados.utilidad_bruta", er.Ventas-er.CostosVentas, er.UtilidadBruta)
+		comparar(a, m, "resultados.utilidad_previo_int_imp", er.UtilidadBruta-er.GastosVentaAdm-er.Depreciacion-er.Amortizacion, er.UtilidadprevioIntImp)
+		comparar(a, m, "resultados.utilidad_antes_ptu", er.UtilidadprevioIntImp-er.GastosFinancieros, er.UtilidadAntesPTU)
+		comparar(a, m, "resultados.utilidad_antes_impuestos", er.UtilidadAntesPTU-er.PTU, er.UtilidadAntesImpuestos)
+		comparar(a, m, "resultados.utilidad_neta", er.UtilidadAntesImpuestos-er.ISR, er.UtilidadNeta)
+	}
+
+	feIdx := indicePeriodo(in.FlujoEfectivo, func(fe models.FlujoEfectivo) periodo { return periodo{fe.Anio, fe.Mes} })
+	for _, fe := range in.FlujoEfectivo {
+		a, m := fe.Anio, fe.Mes
+		comparar(a, m, "flujo.ingresos", fe.Ingresos_VentaContado+fe.Ingresos_CobrosVentasCredito+fe.Ingresos_OtrosIngresos+fe.Ingresos_Prestamos+fe.Ingresos_AportesCapital, fe.Ingresos)
+		comparar(a, m, "flujo.egresos", fe.Egresos_ComprasCostosContado+fe.Egresos_ComprasCostosCredito+fe.Egresos_GastosOperacion+fe.Egresos_Intereses+fe.Egresos_PagosPrestamos+fe.Egresos_PagosSRI+fe.Egresos_PagoPTU, fe.Egresos)
+		comparar(a, m, "flujo.flujo_caja", fe.Ingresos-fe.Egresos, fe.FlujoCaja)
+		comparar(a, m, "flujo.efectivo_final", fe.EfectivoInicial+fe.FlujoCaja, fe.EfectivoFinal)
+		// el mes 1 del año 1 sigue al mes 0 (apertura), igual que en el balance
+		if j, ok := feIdx[periodo{a, m}.anteriorBalance()]; ok && !(a == 1 && m == 0) {
+			comparar(a, m, "flujo.continuidad_efectivo", in.FlujoEfectivo[j].EfectivoFinal, fe.EfectivoInicial)
+		}
+	}
+
+	bgIdx := indicePeriodo(in.BalanceGeneral, func(bg models.BalanceGeneral) periodo { return periodo{bg.Anio, bg.Mes} })
+	for _, bg := range in.BalanceGeneral {
+		a, m := bg.Anio, bg.Mes
+		comparar(a, m, "balance.activo_corriente", bg.Corrientes_Efectivo+bg.Corrientes_CuentasxCobrar+bg.Corrientes_Inventarios+bg.Corrientes_Otros, bg.Corrientes_Suma)
+		comparar(a, m, "balance.total_activo", bg.Corrientes_Suma+bg.NoCorrientes_Suma, bg.TotalActivo)
+		comparar(a, m, "balance.pasivo_corto_plazo", bg.PasivoProveedoresCortoPlazo+bg.PasivoPrestamosCortoPlazo+bg.PasivoCuentasxPagarCortoPlazo+bg.PasivoOtrosCortoPlazo, bg.PasivoCortoPlazo_Suma)
+		comparar(a, m, "balance.pasivo_largo_plazo", bg.PasivoPrestamosLargoPlazo+bg.PasivoOtrosLargoPlazo, bg.PasivoLargoPlazo_Suma)
+		comparar(a, m, "balance.total_pasivo", bg.PasivoCortoPlazo_Suma+bg.PasivoLargoPlazo_Suma, bg.TotalPasivo)
+		comparar(a, m, "balance.capital_contable", bg.CapitalSocial+bg.CapitalAdicional+bg.UtilidadesRetenidas+bg.UtilidadDelEjercicio, bg.TotalCapitalContable)
+		comparar(a, m, "balance.ecuacion_contable", bg.TotalPasivo+bg.TotalCapitalContable, bg.TotalActivo)
+		if j, ok := feIdx[periodo{a, m}]; ok {
+			comparar(a, m, "flujo_balance.efectivo", in.FlujoEfectivo[j].EfectivoFinal, bg.Corrientes_Efectivo)
+		}
+	}
+	for _, fe := range in.FlujoEfectivo {
+		if _, ok := bgIdx[periodo{fe.Anio, fe.Mes}]; !ok {
+			out = append(out, Discrepancia{fe.Anio, fe.Mes, "flujo_balance.mes_sin_balance", 1, 0, -1})
+		}
+	}
+	for _, er := range in.EstadoResultados {
+		if _, ok := feIdx[periodo{er.Anio, er.Mes}]; !ok {
+			// el flujo solo tiene mes 0 en el año 1
+			if er.Mes == 0 && er.Anio != 1 {
+				continue
+			}
+			out = append(out, Discrepancia{er.Anio, er.Mes, "resultados_flujo.mes_sin_flujo_efectivo", 1, 0, -1})
+		}
+	}
+
+	sort.SliceStable(out, func(i, j int) bool {
+		if out[i].Anio != out[j].Anio {
+			return out[i].Anio < out[j].Anio
+		}
+		if out[i].Mes != out[j].Mes {
+			return out[i].Mes < out[j].Mes
+		}
+		return out[i].Regla < out[j].Regla
+	})
+	return out
+}
+
+func dentroDelHorizonte[T any](rows []T, horizonte int, anio func(T) int) []T {
+	out := make([]T, 0, len(rows))
+	for _, r := range rows {
+		if anio(r) <= horizonte {
+			out = append(out, r)
+		}
+	}
+	return out
+}
@@ -0,0 +1,83 @@
+package model
+
+import (
+	"testing"
+
+	"github.com/JostinAlvaradoS/liveplan_backend_go/internal/models"
+)
+
+// El plan calculado cumple todas las reglas salvo la ecuación contable, que
+// el balance todavía no cuadra
+func TestCheckIntegrityPlanCalculado(t *testing.T) {
+	for _, horizonte := range []int{1, 2, 5} {
+		out, err := Compute(planDePrueba(horizonte), Shocks{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		ds := CheckIntegrity(IntegrityInputs{
+			Horizonte:        horizonte,
+			EstadoResultados: out.EstadoResultados,
+			FlujoEfectivo:    out.FlujoEfectivo,
+			BalanceGeneral:   out.BalanceGeneral,
+		})
+		var descuadres int
+		for _, d := range ds {
+			if d.Regla != "balance.ecuacion_contable" {
+				t.Errorf("horizonte %d: %+v", horizonte, d)
+				continue
+			}
+			descuadres++
+		}
+		if descuadres == 0 {
+			t.Errorf("horizonte %d: el balance cuadra; revisar la documentación de ComputeBalanceSheet", horizonte)
+		}
+	}
+}
+
+func TestCheckIntegrityDetectaDescuadres(t *testing.T) {
+	out, err := Compute(planDePrueba(1), Shocks{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		regla   string
+		alterar func(in *IntegrityInputs)
+	}{
+		{"resultados.utilidad_neta", func(in *IntegrityInputs) { in.EstadoResultados[3].UtilidadNeta += 5 }},
+		{"flujo.flujo_caja", func(in *IntegrityInputs) { in.FlujoEfectivo[3].FlujoCaja += 5 }},
+		{"flujo.continuidad_efectivo", func(in *IntegrityInputs) { in.FlujoEfectivo[4].EfectivoInicial += 5 }},
+		{"balance.total_pasivo", func(in *IntegrityInputs) { in.BalanceGeneral[3].TotalPasivo += 5 }},
+		{"flujo_balance.efectivo", func(in *IntegrityInputs) { in.BalanceGeneral[4].Corrientes_Efectivo += 5 }},
+		{"flujo_balance.mes_sin_balance", func(in *IntegrityInputs) { in.BalanceGeneral = in.BalanceGeneral[:5] }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.regla, func(t *testing.T) {
+			in := IntegrityInputs{
+				Horizonte:        1,
+				EstadoResultados: append([]models.EstadoResultados(nil), out.EstadoResultados...),
+				FlujoEfectivo:    append([]models.FlujoEfectivo(nil), out.FlujoEfectivo...),
+				BalanceGeneral:   append([]models.BalanceGeneral(nil), out.BalanceGeneral...),
+			}
+			tt.alterar(&in)
+			var encontrada bool
+			for _, d := range CheckIntegrity(in) {
+				if d.Regla == tt.regla {
+					encontrada = true
+				}
+			}
+			if !encontrada {
+				t.Errorf("no se reportó %s", tt.regla)
+			}
+		})
+	}
+}
+
+func TestCheckIntegrityIgnoraAniosFueraDelHorizonte(t *testing.T) {
+	in := IntegrityInputs{
+		Horizonte:        1,
+		EstadoResultados: []models.EstadoResultados{{Anio: 2, Mes: 1, Ventas: 100}},
+	}
+	if ds := CheckIntegrity(in); len(ds) != 0 {
+		t.Errorf("discrepancias = %+v", ds)
+	}
+}
@@ -0,0 +1,83 @@
+package model
+
+import "github.com/JostinAlvaradoS/liveplan_backend_go/internal/models"
+
+// ComputeInvestmentTotal devuelve la composición con total_inversion igual a la
+// suma de los importes de los detalles de inversión inicial.
+func ComputeInvestmentTotal(comp models.ComposicionFinanciamiento, detalles []models.DetalleInversionInicial) models.ComposicionFinanciamiento {
+	var total float64
+	for _, d := range detalles {
+		total += d.Importe
+	}
+	comp.Total_Inversion = total
+	return comp
+}
+
+// ComputeDepreciation calcula la depreciación de cada detalle de inversión:
+//
+//	mensual = importe / vida_util (meses)
+//	año i = mensual * min(12, meses restantes), NULL si ya no quedan meses
+//	valor_rescate = importe - suma(años 1..H)
+//
+// Los detalles con vida útil <= 0 quedan con todos los valores en NULL. Las
+// filas existentes conservan su ID; las depreciaciones de detalles que ya no
+// existen se devuelven sin cambios.
+func ComputeDepreciation(existentes []models.Depreciacion, detalles []models.DetalleInversionInicial, horizonte int) []models.Depreciacion {
+	out := make([]models.Depreciacion, 0, len(existentes)+len(detalles))
+	idx := make(map[uint]int, len(existentes))
+	for _, e := range existentes {
+		if _, ok := idx[e.DetalleInversionID]; ok {
+			out = append(out, e)
+			continue
+		}
+		idx[e.DetalleInversionID] = len(out)
+		out = append(out, e)
+	}
+
+	for _, d := range detalles {
+		dep := models.Depreciacion{PlanNegocioID: d.PlanNegocioID, DetalleInversionID: d.ID}
+		if i, ok := idx[d.ID]; ok {
+			dep = out[i]
+		}
+		years := make([]*float64, horizonte)
+		if d.VidaUtil <= 0 {
+			dep.DepreciacionMensual = nil
+			dep.ValorRescate = nil
+		} else {
+			monthly := d.Importe / float64(d.VidaUtil)
+			monthsRemaining := d.VidaUtil
+			var sumYears float64
+			for y := 0; y < horizonte; y++ {
+				if monthsRemaining <= 0 {
+					continue
+				}
+				monthsInYear := 12
+				if monthsRemaining < 12 {
+					monthsInYear = monthsRemaining
+				}
+				val := monthly * float64(monthsInYear)
+				years[y] = ptr(val)
+				sumYears += val
+				monthsRemaining -= monthsInYear
+			}
+			dep.DepreciacionMensual = ptr(monthly)
+			dep.ValorRescate = ptr(d.Importe - sumYears)
+		}
+		dep.Anios = make([]models.DepreciacionAnual, 0, horizonte)
+		for y, v := range years {
+			dep.Anios = append(dep.Anios, models.DepreciacionAnual{
+				DepreciacionID: dep.ID,
+				PlanNegocioID:  dep.PlanNegocioID,
+				Anio:           y + 1,
+				Valor:          v,
+			})
+		}
+		if i, ok := idx[d.ID]; ok {
+			out[i] = dep
+			continue
+		}
+		idx[d.ID] = len(out)
+		out = append(out, dep)
+	}
+	return out
+}
@@ -0,0 +1,200 @@
+// Package model contiene el núcleo de cálculo financiero del plan de negocio sin
+// dependencias de base de datos: recibe una foto en memoria de las entradas del
+// plan (Inputs), aplica opcionalmente choques de sensibilidad (Shocks) y devuelve
+// todas las tablas derivadas (Outputs). Ninguna función de este paquete escribe
+// en la base de datos.
+package model
+
+import "github.com/JostinAlvaradoS/liveplan_backend_go/internal/models"
+
+// Inputs es la foto de un plan necesaria para recalcularlo. Además de las
+// tablas de entrada incluye las filas derivadas ya guardadas: se usan para
+// conservar sus IDs y los campos que el usuario captura a mano (por ejemplo
+// FlujoEfectivo.Ingresos_Prestamos o la fila mes 0 de EstadoResultados).
+type Inputs struct {
+	PlanID    uint
+	Horizonte int
+
+	Variables   models.VariablesDeSensibilidad
+	Indicadores models.IndicadoresMacro
+	Supuesto    models.Supuesto
+	Composicion models.ComposicionFinanciamiento
+	Prestamo    models.DatosPrestamo
+	Evaluacion  models.EvaluacionProyecto
+
+	Detalles        []models.DetalleInversionInicial
+	VentasDiarias   []models.VentaDiaria
+	Precios         []models.PreciosProdServ
+	Costos          []models.CostosProdServ
+	Presupuestos    []models.PresupuestoVenta
+	GastosOperacion []models.GastosOperacion
+	PoliticasVenta  []models.PoliticasVenta
+	PoliticasCompra []models.PoliticasCompra
+
+	// Filas derivadas existentes
+	Depreciaciones      []models.Depreciacion
+	VentasDinero        []models.VentasDinero
+	Ventas              []models.Ventas
+	CostosVentas        []models.CostosVentas
+	CostoMateriasPrimas []models.CostoMateriasPrimas
+	PrestamoCuotas      []models.PrestamoCuotas
+	EstadoResultados    []models.EstadoResultados
+	FlujoEfectivo       []models.FlujoEfectivo
+	BalanceGeneral      []models.BalanceGeneral
+	ConceptosEvaluacion []models.ConceptosEvaluacion
+}
+
+// Shocks son variaciones porcentuales aplicadas sobre las entradas sin tocarlas:
+// Volumen escala las unidades vendidas (VentaDiaria), Precio el precio calculado
+// y Costo los costos unitarios, encima de lo que ya indique VariablesDeSensibilidad.
+// CostoCategoria agrega un choque a los costos de las categorías indicadas (por id).
+type Shocks struct {
+	Volumen        float64          `json:"volumen"`
+	Precio         float64          `json:"precio"`
+	Costo          float64          `json:"costo"`
+	CostoCategoria map[uint]float64 `json:"costo_categoria,omitempty"`
+}
+
+// Outputs contiene todas las tablas derivadas de un recálculo
+type Outputs struct {
+	Precios             []models.PreciosProdServ
+	Costos              []models.CostosProdServ
+	Composicion         models.ComposicionFinanciamiento
+	Depreciaciones      []models.Depreciacion
+	Presupuestos        []models.PresupuestoVenta
+	VentasDinero        []models.VentasDinero
+	Prestamo            models.DatosPrestamo
+	PrestamoCuotas      []models.PrestamoCuotas
+	CostoMateriasPrimas []models.CostoMateriasPrimas
+	Ventas              []models.Ventas
+	CostosVentas        []models.CostosVentas
+	EstadoResultados    []models.EstadoResultados
+	FlujoEfectivo       []models.FlujoEfectivo
+	BalanceGeneral      []models.BalanceGeneral
+	ConceptosEvaluacion []models.ConceptosEvaluacion
+	Evaluacion          models.EvaluacionProyecto
+}
+
+// Compute ejecuta en memoria todo el recálculo del plan en el mismo orden que
+// procedimientos.Recalcular y devuelve las tablas resultantes. in no se modifica.
+func Compute(in Inputs, s Shocks) (Outputs, error) {
+	var out Outputs
+	horizonte := in.Horizonte
+	if horizonte <= 0 {
+		horizonte = models.HorizontePorDefecto
+	}
+
+	// Etapa 1: precios/costos y composición
+	out.Precios, out.Costos = ComputePrices(in.Precios, in.Costos, in.Variables, s)
+	out.Composicion = ComputeInvestmentTotal(in.Composicion, in.Detalles)
+
+	// Etapa 2: depreciaciones, presupuestos, préstamo y ventas/costos derivados
+	out.Depreciaciones = ComputeDepreciation(in.Depreciaciones, in.Detalles, horizonte)
+	out.Presupuestos, out.VentasDinero = ComputeSalesBudget(in.Presupuestos, in.VentasDiarias, in.VentasDinero, in.Indicadores, s)
+	prestamo, cuotas, err := ComputeLoan(in.Prestamo, out.Composicion, in.PrestamoCuotas, horizonte)
+	if err != nil {
+		return out, err
+	}
+	out.Prestamo, out.PrestamoCuotas = prestamo, cuotas
+	out.CostoMateriasPrimas = ComputeRawMaterialCost(in.CostoMateriasPrimas, out.VentasDinero, out.Costos)
+	out.Ventas = ComputeSales(in.Ventas, out.VentasDinero, out.Precios)
+	out.CostosVentas = ComputeCostOfSales(in.CostosVentas, out.VentasDinero, out.Costos)
+
+	// Etapas 3 a 6: estados financieros y evaluación
+	out.EstadoResultados = ComputeIncomeStatement(IncomeStatementInputs{
+		PlanID:         in.PlanID,
+		Horizonte:      horizonte,
+		Existentes:     in.EstadoResultados,
+		Ventas:         out.Ventas,
+		CostosVentas:   out.CostosVentas,
+		Gastos:         in.GastosOperacion,
+		Depreciaciones: out.Depreciaciones,
+		Detalles:       in.Detalles,
+		Cuotas:         out.PrestamoCuotas,
+		Indicadores:    in.Indicadores,
+	})
+	out.FlujoEfectivo = ComputeCashFlow(CashFlowInputs{
+		PlanID:              in.PlanID,
+		Horizonte:           horizonte,
+		Existentes:          in.FlujoEfectivo,
+		EstadoResultados:    out.EstadoResultados,
+		CostoMateriasPrimas: out.CostoMateriasPrimas,
+		PoliticasVenta:      in.PoliticasVenta,
+		PoliticasCompra:     in.PoliticasCompra,
+		Gastos:              in.GastosOperacion,
+		Cuotas:              out.PrestamoCuotas,
+		Detalles:            in.Detalles,
+	})
+	out.BalanceGeneral = ComputeBalanceSheet(BalanceSheetInputs{
+		PlanID:              in.PlanID,
+		Horizonte:           horizonte,
+		Existentes:          in.BalanceGeneral,
+		EstadoResultados:    out.EstadoResultados,
+		FlujoEfectivo:       out.FlujoEfectivo,
+		PoliticasVenta:      in.PoliticasVenta,
+		PoliticasCompra:     in.PoliticasCompra,
+		CostoMateriasPrimas: out.CostoMateriasPrimas,
+		Cuotas:              out.PrestamoCuotas,
+		Depreciaciones:      out.Depreciaciones,
+		Detalles:            in.Detalles,
+		Supuesto:            in.Supuesto,
+		Composicion:         out.Composicion,
+	})
+	out.ConceptosEvaluacion, out.Evaluacion = ComputeEvaluation(EvaluationInputs{
+		PlanID:         in.PlanID,
+		Horizonte:      horizonte,
+		Existentes:     in.ConceptosEvaluacion,
+		Composicion:    out.Composicion,
+		Evaluacion:     in.Evaluacion,
+		BalanceGeneral: out.BalanceGeneral,
+		FlujoEfectivo:  out.FlujoEfectivo,
+	})
+	return out, nil
+}
+
+// periodo identifica un mes de la proyección
+type periodo struct {
+	anio int
+	mes  int
+}
+
+// anterior devuelve el periodo previo siguiendo la convención de los
+// procedimientos: el mes 1 de un año apunta al mes 12 del año anterior.
+func (p periodo) anterior() periodo {
+	if p.mes-1 == 0 {
+		return periodo{anio: p.anio - 1, mes: 12}
+	}
+	return periodo{anio: p.anio, mes: p.mes - 1}
+}
+
+// anteriorBalance es la variante del balance general: el mes 1 del año 1 apunta
+// al mes 0 (apertura) del año 1.
+func (p periodo) anteriorBalance() periodo {
+	if p.mes == 1 {
+		if p.anio == 1 {
+			return periodo{anio: 1, mes: 0}
+		}
+		return periodo{anio: p.anio - 1, mes: 12}
+	}
+	return periodo{anio: p.anio, mes: p.mes - 1}
+}
+
+type claveProductoAnio struct {
+	producto uint
+	anio     int
+}
+
+type claveProductoPeriodo struct {
+	producto uint
+	anio     int
+	mes      int
+}
+
+func valor(p *float64) float64 {
+	if p == nil {
+		return 0
+	}
+	return *p
+}
+
+func ptr(v float64) *float64 { return &v }
@@ -0,0 +1,66 @@
+package model
+
+import (
+	"math"
+	"testing"
+
+	"github.com/JostinAlvaradoS/liveplan_backend_go/internal/models"
+)
+
+// planDePrueba es un plan chico completo: un producto de precio 10 con costos
+// de las categorías 1 y 2, un equipo depreciable, efectivo inicial, un préstamo
+// por la mitad de la inversión y políticas de venta y compra 50/50.
+func planDePrueba(horizonte int) Inputs {
+	in := Inputs{
+		PlanID:      1,
+		Horizonte:   horizonte,
+		Indicadores: models.IndicadoresMacro{PTU: 10, TasaImpuesto: 25, DiasxMes: 30},
+		Supuesto:    models.Supuesto{PorcenVentas: 10},
+		Composicion: models.ComposicionFinanciamiento{CapitalPorcentaje: 50, DeudaPorcentaje: 50},
+		Prestamo:    models.DatosPrestamo{TasaAnual: 12, PeriodosCapitalizacion: 12},
+		Evaluacion:  models.EvaluacionProyecto{TREMA: 10},
+		Detalles: []models.DetalleInversionInicial{
+			{ID: 1, TipoID: 1, Elemento: "Equipo", Importe: 12000, VidaUtil: 60},
+			{ID: 2, TipoID: 3, Elemento: "Efectivo", Importe: 3000},
+		},
+		VentasDiarias: []models.VentaDiaria{{ProductoServicioID: 1, VentaDia: intPtr(20)}},
+		Precios:       []models.PreciosProdServ{{ProductoServicioID: 1, Precio: ptr(10)}},
+		Costos: []models.CostosProdServ{
+			{ProductoServicioID: 1, CategoriaCostoID: 1},
+			{ProductoServicioID: 1, CategoriaCostoID: 2},
+		},
+		GastosOperacion: []models.GastosOperacion{{Descripcion: "Renta", Mensual: 1500, Anual: 18000}},
+	}
+	for anio := 1; anio <= horizonte; anio++ {
+		in.Presupuestos = append(in.Presupuestos, models.PresupuestoVenta{PlanNegocioID: 1, ProductoID: 1, Anio: anio, Crecimiento: ptr(5)})
+		for mes := 1; mes <= 12; mes++ {
+			in.PoliticasVenta = append(in.PoliticasVenta, models.PoliticasVenta{Anio: anio, Mes: mes, PorcentajeContado: 50, PorcentajeCredito: 50})
+			in.PoliticasCompra = append(in.PoliticasCompra, models.PoliticasCompra{Anio: anio, Mes: mes, PorcentajeContado: 50, PorcentajeCredito: 50})
+		}
+	}
+	return in
+}
+
+func intPtr(v int) *int { return &v }
+
+func cerca(a, b float64) bool { return math.Abs(a-b) < 1e-6 }
+
+func TestComputePlanDePrueba(t *testing.T) {
+	out, err := Compute(planDePrueba(2), Shocks{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := out.Composicion.Total_Inversion; got != 15000 {
+		t.Errorf("total_inversion = %v, want 15000", got)
+	}
+	if got := len(out.PrestamoCuotas); got != 24 {
+		t.Errorf("cuotas = %d, want 24", got)
+	}
+	// 12 meses por año y el mes 0 del balance
+	if len(out.EstadoResultados) != 24 || len(out.FlujoEfectivo) != 24 || len(out.BalanceGeneral) != 25 {
+		t.Errorf("filas = %d resultados, %d flujo, %d balance", len(out.EstadoResultados), len(out.FlujoEfectivo), len(out.BalanceGeneral))
+	}
+	if len(out.ConceptosEvaluacion) != 3 {
+		t.Errorf("conceptos = %d, want 3", len(out.ConceptosEvaluacion))
+	}
+}
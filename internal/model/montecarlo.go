@@ -0,0 +1,383 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+	"sort"
+
+	"github.com/JostinAlvaradoS/liveplan_backend_go/internal/models"
+)
+
+// Variables que acepta la simulación Monte Carlo. Volumen, precio y costo son
+// choques porcentuales (como Shocks); crecimiento son puntos porcentuales que
+// se suman al crecimiento de ventas de cada año; tasa_interes es la tasa
+// anual del préstamo.
+const (
+	VarVolumen     = "volumen"
+	VarPrecio      = "precio"
+	VarCosto       = "costo"
+	VarCrecimiento = "crecimiento"
+	VarTasaInteres = "tasa_interes"
+)
+
+// VariablesMonteCarlo son las variables simulables, en el orden en que se muestrean
+var VariablesMonteCarlo = []string{VarVolumen, VarPrecio, VarCosto, VarCrecimiento, VarTasaInteres}
+
+// Tipos de distribución
+const (
+	DistNormal     = "normal"
+	DistTriangular = "triangular"
+	DistUniforme   = "uniforme"
+	DistPERT       = "pert"
+)
+
+// tasaMinima es el piso de la tasa anual simulada: con tasa 0 ComputeLoan no
+// puede calcular la cuota
+const tasaMinima = 0.01
+
+// Distribution describe la distribución de una variable. Normal usa Media y
+// Desviacion; uniforme Min y Max; triangular y PERT Min, Moda y Max.
+type Distribution struct {
+	Tipo       string  `json:"tipo"`
+	Media      float64 `json:"media,omitempty"`
+	Desviacion float64 `json:"desviacion,omitempty"`
+	Min        float64 `json:"min,omitempty"`
+	Moda       float64 `json:"moda,omitempty"`
+	Max        float64 `json:"max,omitempty"`
+}
+
+// Validate revisa que los parámetros correspondan al tipo
+func (d Distribution) Validate() error {
+	switch d.Tipo {
+	case DistNormal:
+		if d.Desviacion < 0 {
+			return errors.New("normal: desviacion no puede ser negativa")
+		}
+	case DistUniforme:
+		if d.Max < d.Min {
+			return errors.New("uniforme: max debe ser mayor o igual a min")
+		}
+	case DistTriangular, DistPERT:
+		if d.Moda < d.Min || d.Moda > d.Max {
+			return fmt.Errorf("%s: se requiere min <= moda <= max", d.Tipo)
+		}
+	default:
+		return fmt.Errorf("tipo de distribución desconocido %q (normal, triangular, uniforme o pert)", d.Tipo)
+	}
+	return nil
+}
+
+// quantile devuelve el valor de la distribución para la normal estándar z
+// (u = Φ(z) es la probabilidad acumulada)
+func (d Distribution) quantile(z float64) float64 {
+	u := 0.5 * math.Erfc(-z/math.Sqrt2)
+	switch d.Tipo {
+	case DistNormal:
+		return d.Media + d.Desviacion*z
+	case DistUniforme:
+		return d.Min + u*(d.Max-d.Min)
+	case DistTriangular:
+		rango := d.Max - d.Min
+		if rango == 0 {
+			return d.Min
+		}
+		if c := (d.Moda - d.Min) / rango; u < c {
+			return d.Min + math.Sqrt(u*rango*(d.Moda-d.Min))
+		}
+		return d.Max - math.Sqrt((1-u)*rango*(d.Max-d.Moda))
+	case DistPERT:
+		rango := d.Max - d.Min
+		if rango == 0 {
+			return d.Min
+		}
+		a := 1 + 4*(d.Moda-d.Min)/rango
+		b := 1 + 4*(d.Max-d.Moda)/rango
+		return d.Min + rango*betaInversa(u, a, b)
+	}
+	return 0
+}
+
+// Correlation es la correlación entre dos variables de la simulación
+type Correlation struct {
+	A   string  `json:"a"`
+	B   string  `json:"b"`
+	Rho float64 `json:"rho"`
+}
+
+// Sampler genera escenarios aleatorios correlacionados con una cópula
+// gaussiana: normales estándar independientes se correlacionan con el factor
+// de Cholesky de la matriz de correlaciones y cada una se transforma con la
+// inversa de la distribución de su variable.
+type Sampler struct {
+	vars   []string
+	dists  []Distribution
+	chol   [][]float64
+	rng    *rand.Rand
+	normal []float64
+}
+
+// NewSampler prepara el muestreo de las variables dadas. Las correlaciones
+// deben nombrar variables con distribución y formar una matriz definida positiva.
+func NewSampler(dists map[string]Distribution, corr []Correlation, seed int64) (*Sampler, error) {
+	s := &Sampler{rng: rand.New(rand.NewSource(seed))}
+	indice := make(map[string]int)
+	for _, v := range VariablesMonteCarlo {
+		d, ok := dists[v]
+		if !ok {
+			continue
+		}
+		if err := d.Validate(); err != nil {
+			return nil, fmt.Errorf("%s: %w", v, err)
+		}
+		indice[v] = len(s.vars)
+		s.vars = append(s.vars, v)
+		s.dists = append(s.dists, d)
+	}
+	for v := range dists {
+		if _, ok := indice[v]; !ok {
+			return nil, fmt.Errorf("variable desconocida %q", v)
+		}
+	}
+	if len(s.vars) == 0 {
+		return nil, errors.New("se requiere la distribución de al menos una variable")
+	}
+
+	n := len(s.vars)
+	m := make([][]float64, n)
+	for i := range m {
+		m[i] = make([]float64, n)
+		m[i][i] = 1
+	}
+	for _, c := range corr {
+		i, okA := indice[c.A]
+		j, okB := indice[c.B]
+		if !okA || !okB {
+			return nil, fmt.Errorf("correlación %s-%s: ambas variables deben tener distribución", c.A, c.B)
+		}
+		if i == j || c.Rho < -1 || c.Rho > 1 {
+			return nil, fmt.Errorf("correlación %s-%s: se requieren dos variables distintas y rho entre -1 y 1", c.A, c.B)
+		}
+		m[i][j], m[j][i] = c.Rho, c.Rho
+	}
+	chol, err := cholesky(m)
+	if err != nil {
+		return nil, err
+	}
+	s.chol = chol
+	s.normal = make([]float64, n)
+	return s, nil
+}
+
+// Next devuelve el valor de cada variable en un escenario
+func (s *Sampler) Next() map[string]float64 {
+	for i := range s.normal {
+		s.normal[i] = s.rng.NormFloat64()
+	}
+	out := make(map[string]float64, len(s.vars))
+	for i, v := range s.vars {
+		var z float64
+		for j := 0; j <= i; j++ {
+			z += s.chol[i][j] * s.normal[j]
+		}
+		out[v] = s.dists[i].quantile(z)
+	}
+	return out
+}
+
+// ApplyDraw devuelve una copia de in con los valores de un escenario de
+// Sampler y los choques para Compute. in no se modifica.
+func ApplyDraw(in Inputs, draw map[string]float64) (Inputs, Shocks) {
+	out := in
+	if delta, ok := draw[VarCrecimiento]; ok {
+		out.Presupuestos = append([]models.PresupuestoVenta(nil), in.Presupuestos...)
+		for i, p := range out.Presupuestos {
+			out.Presupuestos[i].Crecimiento = ptr(valor(p.Crecimiento) + delta)
+		}
+	}
+	if tasa, ok := draw[VarTasaInteres]; ok {
+		fijarTasaAnual(&out.Prestamo, math.Max(tasa, tasaMinima))
+	}
+	return out, Shocks{Volumen: draw[VarVolumen], Precio: draw[VarPrecio], Costo: draw[VarCosto]}
+}
+
+// cholesky devuelve L triangular inferior con L·Lᵀ = m
+func cholesky(m [][]float64) ([][]float64, error) {
+	n := len(m)
+	l := make([][]float64, n)
+	for i := range l {
+		l[i] = make([]float64, n)
+	}
+	for i := 0; i < n; i++ {
+		for j := 0; j <= i; j++ {
+			suma := m[i][j]
+			for k := 0; k < j; k++ {
+				suma -= l[i][k] * l[j][k]
+			}
+			if i == j {
+				if suma <= 0 {
+					return nil, errors.New("las correlaciones no forman una matriz definida positiva")
+				}
+				l[i][i] = math.Sqrt(suma)
+			} else {
+				l[i][j] = suma / l[j][j]
+			}
+		}
+	}
+	return l, nil
+}
+
+// betaInversa invierte por bisección la función beta incompleta regularizada
+func betaInversa(u, a, b float64) float64 {
+	lo, hi := 0.0, 1.0
+	for i := 0; i < 60; i++ {
+		x := (lo + hi) / 2
+		if betaIncompleta(x, a, b) < u {
+			lo = x
+		} else {
+			hi = x
+		}
+	}
+	return (lo + hi) / 2
+}
+
+// betaIncompleta es I_x(a, b), con la fracción continua de Lentz
+func betaIncompleta(x, a, b float64) float64 {
+	if x <= 0 {
+		return 0
+	}
+	if x >= 1 {
+		return 1
+	}
+	la, _ := math.Lgamma(a)
+	lb, _ := math.Lgamma(b)
+	lab, _ := math.Lgamma(a + b)
+	frente := math.Exp(lab - la - lb + a*math.Log(x) + b*math.Log(1-x))
+	// la fracción converge rápido para x < (a+1)/(a+b+2); si no, usar la simetría
+	if x > (a+1)/(a+b+2) {
+		return 1 - frente*fraccionBeta(1-x, b, a)/b
+	}
+	return frente * fraccionBeta(x, a, b) / a
+}
+
+func fraccionBeta(x, a, b float64) float64 {
+	const (
+		minimo    = 1e-300
+		precision = 1e-12
+	)
+	c, d := 1.0, 1-(a+b)*x/(a+1)
+	if math.Abs(d) < minimo {
+		d = minimo
+	}
+	d = 1 / d
+	f := d
+	for m := 1; m <= 200; m++ {
+		fm := float64(m)
+		for paso := 0; paso < 2; paso++ {
+			var num float64
+			if paso == 0 {
+				num = fm * (b - fm) * x / ((a + 2*fm - 1) * (a + 2*fm))
+			} else {
+				num = -(a + fm) * (a + b + fm) * x / ((a + 2*fm) * (a + 2*fm + 1))
+			}
+			d = 1 + num*d
+			if math.Abs(d) < minimo {
+				d = minimo
+			}
+			c = 1 + num/c
+			if math.Abs(c) < minimo {
+				c = minimo
+			}
+			d = 1 / d
+			f *= d * c
+			if paso == 1 && math.Abs(d*c-1) < precision {
+				return f
+			}
+		}
+	}
+	return f
+}
+
+// Percentil es el valor bajo el que queda el P por ciento de las simulaciones
+type Percentil struct {
+	P     float64 `json:"p"`
+	Valor float64 `json:"valor"`
+}
+
+// Intervalo es una barra del histograma: las simulaciones en [Desde, Hasta)
+// (la última incluye Hasta)
+type Intervalo struct {
+	Desde      float64 `json:"desde"`
+	Hasta      float64 `json:"hasta"`
+	Frecuencia int     `json:"frecuencia"`
+}
+
+// Summary resume los valores simulados de un indicador
+type Summary struct {
+	Media       float64     `json:"media"`
+	Desviacion  float64     `json:"desviacion"`
+	Min         float64     `json:"min"`
+	Max         float64     `json:"max"`
+	Percentiles []Percentil `json:"percentiles"`
+	Histograma  []Intervalo `json:"histograma"`
+}
+
+// percentilesReportados son los percentiles de Summary
+var percentilesReportados = []float64{5, 10, 25, 50, 75, 90, 95}
+
+// Summarize calcula media, desviación, percentiles (interpolados como
+// PERCENTILE.INC de Excel) y un histograma de intervalos iguales
+func Summarize(valores []float64, intervalos int) Summary {
+	var s Summary
+	n := len(valores)
+	if n == 0 {
+		return s
+	}
+	orden := append([]float64(nil), valores...)
+	sort.Float64s(orden)
+	s.Min, s.Max = orden[0], orden[n-1]
+	for _, v := range orden {
+		s.Media += v
+	}
+	s.Media /= float64(n)
+	if n > 1 {
+		for _, v := range orden {
+			s.Desviacion += (v - s.Media) * (v - s.Media)
+		}
+		s.Desviacion = math.Sqrt(s.Desviacion / float64(n-1))
+	}
+	for _, p := range percentilesReportados {
+		pos := p / 100 * float64(n-1)
+		i := int(pos)
+		v := orden[i]
+		if i+1 < n {
+			v += (pos - float64(i)) * (orden[i+1] - orden[i])
+		}
+		s.Percentiles = append(s.Percentiles, Percentil{P: p, Valor: v})
+	}
+
+	if intervalos <= 0 {
+		return s
+	}
+	ancho := (s.Max - s.Min) / float64(intervalos)
+	if ancho == 0 {
+		// todos los valores son iguales: un solo intervalo
+		s.Histograma = []Intervalo{{Desde: s.Min, Hasta: s.Max, Frecuencia: n}}
+		return s
+	}
+	s.Histograma = make([]Intervalo, intervalos)
+	for i := range s.Histograma {
+		s.Histograma[i].Desde = s.Min + float64(i)*ancho
+		s.Histograma[i].Hasta = s.Min + float64(i+1)*ancho
+	}
+	s.Histograma[intervalos-1].Hasta = s.Max
+	for _, v := range orden {
+		i := int((v - s.Min) / ancho)
+		if i >= intervalos {
+			i = intervalos - 1
+		}
+		s.Histograma[i].Frecuencia++
+	}
+	return s
+}
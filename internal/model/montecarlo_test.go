@@ -0,0 +1,219 @@
+package model
+
+import (
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestDistributionValidate(t *testing.T) {
+	tests := []struct {
+		d     Distribution
+		error string
+	}{
+		{Distribution{Tipo: DistNormal, Media: 1, Desviacion: 2}, ""},
+		{Distribution{Tipo: DistNormal, Desviacion: -1}, "desviacion"},
+		{Distribution{Tipo: DistUniforme, Min: 1, Max: 1}, ""},
+		{Distribution{Tipo: DistUniforme, Min: 2, Max: 1}, "max"},
+		{Distribution{Tipo: DistTriangular, Min: 0, Moda: 0, Max: 1}, ""},
+		{Distribution{Tipo: DistTriangular, Min: 0, Moda: 2, Max: 1}, "moda"},
+		{Distribution{Tipo: DistPERT, Min: 0, Moda: -1, Max: 1}, "moda"},
+		{Distribution{Tipo: "lognormal"}, "desconocido"},
+	}
+	for _, tt := range tests {
+		err := tt.d.Validate()
+		if tt.error == "" && err != nil || tt.error != "" && (err == nil || !strings.Contains(err.Error(), tt.error)) {
+			t.Errorf("Validate(%+v) = %v, want %q", tt.d, err, tt.error)
+		}
+	}
+}
+
+func TestNewSamplerErrores(t *testing.T) {
+	normal := Distribution{Tipo: DistNormal, Desviacion: 1}
+	tests := []struct {
+		nombre string
+		dists  map[string]Distribution
+		corr   []Correlation
+		error  string
+	}{
+		{"sin variables", nil, nil, "al menos una"},
+		{"variable desconocida", map[string]Distribution{"demanda": normal}, nil, "desconocida"},
+		{"distribución inválida", map[string]Distribution{VarPrecio: {Tipo: DistUniforme, Min: 1}}, nil, "precio"},
+		{"correlación sin distribución", map[string]Distribution{VarPrecio: normal},
+			[]Correlation{{A: VarPrecio, B: VarCosto, Rho: 0.5}}, "ambas variables"},
+		{"misma variable", map[string]Distribution{VarPrecio: normal},
+			[]Correlation{{A: VarPrecio, B: VarPrecio, Rho: 0.5}}, "distintas"},
+		{"rho fuera de rango", map[string]Distribution{VarPrecio: normal, VarCosto: normal},
+			[]Correlation{{A: VarPrecio, B: VarCosto, Rho: 1.5}}, "rho"},
+		{"no definida positiva", map[string]Distribution{VarPrecio: normal, VarCosto: normal, VarVolumen: normal},
+			[]Correlation{{A: VarPrecio, B: VarCosto, Rho: 0.9}, {A: VarPrecio, B: VarVolumen, Rho: 0.9}, {A: VarCosto, B: VarVolumen, Rho: -0.9}},
+			"definida positiva"},
+	}
+	for _, tt := range tests {
+		if _, err := NewSampler(tt.dists, tt.corr, 1); err == nil || !strings.Contains(err.Error(), tt.error) {
+			t.Errorf("%s: err = %v, want %q", tt.nombre, err, tt.error)
+		}
+	}
+}
+
+// momentos devuelve media y desviación de una muestra
+func momentos(xs []float64) (media, desviacion float64) {
+	for _, x := range xs {
+		media += x
+	}
+	media /= float64(len(xs))
+	for _, x := range xs {
+		desviacion += (x - media) * (x - media)
+	}
+	return media, math.Sqrt(desviacion / float64(len(xs)-1))
+}
+
+func TestSamplerDistribuciones(t *testing.T) {
+	const n = 20000
+	tests := []struct {
+		variable       string
+		d              Distribution
+		media, desv    float64
+		minimo, maximo float64
+	}{
+		{VarVolumen, Distribution{Tipo: DistNormal, Media: 5, Desviacion: 2}, 5, 2, math.Inf(-1), math.Inf(1)},
+		{VarPrecio, Distribution{Tipo: DistUniforme, Min: -10, Max: 10}, 0, 20 / math.Sqrt(12), -10, 10},
+		// media (min+moda+max)/3, varianza (a²+b²+c²-ab-ac-bc)/18
+		{VarCosto, Distribution{Tipo: DistTriangular, Min: 0, Moda: 3, Max: 9}, 4, math.Sqrt(63.0 / 18), 0, 9},
+		// media (min+4moda+max)/6, varianza (media-min)(max-media)/7
+		{VarCrecimiento, Distribution{Tipo: DistPERT, Min: 0, Moda: 3, Max: 9}, 3.5, math.Sqrt(3.5 * 5.5 / 7), 0, 9},
+	}
+	dists := make(map[string]Distribution)
+	for _, tt := range tests {
+		dists[tt.variable] = tt.d
+	}
+	s, err := NewSampler(dists, nil, 42)
+	if err != nil {
+		t.Fatal(err)
+	}
+	muestras := make(map[string][]float64)
+	for i := 0; i < n; i++ {
+		for v, x := range s.Next() {
+			muestras[v] = append(muestras[v], x)
+		}
+	}
+	for _, tt := range tests {
+		xs := muestras[tt.variable]
+		media, desv := momentos(xs)
+		if math.Abs(media-tt.media) > 0.05*tt.desv || math.Abs(desv-tt.desv) > 0.05*tt.desv {
+			t.Errorf("%s: media %.3f desviación %.3f, want %.3f %.3f", tt.d.Tipo, media, desv, tt.media, tt.desv)
+		}
+		for _, x := range xs {
+			if x < tt.minimo || x > tt.maximo {
+				t.Errorf("%s: %v fuera de [%v, %v]", tt.d.Tipo, x, tt.minimo, tt.maximo)
+				break
+			}
+		}
+	}
+}
+
+func TestSamplerCorrelacion(t *testing.T) {
+	normal := Distribution{Tipo: DistNormal, Desviacion: 1}
+	nuevo := func() *Sampler {
+		s, err := NewSampler(map[string]Distribution{VarVolumen: normal, VarPrecio: normal},
+			[]Correlation{{A: VarPrecio, B: VarVolumen, Rho: -0.8}}, 7)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return s
+	}
+	s := nuevo()
+	const n = 20000
+	var sxy, sxx, syy float64
+	for i := 0; i < n; i++ {
+		d := s.Next()
+		x, y := d[VarVolumen], d[VarPrecio]
+		sxy += x * y
+		sxx += x * x
+		syy += y * y
+	}
+	if rho := sxy / math.Sqrt(sxx*syy); math.Abs(rho+0.8) > 0.02 {
+		t.Errorf("correlación = %.3f, want -0.8", rho)
+	}
+	// la misma semilla repite los escenarios
+	a, b := nuevo(), nuevo()
+	for i := 0; i < 10; i++ {
+		if da, db := a.Next(), b.Next(); !reflect.DeepEqual(da, db) {
+			t.Fatalf("escenario %d: %v != %v", i, da, db)
+		}
+	}
+}
+
+func TestBetaInversa(t *testing.T) {
+	tests := []struct {
+		u, a, b float64
+		want    float64
+	}{
+		{0.5, 2, 2, 0.5},
+		{0.3, 1, 1, 0.3},
+		// I_x(2, 1) = x²
+		{0.49, 2, 1, 0.7},
+		// I_x(1, 3) = 1 - (1-x)³
+		{0.875, 1, 3, 0.5},
+		// I_x(2, 2) = 3x² - 2x³
+		{0.15625, 2, 2, 0.25},
+		{0, 3, 2, 0},
+		{1, 3, 2, 1},
+	}
+	for _, tt := range tests {
+		got := betaInversa(tt.u, tt.a, tt.b)
+		if math.Abs(got-tt.want) > 1e-6 {
+			t.Errorf("betaInversa(%v, %v, %v) = %v, want %v", tt.u, tt.a, tt.b, got, tt.want)
+		}
+		if tt.u > 0 && tt.u < 1 {
+			if i := betaIncompleta(got, tt.a, tt.b); math.Abs(i-tt.u) > 1e-9 {
+				t.Errorf("betaIncompleta(%v, %v, %v) = %v, want %v", got, tt.a, tt.b, i, tt.u)
+			}
+		}
+	}
+}
+
+func TestSummarize(t *testing.T) {
+	tests := []struct {
+		nombre      string
+		valores     []float64
+		intervalos  int
+		media, desv float64
+		percentiles map[float64]float64
+		histograma  []int
+	}{
+		{"vacío", nil, 3, 0, 0, nil, nil},
+		{"uno a cinco", []float64{5, 1, 4, 2, 3}, 2, 3, math.Sqrt(2.5),
+			map[float64]float64{5: 1.2, 25: 2, 50: 3, 90: 4.6, 95: 4.8}, []int{2, 3}},
+		{"iguales", []float64{7, 7, 7}, 4, 7, 0, map[float64]float64{5: 7, 95: 7}, []int{3}},
+		{"un valor", []float64{4}, 2, 4, 0, map[float64]float64{50: 4}, []int{1}},
+		{"sin histograma", []float64{1, 2}, 0, 1.5, math.Sqrt(0.5), map[float64]float64{50: 1.5}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.nombre, func(t *testing.T) {
+			s := Summarize(tt.valores, tt.intervalos)
+			if !cerca(s.Media, tt.media) || !cerca(s.Desviacion, tt.desv) {
+				t.Errorf("media %v desviación %v, want %v %v", s.Media, s.Desviacion, tt.media, tt.desv)
+			}
+			for _, p := range s.Percentiles {
+				if want, ok := tt.percentiles[p.P]; ok && !cerca(p.Valor, want) {
+					t.Errorf("percentil %v = %v, want %v", p.P, p.Valor, want)
+				}
+			}
+			if len(tt.valores) > 0 && len(s.Percentiles) != len(percentilesReportados) {
+				t.Errorf("percentiles = %d, want %d", len(s.Percentiles), len(percentilesReportados))
+			}
+			var frec []int
+			for _, in := range s.Histograma {
+				frec = append(frec, in.Frecuencia)
+			}
+			if !reflect.DeepEqual(frec, tt.histograma) {
+				t.Errorf("histograma = %v, want %v", frec, tt.histograma)
+			}
+			if h := s.Histograma; len(h) > 0 && (h[0].Desde != s.Min || h[len(h)-1].Hasta != s.Max) {
+				t.Errorf("histograma de %v a %v, want %v a %v", h[0].Desde, h[len(h)-1].Hasta, s.Min, s.Max)
+			}
+		})
+	}
+}
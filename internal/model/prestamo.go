@@ -0,0 +1,103 @@
+package model
+
+import (
+	"fmt"
+	"math"
+	"sort"
+
+	"github.com/JostinAlvaradoS/liveplan_backend_go/internal/models"
+)
+
+// ComputeLoan calcula la tabla de amortización del préstamo con cuota fija:
+//
+//	P = deuda% * total_inversion (si difiere del monto capturado)
+//	tasa_mensual = tasa_anual / periodos_capitalizacion
+//	cuota = P * r / (1 - (1+r)^-n), salvo que DatosPrestamo ya tenga cuota
+//
+// n es PeriodosAmortizacion, o el número de cuotas existentes, o 12 * horizonte.
+// Si las cuotas existentes no son exactamente n se devuelven n filas nuevas (sin
+// ID) para que la capa de persistencia las recree.
+func ComputeLoan(dp models.DatosPrestamo, comp models.ComposicionFinanciamiento, existentes []models.PrestamoCuotas, horizonte int) (models.DatosPrestamo, []models.PrestamoCuotas, error) {
+	P := dp.Monto
+	if comp.Total_Inversion > 0 && comp.DeudaPorcentaje > 0 {
+		derived := comp.Total_Inversion * (comp.DeudaPorcentaje / 100.0)
+		if dp.Monto == 0 || math.Abs(derived-dp.Monto) > 0.01 {
+			P = derived
+			dp.Monto = P
+		}
+	}
+
+	n := dp.PeriodosAmortizacion
+	if n <= 0 {
+		if len(existentes) > 0 {
+			n = len(existentes)
+		} else {
+			n = 12 * horizonte
+		}
+	}
+
+	if dp.TasaAnual != 0 && dp.PeriodosCapitalizacion > 0 {
+		dp.TasaMensual = dp.TasaAnual / float64(dp.PeriodosCapitalizacion)
+	}
+
+	var r float64
+	if dp.TasaMensual != 0 {
+		r = dp.TasaMensual / 100.0
+	} else if dp.TasaAnual != 0 {
+		annualRate := dp.TasaAnual / 100.0
+		r = math.Pow(1+annualRate, 1.0/float64(dp.PeriodosCapitalizacion)) - 1.0
+	} else {
+		return dp, nil, fmt.Errorf("no tasa de interés (anual o mensual) proporcionada para plan %d", dp.PlanNegocioID)
+	}
+
+	var cuota float64
+	if dp.Cuota != 0 {
+		cuota = dp.Cuota
+	} else if r == 0 {
+		cuota = P / float64(n)
+	} else {
+		cuota = P * r / (1 - math.Pow(1+r, -float64(n)))
+	}
+
+	cuotas := append([]models.PrestamoCuotas(nil), existentes...)
+	sort.SliceStable(cuotas, func(i, j int) bool { return cuotas[i].PeriodoMes < cuotas[j].PeriodoMes })
+	if len(cuotas) != n {
+		cuotas = make([]models.PrestamoCuotas, 0, n)
+		for m := 1; m <= n; m++ {
+			cuotas = append(cuotas, models.PrestamoCuotas{
+				PlanNegocioID: dp.PlanNegocioID,
+				PeriodoMes:    m,
+				Anio:          (m-1)/12 + 1,
+				Mes:           (m-1)%12 + 1,
+			})
+		}
+	}
+
+	saldo := P
+	for i := 0; i < n; i++ {
+		saldoInicial := saldo
+		interes := saldoInicial * r
+		amort := cuota - interes
+		if amort < 0 {
+			amort = 0
+		}
+		if saldoInicial < cuota {
+			cuota = saldoInicial + interes
+			amort = saldoInicial
+		}
+		saldo = saldoInicial - amort
+		if saldo < 0 {
+			saldo = 0
+		}
+		cuotas[i].SaldoInicial = saldoInicial
+		cuotas[i].Interes = interes
+		cuotas[i].Amortizacion = amort
+		cuotas[i].CuotaTotal = cuota
+		cuotas[i].SaldoPendiente = saldo
+	}
+
+	if dp.Cuota == 0 {
+		dp.Cuota = cuota
+	}
+	return dp, cuotas, nil
+}
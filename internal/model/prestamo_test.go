@@ -0,0 +1,114 @@
+package model
+
+import (
+	"testing"
+
+	"github.com/JostinAlvaradoS/liveplan_backend_go/internal/models"
+)
+
+func TestComputeLoan(t *testing.T) {
+	tests := []struct {
+		nombre     string
+		dp         models.DatosPrestamo
+		comp       models.ComposicionFinanciamiento
+		existentes []models.PrestamoCuotas
+		horizonte  int
+		monto      float64
+		cuota      float64
+		intereses  []float64 // por cuota, en orden
+		saldos     []float64
+	}{
+		{
+			nombre:    "cuota fija con tasa mensual",
+			dp:        models.DatosPrestamo{Monto: 1000, TasaMensual: 1, PeriodosAmortizacion: 2},
+			monto:     1000,
+			cuota:     507.5124378109438,
+			intereses: []float64{10, 5.024875621890562},
+			saldos:    []float64{502.4875621890562, 0},
+		},
+		{
+			nombre:    "monto de la composición y tasa anual capitalizable",
+			dp:        models.DatosPrestamo{Monto: 300, TasaAnual: 12, PeriodosCapitalizacion: 12},
+			comp:      models.ComposicionFinanciamiento{DeudaPorcentaje: 50, Total_Inversion: 2000},
+			horizonte: 1,
+			monto:     1000,
+			cuota:     88.8487886783416,
+			intereses: []float64{10},
+		},
+		{
+			// la última cuota solo paga el saldo y sus intereses
+			nombre:    "cuota capturada",
+			dp:        models.DatosPrestamo{Monto: 1000, TasaMensual: 1, PeriodosAmortizacion: 2, Cuota: 600},
+			monto:     1000,
+			cuota:     600,
+			intereses: []float64{10, 4.1},
+			saldos:    []float64{410, 0},
+		},
+		{
+			nombre:     "tantas cuotas como existentes",
+			dp:         models.DatosPrestamo{Monto: 1000, TasaMensual: 1},
+			existentes: []models.PrestamoCuotas{{PeriodoMes: 1, Anio: 1, Mes: 1}, {PeriodoMes: 2, Anio: 1, Mes: 2}},
+			horizonte:  5,
+			monto:      1000,
+			cuota:      507.5124378109438,
+			saldos:     []float64{502.4875621890562, 0},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.nombre, func(t *testing.T) {
+			dp, cuotas, err := ComputeLoan(tt.dp, tt.comp, tt.existentes, tt.horizonte)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !cerca(dp.Monto, tt.monto) || !cerca(dp.Cuota, tt.cuota) {
+				t.Errorf("monto %v cuota %v, want %v %v", dp.Monto, dp.Cuota, tt.monto, tt.cuota)
+			}
+			n := tt.dp.PeriodosAmortizacion
+			if n == 0 && len(tt.existentes) > 0 {
+				n = len(tt.existentes)
+			} else if n == 0 {
+				n = 12 * tt.horizonte
+			}
+			if len(cuotas) != n {
+				t.Fatalf("cuotas = %d, want %d", len(cuotas), n)
+			}
+			for i, c := range cuotas {
+				if c.PeriodoMes != i+1 || c.Anio != i/12+1 || c.Mes != i%12+1 {
+					t.Errorf("cuota %d: periodo %d año %d mes %d", i, c.PeriodoMes, c.Anio, c.Mes)
+				}
+				if i < len(tt.intereses) && !cerca(c.Interes, tt.intereses[i]) {
+					t.Errorf("cuota %d: interés %v, want %v", i, c.Interes, tt.intereses[i])
+				}
+				if i < len(tt.saldos) && !cerca(c.SaldoPendiente, tt.saldos[i]) {
+					t.Errorf("cuota %d: saldo %v, want %v", i, c.SaldoPendiente, tt.saldos[i])
+				}
+			}
+			if ultima := cuotas[len(cuotas)-1]; ultima.SaldoPendiente > 1e-6 {
+				t.Errorf("saldo final = %v, want 0", ultima.SaldoPendiente)
+			}
+		})
+	}
+}
+
+func TestComputeLoanConservaCuotas(t *testing.T) {
+	existentes := []models.PrestamoCuotas{
+		{ID: 8, PeriodoMes: 2, Anio: 1, Mes: 2},
+		{ID: 7, PeriodoMes: 1, Anio: 1, Mes: 1},
+	}
+	_, cuotas, err := ComputeLoan(models.DatosPrestamo{Monto: 1000, TasaMensual: 1}, models.ComposicionFinanciamiento{}, existentes, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cuotas[0].ID != 7 || cuotas[1].ID != 8 {
+		t.Errorf("ids = %d %d, want 7 8", cuotas[0].ID, cuotas[1].ID)
+	}
+	if existentes[0].SaldoInicial != 0 {
+		t.Error("ComputeLoan modificó las cuotas existentes")
+	}
+}
+
+func TestComputeLoanSinTasa(t *testing.T) {
+	if _, _, err := ComputeLoan(models.DatosPrestamo{PlanNegocioID: 3, Monto: 1000}, models.ComposicionFinanciamiento{}, nil, 1); err == nil {
+		t.Error("err = nil, want error sin tasa")
+	}
+}
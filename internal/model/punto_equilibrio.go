@@ -0,0 +1,158 @@
+package model
+
+import (
+	"sort"
+
+	"github.com/JostinAlvaradoS/liveplan_backend_go/internal/models"
+)
+
+// BreakEvenInputs son las tablas del plan que usa ComputeBreakEven
+type BreakEvenInputs struct {
+	Horizonte      int
+	Productos      map[uint]string // nombres por id
+	Precios        []models.PreciosProdServ
+	Costos         []models.CostosProdServ
+	VentasDinero   []models.VentasDinero
+	Gastos         []models.GastosOperacion
+	Depreciaciones []models.Depreciacion
+	Detalles       []models.DetalleInversionInicial
+	Cuotas         []models.PrestamoCuotas
+}
+
+// PuntoEquilibrioProducto es la parte de un producto en el punto de
+// equilibrio del año según la mezcla de ventas proyectada
+type PuntoEquilibrioProducto struct {
+	ProductoID          uint     `json:"producto_id"`
+	Nombre              string   `json:"nombre"`
+	Precio              float64  `json:"precio"`
+	CostoVariable       float64  `json:"costo_variable"`
+	MargenContribucion  float64  `json:"margen_contribucion"`
+	Participacion       float64  `json:"participacion"`
+	UnidadesProyectadas float64  `json:"unidades_proyectadas"`
+	IngresosProyectados float64  `json:"ingresos_proyectados"`
+	UnidadesEquilibrio  *float64 `json:"unidades_equilibrio"`
+	IngresosEquilibrio  *float64 `json:"ingresos_equilibrio"`
+}
+
+// PuntoEquilibrioAnio es el punto de equilibrio del plan en un año. Los
+// valores de equilibrio y el margen de seguridad son nil si el margen de
+// contribución ponderado no es positivo (o no hay ventas proyectadas).
+type PuntoEquilibrioAnio struct {
+	Anio                        int      `json:"anio"`
+	GastosOperacion             float64  `json:"gastos_operacion"`
+	Depreciacion                float64  `json:"depreciacion"`
+	Intereses                   float64  `json:"intereses"`
+	CostosFijos                 float64  `json:"costos_fijos"`
+	MargenContribucionPonderado float64  `json:"margen_contribucion_ponderado"`
+	UnidadesProyectadas         float64  `json:"unidades_proyectadas"`
+	IngresosProyectados         float64  `json:"ingresos_proyectados"`
+	UnidadesEquilibrio          *float64 `json:"unidades_equilibrio"`
+	IngresosEquilibrio          *float64 `json:"ingresos_equilibrio"`
+	// MargenSeguridad es (ingresos proyectados - ingresos de equilibrio) en
+	// importe y en porcentaje de los ingresos proyectados
+	MargenSeguridad           *float64                  `json:"margen_seguridad"`
+	MargenSeguridadPorcentaje *float64                  `json:"margen_seguridad_porcentaje"`
+	Productos                 []PuntoEquilibrioProducto `json:"productos"`
+}
+
+// ComputeBreakEven calcula el punto de equilibrio de cada año del horizonte:
+//
+//	costos fijos = gastos de operación + depreciación y amortización + intereses del préstamo del año
+//	margen de contribución = precio_calc - suma(CostosProdServ) del producto
+//	participación = unidades del producto / unidades del año (VentasDinero.anual)
+//	unidades de equilibrio = costos fijos / suma(participación * margen de contribución)
+//
+// Los gastos y la depreciación son los mismos cada mes, como en el estado de
+// resultados.
+func ComputeBreakEven(in BreakEvenInputs) []PuntoEquilibrioAnio {
+	precio := precioPorProducto(in.Precios)
+	costo := make(map[uint]float64)
+	for _, c := range in.Costos {
+		if c.CostoCalc != nil {
+			costo[c.ProductoServicioID] += *c.CostoCalc
+		} else if c.Costo != nil {
+			costo[c.ProductoServicioID] += *c.Costo
+		}
+	}
+
+	var gastos float64
+	for _, g := range in.Gastos {
+		gastos += g.Mensual * 12
+	}
+	tipoDetalle := make(map[uint]uint, len(in.Detalles))
+	for _, d := range in.Detalles {
+		tipoDetalle[d.ID] = d.TipoID
+	}
+	var depreciacion float64
+	for _, dep := range in.Depreciaciones {
+		if t := tipoDetalle[dep.DetalleInversionID]; t == 1 || t == 2 {
+			depreciacion += valor(dep.DepreciacionMensual) * 12
+		}
+	}
+	intereses := make(map[int]float64)
+	for _, c := range in.Cuotas {
+		intereses[c.Anio] += c.Interes
+	}
+	unidades := make(map[claveProductoAnio]float64)
+	for _, vd := range in.VentasDinero {
+		unidades[claveProductoAnio{vd.ProductoID, vd.Anio}] += vd.Anual
+	}
+	var productos []uint
+	vistos := make(map[uint]bool)
+	for _, vd := range in.VentasDinero {
+		if !vistos[vd.ProductoID] {
+			vistos[vd.ProductoID] = true
+			productos = append(productos, vd.ProductoID)
+		}
+	}
+	sort.Slice(productos, func(i, j int) bool { return productos[i] < productos[j] })
+
+	out := make([]PuntoEquilibrioAnio, 0, in.Horizonte)
+	for anio := 1; anio <= in.Horizonte; anio++ {
+		a := PuntoEquilibrioAnio{
+			Anio:            anio,
+			GastosOperacion: gastos,
+			Depreciacion:    depreciacion,
+			Intereses:       intereses[anio],
+		}
+		a.CostosFijos = a.GastosOperacion + a.Depreciacion + a.Intereses
+		for _, id := range productos {
+			p := PuntoEquilibrioProducto{
+				ProductoID:          id,
+				Nombre:              in.Productos[id],
+				Precio:              precio[id],
+				CostoVariable:       costo[id],
+				UnidadesProyectadas: unidades[claveProductoAnio{id, anio}],
+			}
+			p.MargenContribucion = p.Precio - p.CostoVariable
+			p.IngresosProyectados = p.UnidadesProyectadas * p.Precio
+			a.UnidadesProyectadas += p.UnidadesProyectadas
+			a.IngresosProyectados += p.IngresosProyectados
+			a.Productos = append(a.Productos, p)
+		}
+		if a.UnidadesProyectadas > 0 {
+			for i := range a.Productos {
+				p := &a.Productos[i]
+				p.Participacion = p.UnidadesProyectadas / a.UnidadesProyectadas
+				a.MargenContribucionPonderado += p.Participacion * p.MargenContribucion
+			}
+		}
+		if a.MargenContribucionPonderado > 0 {
+			a.UnidadesEquilibrio = ptr(a.CostosFijos / a.MargenContribucionPonderado)
+			var ingresos float64
+			for i := range a.Productos {
+				p := &a.Productos[i]
+				p.UnidadesEquilibrio = ptr(*a.UnidadesEquilibrio * p.Participacion)
+				p.IngresosEquilibrio = ptr(*p.UnidadesEquilibrio * p.Precio)
+				ingresos += *p.IngresosEquilibrio
+			}
+			a.IngresosEquilibrio = ptr(ingresos)
+			a.MargenSeguridad = ptr(a.IngresosProyectados - ingresos)
+			if a.IngresosProyectados != 0 {
+				a.MargenSeguridadPorcentaje = ptr(*a.MargenSeguridad / a.IngresosProyectados * 100)
+			}
+		}
+		out = append(out, a)
+	}
+	return out
+}
@@ -0,0 +1,114 @@
+package model
+
+import (
+	"testing"
+
+	"github.com/JostinAlvaradoS/liveplan_backend_go/internal/models"
+)
+
+func TestComputeBreakEven(t *testing.T) {
+	in := BreakEvenInputs{
+		Horizonte: 3,
+		Productos: map[uint]string{1: "Café", 2: "Pastel"},
+		Precios: []models.PreciosProdServ{
+			{ProductoServicioID: 1, Precio: ptr(99), PrecioCalc: ptr(10)},
+			{ProductoServicioID: 2, Precio: ptr(20)},
+		},
+		Costos: []models.CostosProdServ{
+			{ProductoServicioID: 1, CostoCalc: ptr(4), Costo: ptr(99)},
+			{ProductoServicioID: 1, Costo: ptr(2)},
+			{ProductoServicioID: 2, CostoCalc: ptr(14)},
+		},
+		VentasDinero: []models.VentasDinero{
+			{ProductoID: 2, Anio: 1, Anual: 2000},
+			{ProductoID: 1, Anio: 1, Anual: 2000},
+			{ProductoID: 1, Anio: 2, Anual: 1000},
+			{ProductoID: 2, Anio: 2, Anual: 3000},
+		},
+		Gastos: []models.GastosOperacion{{Mensual: 200}},
+		Depreciaciones: []models.Depreciacion{
+			{DetalleInversionID: 1, DepreciacionMensual: ptr(25)},
+			// el efectivo no se deprecia
+			{DetalleInversionID: 3, DepreciacionMensual: ptr(100)},
+		},
+		Detalles: []models.DetalleInversionInicial{{ID: 1, TipoID: 1}, {ID: 3, TipoID: 3}},
+		Cuotas:   []models.PrestamoCuotas{{Anio: 1, Mes: 1, Interes: 200}, {Anio: 1, Mes: 2, Interes: 100}},
+	}
+	anios := ComputeBreakEven(in)
+	if len(anios) != 3 {
+		t.Fatalf("años = %d, want 3", len(anios))
+	}
+
+	tests := []struct {
+		anio                  int
+		fijos, margen         float64
+		unidades, ingresos    *float64
+		seguridad, porcentaje *float64
+		unidadesProducto      []float64
+	}{
+		// 2400 + 300 + 300; mezcla 50/50 de márgenes 4 y 6
+		{1, 3000, 5, ptr(600), ptr(9000), ptr(51000), ptr(85), []float64{300, 300}},
+		// mezcla 25/75: 0.25*4 + 0.75*6
+		{2, 2700, 5.5, ptr(2700 / 5.5), ptr(2700 / 5.5 * 17.5), ptr(70000 - 2700/5.5*17.5), ptr((70000 - 2700/5.5*17.5) / 700),
+			[]float64{2700 / 5.5 * 0.25, 2700 / 5.5 * 0.75}},
+		// sin ventas proyectadas no hay equilibrio
+		{3, 2700, 0, nil, nil, nil, nil, nil},
+	}
+	for _, tt := range tests {
+		a := anios[tt.anio-1]
+		if a.Anio != tt.anio || !cerca(a.CostosFijos, tt.fijos) || !cerca(a.MargenContribucionPonderado, tt.margen) {
+			t.Errorf("año %d: costos fijos %v margen %v, want %v %v", tt.anio, a.CostosFijos, a.MargenContribucionPonderado, tt.fijos, tt.margen)
+		}
+		for _, c := range []struct {
+			campo     string
+			got, want *float64
+		}{
+			{"unidades_equilibrio", a.UnidadesEquilibrio, tt.unidades},
+			{"ingresos_equilibrio", a.IngresosEquilibrio, tt.ingresos},
+			{"margen_seguridad", a.MargenSeguridad, tt.seguridad},
+			{"margen_seguridad_porcentaje", a.MargenSeguridadPorcentaje, tt.porcentaje},
+		} {
+			if c.want == nil {
+				if c.got != nil {
+					t.Errorf("año %d: %s = %v, want nil", tt.anio, c.campo, *c.got)
+				}
+				continue
+			}
+			if c.got == nil || !cerca(*c.got, *c.want) {
+				t.Errorf("año %d: %s = %v, want %v", tt.anio, c.campo, c.got, *c.want)
+			}
+		}
+		if len(a.Productos) != 2 || a.Productos[0].ProductoID != 1 || a.Productos[0].Nombre != "Café" {
+			t.Errorf("año %d: productos = %+v", tt.anio, a.Productos)
+			continue
+		}
+		for i, want := range tt.unidadesProducto {
+			if p := a.Productos[i]; p.UnidadesEquilibrio == nil || !cerca(*p.UnidadesEquilibrio, want) {
+				t.Errorf("año %d: unidades de equilibrio del producto %d = %v, want %v", tt.anio, p.ProductoID, p.UnidadesEquilibrio, want)
+			}
+		}
+	}
+
+	p := anios[0].Productos[0]
+	if p.Precio != 10 || p.CostoVariable != 6 || p.MargenContribucion != 4 || p.Participacion != 0.5 || p.IngresosProyectados != 20000 {
+		t.Errorf("producto 1 = %+v", p)
+	}
+}
+
+// Con margen de contribución ponderado no positivo el equilibrio no existe
+func TestComputeBreakEvenMargenNegativo(t *testing.T) {
+	anios := ComputeBreakEven(BreakEvenInputs{
+		Horizonte:    1,
+		Precios:      []models.PreciosProdServ{{ProductoServicioID: 1, Precio: ptr(5)}},
+		Costos:       []models.CostosProdServ{{ProductoServicioID: 1, Costo: ptr(8)}},
+		VentasDinero: []models.VentasDinero{{ProductoID: 1, Anio: 1, Anual: 100}},
+		Gastos:       []models.GastosOperacion{{Mensual: 10}},
+	})
+	a := anios[0]
+	if a.MargenContribucionPonderado != -3 {
+		t.Errorf("margen ponderado = %v, want -3", a.MargenContribucionPonderado)
+	}
+	if a.UnidadesEquilibrio != nil || a.IngresosEquilibrio != nil || a.MargenSeguridad != nil || a.Productos[0].UnidadesEquilibrio != nil {
+		t.Errorf("año = %+v, want valores de equilibrio nil", a)
+	}
+}
@@ -0,0 +1,215 @@
+package model
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/JostinAlvaradoS/liveplan_backend_go/internal/models"
+)
+
+// RatiosInputs son los estados calculados del plan que usa ComputeRatios
+type RatiosInputs struct {
+	Horizonte        int
+	EstadoResultados []models.EstadoResultados
+	FlujoEfectivo    []models.FlujoEfectivo
+	BalanceGeneral   []models.BalanceGeneral
+	Cuotas           []models.PrestamoCuotas
+	// Umbrales opcionales por clave de razón (ver ParseThresholds)
+	Umbrales map[string]Umbral
+}
+
+// Umbral es el rango sano de una razón; un límite nil no se revisa
+type Umbral struct {
+	Min *float64 `json:"min"`
+	Max *float64 `json:"max"`
+}
+
+// Razon es el valor de una razón financiera en un año. Valor es nil si el
+// denominador es menor a un centavo (restos de redondeo cuentan como 0). FueraDeRango solo se marca si la razón tiene umbral.
+type Razon struct {
+	Clave        string   `json:"clave"`
+	Nombre       string   `json:"nombre"`
+	Formula      string   `json:"formula"`
+	Valor        *float64 `json:"valor"`
+	Umbral       *Umbral  `json:"umbral,omitempty"`
+	FueraDeRango bool     `json:"fuera_de_rango"`
+}
+
+// RazonesAnio son las razones financieras de un año del horizonte
+type RazonesAnio struct {
+	Anio    int     `json:"anio"`
+	Razones []Razon `json:"razones"`
+}
+
+// totalesAnio son los montos de un año con que se calculan las razones
+type totalesAnio struct {
+	er       models.EstadoResultados // meses 1..12 sumados
+	bg       models.BalanceGeneral   // saldo al cierre del año
+	flujo    float64                 // flujo disponible para el servicio de la deuda
+	servicio float64                 // cuotas del préstamo del año
+}
+
+// definicionRazon es una razón con su fórmula: numerador / denominador
+type definicionRazon struct {
+	clave, nombre, formula string
+	num, den               func(t totalesAnio) float64
+}
+
+// denominadorMinimo es el menor denominador (en valor absoluto) con que se
+// calcula una razón: por debajo de un centavo es un resto de punto flotante
+const denominadorMinimo = 0.005
+
+var definicionesRazones = []definicionRazon{
+	{"razon_circulante", "Razón circulante", "activo corriente / pasivo a corto plazo",
+		func(t totalesAnio) float64 { return t.bg.Corrientes_Suma },
+		func(t totalesAnio) float64 { return t.bg.PasivoCortoPlazo_Suma }},
+	{"prueba_acida", "Prueba ácida", "(activo corriente - inventarios) / pasivo a corto plazo",
+		func(t totalesAnio) float64 { return t.bg.Corrientes_Suma - t.bg.Corrientes_Inventarios },
+		func(t totalesAnio) float64 { return t.bg.PasivoCortoPlazo_Suma }},
+	{"deuda_capital", "Deuda a capital", "total pasivo / total capital contable",
+		func(t totalesAnio) float64 { return t.bg.TotalPasivo },
+		func(t totalesAnio) float64 { return t.bg.TotalCapitalContable }},
+	{"razon_endeudamiento", "Razón de endeudamiento", "total pasivo / total activo",
+		func(t totalesAnio) float64 { return t.bg.TotalPasivo },
+		func(t totalesAnio) float64 { return t.bg.TotalActivo }},
+	{"margen_bruto", "Margen bruto", "utilidad bruta / ventas",
+		func(t totalesAnio) float64 { return t.er.UtilidadBruta },
+		func(t totalesAnio) float64 { return t.er.Ventas }},
+	{"margen_operativo", "Margen operativo", "utilidad antes de intereses e impuestos / ventas",
+		func(t totalesAnio) float64 { return t.er.UtilidadprevioIntImp },
+		func(t totalesAnio) float64 { return t.er.Ventas }},
+	{"margen_neto", "Margen neto", "utilidad neta / ventas",
+		func(t totalesAnio) float64 { return t.er.UtilidadNeta },
+		func(t totalesAnio) float64 { return t.er.Ventas }},
+	{"roa", "Rendimiento sobre activos (ROA)", "utilidad neta / total activo al cierre",
+		func(t totalesAnio) float64 { return t.er.UtilidadNeta },
+		func(t totalesAnio) float64 { return t.bg.TotalActivo }},
+	{"roe", "Rendimiento sobre capital (ROE)", "utilidad neta / total capital contable al cierre",
+		func(t totalesAnio) float64 { return t.er.UtilidadNeta },
+		func(t totalesAnio) float64 { return t.bg.TotalCapitalContable }},
+	{"rotacion_activos", "Rotación de activos", "ventas / total activo al cierre",
+		func(t totalesAnio) float64 { return t.er.Ventas },
+		func(t totalesAnio) float64 { return t.bg.TotalActivo }},
+	{"cobertura_intereses", "Cobertura de intereses", "utilidad antes de intereses e impuestos / gastos financieros",
+		func(t totalesAnio) float64 { return t.er.UtilidadprevioIntImp },
+		func(t totalesAnio) float64 { return t.er.GastosFinancieros }},
+	{"dscr", "Cobertura del servicio de la deuda (DSCR)",
+		"(flujo de caja + intereses + pagos de préstamos - préstamos recibidos - aportes de capital) / suma de cuotas del préstamo",
+		func(t totalesAnio) float64 { return t.flujo },
+		func(t totalesAnio) float64 { return t.servicio }},
+}
+
+// ComputeRatios calcula las razones financieras de cada año del horizonte.
+// Los flujos del año (estado de resultados, flujo de efectivo, cuotas) son la
+// suma de los meses 1..12 y los saldos del balance los del último mes del año.
+func ComputeRatios(in RatiosInputs) []RazonesAnio {
+	totales := make(map[int]*totalesAnio)
+	delAnio := func(anio int) *totalesAnio {
+		t, ok := totales[anio]
+		if !ok {
+			t = &totalesAnio{}
+			totales[anio] = t
+		}
+		return t
+	}
+	for _, er := range in.EstadoResultados {
+		if er.Mes < 1 {
+			continue
+		}
+		t := &delAnio(er.Anio).er
+		t.Ventas += er.Ventas
+		t.UtilidadBruta += er.UtilidadBruta
+		t.UtilidadprevioIntImp += er.UtilidadprevioIntImp
+		t.GastosFinancieros += er.GastosFinancieros
+		t.UtilidadNeta += er.UtilidadNeta
+	}
+	cierre := make(map[int]int)
+	for _, bg := range in.BalanceGeneral {
+		if mes, ok := cierre[bg.Anio]; ok && bg.Mes <= mes {
+			continue
+		}
+		cierre[bg.Anio] = bg.Mes
+		delAnio(bg.Anio).bg = bg
+	}
+	for _, fe := range in.FlujoEfectivo {
+		if fe.Mes < 1 {
+			continue
+		}
+		delAnio(fe.Anio).flujo += fe.FlujoCaja + fe.Egresos_Intereses + fe.Egresos_PagosPrestamos - fe.Ingresos_Prestamos - fe.Ingresos_AportesCapital
+	}
+	for _, c := range in.Cuotas {
+		delAnio(c.Anio).servicio += c.CuotaTotal
+	}
+
+	out := make([]RazonesAnio, 0, in.Horizonte)
+	for anio := 1; anio <= in.Horizonte; anio++ {
+		t := delAnio(anio)
+		ra := RazonesAnio{Anio: anio}
+		for _, d := range definicionesRazones {
+			r := Razon{Clave: d.clave, Nombre: d.nombre, Formula: d.formula}
+			if den := d.den(*t); math.Abs(den) >= denominadorMinimo {
+				r.Valor = ptr(d.num(*t) / den)
+			}
+			if u, ok := in.Umbrales[d.clave]; ok {
+				u := u
+				r.Umbral = &u
+				r.FueraDeRango = r.Valor != nil &&
+					((u.Min != nil && *r.Valor < *u.Min) || (u.Max != nil && *r.Valor > *u.Max))
+			}
+			ra.Razones = append(ra.Razones, r)
+		}
+		out = append(out, ra)
+	}
+	return out
+}
+
+// ParseThresholds lee una lista "clave=min..max" separada por comas, p. ej.
+// "razon_circulante=1.5..,deuda_capital=..2,dscr=1.25.."; cualquiera de los
+// dos límites puede omitirse
+func ParseThresholds(s string) (map[string]Umbral, error) {
+	validas := make(map[string]bool, len(definicionesRazones))
+	for _, d := range definicionesRazones {
+		validas[d.clave] = true
+	}
+	out := make(map[string]Umbral)
+	for _, par := range strings.Split(s, ",") {
+		par = strings.TrimSpace(par)
+		if par == "" {
+			continue
+		}
+		clave, rango, ok := strings.Cut(par, "=")
+		clave = strings.TrimSpace(clave)
+		if !ok {
+			return nil, fmt.Errorf("umbrales: se esperaba clave=min..max en %q", par)
+		}
+		if !validas[clave] {
+			return nil, fmt.Errorf("umbrales: razón desconocida %q", clave)
+		}
+		desde, hasta, ok := strings.Cut(rango, "..")
+		if !ok {
+			return nil, fmt.Errorf("umbrales: %s: se esperaba min..max", clave)
+		}
+		var u Umbral
+		for _, l := range []struct {
+			texto string
+			dest  **float64
+		}{{desde, &u.Min}, {hasta, &u.Max}} {
+			texto := strings.TrimSpace(l.texto)
+			if texto == "" {
+				continue
+			}
+			v, err := strconv.ParseFloat(texto, 64)
+			if err != nil {
+				return nil, fmt.Errorf("umbrales: %s: %w", clave, err)
+			}
+			*l.dest = &v
+		}
+		if u.Min != nil && u.Max != nil && *u.Min > *u.Max {
+			return nil, fmt.Errorf("umbrales: %s: min mayor que max", clave)
+		}
+		out[clave] = u
+	}
+	return out, nil
+}
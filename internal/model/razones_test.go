@@ -0,0 +1,153 @@
+package model
+
+import (
+	"testing"
+
+	"github.com/JostinAlvaradoS/liveplan_backend_go/internal/models"
+)
+
+// razon busca una razón por clave en un año
+func razon(t *testing.T, ra RazonesAnio, clave string) Razon {
+	t.Helper()
+	for _, r := range ra.Razones {
+		if r.Clave == clave {
+			return r
+		}
+	}
+	t.Fatalf("año %d: falta la razón %s", ra.Anio, clave)
+	return Razon{}
+}
+
+func TestComputeRatiosPlanCalculado(t *testing.T) {
+	out, err := Compute(planDePrueba(2), Shocks{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	anios := ComputeRatios(RatiosInputs{
+		Horizonte:        2,
+		EstadoResultados: out.EstadoResultados,
+		FlujoEfectivo:    out.FlujoEfectivo,
+		BalanceGeneral:   out.BalanceGeneral,
+		Cuotas:           out.PrestamoCuotas,
+	})
+	if len(anios) != 2 {
+		t.Fatalf("años = %d, want 2", len(anios))
+	}
+	for _, ra := range anios {
+		if len(ra.Razones) != len(definicionesRazones) {
+			t.Errorf("año %d: razones = %d, want %d", ra.Anio, len(ra.Razones), len(definicionesRazones))
+		}
+		// las razones del balance usan los totales que calcula ComputeBalanceSheet
+		for _, clave := range []string{"razon_circulante", "deuda_capital", "razon_endeudamiento", "roa", "roe", "margen_neto", "dscr"} {
+			if r := razon(t, ra, clave); r.Valor == nil {
+				t.Errorf("año %d: %s sin valor", ra.Anio, clave)
+			}
+		}
+		if r := razon(t, ra, "razon_endeudamiento"); r.Valor != nil && (*r.Valor <= 0 || *r.Valor >= 1) {
+			t.Errorf("año %d: razón de endeudamiento = %v, want entre 0 y 1", ra.Anio, *r.Valor)
+		}
+	}
+}
+
+func TestComputeRatiosDenominadores(t *testing.T) {
+	tests := []struct {
+		nombre string
+		pasivo float64
+		want   *float64
+	}{
+		{"cero", 0, nil},
+		{"resto de redondeo", 1e-9, nil},
+		{"resto negativo", -0.004, nil},
+		{"un centavo", 0.01, ptr(100)},
+		{"normal", 4, ptr(0.25)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.nombre, func(t *testing.T) {
+			anios := ComputeRatios(RatiosInputs{
+				Horizonte: 1,
+				BalanceGeneral: []models.BalanceGeneral{
+					{Anio: 1, Mes: 11, Corrientes_Suma: 50, PasivoCortoPlazo_Suma: 50},
+					{Anio: 1, Mes: 12, Corrientes_Suma: 1, PasivoCortoPlazo_Suma: tt.pasivo},
+				},
+			})
+			got := razon(t, anios[0], "razon_circulante").Valor
+			switch {
+			case tt.want == nil && got != nil:
+				t.Errorf("valor = %v, want nil", *got)
+			case tt.want != nil && (got == nil || !cerca(*got, *tt.want)):
+				t.Errorf("valor = %v, want %v", got, *tt.want)
+			}
+		})
+	}
+}
+
+func TestComputeRatiosUmbrales(t *testing.T) {
+	umbrales, err := ParseThresholds("razon_circulante=1.5..,margen_neto=..0.5")
+	if err != nil {
+		t.Fatal(err)
+	}
+	anios := ComputeRatios(RatiosInputs{
+		Horizonte:        1,
+		EstadoResultados: []models.EstadoResultados{{Anio: 1, Mes: 1, Ventas: 100, UtilidadNeta: 20}},
+		BalanceGeneral:   []models.BalanceGeneral{{Anio: 1, Mes: 12, Corrientes_Suma: 10, PasivoCortoPlazo_Suma: 10}},
+		Umbrales:         umbrales,
+	})
+	if r := razon(t, anios[0], "razon_circulante"); r.Umbral == nil || !r.FueraDeRango {
+		t.Errorf("razon_circulante = %+v, want fuera de rango", r)
+	}
+	if r := razon(t, anios[0], "margen_neto"); r.Umbral == nil || r.FueraDeRango {
+		t.Errorf("margen_neto = %+v, want dentro del rango", r)
+	}
+	// sin umbral ni valor no se marca nada
+	if r := razon(t, anios[0], "cobertura_intereses"); r.Umbral != nil || r.FueraDeRango || r.Valor != nil {
+		t.Errorf("cobertura_intereses = %+v", r)
+	}
+}
+
+func TestParseThresholds(t *testing.T) {
+	tests := []struct {
+		entrada string
+		want    map[string]Umbral
+		error   bool
+	}{
+		{"", map[string]Umbral{}, false},
+		{"razon_circulante=1.5..", map[string]Umbral{"razon_circulante": {Min: ptr(1.5)}}, false},
+		{" deuda_capital = ..2 , dscr=1.25..3", map[string]Umbral{
+			"deuda_capital": {Max: ptr(2)},
+			"dscr":          {Min: ptr(1.25), Max: ptr(3)},
+		}, false},
+		{"roe=..", map[string]Umbral{"roe": {}}, false},
+		{"razon_circulante", nil, true},
+		{"desconocida=1..", nil, true},
+		{"roe=1", nil, true},
+		{"roe=a..", nil, true},
+		{"roe=2..1", nil, true},
+	}
+	for _, tt := range tests {
+		got, err := ParseThresholds(tt.entrada)
+		if (err != nil) != tt.error {
+			t.Errorf("ParseThresholds(%q) err = %v", tt.entrada, err)
+			continue
+		}
+		if tt.error {
+			continue
+		}
+		if len(got) != len(tt.want) {
+			t.Errorf("ParseThresholds(%q) = %v, want %v", tt.entrada, got, tt.want)
+			continue
+		}
+		for clave, w := range tt.want {
+			g, ok := got[clave]
+			if !ok || !mismoLimite(g.Min, w.Min) || !mismoLimite(g.Max, w.Max) {
+				t.Errorf("ParseThresholds(%q)[%s] = %+v, want %+v", tt.entrada, clave, g, w)
+			}
+		}
+	}
+}
+
+func mismoLimite(a, b *float64) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
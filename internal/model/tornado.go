@@ -0,0 +1,89 @@
+package model
+
+import (
+	"fmt"
+	"math"
+	"sort"
+
+	"github.com/JostinAlvaradoS/liveplan_backend_go/internal/models"
+)
+
+// TornadoDriver es una entrada del plan que el análisis de tornado varía
+// mientras deja fijas las demás. Apply devuelve una copia de in con la
+// entrada variada en pct por ciento y los choques para Compute.
+type TornadoDriver struct {
+	Clave  string
+	Nombre string
+	Apply  func(in Inputs, pct float64) (Inputs, Shocks)
+}
+
+// TornadoDrivers devuelve las entradas del análisis de tornado: precio,
+// volumen, el costo de cada categoría presente en in.Costos (nombres por id,
+// ordenadas por id), los gastos de operación, la tasa anual del préstamo, la
+// TREMA y los días por mes. La inflación no está: el cálculo todavía no la usa.
+// La tasa no baja de tasaMinima y los días por mes se redondean a enteros de al
+// menos 1.
+func TornadoDrivers(in Inputs, categorias map[uint]string) []TornadoDriver {
+	drivers := []TornadoDriver{
+		{Clave: "precio", Nombre: "Precio", Apply: func(in Inputs, pct float64) (Inputs, Shocks) {
+			return in, Shocks{Precio: pct}
+		}},
+		{Clave: "volumen", Nombre: "Volumen", Apply: func(in Inputs, pct float64) (Inputs, Shocks) {
+			return in, Shocks{Volumen: pct}
+		}},
+	}
+
+	var ids []uint
+	vistas := map[uint]bool{}
+	for _, c := range in.Costos {
+		if !vistas[c.CategoriaCostoID] {
+			vistas[c.CategoriaCostoID] = true
+			ids = append(ids, c.CategoriaCostoID)
+		}
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	for _, id := range ids {
+		id := id
+		nombre := categorias[id]
+		if nombre == "" {
+			nombre = fmt.Sprintf("categoría %d", id)
+		}
+		drivers = append(drivers, TornadoDriver{
+			Clave:  fmt.Sprintf("costo_categoria_%d", id),
+			Nombre: "Costo: " + nombre,
+			Apply: func(in Inputs, pct float64) (Inputs, Shocks) {
+				return in, Shocks{CostoCategoria: map[uint]float64{id: pct}}
+			},
+		})
+	}
+
+	return append(drivers,
+		TornadoDriver{Clave: "gastos_operacion", Nombre: "Gastos de operación", Apply: func(in Inputs, pct float64) (Inputs, Shocks) {
+			gastos := make([]models.GastosOperacion, len(in.GastosOperacion))
+			for i, g := range in.GastosOperacion {
+				g.Mensual *= 1 + pct/100
+				g.Anual *= 1 + pct/100
+				gastos[i] = g
+			}
+			in.GastosOperacion = gastos
+			return in, Shocks{}
+		}},
+		TornadoDriver{Clave: "tasa_anual", Nombre: "Tasa anual del préstamo", Apply: func(in Inputs, pct float64) (Inputs, Shocks) {
+			fijarTasaAnual(&in.Prestamo, math.Max(in.Prestamo.TasaAnual*(1+pct/100), tasaMinima))
+			return in, Shocks{}
+		}},
+		TornadoDriver{Clave: "trema", Nombre: "TREMA", Apply: func(in Inputs, pct float64) (Inputs, Shocks) {
+			in.Evaluacion.TREMA *= 1 + pct/100
+			return in, Shocks{}
+		}},
+		TornadoDriver{Clave: "diasxmes", Nombre: "Días por mes", Apply: func(in Inputs, pct float64) (Inputs, Shocks) {
+			dias := in.Indicadores.DiasxMes
+			if dias <= 0 {
+				dias = 30
+			}
+			// días enteros, al menos uno
+			in.Indicadores.DiasxMes = int(math.Max(1, math.Round(float64(dias)*(1+pct/100))))
+			return in, Shocks{}
+		}},
+	)
+}
@@ -0,0 +1,130 @@
+package model
+
+import (
+	"reflect"
+	"testing"
+)
+
+// Cada entrada del tornado cambia solo lo suyo: al devolver ese campo a su
+// valor original el plan queda igual, y el plan recibido no se modifica.
+func TestTornadoDriversCambianSoloSuEntrada(t *testing.T) {
+	in := planDePrueba(2)
+	drivers := TornadoDrivers(in, map[uint]string{1: "Harina"})
+
+	var claves []string
+	for _, d := range drivers {
+		claves = append(claves, d.Clave)
+	}
+	want := []string{"precio", "volumen", "costo_categoria_1", "costo_categoria_2", "gastos_operacion", "tasa_anual", "trema", "diasxmes"}
+	if !reflect.DeepEqual(claves, want) {
+		t.Fatalf("claves = %v, want %v", claves, want)
+	}
+	if drivers[2].Nombre != "Costo: Harina" || drivers[3].Nombre != "Costo: categoría 2" {
+		t.Errorf("nombres = %q, %q", drivers[2].Nombre, drivers[3].Nombre)
+	}
+
+	tests := map[string]struct {
+		shocks Shocks
+		// revisar comprueba el cambio y devuelve el campo a su valor original
+		revisar func(t *testing.T, got *Inputs)
+	}{
+		"precio":            {Shocks{Precio: 10}, nil},
+		"volumen":           {Shocks{Volumen: 10}, nil},
+		"costo_categoria_1": {Shocks{CostoCategoria: map[uint]float64{1: 10}}, nil},
+		"costo_categoria_2": {Shocks{CostoCategoria: map[uint]float64{2: 10}}, nil},
+		"gastos_operacion": {Shocks{}, func(t *testing.T, got *Inputs) {
+			if g := got.GastosOperacion[0]; !cerca(g.Mensual, 1650) || !cerca(g.Anual, 19800) {
+				t.Errorf("gastos = %+v", g)
+			}
+			got.GastosOperacion = in.GastosOperacion
+		}},
+		"tasa_anual": {Shocks{}, func(t *testing.T, got *Inputs) {
+			if p := got.Prestamo; !cerca(p.TasaAnual, 13.2) || !cerca(p.TasaMensual, 1.1) {
+				t.Errorf("préstamo = %+v", p)
+			}
+			got.Prestamo = in.Prestamo
+		}},
+		"trema": {Shocks{}, func(t *testing.T, got *Inputs) {
+			if !cerca(got.Evaluacion.TREMA, 11) {
+				t.Errorf("trema = %v", got.Evaluacion.TREMA)
+			}
+			got.Evaluacion = in.Evaluacion
+		}},
+		"diasxmes": {Shocks{}, func(t *testing.T, got *Inputs) {
+			if got.Indicadores.DiasxMes != 33 {
+				t.Errorf("diasxmes = %d", got.Indicadores.DiasxMes)
+			}
+			got.Indicadores = in.Indicadores
+		}},
+	}
+	for _, d := range drivers {
+		t.Run(d.Clave, func(t *testing.T) {
+			tt := tests[d.Clave]
+			got, s := d.Apply(in, 10)
+			if !reflect.DeepEqual(s, tt.shocks) {
+				t.Errorf("choques = %+v, want %+v", s, tt.shocks)
+			}
+			if tt.revisar != nil {
+				tt.revisar(t, &got)
+			}
+			if !reflect.DeepEqual(got, in) {
+				t.Errorf("cambió otra entrada del plan")
+			}
+			if !reflect.DeepEqual(in, planDePrueba(2)) {
+				t.Errorf("modificó el plan recibido")
+			}
+		})
+	}
+}
+
+func TestTornadoDiasxMes(t *testing.T) {
+	tests := []struct {
+		dias int
+		pct  float64
+		want int
+	}{
+		{30, 10, 33},
+		// 30.3 y 29.7 se redondean a 30
+		{30, 1, 30},
+		{30, -1, 30},
+		{30, -10, 27},
+		// sin días se parte de 30
+		{0, 10, 33},
+		// al menos un día
+		{30, -99, 1},
+		{1, -60, 1},
+		{2, -100, 1},
+	}
+	for _, tt := range tests {
+		in := planDePrueba(1)
+		in.Indicadores.DiasxMes = tt.dias
+		d := driverTornado(t, in, "diasxmes")
+		if got, _ := d.Apply(in, tt.pct); got.Indicadores.DiasxMes != tt.want {
+			t.Errorf("%d días %+v%% = %d, want %d", tt.dias, tt.pct, got.Indicadores.DiasxMes, tt.want)
+		}
+	}
+}
+
+// La tasa no baja de tasaMinima: con tasa 0 ComputeLoan no calcula la cuota
+func TestTornadoTasaMinima(t *testing.T) {
+	in := planDePrueba(1)
+	d := driverTornado(t, in, "tasa_anual")
+	got, _ := d.Apply(in, -100)
+	if got.Prestamo.TasaAnual != tasaMinima || got.Prestamo.Cuota != 0 {
+		t.Errorf("préstamo = %+v, want tasa %v", got.Prestamo, tasaMinima)
+	}
+	if _, err := Compute(got, Shocks{}); err != nil {
+		t.Errorf("Compute con la tasa mínima: %v", err)
+	}
+}
+
+func driverTornado(t *testing.T, in Inputs, clave string) TornadoDriver {
+	t.Helper()
+	for _, d := range TornadoDrivers(in, nil) {
+		if d.Clave == clave {
+			return d
+		}
+	}
+	t.Fatalf("sin entrada %s", clave)
+	return TornadoDriver{}
+}
@@ -0,0 +1,274 @@
+package model
+
+import (
+	"sort"
+
+	"github.com/JostinAlvaradoS/liveplan_backend_go/internal/models"
+)
+
+// multiplicadorCategoria devuelve la fracción del precio que representa el costo
+// de cada categoría (1 -> 0.10, 2 -> 0.12, 3 -> 0.15, resto 0.10)
+func multiplicadorCategoria(categoriaID uint) float64 {
+	switch categoriaID {
+	case 2:
+		return 0.12
+	case 3:
+		return 0.15
+	}
+	return 0.10
+}
+
+// ComputePrices calcula precio_calc de cada PreciosProdServ y el costo de cada
+// CostosProdServ del mismo producto:
+//
+//	precio_calc = precio * (1 + variables.precio/100)
+//	costo = (precio * multiplicador_categoria) * (1 + variables.costo/100)
+//
+// Si el producto no tiene precio, precio_calc y costo_calc quedan en NULL y el
+// costo no se modifica. Los choques de precio y costo (general y de la
+// categoría) se aplican encima.
+func ComputePrices(precios []models.PreciosProdServ, costos []models.CostosProdServ, vs models.VariablesDeSensibilidad, s Shocks) ([]models.PreciosProdServ, []models.CostosProdServ) {
+	outPrecios := append([]models.PreciosProdServ(nil), precios...)
+	outCostos := append([]models.CostosProdServ(nil), costos...)
+
+	costosPorProducto := make(map[uint][]int)
+	for i, c := range outCostos {
+		costosPorProducto[c.ProductoServicioID] = append(costosPorProducto[c.ProductoServicioID], i)
+	}
+
+	for i, p := range outPrecios {
+		if p.Precio == nil {
+			outPrecios[i].PrecioCalc = nil
+		} else {
+			outPrecios[i].PrecioCalc = ptr((*p.Precio) * (1.0 + vs.Precio/100) * (1.0 + s.Precio/100))
+		}
+
+		for _, ci := range costosPorProducto[p.ProductoServicioID] {
+			if p.Precio == nil {
+				outCostos[ci].CostoCalc = nil
+				continue
+			}
+			categoria := outCostos[ci].CategoriaCostoID
+			mult := multiplicadorCategoria(categoria)
+			outCostos[ci].Costo = ptr(((*p.Precio) * mult) * (1.0 + vs.Costo/100) * (1.0 + s.Costo/100) * (1.0 + s.CostoCategoria[categoria]/100))
+		}
+	}
+	return outPrecios, outCostos
+}
+
+// ComputeSalesBudget recalcula PresupuestoVenta y VentasDinero por producto y año:
+//
+//	mensual año 1 = venta_dia * (1 + crecimiento/100)
+//	mensual año n = mensual año n-1 * (1 + crecimiento/100)
+//	anual = mensual * 12 * diasxmes
+//	ventas_dinero.mensual = mensual * diasxmes, ventas_dinero.anual = ventas_dinero.mensual * 12
+//
+// Sin VentaDiaria (o con venta_dia NULL) mensual/anual quedan en NULL y
+// VentasDinero conserva sus valores. El choque de volumen escala venta_dia.
+func ComputeSalesBudget(presupuestos []models.PresupuestoVenta, ventasDiarias []models.VentaDiaria, ventasDinero []models.VentasDinero, ind models.IndicadoresMacro, s Shocks) ([]models.PresupuestoVenta, []models.VentasDinero) {
+	diasxmes := 30
+	if ind.DiasxMes > 0 {
+		diasxmes = ind.DiasxMes
+	}
+
+	outPres := append([]models.PresupuestoVenta(nil), presupuestos...)
+	outVD := append([]models.VentasDinero(nil), ventasDinero...)
+	vdIdx := make(map[claveProductoAnio]int, len(outVD))
+	for i, vd := range outVD {
+		k := claveProductoAnio{vd.ProductoID, vd.Anio}
+		if _, ok := vdIdx[k]; !ok {
+			vdIdx[k] = i
+		}
+	}
+
+	// primera VentaDiaria por producto
+	ventaDia := make(map[uint]*int)
+	tieneVenta := make(map[uint]bool)
+	for _, v := range ventasDiarias {
+		if tieneVenta[v.ProductoServicioID] {
+			continue
+		}
+		tieneVenta[v.ProductoServicioID] = true
+		ventaDia[v.ProductoServicioID] = v.VentaDia
+	}
+
+	porProducto := make(map[uint][]int)
+	var productos []uint
+	for i, p := range outPres {
+		if _, ok := porProducto[p.ProductoID]; !ok {
+			productos = append(productos, p.ProductoID)
+		}
+		porProducto[p.ProductoID] = append(porProducto[p.ProductoID], i)
+	}
+
+	for _, productoID := range productos {
+		idxs := porProducto[productoID]
+		sort.SliceStable(idxs, func(i, j int) bool { return outPres[idxs[i]].Anio < outPres[idxs[j]].Anio })
+
+		vd := ventaDia[productoID]
+		if vd == nil {
+			for _, i := range idxs {
+				outPres[i].Mensual = nil
+				outPres[i].Anual = nil
+			}
+			continue
+		}
+
+		base := float64(*vd) * (1.0 + s.Volumen/100)
+		var prevMensual float64
+		for n, i := range idxs {
+			p := outPres[i]
+			var growth float64
+			if p.Crecimiento != nil {
+				growth = *p.Crecimiento / 100.0
+			}
+			var mensual float64
+			if n == 0 {
+				mensual = base * (1.0 + growth)
+			} else {
+				mensual = prevMensual * (1.0 + growth)
+			}
+			anual := mensual * 12.0 * float64(diasxmes)
+			outPres[i].Mensual = ptr(mensual)
+			outPres[i].Anual = ptr(anual)
+
+			ventasMensual := mensual * float64(diasxmes)
+			k := claveProductoAnio{p.ProductoID, p.Anio}
+			if j, ok := vdIdx[k]; ok {
+				outVD[j].Mensual = ventasMensual
+				outVD[j].Anual = ventasMensual * 12.0
+			} else {
+				outVD = append(outVD, models.VentasDinero{
+					PlanNegocioID: p.PlanNegocioID,
+					ProductoID:    p.ProductoID,
+					Anio:          p.Anio,
+					Mensual:       ventasMensual,
+					Anual:         ventasMensual * 12.0,
+				})
+				vdIdx[k] = len(outVD) - 1
+			}
+			prevMensual = mensual
+		}
+	}
+	return outPres, outVD
+}
+
+// precioPorProducto devuelve PrecioCalc (o Precio si no hay calculado) por producto
+func precioPorProducto(precios []models.PreciosProdServ) map[uint]float64 {
+	m := make(map[uint]float64)
+	for _, p := range precios {
+		if p.PrecioCalc != nil {
+			m[p.ProductoServicioID] = *p.PrecioCalc
+		} else if p.Precio != nil {
+			m[p.ProductoServicioID] = *p.Precio
+		} else {
+			m[p.ProductoServicioID] = 0
+		}
+	}
+	return m
+}
+
+// ComputeSales calcula Ventas.Venta = VentasDinero.Mensual * precio para cada
+// producto y año, actualizando las filas existentes y agregando las que falten.
+func ComputeSales(existentes []models.Ventas, ventasDinero []models.VentasDinero, precios []models.PreciosProdServ) []models.Ventas {
+	out := append([]models.Ventas(nil), existentes...)
+	idx := make(map[claveProductoAnio]int, len(out))
+	for i, v := range out {
+		k := claveProductoAnio{v.ProductoID, v.Anio}
+		if _, ok := idx[k]; !ok {
+			idx[k] = i
+		}
+	}
+	precio := precioPorProducto(precios)
+	for _, vd := range ventasDinero {
+		venta := vd.Mensual * precio[vd.ProductoID]
+		k := claveProductoAnio{vd.ProductoID, vd.Anio}
+		if i, ok := idx[k]; ok {
+			out[i].Venta = venta
+			continue
+		}
+		out = append(out, models.Ventas{PlanNegocioID: vd.PlanNegocioID, ProductoID: vd.ProductoID, Anio: vd.Anio, Venta: venta})
+		idx[k] = len(out) - 1
+	}
+	return out
+}
+
+// ComputeCostOfSales calcula CostosVentas: para cada producto y año el costo
+// mensual = VentasDinero.Mensual * suma(CostosProdServ.CostoCalc | Costo) y se
+// repite en los meses 1..12. Las filas nuevas llevan anual = mensual * 12.
+func ComputeCostOfSales(existentes []models.CostosVentas, ventasDinero []models.VentasDinero, costos []models.CostosProdServ) []models.CostosVentas {
+	out := append([]models.CostosVentas(nil), existentes...)
+	idx := make(map[claveProductoPeriodo]int, len(out))
+	for i, c := range out {
+		k := claveProductoPeriodo{c.ProductoID, c.Anio, c.Mes}
+		if _, ok := idx[k]; !ok {
+			idx[k] = i
+		}
+	}
+	costoPorProducto := make(map[uint]float64)
+	for _, c := range costos {
+		if c.CostoCalc != nil {
+			costoPorProducto[c.ProductoServicioID] += *c.CostoCalc
+		} else if c.Costo != nil {
+			costoPorProducto[c.ProductoServicioID] += *c.Costo
+		}
+	}
+	for _, vd := range ventasDinero {
+		costoMensual := vd.Mensual * costoPorProducto[vd.ProductoID]
+		for mes := 1; mes <= 12; mes++ {
+			k := claveProductoPeriodo{vd.ProductoID, vd.Anio, mes}
+			if i, ok := idx[k]; ok {
+				out[i].Mensual = costoMensual
+				continue
+			}
+			out = append(out, models.CostosVentas{
+				PlanNegocioID: vd.PlanNegocioID,
+				ProductoID:    vd.ProductoID,
+				Anio:          vd.Anio,
+				Mes:           mes,
+				Mensual:       costoMensual,
+				Anual:         costoMensual * 12,
+			})
+			idx[k] = len(out) - 1
+		}
+	}
+	return out
+}
+
+// ComputeRawMaterialCost calcula CostoMateriasPrimas por producto y año:
+// costo_mensual = VentasDinero.Mensual * suma(Costo de la categoría 2) y
+// costo_anual = costo_mensual * 12.
+func ComputeRawMaterialCost(existentes []models.CostoMateriasPrimas, ventasDinero []models.VentasDinero, costos []models.CostosProdServ) []models.CostoMateriasPrimas {
+	out := append([]models.CostoMateriasPrimas(nil), existentes...)
+	idx := make(map[claveProductoAnio]int, len(out))
+	for i, c := range out {
+		k := claveProductoAnio{c.ProductoID, c.Anio}
+		if _, ok := idx[k]; !ok {
+			idx[k] = i
+		}
+	}
+	materiaPrima := make(map[uint]float64)
+	for _, c := range costos {
+		if c.CategoriaCostoID == 2 && c.Costo != nil {
+			materiaPrima[c.ProductoServicioID] += *c.Costo
+		}
+	}
+	for _, vd := range ventasDinero {
+		costoMensual := vd.Mensual * materiaPrima[vd.ProductoID]
+		k := claveProductoAnio{vd.ProductoID, vd.Anio}
+		if i, ok := idx[k]; ok {
+			out[i].CostoMensual = costoMensual
+			out[i].CostoAnual = costoMensual * 12.0
+			continue
+		}
+		out = append(out, models.CostoMateriasPrimas{
+			PlanNegocioID: vd.PlanNegocioID,
+			ProductoID:    vd.ProductoID,
+			Anio:          vd.Anio,
+			CostoMensual:  costoMensual,
+			CostoAnual:    costoMensual * 12.0,
+		})
+		idx[k] = len(out) - 1
+	}
+	return out
+}
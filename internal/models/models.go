@@ -1,12 +1,34 @@
 package models
 
+import (
+	"encoding/json"
+	"strconv"
+	"time"
+)
+
+const (
+	// HorizontePorDefecto es el número de años proyectados cuando el plan no define uno
+	HorizontePorDefecto = 5
+	// HorizonteMaximo limita los años de proyección que puede pedir un plan
+	HorizonteMaximo = 30
+)
 
 // PlanNegocio representa la tabla plan_negocio
 type PlanNegocio struct {
-	ID           uint   `json:"id" gorm:"primaryKey;autoIncrement"`
-	Autor        string `json:"autor" gorm:"type:varchar(90);not null"`
-	Problematica string `json:"problematica" gorm:"type:varchar(300);not null"`
-	Descripcion  string `json:"descripcion" gorm:"type:text"`
+	ID             uint   `json:"id" gorm:"primaryKey;autoIncrement"`
+	Autor          string `json:"autor" gorm:"type:varchar(90);not null"`
+	Problematica   string `json:"problematica" gorm:"type:varchar(300);not null"`
+	Descripcion    string `json:"descripcion" gorm:"type:text"`
+	HorizonteAnios int    `json:"horizonte_anios" gorm:"column:horizonte_anios;not null;default:5"`
+}
+
+// Horizonte devuelve los años de proyección del plan, usando el valor por defecto
+// cuando el campo no fue definido.
+func (p PlanNegocio) Horizonte() int {
+	if p.HorizonteAnios <= 0 {
+		return HorizontePorDefecto
+	}
+	return p.HorizonteAnios
 }
 
 // TipoInversionInicial representa la tabla tipo_inversion_inicial
@@ -78,16 +100,41 @@ type VariablesDeSensibilidad struct {
 	PlanNegocio      *PlanNegocio `json:"plan_negocio,omitempty" gorm:"foreignKey:PlanNegocioID;constraint:OnDelete:CASCADE"`
 }
 
-// VariacionAnual almacena el porcentaje de crecimiento de ventas por año (año 1..5) para un plan
+// VariacionAnual agrupa el porcentaje de crecimiento de ventas por año para un plan.
+// Los porcentajes viven en VariacionAnualAnio, una fila por año del horizonte.
 type VariacionAnual struct {
-	ID            uint         `json:"id" gorm:"primaryKey;autoIncrement"`
-	PlanNegocioID uint         `json:"plan_negocio_id" gorm:"not null;index"`
-	Año1          float64      `json:"anio1" gorm:"column:anio1;type:numeric(6,2)"`
-	Año2          float64      `json:"anio2" gorm:"column:anio2;type:numeric(6,2)"`
-	Año3          float64      `json:"anio3" gorm:"column:anio3;type:numeric(6,2)"`
-	Año4          float64      `json:"anio4" gorm:"column:anio4;type:numeric(6,2)"`
-	Año5          float64      `json:"anio5" gorm:"column:anio5;type:numeric(6,2)"`
-	PlanNegocio   *PlanNegocio `json:"plan_negocio,omitempty" gorm:"foreignKey:PlanNegocioID;constraint:OnDelete:CASCADE"`
+	ID            uint                 `json:"id" gorm:"primaryKey;autoIncrement"`
+	PlanNegocioID uint                 `json:"plan_negocio_id" gorm:"not null;index"`
+	Anios         []VariacionAnualAnio `json:"anios" gorm:"foreignKey:VariacionAnualID;constraint:OnDelete:CASCADE"`
+	PlanNegocio   *PlanNegocio         `json:"plan_negocio,omitempty" gorm:"foreignKey:PlanNegocioID;constraint:OnDelete:CASCADE"`
+}
+
+// MarshalJSON conserva las claves planas anio1..anioN que consume el frontend
+// además del arreglo anios.
+func (v VariacionAnual) MarshalJSON() ([]byte, error) {
+	type alias VariacionAnual
+	raw, err := json.Marshal(alias(v))
+	if err != nil {
+		return nil, err
+	}
+	out := make(map[string]json.RawMessage)
+	if err := json.Unmarshal(raw, &out); err != nil {
+		return nil, err
+	}
+	for _, a := range v.Anios {
+		val, _ := json.Marshal(a.Porcentaje)
+		out[ClaveAnio("anio", a.Anio)] = val
+	}
+	return json.Marshal(out)
+}
+
+// VariacionAnualAnio almacena el porcentaje de crecimiento de un año concreto
+type VariacionAnualAnio struct {
+	ID               uint    `json:"id" gorm:"primaryKey;autoIncrement"`
+	VariacionAnualID uint    `json:"variacion_anual_id" gorm:"not null;index"`
+	PlanNegocioID    uint    `json:"plan_negocio_id" gorm:"not null;index"`
+	Anio             int     `json:"anio" gorm:"not null;index"`
+	Porcentaje       float64 `json:"porcentaje" gorm:"type:numeric(6,2)"`
 }
 
 // PreciosProdServ almacena precio por producto_servicio dentro de un plan y un precio calculado
@@ -150,17 +197,46 @@ type Depreciacion struct {
 	PlanNegocioID       uint     `json:"plan_negocio_id" gorm:"not null;index"`
 	DetalleInversionID  uint     `json:"detalle_inversion_id" gorm:"not null;index"`
 	DepreciacionMensual *float64 `json:"depreciacion_mensual" gorm:"column:depreciacion_mensual;type:numeric(15,2)"`
-	DepreciacionAnio1   *float64 `json:"depreciacion_anio1" gorm:"column:depreciacion_anio1;type:numeric(15,2)"`
-	DepreciacionAnio2   *float64 `json:"depreciacion_anio2" gorm:"column:depreciacion_anio2;type:numeric(15,2)"`
-	DepreciacionAnio3   *float64 `json:"depreciacion_anio3" gorm:"column:depreciacion_anio3;type:numeric(15,2)"`
-	DepreciacionAnio4   *float64 `json:"depreciacion_anio4" gorm:"column:depreciacion_anio4;type:numeric(15,2)"`
-	DepreciacionAnio5   *float64 `json:"depreciacion_anio5" gorm:"column:depreciacion_anio5;type:numeric(15,2)"`
 	ValorRescate        *float64 `json:"valor_rescate" gorm:"column:valor_rescate;type:numeric(15,2)"`
 
+	Anios            []DepreciacionAnual      `json:"anios" gorm:"foreignKey:DepreciacionID;constraint:OnDelete:CASCADE"`
 	PlanNegocio      *PlanNegocio             `json:"plan_negocio,omitempty" gorm:"foreignKey:PlanNegocioID;constraint:OnDelete:CASCADE"`
 	DetalleInversion *DetalleInversionInicial `json:"detalle_inversion,omitempty" gorm:"foreignKey:DetalleInversionID;constraint:OnDelete:CASCADE"`
 }
 
+// MarshalJSON conserva las claves planas depreciacion_anio1..N que consume el frontend
+// además del arreglo anios.
+func (d Depreciacion) MarshalJSON() ([]byte, error) {
+	type alias Depreciacion
+	raw, err := json.Marshal(alias(d))
+	if err != nil {
+		return nil, err
+	}
+	out := make(map[string]json.RawMessage)
+	if err := json.Unmarshal(raw, &out); err != nil {
+		return nil, err
+	}
+	for _, a := range d.Anios {
+		val, _ := json.Marshal(a.Valor)
+		out[ClaveAnio("depreciacion_anio", a.Anio)] = val
+	}
+	return json.Marshal(out)
+}
+
+// DepreciacionAnual almacena la depreciación de un año concreto del horizonte
+type DepreciacionAnual struct {
+	ID             uint     `json:"id" gorm:"primaryKey;autoIncrement"`
+	DepreciacionID uint     `json:"depreciacion_id" gorm:"not null;index"`
+	PlanNegocioID  uint     `json:"plan_negocio_id" gorm:"not null;index"`
+	Anio           int      `json:"anio" gorm:"not null;index"`
+	Valor          *float64 `json:"valor" gorm:"type:numeric(15,2)"`
+}
+
+// ClaveAnio arma claves del tipo anio3 o depreciacion_anio3
+func ClaveAnio(prefijo string, anio int) string {
+	return prefijo + strconv.Itoa(anio)
+}
+
 // PresupuestoVenta representa el presupuesto de ventas para un producto dentro de un plan
 type PresupuestoVenta struct {
 	ID            uint              `json:"id" gorm:"primaryKey;autoIncrement"`
@@ -387,4 +463,178 @@ type AnalisisSensibilidad struct {
 	Costo 		float64      `json:"costo" gorm:"not null;index"`
 	Valor 		float64      `json:"valor" gorm:"not null;index"`
 	PlanNegocio       *PlanNegocio `json:"plan_negocio,omitempty" gorm:"foreignKey:PlanNegocioID;constraint:OnDelete:CASCADE"`
-}
\ No newline at end of file
+}
+// Roles de un colaborador sobre un plan, de menor a mayor permiso
+const (
+	RolViewer = "viewer"
+	RolEditor = "editor"
+	RolOwner  = "owner"
+)
+
+// NivelRol ordena los roles para compararlos; 0 si el rol no es válido
+func NivelRol(rol string) int {
+	switch rol {
+	case RolViewer:
+		return 1
+	case RolEditor:
+		return 2
+	case RolOwner:
+		return 3
+	}
+	return 0
+}
+
+// PlanColaborador da acceso a un plan a un usuario distinto del Autor. El
+// usuario se identifica por UID o, si se invitó por correo y aún no entró, por Email.
+type PlanColaborador struct {
+	ID            uint         `json:"id" gorm:"primaryKey;autoIncrement"`
+	PlanNegocioID uint         `json:"plan_negocio_id" gorm:"not null;index"`
+	UsuarioUID    string       `json:"usuario_uid" gorm:"type:varchar(90);index"`
+	Email         string       `json:"email" gorm:"type:varchar(254);index"`
+	Rol           string       `json:"rol" gorm:"type:varchar(10);not null"`
+	InvitadoPor   string       `json:"invitado_por" gorm:"type:varchar(90)"`
+	CreatedAt     time.Time    `json:"created_at"`
+	PlanNegocio   *PlanNegocio `json:"plan_negocio,omitempty" gorm:"foreignKey:PlanNegocioID;constraint:OnDelete:CASCADE"`
+}
+
+func (PlanColaborador) TableName() string { return "plan_colaboradores" }
+
+// Resultado de una ejecución de Recalcular
+const (
+	RecalculoOK    = "ok"
+	RecalculoError = "error"
+	// RecalculoCancelado: todas las peticiones que esperaban la ejecución se cancelaron
+	RecalculoCancelado = "cancelado"
+)
+
+// RecalculoEjecucion registra cada ejecución de Recalcular de un plan. Etapa es
+// la última etapa alcanzada (la que falló si Estado es error), Alcance es
+// "completo" o las tablas modificadas que pidieron el recálculo, y Solicitudes
+// la cantidad de peticiones atendidas por la ejecución.
+type RecalculoEjecucion struct {
+	ID            uint             `json:"id" gorm:"primaryKey;autoIncrement"`
+	PlanNegocioID uint             `json:"plan_negocio_id" gorm:"not null;index"`
+	Estado        string           `json:"estado" gorm:"type:varchar(10);not null"`
+	Etapa         string           `json:"etapa" gorm:"type:varchar(40)"`
+	Alcance       string           `json:"alcance" gorm:"type:text"`
+	Error         string           `json:"error,omitempty" gorm:"type:text"`
+	Solicitudes   int              `json:"solicitudes" gorm:"not null;default:1"`
+	IniciadoEn    time.Time        `json:"iniciado_en" gorm:"not null"`
+	TerminadoEn   time.Time        `json:"terminado_en" gorm:"not null"`
+	DuracionMs    int64            `json:"duracion_ms" gorm:"not null"`
+	PlanNegocio   *PlanNegocio     `json:"plan_negocio,omitempty" gorm:"foreignKey:PlanNegocioID;constraint:OnDelete:CASCADE"`
+	Tareas        []RecalculoTarea `json:"tareas,omitempty" gorm:"foreignKey:RecalculoEjecucionID;constraint:OnDelete:CASCADE"`
+}
+
+func (RecalculoEjecucion) TableName() string { return "recalculo_ejecuciones" }
+
+// RecalculoTarea es un procedimiento ejecutado dentro de una ejecución de
+// Recalcular: su nivel en el grafo, los intentos (más de 1 si se reintentó por
+// un error de serialización o deadlock), el tiempo y el error si falló.
+type RecalculoTarea struct {
+	ID                   uint      `json:"id" gorm:"primaryKey;autoIncrement"`
+	RecalculoEjecucionID uint      `json:"recalculo_ejecucion_id" gorm:"not null;index"`
+	Procedimiento        string    `json:"procedimiento" gorm:"type:varchar(40);not null"`
+	Nivel                int       `json:"nivel" gorm:"not null"`
+	Intentos             int       `json:"intentos" gorm:"not null;default:1"`
+	IniciadoEn           time.Time `json:"iniciado_en" gorm:"not null"`
+	DuracionMs           int64     `json:"duracion_ms" gorm:"not null"`
+	Error                string    `json:"error,omitempty" gorm:"type:text"`
+}
+
+func (RecalculoTarea) TableName() string { return "recalculo_tareas" }
+
+// Acción registrada en Auditoria
+const (
+	AuditoriaCrear      = "crear"
+	AuditoriaActualizar = "actualizar"
+	AuditoriaEliminar   = "eliminar"
+)
+
+// Auditoria registra un campo de una tabla de entrada que cambió: la fila
+// (Entidad es el nombre de la tabla), el valor anterior y el nuevo como JSON
+// (nil si la fila no existía antes o ya no existe), quién lo cambió y cuándo.
+// Las filas de un mismo cambio comparten Fecha.
+type Auditoria struct {
+	ID            uint      `json:"id" gorm:"primaryKey;autoIncrement"`
+	PlanNegocioID uint      `json:"plan_negocio_id" gorm:"not null;index"`
+	Entidad       string    `json:"entidad" gorm:"type:varchar(60);not null;index"`
+	RegistroID    uint      `json:"registro_id" gorm:"not null"`
+	Accion        string    `json:"accion" gorm:"type:varchar(10);not null"`
+	Campo         string    `json:"campo" gorm:"type:varchar(60);not null"`
+	ValorAnterior *string   `json:"valor_anterior" gorm:"type:text"`
+	ValorNuevo    *string   `json:"valor_nuevo" gorm:"type:text"`
+	Actor         string    `json:"actor" gorm:"type:varchar(90);not null"`
+	Fecha         time.Time `json:"fecha" gorm:"not null;index"`
+}
+
+func (Auditoria) TableName() string { return "auditoria" }
+
+// PlanSnapshot es una copia congelada de un plan con una etiqueta (p. ej.
+// "presentado al banco 2026-09"): Documento es el JSON de ExportarPlan con las
+// entradas y los estados calculados al momento de crearla. Desactualizado
+// indica que la última ejecución de Recalcular no había terminado bien.
+type PlanSnapshot struct {
+	ID             uint         `json:"id" gorm:"primaryKey;autoIncrement"`
+	PlanNegocioID  uint         `json:"plan_negocio_id" gorm:"not null;index"`
+	Etiqueta       string       `json:"etiqueta" gorm:"type:varchar(120);not null"`
+	CreadoPor      string       `json:"creado_por" gorm:"type:varchar(90)"`
+	Desactualizado bool         `json:"desactualizado" gorm:"not null;default:false"`
+	CreatedAt      time.Time    `json:"created_at"`
+	Documento      string       `json:"-" gorm:"type:text;not null"`
+	PlanNegocio    *PlanNegocio `json:"plan_negocio,omitempty" gorm:"foreignKey:PlanNegocioID;constraint:OnDelete:CASCADE"`
+}
+
+func (PlanSnapshot) TableName() string { return "plan_snapshots" }
+
+// Escenario es una variante con nombre de un plan (base, optimista, pesimista...)
+// que se calcula en memoria sin tocar las entradas del plan. Volumen, Precio y
+// Costo son choques porcentuales como los de la matriz de sensibilidad; los
+// demás campos reemplazan el valor del plan cuando no son nil, y Anios
+// reemplaza el crecimiento de ventas (VariacionAnual) de esos años.
+type Escenario struct {
+	ID                   uint            `json:"id" gorm:"primaryKey;autoIncrement"`
+	PlanNegocioID        uint            `json:"plan_negocio_id" gorm:"not null;index"`
+	Nombre               string          `json:"nombre" gorm:"type:varchar(80);not null"`
+	Descripcion          string          `json:"descripcion" gorm:"type:text"`
+	Volumen              float64         `json:"volumen" gorm:"type:numeric(6,2);not null;default:0"`
+	Precio               float64         `json:"precio" gorm:"type:numeric(6,2);not null;default:0"`
+	Costo                float64         `json:"costo" gorm:"type:numeric(6,2);not null;default:0"`
+	Inflacion            *float64        `json:"inflacion" gorm:"type:numeric(6,2)"`
+	TasaImpuesto         *float64        `json:"tasa_impuesto" gorm:"type:numeric(6,2)"`
+	PTU                  *float64        `json:"ptu" gorm:"type:numeric(6,2)"`
+	DiasxMes             *int            `json:"diasxmes" gorm:"column:diasxmes"`
+	CapitalPorcentaje    *float64        `json:"capital_porcentaje" gorm:"type:numeric(6,2)"`
+	DeudaPorcentaje      *float64        `json:"deuda_porcentaje" gorm:"type:numeric(6,2)"`
+	TasaAnual            *float64        `json:"tasa_anual" gorm:"type:numeric(6,2)"`
+	PeriodosAmortizacion *int            `json:"periodos_amortizacion"`
+	TREMA                *float64        `json:"trema" gorm:"type:numeric(6,2)"`
+	Anios                []EscenarioAnio `json:"anios" gorm:"foreignKey:EscenarioID;constraint:OnDelete:CASCADE"`
+	PlanNegocio          *PlanNegocio    `json:"plan_negocio,omitempty" gorm:"foreignKey:PlanNegocioID;constraint:OnDelete:CASCADE"`
+}
+
+// EscenarioAnio es el crecimiento de ventas de un año dentro de un Escenario
+type EscenarioAnio struct {
+	ID          uint    `json:"id" gorm:"primaryKey;autoIncrement"`
+	EscenarioID uint    `json:"escenario_id" gorm:"not null;index"`
+	Anio        int     `json:"anio" gorm:"not null"`
+	Crecimiento float64 `json:"crecimiento" gorm:"type:numeric(6,2)"`
+}
+
+// SimulacionMonteCarlo guarda una corrida de la simulación Monte Carlo del
+// plan para mostrarla de nuevo sin volver a simular: Parametros son las
+// distribuciones, correlaciones y semilla usadas y Resultado el resumen de
+// VAN/TIR, ambos como JSON.
+type SimulacionMonteCarlo struct {
+	ID            uint         `json:"id" gorm:"primaryKey;autoIncrement"`
+	PlanNegocioID uint         `json:"plan_negocio_id" gorm:"not null;index"`
+	Simulaciones  int          `json:"simulaciones" gorm:"not null"`
+	Semilla       int64        `json:"semilla" gorm:"not null"`
+	CreadoPor     string       `json:"creado_por" gorm:"type:varchar(90)"`
+	CreatedAt     time.Time    `json:"created_at"`
+	Parametros    string       `json:"-" gorm:"type:text;not null"`
+	Resultado     string       `json:"-" gorm:"type:text;not null"`
+	PlanNegocio   *PlanNegocio `json:"plan_negocio,omitempty" gorm:"foreignKey:PlanNegocioID;constraint:OnDelete:CASCADE"`
+}
+
+func (SimulacionMonteCarlo) TableName() string { return "simulaciones_montecarlo" }
@@ -0,0 +1,53 @@
+package pdf
+
+// Anchos de los caracteres 32-126 (en milésimas del tamaño de la fuente)
+// según las métricas AFM de Helvetica y Helvetica-Bold.
+var anchosHelvetica = [95]int{
+	278, 278, 355, 556, 556, 889, 667, 191, 333, 333, 389, 584, 278, 333, 278, 278, // espacio a /
+	556, 556, 556, 556, 556, 556, 556, 556, 556, 556, // 0-9
+	278, 278, 584, 584, 584, 556, 1015, // : a @
+	667, 667, 722, 722, 667, 611, 778, 722, 278, 500, 667, 556, 833, // A-M
+	722, 778, 667, 778, 722, 667, 611, 722, 667, 944, 667, 667, 611, // N-Z
+	278, 278, 278, 469, 556, 333, // [ a `
+	556, 556, 500, 556, 556, 278, 556, 556, 222, 222, 500, 222, 833, // a-m
+	556, 556, 556, 556, 333, 500, 278, 556, 500, 722, 500, 500, 500, // n-z
+	334, 260, 334, 584, // { a ~
+}
+
+var anchosHelveticaNegrita = [95]int{
+	278, 333, 474, 556, 556, 889, 722, 238, 333, 333, 389, 584, 278, 333, 278, 278,
+	556, 556, 556, 556, 556, 556, 556, 556, 556, 556,
+	333, 333, 584, 584, 584, 611, 975,
+	722, 722, 722, 722, 667, 611, 778, 722, 278, 556, 722, 611, 833,
+	722, 778, 667, 778, 722, 667, 611, 722, 667, 944, 667, 667, 611,
+	333, 278, 333, 584, 556, 333,
+	556, 611, 556, 611, 556, 333, 611, 611, 278, 278, 556, 278, 889,
+	611, 611, 611, 611, 389, 556, 333, 611, 556, 778, 556, 556, 500,
+	389, 280, 389, 584,
+}
+
+// baseLatin1 asocia las letras acentuadas de 0xC0-0xFF con la letra sin acento,
+// que tiene el mismo ancho
+const baseLatin1 = "AAAAAAACEEEEIIIIDNOOOOO*OUUUUYPsaaaaaaaceeeeiiiidnooooo/ouuuuypy"
+
+func ancho(anchos *[95]int, b byte) int {
+	switch {
+	case b >= 32 && b <= 126:
+		return anchos[b-32]
+	case b >= 0xC0:
+		return anchos[baseLatin1[b-0xC0]-32]
+	case b == 0xA0:
+		return anchos[0]
+	case b == 0xA1: // ¡
+		return anchos['!'-32]
+	case b == 0xBF: // ¿
+		return anchos['?'-32]
+	case b == 0x85: // …
+		return 1000
+	case b == 0x96: // –
+		return 556
+	case b == 0x97: // —
+		return 1000
+	}
+	return 556
+}
@@ -0,0 +1,216 @@
+// Package pdf genera documentos PDF sencillos sin dependencias externas: páginas
+// A4 con texto en las fuentes estándar Helvetica y Helvetica-Bold (codificación
+// WinAnsi, que cubre los acentos y la ñ del español), rectángulos y líneas.
+// Las coordenadas se expresan en puntos con el origen en la esquina superior
+// izquierda de la página.
+package pdf
+
+import (
+	"bytes"
+	"compress/zlib"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// Tamaño de página A4 en puntos
+const (
+	AnchoA4 = 595.28
+	AltoA4  = 841.89
+)
+
+// Fuente es una de las fuentes estándar incluidas en todo lector de PDF
+type Fuente int
+
+const (
+	Normal Fuente = iota
+	Negrita
+)
+
+// Color RGB con componentes entre 0 y 1
+type Color struct{ R, G, B float64 }
+
+var (
+	Negro  = Color{0, 0, 0}
+	Blanco = Color{1, 1, 1}
+	Gris   = Color{0.5, 0.5, 0.5}
+)
+
+// Alineacion horizontal del texto respecto de x
+type Alineacion int
+
+const (
+	Izquierda Alineacion = iota
+	Derecha
+	Centro
+)
+
+// Documento es un PDF en construcción
+type Documento struct {
+	Titulo  string
+	paginas []*bytes.Buffer
+	actual  *bytes.Buffer
+}
+
+// Nuevo crea un documento sin páginas
+func Nuevo() *Documento { return &Documento{} }
+
+// NuevaPagina agrega una página A4 vertical; los dibujos siguientes van en ella
+func (d *Documento) NuevaPagina() {
+	d.actual = &bytes.Buffer{}
+	d.paginas = append(d.paginas, d.actual)
+}
+
+// Paginas devuelve la cantidad de páginas
+func (d *Documento) Paginas() int { return len(d.paginas) }
+
+func (d *Documento) pagina() *bytes.Buffer {
+	if d.actual == nil {
+		d.NuevaPagina()
+	}
+	return d.actual
+}
+
+// Texto escribe s con la línea base en y
+func (d *Documento) Texto(x, y float64, s string, f Fuente, tam float64, c Color, al Alineacion) {
+	switch al {
+	case Derecha:
+		x -= AnchoTexto(s, f, tam)
+	case Centro:
+		x -= AnchoTexto(s, f, tam) / 2
+	}
+	fmt.Fprintf(d.pagina(), "BT %s rg /F%d %s Tf %s %s Td (%s) Tj ET\n",
+		color(c), f+1, num(tam), num(x), num(AltoA4-y), cadena(s))
+}
+
+// Rect rellena el rectángulo cuya esquina superior izquierda es (x, y)
+func (d *Documento) Rect(x, y, ancho, alto float64, relleno Color) {
+	fmt.Fprintf(d.pagina(), "%s rg %s %s %s %s re f\n",
+		color(relleno), num(x), num(AltoA4-y-alto), num(ancho), num(alto))
+}
+
+// Linea traza un segmento
+func (d *Documento) Linea(x1, y1, x2, y2, grosor float64, c Color) {
+	fmt.Fprintf(d.pagina(), "%s RG %s w %s %s m %s %s l S\n",
+		color(c), num(grosor), num(x1), num(AltoA4-y1), num(x2), num(AltoA4-y2))
+}
+
+// AnchoTexto mide s en puntos
+func AnchoTexto(s string, f Fuente, tam float64) float64 {
+	anchos := &anchosHelvetica
+	if f == Negrita {
+		anchos = &anchosHelveticaNegrita
+	}
+	total := 0
+	for _, b := range winAnsi(s) {
+		total += ancho(anchos, b)
+	}
+	return float64(total) * tam / 1000
+}
+
+// Escribir serializa el documento en w
+func (d *Documento) Escribir(w io.Writer) error {
+	if len(d.paginas) == 0 {
+		d.NuevaPagina()
+	}
+	var out bytes.Buffer
+	var offsets []int
+	objeto := func(cuerpo string) {
+		offsets = append(offsets, out.Len())
+		fmt.Fprintf(&out, "%d 0 obj\n%s\nendobj\n", len(offsets), cuerpo)
+	}
+	out.WriteString("%PDF-1.4\n%\xe2\xe3\xcf\xd3\n")
+
+	// 1 catálogo, 2 árbol de páginas, 3 y 4 fuentes, 5 info; luego página y contenido por cada página
+	const primeraPagina = 6
+	objeto("<< /Type /Catalog /Pages 2 0 R >>")
+	kids := make([]string, len(d.paginas))
+	for i := range d.paginas {
+		kids[i] = fmt.Sprintf("%d 0 R", primeraPagina+2*i)
+	}
+	objeto(fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), len(d.paginas)))
+	objeto("<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica /Encoding /WinAnsiEncoding >>")
+	objeto("<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica-Bold /Encoding /WinAnsiEncoding >>")
+	objeto(fmt.Sprintf("<< /Title (%s) /Producer (liveplan) >>", cadena(d.Titulo)))
+	for i, p := range d.paginas {
+		objeto(fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %s %s] "+
+			"/Resources << /Font << /F1 3 0 R /F2 4 0 R >> >> /Contents %d 0 R >>",
+			num(AnchoA4), num(AltoA4), primeraPagina+2*i+1))
+		var z bytes.Buffer
+		zw := zlib.NewWriter(&z)
+		if _, err := zw.Write(p.Bytes()); err != nil {
+			return err
+		}
+		if err := zw.Close(); err != nil {
+			return err
+		}
+		offsets = append(offsets, out.Len())
+		fmt.Fprintf(&out, "%d 0 obj\n<< /Length %d /Filter /FlateDecode >>\nstream\n", len(offsets), z.Len())
+		out.Write(z.Bytes())
+		out.WriteString("\nendstream\nendobj\n")
+	}
+
+	xref := out.Len()
+	fmt.Fprintf(&out, "xref\n0 %d\n0000000000 65535 f \n", len(offsets)+1)
+	for _, o := range offsets {
+		fmt.Fprintf(&out, "%010d 00000 n \n", o)
+	}
+	fmt.Fprintf(&out, "trailer\n<< /Size %d /Root 1 0 R /Info 5 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(offsets)+1, xref)
+	_, err := w.Write(out.Bytes())
+	return err
+}
+
+func num(v float64) string {
+	return strconv.FormatFloat(v, 'f', 2, 64)
+}
+
+func color(c Color) string {
+	return num(c.R) + " " + num(c.G) + " " + num(c.B)
+}
+
+// cadena escribe s como literal de PDF en WinAnsi
+func cadena(s string) string {
+	var b strings.Builder
+	for _, c := range winAnsi(s) {
+		switch c {
+		case '(', ')', '\\':
+			b.WriteByte('\\')
+			b.WriteByte(c)
+		default:
+			if c < 32 || c > 126 {
+				fmt.Fprintf(&b, "\\%03o", c)
+			} else {
+				b.WriteByte(c)
+			}
+		}
+	}
+	return b.String()
+}
+
+// especialesWinAnsi son los caracteres de 0x80-0x9F que difieren de Latin-1
+var especialesWinAnsi = map[rune]byte{
+	'€': 0x80, '‚': 0x82, '„': 0x84, '…': 0x85, '‘': 0x91, '’': 0x92,
+	'“': 0x93, '”': 0x94, '•': 0x95, '–': 0x96, '—': 0x97, '™': 0x99,
+}
+
+// winAnsi convierte s a la codificación de las fuentes; lo que no tiene
+// representación se escribe como '?'
+func winAnsi(s string) []byte {
+	out := make([]byte, 0, len(s))
+	for _, r := range s {
+		switch {
+		case r == '\t' || r == '\n' || r == '\r':
+			out = append(out, ' ')
+		case r >= 32 && r < 127, r >= 0xA0 && r <= 0xFF:
+			out = append(out, byte(r))
+		default:
+			if b, ok := especialesWinAnsi[r]; ok {
+				out = append(out, b)
+			} else {
+				out = append(out, '?')
+			}
+		}
+	}
+	return out
+}
@@ -3,81 +3,68 @@ package procedimientos
 import (
 	"fmt"
 
+	"github.com/JostinAlvaradoS/liveplan_backend_go/internal/model"
 	"github.com/JostinAlvaradoS/liveplan_backend_go/internal/models"
 	"gorm.io/gorm"
 )
 
 // CalcularAnalisisSensibilidad calcula el análisis de sensibilidad para un plan:
-// 1. Obtiene todos los registros de AnalisisSensibilidad para el plan
-// 2. Para cada combinación volumen/costo:
-//   - Guarda los valores originales de VariablesDeSensibilidad
-//   - Actualiza VariablesDeSensibilidad con los valores de la combinación
-//   - Ejecuta Recalcular para actualizar todos los cálculos
-//   - Obtiene el VAN de EvaluacionProyecto
-//   - Actualiza el registro de AnalisisSensibilidad con el VAN
-//   - Restaura los valores originales de VariablesDeSensibilidad
+//  1. Obtiene todos los registros de AnalisisSensibilidad para el plan
+//  2. Carga una sola vez la foto del plan (CargarEntradas)
+//  3. Para cada combinación volumen/costo ejecuta el recálculo en memoria
+//     (model.Compute) con esos choques y guarda el VAN resultante en la celda
+//
+// VariablesDeSensibilidad y las tablas derivadas del plan no se modifican; lo
+// único que se escribe es el valor de cada celda de la matriz.
 func CalcularAnalisisSensibilidad(db *gorm.DB, planID uint) error {
-	return db.Transaction(func(tx *gorm.DB) error {
-		// 1. Obtener todos los registros de AnalisisSensibilidad para el plan
-		var analisisList []models.AnalisisSensibilidad
-		if err := tx.Where("plan_negocio_id = ?", planID).Find(&analisisList).Error; err != nil {
-			return fmt.Errorf("error al obtener AnalisisSensibilidad: %w", err)
-		}
-
-		// 2. Obtener las variables de sensibilidad originales
-		var variablesOriginales models.VariablesDeSensibilidad
-		if err := tx.Where("plan_negocio_id = ?", planID).First(&variablesOriginales).Error; err != nil {
-			return fmt.Errorf("error al obtener VariablesDeSensibilidad originales: %w", err)
-		}
-
-		// 3. Para cada combinación volumen/costo, calcular el VAN
-		for i, analisis := range analisisList {
-			// Actualizar VariablesDeSensibilidad con los valores de la combinación
-			variablesTemp := variablesOriginales
+	return CalcularAnalisisSensibilidadConProgreso(db, planID, nil)
+}
 
-			// Aplicar las variaciones de volumen y costo
-			variablesTemp.Cantidad_volumen = variablesOriginales.Cantidad_volumen * (1 + analisis.Volumen/100.0)
-			variablesTemp.Costo = variablesOriginales.Costo * (1 + analisis.Costo/100.0)
+// CalcularAnalisisSensibilidadConProgreso hace lo mismo que CalcularAnalisisSensibilidad
+// y llama a progreso(hechas, total) después de cada celda de la matriz (progreso puede ser nil).
+// Corre con el plazo de EtapaSensibilidad y se detiene entre celdas si el
+// contexto de db se cancela; en ese caso no guarda ninguna celda.
+func CalcularAnalisisSensibilidadConProgreso(db *gorm.DB, planID uint, progreso func(hechas, total int)) error {
+	return conPlazo(db, EtapaSensibilidad, func(db *gorm.DB) error {
+		return calcularSensibilidad(db, planID, progreso)
+	})
+}
 
-			// Guardar las variables temporales (sin usar transacción anidada)
-			if err := tx.Model(&models.VariablesDeSensibilidad{}).
-				Where("plan_negocio_id = ?", planID).
-				Updates(map[string]interface{}{
-					"cantidad_volumen": variablesTemp.Cantidad_volumen,
-					"costo":            variablesTemp.Costo,
-				}).Error; err != nil {
-				return fmt.Errorf("error al actualizar VariablesDeSensibilidad temporalmente: %w", err)
-			}
+func calcularSensibilidad(db *gorm.DB, planID uint, progreso func(hechas, total int)) error {
+	var analisisList []models.AnalisisSensibilidad
+	if err := db.Where("plan_negocio_id = ?", planID).Order("id asc").Find(&analisisList).Error; err != nil {
+		return fmt.Errorf("error al obtener AnalisisSensibilidad: %w", err)
+	}
 
-			// Ejecutar recálculo completo
-			if err := Recalcular(tx, planID); err != nil {
-				return fmt.Errorf("error en recálculo para volumen %.2f%%, costo %.2f%%: %w",
-					analisis.Volumen, analisis.Costo, err)
-			}
+	entradas, err := CargarEntradas(db, planID)
+	if err != nil {
+		return fmt.Errorf("error al cargar el plan %d: %w", planID, err)
+	}
 
-			// Obtener el VAN calculado
-			var evaluacion models.EvaluacionProyecto
-			if err := tx.Where("plan_negocio_id = ?", planID).First(&evaluacion).Error; err != nil {
-				return fmt.Errorf("error al obtener EvaluacionProyecto: %w", err)
-			}
+	ctx := contexto(db)
+	for i, analisis := range analisisList {
+		if err := ctx.Err(); err != nil {
+			return fmt.Errorf("análisis de sensibilidad cancelado en la celda %d de %d: %w", i+1, len(analisisList), err)
+		}
+		out, err := model.Compute(entradas, model.Shocks{Volumen: analisis.Volumen, Costo: analisis.Costo})
+		if err != nil {
+			return fmt.Errorf("error en recálculo para volumen %.2f%%, costo %.2f%%: %w",
+				analisis.Volumen, analisis.Costo, err)
+		}
+		analisisList[i].Valor = out.Evaluacion.VAN
+		if progreso != nil {
+			progreso(i+1, len(analisisList))
+		}
+	}
 
-			// Actualizar el registro de AnalisisSensibilidad con el VAN
-			analisisList[i].Valor = evaluacion.VAN
-			if err := tx.Save(&analisisList[i]).Error; err != nil {
+	// Guardar todas las celdas juntas para no dejar la matriz a medias
+	return db.Transaction(func(tx *gorm.DB) error {
+		for _, analisis := range analisisList {
+			if err := tx.Model(&models.AnalisisSensibilidad{}).Where("id = ?", analisis.ID).
+				Update("valor", analisis.Valor).Error; err != nil {
 				return fmt.Errorf("error al actualizar AnalisisSensibilidad: %w", err)
 			}
 		}
-
-		// 4. Restaurar los valores originales de VariablesDeSensibilidad
-		if err := tx.Model(&models.VariablesDeSensibilidad{}).
-			Where("plan_negocio_id = ?", planID).
-			Updates(map[string]interface{}{
-				"cantidad_volumen": variablesOriginales.Cantidad_volumen,
-				"costo":            variablesOriginales.Costo,
-			}).Error; err != nil {
-			return fmt.Errorf("error al restaurar VariablesDeSensibilidad originales: %w", err)
-		}
-
 		return nil
 	})
 }
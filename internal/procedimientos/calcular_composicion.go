@@ -3,10 +3,18 @@ package procedimientos
 import (
 	"fmt"
 
+	"github.com/JostinAlvaradoS/liveplan_backend_go/internal/model"
 	"github.com/JostinAlvaradoS/liveplan_backend_go/internal/models"
 	"gorm.io/gorm"
 )
 
+var procComposicion = Procedimiento{
+	Nombre:   "composicion",
+	Lee:      []string{"detalle_inversion_inicial", "composicion_financiamiento"},
+	Escribe:  []string{"composicion_financiamiento"},
+	Ejecutar: CalcularComposicion,
+}
+
 // CalcularComposicion calcula el total de inversión para la tabla
 // ComposicionFinanciamiento de un plan sumando los importes de todos los
 // DetalleInversionInicial asociados al plan y actualiza el campo
@@ -14,37 +22,18 @@ import (
 func CalcularComposicion(db *gorm.DB, planID uint) error {
 	return db.Transaction(func(tx *gorm.DB) error {
 		var detalles []models.DetalleInversionInicial
-		if err := tx.Where("plan_negocio_id = ?", planID).Find(&detalles).Error; err != nil {
-			return fmt.Errorf("loading detalle_inversion for plan %d: %w", planID, err)
+		if err := cargarLista(tx, planID, "detalle_inversion_inicial", &detalles); err != nil {
+			return err
 		}
 
-		var total float64
-		for _, d := range detalles {
-			total += d.Importe
+		// si no existe la composición se crea con el total calculado
+		comp := models.ComposicionFinanciamiento{PlanNegocioID: planID}
+		if err := cargarOpcional(tx, planID, "composicion_financiamiento", &comp); err != nil {
+			return err
 		}
-
-		// upsert: try to find existing composicion_financiamiento row for plan
-		var comp models.ComposicionFinanciamiento
-		err := tx.Where("plan_negocio_id = ?", planID).First(&comp).Error
-		if err != nil {
-			if err == gorm.ErrRecordNotFound {
-				// create new
-				comp = models.ComposicionFinanciamiento{
-					PlanNegocioID:   planID,
-					Total_Inversion: total,
-				}
-				if err := tx.Create(&comp).Error; err != nil {
-					return fmt.Errorf("creating composicion_financiamiento for plan %d: %w", planID, err)
-				}
-				return nil
-			}
-			return fmt.Errorf("loading composicion_financiamiento for plan %d: %w", planID, err)
-		}
-
-		// update existing
-		comp.Total_Inversion = total
+		comp = model.ComputeInvestmentTotal(comp, detalles)
 		if err := tx.Save(&comp).Error; err != nil {
-			return fmt.Errorf("updating composicion_financiamiento for plan %d: %w", planID, err)
+			return fmt.Errorf("saving composicion_financiamiento for plan %d: %w", planID, err)
 		}
 		return nil
 	})
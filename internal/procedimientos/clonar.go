@@ -0,0 +1,206 @@
+package procedimientos
+
+import (
+	"fmt"
+	"reflect"
+
+	"github.com/JostinAlvaradoS/liveplan_backend_go/internal/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ClonarPlan copia en una sola transacción el plan y todas sus tablas a un plan
+// nuevo cuyo autor es autor. Las referencias internas (producto, inversión,
+// detalle de inversión, depreciación, variación anual) se remapean a las filas
+// nuevas; las de catálogo (tipo de inversión, categoría de costo) se conservan.
+// Las filas huérfanas (que apuntan a un producto o inversión que ya no existe)
+// no se copian. No recalcula: el llamador debe ejecutar Recalcular sobre el clon.
+func ClonarPlan(db *gorm.DB, planID uint, autor string) (models.PlanNegocio, error) {
+	var clon models.PlanNegocio
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var origen models.PlanNegocio
+		if err := tx.First(&origen, planID).Error; err != nil {
+			return err
+		}
+		clon = origen
+		clon.ID = 0
+		clon.Autor = autor
+		clon.Problematica = recortar(origen.Problematica+" (copia)", 300)
+		if err := tx.Create(&clon).Error; err != nil {
+			return fmt.Errorf("creating plan clone: %w", err)
+		}
+		c := clonador{tx: tx, origen: planID, destino: clon.ID}
+
+		productos, err := clonarTabla(c, "producto_servicio", siempre[models.ProductoServicio])
+		if err != nil {
+			return err
+		}
+		porProducto := func(id *uint) bool { return remapear(productos, id) }
+
+		inversiones, err := clonarTabla(c, "inversion_inicial", siempre[models.InversionInicial])
+		if err != nil {
+			return err
+		}
+		detalles, err := clonarTabla(c, "detalle_inversion_inicial", func(f *models.DetalleInversionInicial) bool {
+			return remapear(inversiones, &f.InversionID)
+		})
+		if err != nil {
+			return err
+		}
+		depreciaciones, err := clonarTabla(c, "depreciaciones", func(f *models.Depreciacion) bool {
+			return remapear(detalles, &f.DetalleInversionID)
+		})
+		if err != nil {
+			return err
+		}
+		if _, err := clonarTabla(c, "depreciacion_anual", func(f *models.DepreciacionAnual) bool {
+			return remapear(depreciaciones, &f.DepreciacionID)
+		}); err != nil {
+			return err
+		}
+		variaciones, err := clonarTabla(c, "variacion_anual", siempre[models.VariacionAnual])
+		if err != nil {
+			return err
+		}
+		if _, err := clonarTabla(c, "variacion_anual_anio", func(f *models.VariacionAnualAnio) bool {
+			return remapear(variaciones, &f.VariacionAnualID)
+		}); err != nil {
+			return err
+		}
+
+		// Tablas por producto
+		pasos := []func() error{
+			func() error {
+				_, err := clonarTabla(c, "venta_diaria", func(f *models.VentaDiaria) bool { return porProducto(&f.ProductoServicioID) })
+				return err
+			},
+			func() error {
+				_, err := clonarTabla(c, "precios_prod_serv", func(f *models.PreciosProdServ) bool { return porProducto(&f.ProductoServicioID) })
+				return err
+			},
+			func() error {
+				_, err := clonarTabla(c, "costos_prod_serv", func(f *models.CostosProdServ) bool { return porProducto(&f.ProductoServicioID) })
+				return err
+			},
+			func() error {
+				_, err := clonarTabla(c, "presupuesto_venta", func(f *models.PresupuestoVenta) bool { return porProducto(&f.ProductoID) })
+				return err
+			},
+			func() error {
+				_, err := clonarTabla(c, "ventas_dinero", func(f *models.VentasDinero) bool { return porProducto(&f.ProductoID) })
+				return err
+			},
+			func() error {
+				_, err := clonarTabla(c, "ventas", func(f *models.Ventas) bool { return porProducto(&f.ProductoID) })
+				return err
+			},
+			func() error {
+				_, err := clonarTabla(c, "costos_ventas", func(f *models.CostosVentas) bool { return porProducto(&f.ProductoID) })
+				return err
+			},
+			func() error {
+				_, err := clonarTabla(c, "costo_materias_primas", func(f *models.CostoMateriasPrimas) bool { return porProducto(&f.ProductoID) })
+				return err
+			},
+		}
+		// Tablas sin referencias internas
+		pasos = append(pasos,
+			clonarTodo[models.Supuesto](c, "supuesto"),
+			clonarTodo[models.VariablesDeSensibilidad](c, "variables_de_sensibilidad"),
+			clonarTodo[models.IndicadoresMacro](c, "indicadores_macro"),
+			clonarTodo[models.ComposicionFinanciamiento](c, "composicion_financiamiento"),
+			clonarTodo[models.DatosPrestamo](c, "datos_prestamo"),
+			clonarTodo[models.PrestamoCuotas](c, "prestamo_cuotas"),
+			clonarTodo[models.GastosOperacion](c, "gastos_operacion"),
+			clonarTodo[models.PoliticasVenta](c, "politicas_venta"),
+			clonarTodo[models.PoliticasCompra](c, "politicas_compra"),
+			clonarTodo[models.EstadoResultados](c, "estado_resultados"),
+			clonarTodo[models.FlujoEfectivo](c, "flujo_efectivo"),
+			clonarTodo[models.BalanceGeneral](c, "balance_general"),
+			clonarTodo[models.ConceptosEvaluacion](c, "conceptos_evaluacion"),
+			clonarTodo[models.EvaluacionProyecto](c, "evaluacion_proyecto"),
+			clonarTodo[models.AnalisisSensibilidad](c, "analisis_sensibilidad"),
+		)
+		for _, paso := range pasos {
+			if err := paso(); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	return clon, err
+}
+
+// clonador lleva la transacción y los planes origen y destino de una copia
+type clonador struct {
+	tx      *gorm.DB
+	origen  uint
+	destino uint
+}
+
+// clonarTabla copia las filas del plan origen al destino. ajustar remapea las
+// referencias de cada fila y devuelve false para omitirla. Devuelve el mapa id
+// viejo -> id nuevo de las filas copiadas.
+func clonarTabla[T any](c clonador, tabla string, ajustar func(*T) bool) (map[uint]uint, error) {
+	var filas []T
+	if err := cargarLista(c.tx, c.origen, tabla, &filas); err != nil {
+		return nil, err
+	}
+	return insertarCopias(c.tx, tabla, c.destino, filas, ajustar)
+}
+
+// insertarCopias inserta copias de filas en el plan destino con ID nuevo. ajustar
+// remapea las referencias de cada fila y devuelve false para omitirla. Devuelve
+// el mapa id original -> id nuevo. filas se modifica.
+func insertarCopias[T any](tx *gorm.DB, tabla string, destino uint, filas []T, ajustar func(*T) bool) (map[uint]uint, error) {
+	viejos := make([]uint, 0, len(filas))
+	copiar := make([]T, 0, len(filas))
+	for i := range filas {
+		f := &filas[i]
+		if !ajustar(f) {
+			continue
+		}
+		v := reflect.ValueOf(f).Elem()
+		viejos = append(viejos, uint(v.FieldByName("ID").Uint()))
+		v.FieldByName("ID").SetUint(0)
+		v.FieldByName("PlanNegocioID").SetUint(uint64(destino))
+		copiar = append(copiar, *f)
+	}
+	ids := make(map[uint]uint, len(copiar))
+	if len(copiar) == 0 {
+		return ids, nil
+	}
+	if err := tx.Omit(clause.Associations).CreateInBatches(&copiar, 200).Error; err != nil {
+		return nil, fmt.Errorf("copying %s: %w", tabla, err)
+	}
+	for i := range copiar {
+		ids[viejos[i]] = uint(reflect.ValueOf(&copiar[i]).Elem().FieldByName("ID").Uint())
+	}
+	return ids, nil
+}
+
+// clonarTodo copia una tabla que no referencia otras filas del plan
+func clonarTodo[T any](c clonador, tabla string) func() error {
+	return func() error {
+		_, err := clonarTabla(c, tabla, siempre[T])
+		return err
+	}
+}
+
+// remapear cambia *id por su equivalente en el clon; false si la fila es huérfana
+func remapear(ids map[uint]uint, id *uint) bool {
+	nuevo, ok := ids[*id]
+	if ok {
+		*id = nuevo
+	}
+	return ok
+}
+
+// recortar limita s a n caracteres
+func recortar(s string, n int) string {
+	r := []rune(s)
+	if len(r) <= n {
+		return s
+	}
+	return string(r[:n])
+}
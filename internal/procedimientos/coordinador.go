@@ -0,0 +1,346 @@
+package procedimientos
+
+import (
+	"context"
+	"errors"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/JostinAlvaradoS/liveplan_backend_go/internal/models"
+	"gorm.io/gorm"
+)
+
+// Estados del recálculo de un plan
+const (
+	RecalculoInactivo  = "idle"
+	RecalculoEnCola    = "queued"
+	RecalculoEjecucion = "running"
+)
+
+// claseBloqueoRecalculo es la primera clave de pg_advisory_lock(int, int) para
+// los recálculos; la segunda es el id del plan
+const claseBloqueoRecalculo = 0x4c50 // "LP"
+
+// EstadoRecalculo es la foto del recálculo de un plan
+type EstadoRecalculo struct {
+	PlanNegocioID uint       `json:"plan_negocio_id"`
+	Estado        string     `json:"estado"`
+	Pendientes    int        `json:"pendientes"`
+	Desde         *time.Time `json:"desde,omitempty"`
+	// OtraReplica indica que el bloqueo del plan lo tiene otro proceso
+	OtraReplica bool `json:"otra_replica,omitempty"`
+}
+
+// Coordinador serializa los recálculos de cada plan. Las peticiones que llegan
+// mientras un recálculo corre se agrupan en una sola ejecución posterior, y
+// todas reciben su resultado. Entre réplicas se serializa con un advisory lock
+// de Postgres por plan.
+type Coordinador struct {
+	// Ventana es la espera antes de empezar una ejecución para juntar las
+	// ediciones de una ráfaga
+	Ventana time.Duration
+
+	// Registrar guarda el resultado de cada ejecución (puede ser nil)
+	Registrar func(db *gorm.DB, e *models.RecalculoEjecucion) error
+
+	ejecutar func(db *gorm.DB, planID uint, tablas []string) ([]TiempoTarea, error)
+	mu       sync.Mutex
+	planes   map[uint]*colaPlan
+}
+
+// colaPlan es el estado de un plan con recálculos en curso o pendientes
+type colaPlan struct {
+	pendiente *ronda
+	actual    *ronda
+	// atendiendo queda en true mientras vive la goroutine de atender del plan,
+	// también entre rondas (durante la ventana)
+	atendiendo bool
+}
+
+// ronda es una ejecución de Recalcular compartida por varias peticiones
+type ronda struct {
+	// db está ligado a ctx, que se cancela cuando ya nadie espera la ronda
+	db          *gorm.DB
+	ctx         context.Context
+	cancelar    context.CancelFunc
+	esperando   int
+	solicitudes int
+	// completo pide recalcular todo; si no, solo lo que depende de tablas
+	completo  bool
+	tablas    []string
+	desde     time.Time
+	corriendo bool
+	hecho     chan struct{}
+	ejecucion models.RecalculoEjecucion
+	tiempos   []TiempoTarea
+	err       error
+}
+
+// NuevoCoordinador crea un coordinador que ejecuta fn para cada ronda; tablas
+// es nil si la ronda debe recalcular todo. fn devuelve el tiempo de cada tarea
+// para el registro de la ejecución.
+func NuevoCoordinador(fn func(db *gorm.DB, planID uint, tablas []string) ([]TiempoTarea, error)) *Coordinador {
+	return &Coordinador{ejecutar: fn, planes: make(map[uint]*colaPlan)}
+}
+
+// coordinador es el que usa Recalcular; cada ejecución queda en recalculo_ejecuciones
+var coordinador = func() *Coordinador {
+	c := NuevoCoordinador(recalcularEtapas)
+	c.Registrar = func(db *gorm.DB, e *models.RecalculoEjecucion) error { return db.Create(e).Error }
+	return c
+}()
+
+// ConfigurarRecalculo fija la ventana de agrupación de Recalcular
+func ConfigurarRecalculo(ventana time.Duration) {
+	coordinador.mu.Lock()
+	defer coordinador.mu.Unlock()
+	coordinador.Ventana = ventana
+}
+
+// EstadoRecalculoPlan devuelve el estado del recálculo del plan
+func EstadoRecalculoPlan(db *gorm.DB, planID uint) (EstadoRecalculo, error) {
+	return coordinador.Estado(db, planID)
+}
+
+// Solicitar pide un recálculo del plan y espera a una ejecución que empiece
+// después de la petición. Devuelve el registro y el error de esa ejecución.
+// tablas son las tablas modificadas; sin tablas se recalcula todo. Las
+// peticiones agrupadas en una ejecución suman sus tablas.
+//
+// Si el contexto de db se cancela, Solicitar deja de esperar y devuelve su
+// error; la ejecución solo se cancela cuando se van todas las peticiones que
+// la esperaban (y queda registrada como cancelada).
+func (c *Coordinador) Solicitar(db *gorm.DB, planID uint, tablas ...string) (models.RecalculoEjecucion, error) {
+	c.mu.Lock()
+	cola, ok := c.planes[planID]
+	if !ok {
+		cola = &colaPlan{}
+		c.planes[planID] = cola
+	}
+	r := cola.pendiente
+	if r == nil {
+		r = &ronda{desde: time.Now(), hecho: make(chan struct{})}
+		cola.pendiente = r
+		if !cola.atendiendo {
+			cola.atendiendo = true
+			go c.atender(planID, cola)
+		}
+	}
+	if r.ctx == nil || r.ctx.Err() != nil {
+		// ronda nueva, o abandonada por todos los que la esperaban antes de empezar
+		r.ctx, r.cancelar = context.WithCancel(context.Background())
+		r.db = db.WithContext(r.ctx)
+	}
+	r.esperando++
+	r.solicitudes++
+	r.agregarTablas(tablas)
+	c.mu.Unlock()
+
+	ctx := contexto(db)
+	select {
+	case <-r.hecho:
+		return r.ejecucion, r.err
+	case <-ctx.Done():
+		c.mu.Lock()
+		if r.esperando--; r.esperando == 0 {
+			r.cancelar()
+		}
+		c.mu.Unlock()
+		return models.RecalculoEjecucion{PlanNegocioID: planID, Estado: models.RecalculoCancelado}, ctx.Err()
+	}
+}
+
+// agregarTablas suma las tablas de una petición a la ronda
+func (r *ronda) agregarTablas(tablas []string) {
+	if len(tablas) == 0 {
+		r.completo = true
+		r.tablas = nil
+		return
+	}
+	if r.completo {
+		return
+	}
+	for _, t := range tablas {
+		nueva := true
+		for _, u := range r.tablas {
+			if u == t {
+				nueva = false
+				break
+			}
+		}
+		if nueva {
+			r.tablas = append(r.tablas, t)
+		}
+	}
+}
+
+// alcance devuelve las tablas de la ronda, nil si recalcula todo
+func (r *ronda) alcance() []string {
+	if r.completo {
+		return nil
+	}
+	return r.tablas
+}
+
+// atender ejecuta las rondas del plan una tras otra hasta vaciar la cola. Hay
+// una sola por plan: Solicitar solo la lanza si cola.atendiendo es false.
+func (c *Coordinador) atender(planID uint, cola *colaPlan) {
+	for {
+		c.mu.Lock()
+		ventana := c.Ventana
+		c.mu.Unlock()
+		if ventana > 0 {
+			time.Sleep(ventana)
+		}
+
+		c.mu.Lock()
+		r := cola.pendiente
+		if r == nil {
+			cola.atendiendo = false
+			if c.planes[planID] == cola {
+				delete(c.planes, planID)
+			}
+			c.mu.Unlock()
+			return
+		}
+		cola.pendiente = nil
+		cola.actual = r
+		c.mu.Unlock()
+
+		r.tiempos, r.err = c.conBloqueo(r, planID)
+		c.registrar(r, planID)
+
+		c.mu.Lock()
+		cola.actual = nil
+		c.mu.Unlock()
+		r.cancelar()
+		close(r.hecho)
+	}
+}
+
+// conBloqueo ejecuta la ronda con el advisory lock del plan tomado en una
+// conexión dedicada (el bloqueo es de sesión y se libera si la conexión se cae)
+func (c *Coordinador) conBloqueo(r *ronda, planID uint) ([]TiempoTarea, error) {
+	if r.db.Dialector.Name() != "postgres" {
+		c.marcarCorriendo(r)
+		return c.ejecutar(r.db, planID, r.alcance())
+	}
+	var tiempos []TiempoTarea
+	err := r.db.Connection(func(conn *gorm.DB) error {
+		if err := conn.Exec("SELECT pg_advisory_lock(?, ?)", claseBloqueoRecalculo, int32(planID)).Error; err != nil {
+			return err
+		}
+		defer conn.WithContext(context.Background()).Exec("SELECT pg_advisory_unlock(?, ?)", claseBloqueoRecalculo, int32(planID))
+		c.marcarCorriendo(r)
+		var err error
+		tiempos, err = c.ejecutar(r.db, planID, r.alcance())
+		return err
+	})
+	return tiempos, err
+}
+
+// registrar arma el registro de la ronda terminada y lo guarda con Registrar.
+// Un fallo al guardarlo solo se informa en el log: no cambia el resultado.
+func (c *Coordinador) registrar(r *ronda, planID uint) {
+	c.mu.Lock()
+	e := models.RecalculoEjecucion{
+		PlanNegocioID: planID,
+		Estado:        models.RecalculoOK,
+		Etapa:         "completo",
+		Alcance:       "completo",
+		Solicitudes:   r.solicitudes,
+		IniciadoEn:    r.desde,
+		TerminadoEn:   time.Now(),
+	}
+	if tablas := r.alcance(); tablas != nil {
+		e.Alcance = strings.Join(tablas, ",")
+	}
+	c.mu.Unlock()
+	e.DuracionMs = e.TerminadoEn.Sub(e.IniciadoEn).Milliseconds()
+	for _, t := range r.tiempos {
+		tarea := models.RecalculoTarea{
+			Procedimiento: t.Nombre,
+			Nivel:         t.Nivel,
+			Intentos:      t.Intentos,
+			IniciadoEn:    t.Inicio,
+			DuracionMs:    t.Duracion.Milliseconds(),
+		}
+		var et *ErrorTarea
+		if errors.As(t.Err, &et) {
+			tarea.Error = et.Err.Error()
+		} else if t.Err != nil {
+			tarea.Error = t.Err.Error()
+		}
+		e.Tareas = append(e.Tareas, tarea)
+	}
+	if r.err != nil {
+		e.Estado = models.RecalculoError
+		e.Error = r.err.Error()
+		e.Etapa = "bloqueo"
+		if errors.Is(r.err, context.Canceled) || r.ctx.Err() != nil {
+			e.Estado = models.RecalculoCancelado
+		}
+		var etapa *ErrorEtapa
+		if errors.As(r.err, &etapa) {
+			e.Etapa = etapa.Etapa
+		}
+	}
+	if c.Registrar != nil {
+		// la ronda pudo haberse cancelado: el registro usa un contexto propio
+		if err := c.Registrar(r.db.WithContext(context.Background()), &e); err != nil {
+			log.Printf("recalculo: no se pudo registrar la ejecución del plan %d: %v", planID, err)
+		}
+	}
+	r.ejecucion = e
+}
+
+func (c *Coordinador) marcarCorriendo(r *ronda) {
+	c.mu.Lock()
+	r.corriendo = true
+	r.desde = time.Now()
+	c.mu.Unlock()
+}
+
+// Estado devuelve el estado del plan en este proceso; si aquí está inactivo
+// consulta si otra réplica tiene el bloqueo del plan
+func (c *Coordinador) Estado(db *gorm.DB, planID uint) (EstadoRecalculo, error) {
+	e := EstadoRecalculo{PlanNegocioID: planID, Estado: RecalculoInactivo}
+	c.mu.Lock()
+	if cola, ok := c.planes[planID]; ok {
+		if cola.pendiente != nil {
+			e.Pendientes = cola.pendiente.solicitudes
+		}
+		switch {
+		case cola.actual != nil && cola.actual.corriendo:
+			e.Estado = RecalculoEjecucion
+			desde := cola.actual.desde
+			e.Desde = &desde
+		case cola.actual != nil || cola.pendiente != nil:
+			// esperando la ventana o el bloqueo de otra réplica
+			e.Estado = RecalculoEnCola
+			r := cola.actual
+			if r == nil {
+				r = cola.pendiente
+			}
+			desde := r.desde
+			e.Desde = &desde
+		}
+	}
+	c.mu.Unlock()
+
+	if e.Estado != RecalculoInactivo || db.Dialector.Name() != "postgres" {
+		return e, nil
+	}
+	var enUso int64
+	if err := db.Raw("SELECT count(*) FROM pg_locks WHERE locktype = 'advisory' AND classid = ? AND objid = ? AND objsubid = 2 AND granted",
+		claseBloqueoRecalculo, int32(planID)).Scan(&enUso).Error; err != nil {
+		return e, err
+	}
+	if enUso > 0 {
+		e.Estado = RecalculoEjecucion
+		e.OtraReplica = true
+	}
+	return e, nil
+}
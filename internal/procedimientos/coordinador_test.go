@@ -0,0 +1,158 @@
+package procedimientos
+
+import (
+	"sort"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/schema"
+)
+
+// dialectoPrueba es un dialecto sin conexión: alcanza para los *gorm.DB que
+// solo llevan el contexto (Coordinador y Ejecutor con funciones falsas)
+type dialectoPrueba struct{}
+
+func (dialectoPrueba) Name() string                                   { return "prueba" }
+func (dialectoPrueba) Initialize(*gorm.DB) error                      { return nil }
+func (dialectoPrueba) Migrator(*gorm.DB) gorm.Migrator                { return nil }
+func (dialectoPrueba) DataTypeOf(*schema.Field) string                { return "" }
+func (dialectoPrueba) DefaultValueOf(*schema.Field) clause.Expression { return nil }
+func (dialectoPrueba) BindVarTo(w clause.Writer, _ *gorm.Statement, _ interface{}) {
+	w.WriteByte('?')
+}
+func (dialectoPrueba) QuoteTo(w clause.Writer, s string)           { w.WriteString(s) }
+func (dialectoPrueba) Explain(sql string, _ ...interface{}) string { return sql }
+
+func dbDePrueba(t *testing.T) *gorm.DB {
+	t.Helper()
+	db, err := gorm.Open(dialectoPrueba{}, &gorm.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+// esperar espera hasta que cond se cumpla o falla después de un segundo
+func esperar(t *testing.T, cond func() bool) {
+	t.Helper()
+	limite := time.Now().Add(time.Second)
+	for !cond() {
+		if time.Now().After(limite) {
+			t.Fatal("tiempo agotado")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestCoordinadorAgrupaPeticiones(t *testing.T) {
+	db := dbDePrueba(t)
+	liberar := make(chan struct{})
+	var ejecuciones int32
+	var alcances [][]string
+	var mu sync.Mutex
+	c := NuevoCoordinador(func(db *gorm.DB, planID uint, tablas []string) ([]TiempoTarea, error) {
+		if atomic.AddInt32(&ejecuciones, 1) == 1 {
+			<-liberar
+		}
+		mu.Lock()
+		alcances = append(alcances, tablas)
+		mu.Unlock()
+		return nil, nil
+	})
+
+	var wg sync.WaitGroup
+	solicitar := func(tablas ...string) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := c.Solicitar(db, 1, tablas...); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	solicitar("supuesto")
+	esperar(t, func() bool { return atomic.LoadInt32(&ejecuciones) == 1 })
+	// llegan mientras corre la primera: se agrupan en una sola ronda
+	solicitar("gastos_operacion")
+	solicitar("indicadores_macro")
+	solicitar("gastos_operacion")
+	esperar(t, func() bool {
+		e, _ := c.Estado(db, 1)
+		return e.Pendientes == 3
+	})
+	close(liberar)
+	wg.Wait()
+
+	if n := atomic.LoadInt32(&ejecuciones); n != 2 {
+		t.Fatalf("ejecuciones = %d, want 2", n)
+	}
+	got := append([]string(nil), alcances[1]...)
+	sort.Strings(got)
+	if len(got) != 2 || got[0] != "gastos_operacion" || got[1] != "indicadores_macro" {
+		t.Errorf("alcance de la ronda agrupada = %v", got)
+	}
+	if e, _ := c.Estado(db, 1); e.Estado != RecalculoInactivo {
+		t.Errorf("estado final = %s", e.Estado)
+	}
+}
+
+// Una petición que llega durante la ventana después de una ronda no debe
+// lanzar un segundo atender: las rondas del plan nunca se solapan y Estado
+// sigue viendo la que corre.
+func TestCoordinadorUnSoloAtenderPorPlan(t *testing.T) {
+	db := dbDePrueba(t)
+	var corriendo, maximo, ejecuciones int32
+	bloquear := make(chan struct{})
+	liberar := make(chan struct{})
+	c := NuevoCoordinador(func(db *gorm.DB, planID uint, tablas []string) ([]TiempoTarea, error) {
+		n := atomic.AddInt32(&corriendo, 1)
+		defer atomic.AddInt32(&corriendo, -1)
+		for {
+			m := atomic.LoadInt32(&maximo)
+			if n <= m || atomic.CompareAndSwapInt32(&maximo, m, n) {
+				break
+			}
+		}
+		if atomic.AddInt32(&ejecuciones, 1) == 2 {
+			close(bloquear)
+			<-liberar
+		}
+		return nil, nil
+	})
+	c.Ventana = 20 * time.Millisecond
+
+	if _, err := c.Solicitar(db, 1); err != nil {
+		t.Fatal(err)
+	}
+	// atender sigue vivo y duerme la ventana antes de buscar otra ronda
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		c.Solicitar(db, 1)
+	}()
+	<-bloquear
+	time.Sleep(3 * c.Ventana)
+	if e, _ := c.Estado(db, 1); e.Estado != RecalculoEjecucion {
+		t.Errorf("estado durante la ronda = %s, want %s", e.Estado, RecalculoEjecucion)
+	}
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		c.Solicitar(db, 1)
+	}()
+	time.Sleep(3 * c.Ventana)
+	close(liberar)
+	wg.Wait()
+
+	if m := atomic.LoadInt32(&maximo); m != 1 {
+		t.Errorf("rondas simultáneas = %d, want 1", m)
+	}
+	if n := atomic.LoadInt32(&ejecuciones); n != 3 {
+		t.Errorf("ejecuciones = %d, want 3", n)
+	}
+}
@@ -0,0 +1,212 @@
+package procedimientos
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Tarea es una unidad de trabajo del Ejecutor
+type Tarea struct {
+	Nombre string
+	// Nivel es el nivel del grafo al que pertenece (solo informativo)
+	Nivel    int
+	Ejecutar func(ctx context.Context) error
+}
+
+// TiempoTarea es el resultado de una tarea ejecutada
+type TiempoTarea struct {
+	Nombre   string
+	Nivel    int
+	Inicio   time.Time
+	Duracion time.Duration
+	Intentos int
+	Err      error
+}
+
+// ErrorTarea es el error de una tarea del Ejecutor, después de sus reintentos
+type ErrorTarea struct {
+	Tarea    string
+	Intentos int
+	Err      error
+}
+
+func (e *ErrorTarea) Error() string {
+	if e.Intentos > 1 {
+		return fmt.Sprintf("%s (%d intentos): %v", e.Tarea, e.Intentos, e.Err)
+	}
+	return fmt.Sprintf("%s: %v", e.Tarea, e.Err)
+}
+
+func (e *ErrorTarea) Unwrap() error { return e.Err }
+
+// Ejecutor corre grupos de tareas independientes con concurrencia acotada.
+// Una tarea que falla con un error reintentable (ver ErrorReintentable) se
+// reintenta sola, con espera exponencial; las demás no se repiten. Después del
+// primer fallo no se empiezan tareas nuevas del grupo, y tampoco si ctx se
+// cancela; las que ya corren terminan. El tiempo de cada tarea ejecutada queda
+// en Tiempos.
+type Ejecutor struct {
+	// Concurrencia es el máximo de tareas a la vez (<= 0: todas)
+	Concurrencia int
+	// Reintentos es la cantidad de reintentos por tarea ante errores reintentables
+	Reintentos int
+	// Espera es la espera antes del primer reintento; se duplica en cada uno
+	Espera time.Duration
+	// Reintentable decide si un error se reintenta (nil: ErrorReintentable)
+	Reintentable func(error) bool
+
+	mu      sync.Mutex
+	tiempos []TiempoTarea
+}
+
+// ConfigEjecutor son los valores de los ejecutores de Recalcular
+type ConfigEjecutor struct {
+	Concurrencia int
+	Reintentos   int
+	Espera       time.Duration
+}
+
+var (
+	configEjecutorMu sync.RWMutex
+	configEjecutor   = ConfigEjecutor{Concurrencia: 4, Reintentos: 3, Espera: 50 * time.Millisecond}
+)
+
+// ConfiguracionEjecutor devuelve la configuración actual de los ejecutores de Recalcular
+func ConfiguracionEjecutor() ConfigEjecutor {
+	configEjecutorMu.RLock()
+	defer configEjecutorMu.RUnlock()
+	return configEjecutor
+}
+
+// ConfigurarEjecutor fija la concurrencia y los reintentos de Recalcular
+func ConfigurarEjecutor(c ConfigEjecutor) error {
+	if c.Reintentos < 0 || c.Espera < 0 {
+		return errors.New("ejecutor: reintentos y espera no pueden ser negativos")
+	}
+	configEjecutorMu.Lock()
+	defer configEjecutorMu.Unlock()
+	configEjecutor = c
+	return nil
+}
+
+// nuevoEjecutor crea un ejecutor con la configuración de Recalcular
+func nuevoEjecutor() *Ejecutor {
+	configEjecutorMu.RLock()
+	defer configEjecutorMu.RUnlock()
+	return &Ejecutor{
+		Concurrencia: configEjecutor.Concurrencia,
+		Reintentos:   configEjecutor.Reintentos,
+		Espera:       configEjecutor.Espera,
+	}
+}
+
+// ErrorReintentable indica si err es un fallo de serialización (40001) o un
+// deadlock (40P01) de Postgres: el mismo trabajo puede salir bien al repetirlo
+func ErrorReintentable(err error) bool {
+	var pg interface{ SQLState() string }
+	if !errors.As(err, &pg) {
+		return false
+	}
+	switch pg.SQLState() {
+	case "40001", "40P01":
+		return true
+	}
+	return false
+}
+
+// Ejecutar corre las tareas y devuelve errors.Join de los *ErrorTarea en el
+// orden de las tareas (nil si todas terminaron bien). Si ctx se cancela antes
+// de empezar alguna, el error incluye además ctx.Err().
+func (e *Ejecutor) Ejecutar(ctx context.Context, tareas []Tarea) error {
+	limite := e.Concurrencia
+	if limite <= 0 || limite > len(tareas) {
+		limite = len(tareas)
+	}
+	sem := make(chan struct{}, limite)
+	errs := make([]error, len(tareas))
+	var (
+		wg         sync.WaitGroup
+		fallo      bool
+		falloMu    sync.Mutex
+		sinEmpezar int
+	)
+	for i, t := range tareas {
+		sem <- struct{}{}
+		falloMu.Lock()
+		detener := fallo
+		falloMu.Unlock()
+		if detener || ctx.Err() != nil {
+			<-sem
+			sinEmpezar = len(tareas) - i
+			break
+		}
+		wg.Add(1)
+		go func(i int, t Tarea) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			if err := e.correr(ctx, t); err != nil {
+				errs[i] = err
+				falloMu.Lock()
+				fallo = true
+				falloMu.Unlock()
+			}
+		}(i, t)
+	}
+	wg.Wait()
+
+	if err := ctx.Err(); err != nil && sinEmpezar > 0 {
+		errs = append(errs, fmt.Errorf("%d tareas sin empezar: %w", sinEmpezar, err))
+	}
+	return errors.Join(errs...)
+}
+
+// correr ejecuta una tarea con sus reintentos y registra su tiempo
+func (e *Ejecutor) correr(ctx context.Context, t Tarea) error {
+	reintentable := e.Reintentable
+	if reintentable == nil {
+		reintentable = ErrorReintentable
+	}
+	inicio := time.Now()
+	espera := e.Espera
+	intentos := 0
+	var err error
+	for {
+		intentos++
+		err = t.Ejecutar(ctx)
+		if err == nil || intentos > e.Reintentos || !reintentable(err) || ctx.Err() != nil {
+			break
+		}
+		select {
+		case <-ctx.Done():
+		case <-time.After(espera):
+		}
+		espera *= 2
+	}
+	if err != nil {
+		err = &ErrorTarea{Tarea: t.Nombre, Intentos: intentos, Err: err}
+	}
+	e.mu.Lock()
+	e.tiempos = append(e.tiempos, TiempoTarea{
+		Nombre:   t.Nombre,
+		Nivel:    t.Nivel,
+		Inicio:   inicio,
+		Duracion: time.Since(inicio),
+		Intentos: intentos,
+		Err:      err,
+	})
+	e.mu.Unlock()
+	return err
+}
+
+// Tiempos devuelve las tareas ejecutadas hasta ahora ordenadas por inicio
+func (e *Ejecutor) Tiempos() []TiempoTarea {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	out := append([]TiempoTarea(nil), e.tiempos...)
+	sort.SliceStable(out, func(i, j int) bool { return out[i].Inicio.Before(out[j].Inicio) })
+	return out
+}
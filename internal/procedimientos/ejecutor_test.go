@@ -0,0 +1,177 @@
+package procedimientos
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// errorPG imita el error de Postgres con su SQLSTATE
+type errorPG string
+
+func (e errorPG) Error() string    { return "pg " + string(e) }
+func (e errorPG) SQLState() string { return string(e) }
+
+func TestErrorReintentable(t *testing.T) {
+	tests := []struct {
+		err  error
+		want bool
+	}{
+		{errorPG("40001"), true},
+		{errorPG("40P01"), true},
+		{fmt.Errorf("ventas: %w", errorPG("40001")), true},
+		{errorPG("23505"), false},
+		{errors.New("40001"), false},
+		{nil, false},
+	}
+	for _, tt := range tests {
+		if got := ErrorReintentable(tt.err); got != tt.want {
+			t.Errorf("ErrorReintentable(%v) = %v, want %v", tt.err, got, tt.want)
+		}
+	}
+}
+
+func TestEjecutorReintentos(t *testing.T) {
+	otro := errors.New("otro")
+	tests := []struct {
+		nombre       string
+		reintentos   int
+		reintentable func(error) bool
+		fallos       int // cuántas veces falla antes de salir bien
+		err          error
+		intentos     int
+		falla        bool
+	}{
+		{"sale bien al reintentar", 3, nil, 2, errorPG("40P01"), 3, false},
+		{"agota los reintentos", 2, nil, 10, errorPG("40001"), 3, true},
+		{"no reintentable", 3, nil, 10, errorPG("23505"), 1, true},
+		{"sin reintentos", 0, nil, 10, errorPG("40001"), 1, true},
+		{"criterio propio", 1, func(err error) bool { return err == otro }, 1, otro, 2, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.nombre, func(t *testing.T) {
+			e := &Ejecutor{Reintentos: tt.reintentos, Espera: time.Millisecond, Reintentable: tt.reintentable}
+			var llamadas int32
+			err := e.Ejecutar(context.Background(), []Tarea{{Nombre: "t", Ejecutar: func(context.Context) error {
+				if int(atomic.AddInt32(&llamadas, 1)) <= tt.fallos {
+					return tt.err
+				}
+				return nil
+			}}})
+			if (err != nil) != tt.falla {
+				t.Fatalf("err = %v", err)
+			}
+			if tt.falla {
+				var et *ErrorTarea
+				if !errors.As(err, &et) || et.Tarea != "t" || et.Intentos != tt.intentos || !errors.Is(err, tt.err) {
+					t.Errorf("err = %#v", err)
+				}
+			}
+			tiempos := e.Tiempos()
+			if len(tiempos) != 1 || tiempos[0].Intentos != tt.intentos || int(llamadas) != tt.intentos {
+				t.Errorf("tiempos = %+v, llamadas = %d, want %d intentos", tiempos, llamadas, tt.intentos)
+			}
+		})
+	}
+}
+
+// Después del primer fallo no se empiezan tareas nuevas del grupo
+func TestEjecutorFalloDetiene(t *testing.T) {
+	e := &Ejecutor{Concurrencia: 1}
+	var corridas int32
+	tareas := []Tarea{
+		{Nombre: "a", Ejecutar: func(context.Context) error { atomic.AddInt32(&corridas, 1); return errors.New("falla") }},
+		{Nombre: "b", Ejecutar: func(context.Context) error { atomic.AddInt32(&corridas, 1); return nil }},
+		{Nombre: "c", Ejecutar: func(context.Context) error { atomic.AddInt32(&corridas, 1); return nil }},
+	}
+	err := e.Ejecutar(context.Background(), tareas)
+	var et *ErrorTarea
+	if !errors.As(err, &et) || et.Tarea != "a" {
+		t.Fatalf("err = %v", err)
+	}
+	if n := atomic.LoadInt32(&corridas); n != 1 {
+		t.Errorf("tareas corridas = %d, want 1", n)
+	}
+}
+
+func TestEjecutorCancelacion(t *testing.T) {
+	t.Run("no empieza tareas nuevas", func(t *testing.T) {
+		ctx, cancelar := context.WithCancel(context.Background())
+		defer cancelar()
+		e := &Ejecutor{Concurrencia: 1}
+		var corridas int32
+		tarea := func(context.Context) error {
+			atomic.AddInt32(&corridas, 1)
+			cancelar()
+			return nil
+		}
+		err := e.Ejecutar(ctx, []Tarea{{Nombre: "a", Ejecutar: tarea}, {Nombre: "b", Ejecutar: tarea}, {Nombre: "c", Ejecutar: tarea}})
+		if !errors.Is(err, context.Canceled) || !strings.Contains(err.Error(), "2 tareas sin empezar") {
+			t.Errorf("err = %v", err)
+		}
+		if n := atomic.LoadInt32(&corridas); n != 1 {
+			t.Errorf("tareas corridas = %d, want 1", n)
+		}
+	})
+	t.Run("corta la espera del reintento", func(t *testing.T) {
+		ctx, cancelar := context.WithCancel(context.Background())
+		defer cancelar()
+		e := &Ejecutor{Reintentos: 3, Espera: time.Hour}
+		go func() {
+			time.Sleep(10 * time.Millisecond)
+			cancelar()
+		}()
+		inicio := time.Now()
+		err := e.Ejecutar(ctx, []Tarea{{Nombre: "a", Ejecutar: func(context.Context) error { return errorPG("40001") }}})
+		if time.Since(inicio) > time.Second {
+			t.Fatal("la cancelación no cortó la espera")
+		}
+		var et *ErrorTarea
+		if !errors.As(err, &et) || et.Intentos != 2 {
+			t.Errorf("err = %v, want 2 intentos", err)
+		}
+	})
+}
+
+func TestEjecutorConcurrencia(t *testing.T) {
+	e := &Ejecutor{Concurrencia: 2}
+	var corriendo, maximo, corridas int32
+	var tareas []Tarea
+	for i := 0; i < 6; i++ {
+		tareas = append(tareas, Tarea{Nombre: fmt.Sprint(i), Nivel: 1, Ejecutar: func(context.Context) error {
+			n := atomic.AddInt32(&corriendo, 1)
+			defer atomic.AddInt32(&corriendo, -1)
+			for {
+				m := atomic.LoadInt32(&maximo)
+				if n <= m || atomic.CompareAndSwapInt32(&maximo, m, n) {
+					break
+				}
+			}
+			atomic.AddInt32(&corridas, 1)
+			time.Sleep(5 * time.Millisecond)
+			return nil
+		}})
+	}
+	if err := e.Ejecutar(context.Background(), tareas); err != nil {
+		t.Fatal(err)
+	}
+	if m := atomic.LoadInt32(&maximo); m > 2 {
+		t.Errorf("tareas simultáneas = %d, want <= 2", m)
+	}
+	if n := atomic.LoadInt32(&corridas); n != 6 {
+		t.Errorf("tareas corridas = %d, want 6", n)
+	}
+	tiempos := e.Tiempos()
+	if len(tiempos) != 6 {
+		t.Fatalf("tiempos = %d, want 6", len(tiempos))
+	}
+	for i := 1; i < len(tiempos); i++ {
+		if tiempos[i].Inicio.Before(tiempos[i-1].Inicio) {
+			t.Error("Tiempos no está ordenado por inicio")
+		}
+	}
+}
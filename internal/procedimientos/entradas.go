@@ -0,0 +1,129 @@
+package procedimientos
+
+import (
+	"fmt"
+
+	"github.com/JostinAlvaradoS/liveplan_backend_go/internal/model"
+	"github.com/JostinAlvaradoS/liveplan_backend_go/internal/models"
+	"gorm.io/gorm"
+)
+
+// CargarEntradas lee de la base de datos la foto completa de un plan que
+// necesita model.Compute. Todas las listas se ordenan por id para que las
+// búsquedas en memoria devuelvan la misma fila que First en los procedimientos.
+func CargarEntradas(db *gorm.DB, planID uint) (model.Inputs, error) {
+	in := model.Inputs{PlanID: planID}
+
+	horizonte, err := HorizontePlan(db, planID)
+	if err != nil {
+		return in, err
+	}
+	in.Horizonte = horizonte
+
+	// Registros únicos por plan: los opcionales quedan en cero si no existen
+	if err := cargarOpcional(db, planID, "variables_de_sensibilidad", &in.Variables); err != nil {
+		return in, err
+	}
+	if err := cargarOpcional(db, planID, "indicadores_macro", &in.Indicadores); err != nil {
+		return in, err
+	}
+	requeridos := []struct {
+		tabla string
+		dest  interface{}
+	}{
+		{"composicion_financiamiento", &in.Composicion},
+		{"datos_prestamo", &in.Prestamo},
+		{"supuesto", &in.Supuesto},
+		{"evaluacion_proyecto", &in.Evaluacion},
+	}
+	for _, r := range requeridos {
+		if err := cargarUno(db, planID, r.tabla, r.dest); err != nil {
+			return in, err
+		}
+	}
+
+	if err := cargarListas(db, planID,
+		destino{"detalle_inversion_inicial", &in.Detalles},
+		destino{"venta_diaria", &in.VentasDiarias},
+		destino{"precios_prod_serv", &in.Precios},
+		destino{"costos_prod_serv", &in.Costos},
+		destino{"presupuesto_venta", &in.Presupuestos},
+		destino{"gastos_operacion", &in.GastosOperacion},
+		destino{"politicas_venta", &in.PoliticasVenta},
+		destino{"politicas_compra", &in.PoliticasCompra},
+		destino{"ventas_dinero", &in.VentasDinero},
+		destino{"ventas", &in.Ventas},
+		destino{"costos_ventas", &in.CostosVentas},
+		destino{"costo_materias_primas", &in.CostoMateriasPrimas},
+		destino{"prestamo_cuotas", &in.PrestamoCuotas},
+		destino{"estado_resultados", &in.EstadoResultados},
+		destino{"flujo_efectivo", &in.FlujoEfectivo},
+		destino{"balance_general", &in.BalanceGeneral},
+		destino{"conceptos_evaluacion", &in.ConceptosEvaluacion},
+	); err != nil {
+		return in, err
+	}
+	if err := cargarDepreciaciones(db, planID, &in.Depreciaciones); err != nil {
+		return in, err
+	}
+	return in, nil
+}
+
+// CalcularEnMemoria carga el plan y ejecuta el recálculo completo en memoria
+// aplicando los choques indicados. No escribe nada en la base de datos.
+func CalcularEnMemoria(db *gorm.DB, planID uint, s model.Shocks) (model.Outputs, error) {
+	in, err := CargarEntradas(db, planID)
+	if err != nil {
+		return model.Outputs{}, err
+	}
+	return model.Compute(in, s)
+}
+
+// cargarUno lee el registro del plan en dest; falla si no existe
+func cargarUno(db *gorm.DB, planID uint, tabla string, dest interface{}) error {
+	if err := db.Where("plan_negocio_id = ?", planID).Order("id asc").First(dest).Error; err != nil {
+		return fmt.Errorf("loading %s for plan %d: %w", tabla, planID, err)
+	}
+	return nil
+}
+
+// cargarOpcional lee el registro del plan en dest; si no existe dest queda en cero
+func cargarOpcional(db *gorm.DB, planID uint, tabla string, dest interface{}) error {
+	if err := db.Where("plan_negocio_id = ?", planID).Order("id asc").First(dest).Error; err != nil && err != gorm.ErrRecordNotFound {
+		return fmt.Errorf("loading %s for plan %d: %w", tabla, planID, err)
+	}
+	return nil
+}
+
+// destino asocia una tabla con el slice donde se cargan sus filas
+type destino struct {
+	tabla string
+	dest  interface{}
+}
+
+// cargarListas carga varias tablas del plan con cargarLista
+func cargarListas(db *gorm.DB, planID uint, destinos ...destino) error {
+	for _, d := range destinos {
+		if err := cargarLista(db, planID, d.tabla, d.dest); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// cargarLista lee todas las filas del plan en dest ordenadas por id
+func cargarLista(db *gorm.DB, planID uint, tabla string, dest interface{}) error {
+	if err := db.Where("plan_negocio_id = ?", planID).Order("id asc").Find(dest).Error; err != nil {
+		return fmt.Errorf("loading %s for plan %d: %w", tabla, planID, err)
+	}
+	return nil
+}
+
+// cargarDepreciaciones lee las depreciaciones del plan con sus valores anuales
+func cargarDepreciaciones(db *gorm.DB, planID uint, dest *[]models.Depreciacion) error {
+	if err := db.Preload("Anios", func(db *gorm.DB) *gorm.DB { return db.Order("anio asc") }).
+		Where("plan_negocio_id = ?", planID).Order("id asc").Find(dest).Error; err != nil {
+		return fmt.Errorf("loading depreciaciones for plan %d: %w", planID, err)
+	}
+	return nil
+}
@@ -0,0 +1,52 @@
+package procedimientos
+
+import (
+	"fmt"
+
+	"github.com/JostinAlvaradoS/liveplan_backend_go/internal/model"
+	"github.com/JostinAlvaradoS/liveplan_backend_go/internal/models"
+	"gorm.io/gorm"
+)
+
+// ResultadoEscenario es el recálculo en memoria de un escenario del plan
+type ResultadoEscenario struct {
+	// Escenario es nil en el resultado base (las entradas del plan sin cambios)
+	Escenario *models.Escenario
+	Salidas   model.Outputs
+}
+
+// CalcularEscenarios carga una sola vez la foto del plan y ejecuta el
+// recálculo en memoria de la base y de cada escenario del plan (ordenados por
+// id) con sus reemplazos. No escribe nada en la base de datos; se detiene
+// entre escenarios si el contexto de db se cancela.
+func CalcularEscenarios(db *gorm.DB, planID uint) ([]ResultadoEscenario, error) {
+	var escenarios []models.Escenario
+	if err := db.Preload("Anios").Where("plan_negocio_id = ?", planID).Order("id asc").Find(&escenarios).Error; err != nil {
+		return nil, fmt.Errorf("error al obtener Escenario: %w", err)
+	}
+
+	entradas, err := CargarEntradas(db, planID)
+	if err != nil {
+		return nil, fmt.Errorf("error al cargar el plan %d: %w", planID, err)
+	}
+	base, err := model.Compute(entradas, model.Shocks{})
+	if err != nil {
+		return nil, fmt.Errorf("error en recálculo base: %w", err)
+	}
+	out := []ResultadoEscenario{{Salidas: base}}
+
+	ctx := contexto(db)
+	for i := range escenarios {
+		e := &escenarios[i]
+		if err := ctx.Err(); err != nil {
+			return nil, fmt.Errorf("escenarios cancelados en %q: %w", e.Nombre, err)
+		}
+		in, s := model.ApplyScenario(entradas, *e)
+		salidas, err := model.Compute(in, s)
+		if err != nil {
+			return nil, fmt.Errorf("error en recálculo del escenario %q: %w", e.Nombre, err)
+		}
+		out = append(out, ResultadoEscenario{Escenario: e, Salidas: salidas})
+	}
+	return out, nil
+}
@@ -0,0 +1,475 @@
+package procedimientos
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/JostinAlvaradoS/liveplan_backend_go/internal/models"
+	"gorm.io/gorm"
+)
+
+// VersionDocumento es la versión del esquema que produce ExportarPlan. Al cambiar
+// la forma del documento se incrementa y se agrega en actualizacionesDocumento la
+// función que convierte la versión anterior.
+const VersionDocumento = 1
+
+// actualizacionesDocumento convierte un documento de la versión n (clave) a la
+// n+1. ImportarPlan las aplica en cadena hasta llegar a VersionDocumento.
+var actualizacionesDocumento = map[int]func(doc map[string]json.RawMessage) error{}
+
+// DocumentoPlan es la exportación completa de un plan. Las filas conservan su id
+// original solo como referencia interna del documento (producto_servicio_id,
+// producto_id, inversion_id, variacion_anual_id apuntan a esos ids); los
+// catálogos se referencian por nombre (tipo.tipo, categoria_costo.nombre).
+type DocumentoPlan struct {
+	SchemaVersion int             `json:"schema_version"`
+	ExportadoEn   time.Time       `json:"exportado_en"`
+	Plan          PlanExportado   `json:"plan"`
+	Entradas      EntradasPlan    `json:"entradas"`
+	Calculados    *CalculadosPlan `json:"calculados,omitempty"`
+}
+
+// PlanExportado son los datos propios del plan; el autor no se exporta
+type PlanExportado struct {
+	Problematica   string `json:"problematica"`
+	Descripcion    string `json:"descripcion"`
+	HorizonteAnios int    `json:"horizonte_anios"`
+}
+
+// EntradasPlan son los datos que captura el usuario
+type EntradasPlan struct {
+	Productos                 []models.ProductoServicio         `json:"productos"`
+	Supuesto                  *models.Supuesto                  `json:"supuesto"`
+	VariablesSensibilidad     *models.VariablesDeSensibilidad   `json:"variables_sensibilidad,omitempty"`
+	IndicadoresMacro          *models.IndicadoresMacro          `json:"indicadores_macro,omitempty"`
+	ComposicionFinanciamiento *models.ComposicionFinanciamiento `json:"composicion_financiamiento"`
+	DatosPrestamo             *models.DatosPrestamo             `json:"datos_prestamo"`
+	EvaluacionProyecto        *models.EvaluacionProyecto        `json:"evaluacion_proyecto"`
+	Inversiones               []models.InversionInicial         `json:"inversiones"`
+	DetallesInversion         []models.DetalleInversionInicial  `json:"detalles_inversion"`
+	VentasDiarias             []models.VentaDiaria              `json:"ventas_diarias"`
+	Precios                   []models.PreciosProdServ          `json:"precios"`
+	Costos                    []models.CostosProdServ           `json:"costos"`
+	PresupuestosVenta         []models.PresupuestoVenta         `json:"presupuestos_venta"`
+	VariacionAnual            []models.VariacionAnual           `json:"variacion_anual"`
+	GastosOperacion           []models.GastosOperacion          `json:"gastos_operacion"`
+	PoliticasVenta            []models.PoliticasVenta           `json:"politicas_venta"`
+	PoliticasCompra           []models.PoliticasCompra          `json:"politicas_compra"`
+	AnalisisSensibilidad      []models.AnalisisSensibilidad     `json:"analisis_sensibilidad"`
+}
+
+// CalculadosPlan son las tablas que produce Recalcular. Se exportan solo a
+// pedido y se ignoran al importar: el plan importado se recalcula.
+type CalculadosPlan struct {
+	Depreciaciones      []models.Depreciacion        `json:"depreciaciones"`
+	VentasDinero        []models.VentasDinero        `json:"ventas_dinero"`
+	Ventas              []models.Ventas              `json:"ventas"`
+	CostosVentas        []models.CostosVentas        `json:"costos_ventas"`
+	CostoMateriasPrimas []models.CostoMateriasPrimas `json:"costo_materias_primas"`
+	PrestamoCuotas      []models.PrestamoCuotas      `json:"prestamo_cuotas"`
+	EstadoResultados    []models.EstadoResultados    `json:"estado_resultados"`
+	FlujoEfectivo       []models.FlujoEfectivo       `json:"flujo_efectivo"`
+	BalanceGeneral      []models.BalanceGeneral      `json:"balance_general"`
+	ConceptosEvaluacion []models.ConceptosEvaluacion `json:"conceptos_evaluacion"`
+}
+
+// ErrDocumentoInvalido envuelve los problemas encontrados al validar un documento
+var ErrDocumentoInvalido = errors.New("documento inválido")
+
+// ExportarPlan arma el documento de un plan. Con calculados incluye también los
+// estados financieros y demás tablas derivadas.
+func ExportarPlan(db *gorm.DB, planID uint, calculados bool) (DocumentoPlan, error) {
+	doc := DocumentoPlan{SchemaVersion: VersionDocumento, ExportadoEn: time.Now().UTC()}
+	var plan models.PlanNegocio
+	if err := db.First(&plan, planID).Error; err != nil {
+		return doc, err
+	}
+	doc.Plan = PlanExportado{Problematica: plan.Problematica, Descripcion: plan.Descripcion, HorizonteAnios: plan.Horizonte()}
+
+	e := &doc.Entradas
+	var err error
+	if e.Supuesto, err = cargarUnicoExportado[models.Supuesto](db, planID, "supuesto"); err != nil {
+		return doc, err
+	}
+	if e.VariablesSensibilidad, err = cargarUnicoExportado[models.VariablesDeSensibilidad](db, planID, "variables_de_sensibilidad"); err != nil {
+		return doc, err
+	}
+	if e.IndicadoresMacro, err = cargarUnicoExportado[models.IndicadoresMacro](db, planID, "indicadores_macro"); err != nil {
+		return doc, err
+	}
+	if e.ComposicionFinanciamiento, err = cargarUnicoExportado[models.ComposicionFinanciamiento](db, planID, "composicion_financiamiento"); err != nil {
+		return doc, err
+	}
+	if e.DatosPrestamo, err = cargarUnicoExportado[models.DatosPrestamo](db, planID, "datos_prestamo"); err != nil {
+		return doc, err
+	}
+	if e.EvaluacionProyecto, err = cargarUnicoExportado[models.EvaluacionProyecto](db, planID, "evaluacion_proyecto"); err != nil {
+		return doc, err
+	}
+	if err := cargarListas(db, planID,
+		destino{"producto_servicio", &e.Productos},
+		destino{"venta_diaria", &e.VentasDiarias},
+		destino{"precios_prod_serv", &e.Precios},
+		destino{"presupuesto_venta", &e.PresupuestosVenta},
+		destino{"gastos_operacion", &e.GastosOperacion},
+		destino{"politicas_venta", &e.PoliticasVenta},
+		destino{"politicas_compra", &e.PoliticasCompra},
+		destino{"analisis_sensibilidad", &e.AnalisisSensibilidad},
+	); err != nil {
+		return doc, err
+	}
+	if err := cargarLista(db.Preload("Tipo"), planID, "inversion_inicial", &e.Inversiones); err != nil {
+		return doc, err
+	}
+	if err := cargarLista(db.Preload("Tipo"), planID, "detalle_inversion_inicial", &e.DetallesInversion); err != nil {
+		return doc, err
+	}
+	if err := cargarLista(db.Preload("CategoriaCosto"), planID, "costos_prod_serv", &e.Costos); err != nil {
+		return doc, err
+	}
+	if err := cargarLista(db.Preload("Anios", func(db *gorm.DB) *gorm.DB { return db.Order("anio asc") }), planID, "variacion_anual", &e.VariacionAnual); err != nil {
+		return doc, err
+	}
+
+	if calculados {
+		c := &CalculadosPlan{}
+		if err := cargarListas(db, planID,
+			destino{"ventas_dinero", &c.VentasDinero},
+			destino{"ventas", &c.Ventas},
+			destino{"costos_ventas", &c.CostosVentas},
+			destino{"costo_materias_primas", &c.CostoMateriasPrimas},
+			destino{"prestamo_cuotas", &c.PrestamoCuotas},
+			destino{"estado_resultados", &c.EstadoResultados},
+			destino{"flujo_efectivo", &c.FlujoEfectivo},
+			destino{"balance_general", &c.BalanceGeneral},
+			destino{"conceptos_evaluacion", &c.ConceptosEvaluacion},
+		); err != nil {
+			return doc, err
+		}
+		if err := cargarDepreciaciones(db, planID, &c.Depreciaciones); err != nil {
+			return doc, err
+		}
+		doc.Calculados = c
+	}
+	return doc, nil
+}
+
+// cargarUnicoExportado devuelve el registro único del plan o nil si no existe
+func cargarUnicoExportado[T any](db *gorm.DB, planID uint, tabla string) (*T, error) {
+	var filas []T
+	if err := db.Where("plan_negocio_id = ?", planID).Order("id asc").Limit(1).Find(&filas).Error; err != nil {
+		return nil, fmt.Errorf("loading %s for plan %d: %w", tabla, planID, err)
+	}
+	if len(filas) == 0 {
+		return nil, nil
+	}
+	return &filas[0], nil
+}
+
+// LeerDocumento decodifica un documento exportado, lo actualiza a
+// VersionDocumento si viene de una versión anterior y lo devuelve.
+func LeerDocumento(raw []byte) (DocumentoPlan, error) {
+	var doc DocumentoPlan
+	var crudo map[string]json.RawMessage
+	if err := json.Unmarshal(raw, &crudo); err != nil {
+		return doc, fmt.Errorf("%w: %v", ErrDocumentoInvalido, err)
+	}
+	var version int
+	if v, ok := crudo["schema_version"]; !ok || json.Unmarshal(v, &version) != nil {
+		return doc, fmt.Errorf("%w: schema_version es requerido", ErrDocumentoInvalido)
+	}
+	if version < 1 || version > VersionDocumento {
+		return doc, fmt.Errorf("%w: schema_version %d no soportada (máximo %d)", ErrDocumentoInvalido, version, VersionDocumento)
+	}
+	for ; version < VersionDocumento; version++ {
+		actualizar, ok := actualizacionesDocumento[version]
+		if !ok {
+			return doc, fmt.Errorf("%w: no hay conversión desde schema_version %d", ErrDocumentoInvalido, version)
+		}
+		if err := actualizar(crudo); err != nil {
+			return doc, fmt.Errorf("%w: actualizando desde schema_version %d: %v", ErrDocumentoInvalido, version, err)
+		}
+	}
+	crudo["schema_version"], _ = json.Marshal(VersionDocumento)
+	actualizado, err := json.Marshal(crudo)
+	if err != nil {
+		return doc, err
+	}
+	if err := json.Unmarshal(actualizado, &doc); err != nil {
+		return doc, fmt.Errorf("%w: %v", ErrDocumentoInvalido, err)
+	}
+	return doc, nil
+}
+
+// catalogos resuelve por nombre los catálogos que referencia un documento
+type catalogos struct {
+	tipos      map[string]uint
+	categorias map[string]uint
+}
+
+func cargarCatalogos(db *gorm.DB) (catalogos, error) {
+	c := catalogos{tipos: map[string]uint{}, categorias: map[string]uint{}}
+	var tipos []models.TipoInversionInicial
+	if err := db.Order("id asc").Find(&tipos).Error; err != nil {
+		return c, err
+	}
+	for _, t := range tipos {
+		if _, ok := c.tipos[normalizarNombre(t.Tipo)]; !ok {
+			c.tipos[normalizarNombre(t.Tipo)] = t.ID
+		}
+	}
+	var categorias []models.CategoriaCosto
+	if err := db.Order("id asc").Find(&categorias).Error; err != nil {
+		return c, err
+	}
+	for _, cat := range categorias {
+		if _, ok := c.categorias[normalizarNombre(cat.Nombre)]; !ok {
+			c.categorias[normalizarNombre(cat.Nombre)] = cat.ID
+		}
+	}
+	return c, nil
+}
+
+func normalizarNombre(s string) string {
+	return strings.ToLower(strings.TrimSpace(s))
+}
+
+// validarDocumento revisa el documento y resuelve en él los ids de catálogo.
+// Devuelve todos los problemas encontrados juntos.
+func validarDocumento(doc *DocumentoPlan, cat catalogos) error {
+	var problemas []string
+	fallo := func(format string, args ...interface{}) {
+		problemas = append(problemas, fmt.Sprintf(format, args...))
+	}
+	e := &doc.Entradas
+
+	if strings.TrimSpace(doc.Plan.Problematica) == "" {
+		fallo("plan.problematica es requerido")
+	}
+	if doc.Plan.HorizonteAnios == 0 {
+		doc.Plan.HorizonteAnios = models.HorizontePorDefecto
+	}
+	if doc.Plan.HorizonteAnios < 1 || doc.Plan.HorizonteAnios > models.HorizonteMaximo {
+		fallo("plan.horizonte_anios debe estar entre 1 y %d", models.HorizonteMaximo)
+	}
+	if e.Supuesto == nil {
+		fallo("entradas.supuesto es requerido")
+	}
+	if e.ComposicionFinanciamiento == nil {
+		fallo("entradas.composicion_financiamiento es requerido")
+	}
+	if e.DatosPrestamo == nil {
+		fallo("entradas.datos_prestamo es requerido")
+	}
+	if e.EvaluacionProyecto == nil {
+		fallo("entradas.evaluacion_proyecto es requerido")
+	}
+
+	productos := idsUnicos("productos", len(e.Productos), func(i int) uint { return e.Productos[i].ID }, fallo)
+	for i, p := range e.Productos {
+		if strings.TrimSpace(p.Nombre) == "" {
+			fallo("productos[%d].nombre es requerido", i)
+		}
+	}
+	inversiones := idsUnicos("inversiones", len(e.Inversiones), func(i int) uint { return e.Inversiones[i].ID }, fallo)
+	idsUnicos("variacion_anual", len(e.VariacionAnual), func(i int) uint { return e.VariacionAnual[i].ID }, fallo)
+
+	referencia := func(seccion string, i int, campo string, id uint, ids map[uint]bool) {
+		if !ids[id] {
+			fallo("%s[%d].%s=%d no corresponde a ninguna fila del documento", seccion, i, campo, id)
+		}
+	}
+	tipo := func(seccion string, i int, t *models.TipoInversionInicial) uint {
+		if t == nil || strings.TrimSpace(t.Tipo) == "" {
+			fallo("%s[%d].tipo.tipo es requerido", seccion, i)
+			return 0
+		}
+		id, ok := cat.tipos[normalizarNombre(t.Tipo)]
+		if !ok {
+			fallo("%s[%d]: tipo de inversión %q no existe en el catálogo", seccion, i, t.Tipo)
+		}
+		return id
+	}
+	for i := range e.Inversiones {
+		e.Inversiones[i].TipoID = tipo("inversiones", i, e.Inversiones[i].Tipo)
+	}
+	for i := range e.DetallesInversion {
+		d := &e.DetallesInversion[i]
+		d.TipoID = tipo("detalles_inversion", i, d.Tipo)
+		referencia("detalles_inversion", i, "inversion_id", d.InversionID, inversiones)
+	}
+	for i := range e.Costos {
+		c := &e.Costos[i]
+		referencia("costos", i, "producto_servicio_id", c.ProductoServicioID, productos)
+		if c.CategoriaCosto == nil || strings.TrimSpace(c.CategoriaCosto.Nombre) == "" {
+			fallo("costos[%d].categoria_costo.nombre es requerido", i)
+			continue
+		}
+		id, ok := cat.categorias[normalizarNombre(c.CategoriaCosto.Nombre)]
+		if !ok {
+			fallo("costos[%d]: categoría de costo %q no existe en el catálogo", i, c.CategoriaCosto.Nombre)
+		}
+		c.CategoriaCostoID = id
+	}
+	for i, v := range e.VentasDiarias {
+		referencia("ventas_diarias", i, "producto_servicio_id", v.ProductoServicioID, productos)
+	}
+	for i, p := range e.Precios {
+		referencia("precios", i, "producto_servicio_id", p.ProductoServicioID, productos)
+	}
+	for i, p := range e.PresupuestosVenta {
+		referencia("presupuestos_venta", i, "producto_id", p.ProductoID, productos)
+	}
+
+	if len(problemas) > 0 {
+		return fmt.Errorf("%w: %s", ErrDocumentoInvalido, strings.Join(problemas, "; "))
+	}
+	return nil
+}
+
+// idsUnicos comprueba que los ids de una sección sean distintos de cero y únicos
+func idsUnicos(seccion string, n int, id func(int) uint, fallo func(string, ...interface{})) map[uint]bool {
+	ids := make(map[uint]bool, n)
+	for i := 0; i < n; i++ {
+		switch v := id(i); {
+		case v == 0:
+			fallo("%s[%d].id es requerido como referencia", seccion, i)
+		case ids[v]:
+			fallo("%s[%d].id=%d está repetido", seccion, i, v)
+		default:
+			ids[v] = true
+		}
+	}
+	return ids
+}
+
+// ImportarPlan valida el documento y crea en una transacción un plan nuevo del
+// autor indicado con todas sus entradas. Las secciones calculadas se ignoran y
+// las filas anuales que falten se crean con AjustarHorizonte; el llamador debe
+// ejecutar Recalcular sobre el plan devuelto.
+func ImportarPlan(db *gorm.DB, doc DocumentoPlan, autor string) (models.PlanNegocio, error) {
+	var plan models.PlanNegocio
+	cat, err := cargarCatalogos(db)
+	if err != nil {
+		return plan, err
+	}
+	if err := validarDocumento(&doc, cat); err != nil {
+		return plan, err
+	}
+	e := doc.Entradas
+
+	err = db.Transaction(func(tx *gorm.DB) error {
+		plan = models.PlanNegocio{
+			Autor:          autor,
+			Problematica:   recortar(doc.Plan.Problematica, 300),
+			Descripcion:    doc.Plan.Descripcion,
+			HorizonteAnios: doc.Plan.HorizonteAnios,
+		}
+		if err := tx.Create(&plan).Error; err != nil {
+			return fmt.Errorf("creating imported plan: %w", err)
+		}
+
+		// Registros únicos; los opcionales se crean con los mismos valores por defecto que CreatePlanNegocio
+		if e.VariablesSensibilidad == nil {
+			e.VariablesSensibilidad = &models.VariablesDeSensibilidad{}
+		}
+		if e.IndicadoresMacro == nil {
+			e.IndicadoresMacro = &models.IndicadoresMacro{DiasxMes: 30}
+		}
+		if _, err := insertarCopias(tx, "supuesto", plan.ID, []models.Supuesto{*e.Supuesto}, siempre[models.Supuesto]); err != nil {
+			return err
+		}
+		if _, err := insertarCopias(tx, "variables_de_sensibilidad", plan.ID, []models.VariablesDeSensibilidad{*e.VariablesSensibilidad}, siempre[models.VariablesDeSensibilidad]); err != nil {
+			return err
+		}
+		if _, err := insertarCopias(tx, "indicadores_macro", plan.ID, []models.IndicadoresMacro{*e.IndicadoresMacro}, siempre[models.IndicadoresMacro]); err != nil {
+			return err
+		}
+		if _, err := insertarCopias(tx, "composicion_financiamiento", plan.ID, []models.ComposicionFinanciamiento{*e.ComposicionFinanciamiento}, siempre[models.ComposicionFinanciamiento]); err != nil {
+			return err
+		}
+		if _, err := insertarCopias(tx, "datos_prestamo", plan.ID, []models.DatosPrestamo{*e.DatosPrestamo}, siempre[models.DatosPrestamo]); err != nil {
+			return err
+		}
+		if _, err := insertarCopias(tx, "evaluacion_proyecto", plan.ID, []models.EvaluacionProyecto{*e.EvaluacionProyecto}, siempre[models.EvaluacionProyecto]); err != nil {
+			return err
+		}
+
+		productos, err := insertarCopias(tx, "producto_servicio", plan.ID, e.Productos, siempre[models.ProductoServicio])
+		if err != nil {
+			return err
+		}
+		porProducto := func(id *uint) bool { return remapear(productos, id) }
+		inversiones, err := insertarCopias(tx, "inversion_inicial", plan.ID, e.Inversiones, siempre[models.InversionInicial])
+		if err != nil {
+			return err
+		}
+		if _, err := insertarCopias(tx, "detalle_inversion_inicial", plan.ID, e.DetallesInversion, func(f *models.DetalleInversionInicial) bool {
+			return remapear(inversiones, &f.InversionID)
+		}); err != nil {
+			return err
+		}
+		if _, err := insertarCopias(tx, "venta_diaria", plan.ID, e.VentasDiarias, func(f *models.VentaDiaria) bool { return porProducto(&f.ProductoServicioID) }); err != nil {
+			return err
+		}
+		if _, err := insertarCopias(tx, "precios_prod_serv", plan.ID, e.Precios, func(f *models.PreciosProdServ) bool { return porProducto(&f.ProductoServicioID) }); err != nil {
+			return err
+		}
+		if _, err := insertarCopias(tx, "costos_prod_serv", plan.ID, e.Costos, func(f *models.CostosProdServ) bool { return porProducto(&f.ProductoServicioID) }); err != nil {
+			return err
+		}
+		if _, err := insertarCopias(tx, "presupuesto_venta", plan.ID, e.PresupuestosVenta, func(f *models.PresupuestoVenta) bool { return porProducto(&f.ProductoID) }); err != nil {
+			return err
+		}
+
+		// VariacionAnual con sus años
+		anios := make(map[uint][]models.VariacionAnualAnio, len(e.VariacionAnual))
+		for _, v := range e.VariacionAnual {
+			anios[v.ID] = v.Anios
+		}
+		variaciones, err := insertarCopias(tx, "variacion_anual", plan.ID, e.VariacionAnual, siempre[models.VariacionAnual])
+		if err != nil {
+			return err
+		}
+		for viejo, nuevo := range variaciones {
+			filas := anios[viejo]
+			for i := range filas {
+				filas[i].VariacionAnualID = nuevo
+			}
+			if _, err := insertarCopias(tx, "variacion_anual_anio", plan.ID, filas, siempre[models.VariacionAnualAnio]); err != nil {
+				return err
+			}
+		}
+
+		if _, err := insertarCopias(tx, "gastos_operacion", plan.ID, e.GastosOperacion, siempre[models.GastosOperacion]); err != nil {
+			return err
+		}
+		if _, err := insertarCopias(tx, "politicas_venta", plan.ID, e.PoliticasVenta, siempre[models.PoliticasVenta]); err != nil {
+			return err
+		}
+		if _, err := insertarCopias(tx, "politicas_compra", plan.ID, e.PoliticasCompra, siempre[models.PoliticasCompra]); err != nil {
+			return err
+		}
+		if _, err := insertarCopias(tx, "analisis_sensibilidad", plan.ID, e.AnalisisSensibilidad, siempre[models.AnalisisSensibilidad]); err != nil {
+			return err
+		}
+
+		// Filas anuales y mensuales que falten para el horizonte
+		if err := AjustarHorizonte(tx, plan.ID, plan.HorizonteAnios); err != nil {
+			return err
+		}
+		for _, productoID := range productos {
+			if err := AjustarHorizonteProducto(tx, plan.ID, productoID, plan.HorizonteAnios); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	return plan, err
+}
+
+// siempre es el ajuste de insertarCopias para filas sin referencias internas
+func siempre[T any](*T) bool { return true }
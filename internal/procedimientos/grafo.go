@@ -0,0 +1,162 @@
+package procedimientos
+
+import (
+	"fmt"
+	"sort"
+
+	"gorm.io/gorm"
+)
+
+// Procedimiento es un paso de Recalcular con las tablas que lee y las que
+// escribe. Las tablas se nombran como en cargarLista (nombre de la tabla del
+// plan en singular, p. ej. "ventas_dinero").
+//
+// Cada calcular_*.go declara el suyo (procX) junto a la función que ejecuta:
+// esa función carga las tablas de Lee, calcula con el paquete model y guarda
+// las de Escribe. Una tabla que se carga y no está en Lee no dispara el
+// procedimiento cuando cambia.
+type Procedimiento struct {
+	Nombre   string
+	Lee      []string
+	Escribe  []string
+	Ejecutar func(db *gorm.DB, planID uint) error
+}
+
+// Grafo ordena los procedimientos por sus dependencias: B depende de A si B
+// lee una tabla que escribe A. Que un procedimiento lea la tabla que él mismo
+// escribe (las filas existentes que actualiza) no crea dependencia.
+type Grafo struct {
+	procs []Procedimiento
+	// nivel[i] es 0 si i no depende de nadie y 1 + el mayor nivel de sus dependencias
+	nivel []int
+	// lectores[t] son los procedimientos que leen la tabla t
+	lectores map[string][]int
+}
+
+// NuevoGrafo arma el grafo y falla si hay un ciclo o si dos procedimientos
+// escriben la misma tabla (su orden quedaría indefinido).
+func NuevoGrafo(procs []Procedimiento) (*Grafo, error) {
+	g := &Grafo{procs: procs, nivel: make([]int, len(procs)), lectores: make(map[string][]int)}
+	escritor := make(map[string]int)
+	for i, p := range procs {
+		for _, t := range p.Escribe {
+			if j, ok := escritor[t]; ok {
+				return nil, fmt.Errorf("grafo: %s y %s escriben la tabla %s", procs[j].Nombre, p.Nombre, t)
+			}
+			escritor[t] = i
+		}
+	}
+
+	deps := make([][]int, len(procs))
+	dependientes := make([][]int, len(procs))
+	for i, p := range procs {
+		vistos := make(map[int]bool)
+		for _, t := range p.Lee {
+			g.lectores[t] = append(g.lectores[t], i)
+			if j, ok := escritor[t]; ok && j != i && !vistos[j] {
+				vistos[j] = true
+				deps[i] = append(deps[i], j)
+				dependientes[j] = append(dependientes[j], i)
+			}
+		}
+	}
+
+	// Kahn: si no se pueden ordenar todos hay un ciclo
+	pendientes := make([]int, len(procs))
+	var listos []int
+	for i := range procs {
+		pendientes[i] = len(deps[i])
+		if pendientes[i] == 0 {
+			listos = append(listos, i)
+		}
+	}
+	ordenados := 0
+	for len(listos) > 0 {
+		i := listos[0]
+		listos = listos[1:]
+		ordenados++
+		for _, d := range dependientes[i] {
+			if g.nivel[i]+1 > g.nivel[d] {
+				g.nivel[d] = g.nivel[i] + 1
+			}
+			if pendientes[d]--; pendientes[d] == 0 {
+				listos = append(listos, d)
+			}
+		}
+	}
+	if ordenados != len(procs) {
+		var ciclo []string
+		for i, n := range pendientes {
+			if n > 0 {
+				ciclo = append(ciclo, procs[i].Nombre)
+			}
+		}
+		return nil, fmt.Errorf("grafo: ciclo entre %v", ciclo)
+	}
+	return g, nil
+}
+
+// mustGrafo es NuevoGrafo para declaraciones fijas del paquete
+func mustGrafo(procs []Procedimiento) *Grafo {
+	g, err := NuevoGrafo(procs)
+	if err != nil {
+		panic(err)
+	}
+	return g
+}
+
+// Afectados devuelve los procedimientos que hay que volver a ejecutar cuando
+// cambian las tablas dadas: los que leen alguna de ellas y, transitivamente,
+// los que leen lo que esos escriben. Sin tablas devuelve todos.
+func (g *Grafo) Afectados(tablas ...string) []Procedimiento {
+	var out []Procedimiento
+	for _, nivel := range g.Niveles(tablas...) {
+		out = append(out, nivel...)
+	}
+	return out
+}
+
+// Niveles agrupa los procedimientos afectados por las tablas (todos si no se
+// dan tablas) en niveles: los de un nivel no dependen entre sí y solo dependen
+// de niveles anteriores, así que pueden correr en paralelo.
+func (g *Grafo) Niveles(tablas ...string) [][]Procedimiento {
+	afectado := make([]bool, len(g.procs))
+	if len(tablas) == 0 {
+		for i := range afectado {
+			afectado[i] = true
+		}
+	} else {
+		cola := append([]string(nil), tablas...)
+		for len(cola) > 0 {
+			t := cola[0]
+			cola = cola[1:]
+			for _, i := range g.lectores[t] {
+				if !afectado[i] {
+					afectado[i] = true
+					cola = append(cola, g.procs[i].Escribe...)
+				}
+			}
+		}
+	}
+
+	var idx []int
+	for i, ok := range afectado {
+		if ok {
+			idx = append(idx, i)
+		}
+	}
+	sort.SliceStable(idx, func(a, b int) bool { return g.nivel[idx[a]] < g.nivel[idx[b]] })
+	var niveles [][]Procedimiento
+	for k, i := range idx {
+		if k == 0 || g.nivel[i] != g.nivel[idx[k-1]] {
+			niveles = append(niveles, nil)
+		}
+		niveles[len(niveles)-1] = append(niveles[len(niveles)-1], g.procs[i])
+	}
+	return niveles
+}
+
+// Procedimientos devuelve todos los procedimientos del grafo en orden de ejecución
+func (g *Grafo) Procedimientos() []Procedimiento {
+	return g.Afectados()
+}
@@ -0,0 +1,110 @@
+package procedimientos
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func proc(nombre string, lee, escribe string) Procedimiento {
+	return Procedimiento{Nombre: nombre, Lee: strings.Fields(lee), Escribe: strings.Fields(escribe)}
+}
+
+// nombres devuelve los nombres por nivel
+func nombres(niveles [][]Procedimiento) [][]string {
+	var out [][]string
+	for _, nivel := range niveles {
+		var ns []string
+		for _, p := range nivel {
+			ns = append(ns, p.Nombre)
+		}
+		out = append(out, ns)
+	}
+	return out
+}
+
+func TestGrafoNiveles(t *testing.T) {
+	g, err := NuevoGrafo([]Procedimiento{
+		proc("d", "b c", "d"),
+		proc("a", "x a", "a"),
+		proc("b", "a", "b"),
+		proc("c", "y", "c"),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		tablas []string
+		want   [][]string
+	}{
+		{nil, [][]string{{"a", "c"}, {"b"}, {"d"}}},
+		{[]string{"x"}, [][]string{{"a"}, {"b"}, {"d"}}},
+		{[]string{"y"}, [][]string{{"c"}, {"d"}}},
+		{[]string{"a"}, [][]string{{"a"}, {"b"}, {"d"}}},
+		{[]string{"x", "y"}, [][]string{{"a", "c"}, {"b"}, {"d"}}},
+		{[]string{"b", "c"}, [][]string{{"d"}}},
+		{[]string{"d"}, nil},
+		{[]string{"nadie"}, nil},
+	}
+	for _, tt := range tests {
+		if got := nombres(g.Niveles(tt.tablas...)); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Niveles(%v) = %v, want %v", tt.tablas, got, tt.want)
+		}
+	}
+}
+
+func TestNuevoGrafoErrores(t *testing.T) {
+	tests := []struct {
+		nombre string
+		procs  []Procedimiento
+		error  string
+	}{
+		{"ciclo", []Procedimiento{proc("a", "b", "a"), proc("b", "a", "b")}, "ciclo"},
+		{"dos escritores", []Procedimiento{proc("a", "", "t"), proc("b", "", "t")}, "escriben la tabla t"},
+	}
+	for _, tt := range tests {
+		if _, err := NuevoGrafo(tt.procs); err == nil || !strings.Contains(err.Error(), tt.error) {
+			t.Errorf("%s: err = %v", tt.nombre, err)
+		}
+	}
+}
+
+func TestGrafoRecalculo(t *testing.T) {
+	todos := grafoRecalculo.Procedimientos()
+	if len(todos) != 12 {
+		t.Fatalf("procedimientos = %d, want 12", len(todos))
+	}
+	if todos[len(todos)-1].Nombre != "evaluacion" {
+		t.Errorf("el último es %s, want evaluacion", todos[len(todos)-1].Nombre)
+	}
+	// un cambio de crecimiento recalcula el presupuesto y todo lo que sigue hasta la evaluación
+	var afectados []string
+	for _, p := range grafoRecalculo.Afectados("presupuesto_venta") {
+		afectados = append(afectados, p.Nombre)
+	}
+	for _, n := range []string{"presupuestos", "ventas", "estado_resultados", "flujo_efectivo", "balance_general", "evaluacion"} {
+		if !strings.Contains(" "+strings.Join(afectados, " ")+" ", " "+n+" ") {
+			t.Errorf("Afectados(presupuesto_venta) = %v, falta %s", afectados, n)
+		}
+	}
+
+	// las variables de sensibilidad solas no recalculan el presupuesto: el PATCH
+	// que además propaga el crecimiento del año 1 debe nombrar presupuesto_venta
+	tests := []struct {
+		tablas        []string
+		presupuestos  bool
+		preciosCostos bool
+	}{
+		{[]string{"variables_de_sensibilidad"}, false, true},
+		{[]string{"variables_de_sensibilidad", "presupuesto_venta"}, true, true},
+	}
+	for _, tt := range tests {
+		got := make(map[string]bool)
+		for _, p := range grafoRecalculo.Afectados(tt.tablas...) {
+			got[p.Nombre] = true
+		}
+		if got["presupuestos"] != tt.presupuestos || got["precios_costos"] != tt.preciosCostos {
+			t.Errorf("Afectados(%v) = %v", tt.tablas, got)
+		}
+	}
+}
@@ -0,0 +1,311 @@
+package procedimientos
+
+import (
+	"fmt"
+
+	"github.com/JostinAlvaradoS/liveplan_backend_go/internal/models"
+	"gorm.io/gorm"
+)
+
+// HorizontePlan devuelve el número de años de proyección configurado para el
+// plan (PlanNegocio.HorizonteAnios). Si el plan no lo define se usa
+// models.HorizontePorDefecto.
+func HorizontePlan(db *gorm.DB, planID uint) (int, error) {
+	var plan models.PlanNegocio
+	if err := db.Select("id", "horizonte_anios").First(&plan, planID).Error; err != nil {
+		return 0, fmt.Errorf("loading plan_negocio %d: %w", planID, err)
+	}
+	return plan.Horizonte(), nil
+}
+
+type periodo struct {
+	Anio int
+	Mes  int
+}
+
+// periodosExistentes devuelve el conjunto (anio, mes) ya presente en la tabla del modelo para el plan
+func periodosExistentes(tx *gorm.DB, modelo interface{}, planID uint) (map[periodo]bool, error) {
+	var filas []periodo
+	if err := tx.Model(modelo).Select("anio, mes").Where("plan_negocio_id = ?", planID).Scan(&filas).Error; err != nil {
+		return nil, err
+	}
+	set := make(map[periodo]bool, len(filas))
+	for _, f := range filas {
+		set[f] = true
+	}
+	return set, nil
+}
+
+// aniosExistentes devuelve el conjunto de años ya presentes en la tabla del modelo
+// para las filas que cumplen la condición
+func aniosExistentes(tx *gorm.DB, modelo interface{}, query string, args ...interface{}) (map[int]bool, error) {
+	var anios []int
+	if err := tx.Model(modelo).Where(query, args...).Distinct().Pluck("anio", &anios).Error; err != nil {
+		return nil, err
+	}
+	set := make(map[int]bool, len(anios))
+	for _, a := range anios {
+		set[a] = true
+	}
+	return set, nil
+}
+
+// mesesDelHorizonte enumera los periodos (anio, mes) de un horizonte: mes 0 solo
+// en el primer año y meses 1..12 para cada año.
+func mesesDelHorizonte(horizonte int) []periodo {
+	out := []periodo{{Anio: 1, Mes: 0}}
+	for anio := 1; anio <= horizonte; anio++ {
+		for mes := 1; mes <= 12; mes++ {
+			out = append(out, periodo{Anio: anio, Mes: mes})
+		}
+	}
+	return out
+}
+
+// AjustarHorizonte deja las tablas anuales y mensuales del plan alineadas con el
+// horizonte indicado: crea las filas que falten (con valores por defecto) para los
+// años 1..horizonte y elimina las filas de años posteriores. Se usa al crear el
+// plan y cada vez que cambia PlanNegocio.HorizonteAnios. Debe ejecutarse dentro
+// de una transacción.
+func AjustarHorizonte(tx *gorm.DB, planID uint, horizonte int) error {
+	if horizonte <= 0 || horizonte > models.HorizonteMaximo {
+		return fmt.Errorf("horizonte %d fuera de rango (1..%d)", horizonte, models.HorizonteMaximo)
+	}
+
+	// EstadoResultados, FlujoEfectivo y BalanceGeneral: mes 0 del año 1 y meses 1..12 por año
+	erExist, err := periodosExistentes(tx, &models.EstadoResultados{}, planID)
+	if err != nil {
+		return fmt.Errorf("loading estado_resultados: %w", err)
+	}
+	feExist, err := periodosExistentes(tx, &models.FlujoEfectivo{}, planID)
+	if err != nil {
+		return fmt.Errorf("loading flujo_efectivo: %w", err)
+	}
+	bgExist, err := periodosExistentes(tx, &models.BalanceGeneral{}, planID)
+	if err != nil {
+		return fmt.Errorf("loading balance_general: %w", err)
+	}
+	var ers []models.EstadoResultados
+	var fes []models.FlujoEfectivo
+	var bgs []models.BalanceGeneral
+	for _, p := range mesesDelHorizonte(horizonte) {
+		if !erExist[p] {
+			ers = append(ers, models.EstadoResultados{PlanNegocioID: planID, Anio: p.Anio, Mes: p.Mes})
+		}
+		if !feExist[p] {
+			fes = append(fes, models.FlujoEfectivo{PlanNegocioID: planID, Anio: p.Anio, Mes: p.Mes})
+		}
+		if !bgExist[p] {
+			bgs = append(bgs, models.BalanceGeneral{PlanNegocioID: planID, Anio: p.Anio, Mes: p.Mes})
+		}
+	}
+	if len(ers) > 0 {
+		if err := tx.Create(&ers).Error; err != nil {
+			return fmt.Errorf("creating estado_resultados: %w", err)
+		}
+	}
+	if len(fes) > 0 {
+		if err := tx.Create(&fes).Error; err != nil {
+			return fmt.Errorf("creating flujo_efectivo: %w", err)
+		}
+	}
+	if len(bgs) > 0 {
+		if err := tx.Create(&bgs).Error; err != nil {
+			return fmt.Errorf("creating balance_general: %w", err)
+		}
+	}
+
+	// PoliticasVenta y PoliticasCompra por defecto (80% contado, 20% crédito)
+	pvExist, err := periodosExistentes(tx, &models.PoliticasVenta{}, planID)
+	if err != nil {
+		return fmt.Errorf("loading politicas_venta: %w", err)
+	}
+	pcExist, err := periodosExistentes(tx, &models.PoliticasCompra{}, planID)
+	if err != nil {
+		return fmt.Errorf("loading politicas_compra: %w", err)
+	}
+	var pvs []models.PoliticasVenta
+	var pcs []models.PoliticasCompra
+	for anio := 1; anio <= horizonte; anio++ {
+		for mes := 1; mes <= 12; mes++ {
+			p := periodo{Anio: anio, Mes: mes}
+			if !pvExist[p] {
+				pvs = append(pvs, models.PoliticasVenta{PlanNegocioID: planID, Anio: anio, Mes: mes, PorcentajeCredito: 20, PorcentajeContado: 80})
+			}
+			if !pcExist[p] {
+				pcs = append(pcs, models.PoliticasCompra{PlanNegocioID: planID, Anio: anio, Mes: mes, PorcentajeCredito: 20, PorcentajeContado: 80})
+			}
+		}
+	}
+	if len(pvs) > 0 {
+		if err := tx.Create(&pvs).Error; err != nil {
+			return fmt.Errorf("creating politicas_venta: %w", err)
+		}
+	}
+	if len(pcs) > 0 {
+		if err := tx.Create(&pcs).Error; err != nil {
+			return fmt.Errorf("creating politicas_compra: %w", err)
+		}
+	}
+
+	// ConceptosEvaluacion para los años 0..horizonte
+	ceExist, err := aniosExistentes(tx, &models.ConceptosEvaluacion{}, "plan_negocio_id = ?", planID)
+	if err != nil {
+		return fmt.Errorf("loading conceptos_evaluacion: %w", err)
+	}
+	var ces []models.ConceptosEvaluacion
+	for anio := 0; anio <= horizonte; anio++ {
+		if !ceExist[anio] {
+			ces = append(ces, models.ConceptosEvaluacion{
+				PlanNegocioID:            planID,
+				Anio:                     anio,
+				FlujoEfectivoNominal:     "0.00",
+				ValorRescate:             "0.00",
+				TotalFlujoEfectivo:       "0.00",
+				ValorActualFlujosFuturos: "0.00",
+			})
+		}
+	}
+	if len(ces) > 0 {
+		if err := tx.Create(&ces).Error; err != nil {
+			return fmt.Errorf("creating conceptos_evaluacion: %w", err)
+		}
+	}
+
+	// VariacionAnual: una fila por plan con un porcentaje (0 por defecto) por año
+	var va models.VariacionAnual
+	if err := tx.Where("plan_negocio_id = ?", planID).First(&va).Error; err != nil {
+		if err != gorm.ErrRecordNotFound {
+			return fmt.Errorf("loading variacion_anual: %w", err)
+		}
+		va = models.VariacionAnual{PlanNegocioID: planID}
+		if err := tx.Create(&va).Error; err != nil {
+			return fmt.Errorf("creating variacion_anual: %w", err)
+		}
+	}
+	vaExist, err := aniosExistentes(tx, &models.VariacionAnualAnio{}, "variacion_anual_id = ?", va.ID)
+	if err != nil {
+		return fmt.Errorf("loading variacion_anual_anios: %w", err)
+	}
+	var vaAnios []models.VariacionAnualAnio
+	for anio := 1; anio <= horizonte; anio++ {
+		if !vaExist[anio] {
+			vaAnios = append(vaAnios, models.VariacionAnualAnio{VariacionAnualID: va.ID, PlanNegocioID: planID, Anio: anio})
+		}
+	}
+	if len(vaAnios) > 0 {
+		if err := tx.Create(&vaAnios).Error; err != nil {
+			return fmt.Errorf("creating variacion_anual_anios: %w", err)
+		}
+	}
+
+	// Tablas por producto
+	var productos []models.ProductoServicio
+	if err := tx.Where("plan_negocio_id = ?", planID).Find(&productos).Error; err != nil {
+		return fmt.Errorf("loading productos: %w", err)
+	}
+	for _, p := range productos {
+		if err := AjustarHorizonteProducto(tx, planID, p.ID, horizonte); err != nil {
+			return err
+		}
+	}
+
+	// Eliminar los años que quedan fuera del horizonte
+	fuera := []interface{}{
+		&models.EstadoResultados{},
+		&models.FlujoEfectivo{},
+		&models.BalanceGeneral{},
+		&models.PoliticasVenta{},
+		&models.PoliticasCompra{},
+		&models.ConceptosEvaluacion{},
+		&models.VariacionAnualAnio{},
+		&models.DepreciacionAnual{},
+		&models.CostoMateriasPrimas{},
+	}
+	for _, m := range fuera {
+		if err := tx.Where("plan_negocio_id = ? AND anio > ?", planID, horizonte).Delete(m).Error; err != nil {
+			return fmt.Errorf("deleting years beyond horizon: %w", err)
+		}
+	}
+	return nil
+}
+
+// AjustarHorizonteProducto crea (o recorta) las filas anuales de un producto:
+// PresupuestoVenta, VentasDinero y Ventas por año y CostosVentas por año y mes.
+func AjustarHorizonteProducto(tx *gorm.DB, planID, productoID uint, horizonte int) error {
+	pvExist, err := aniosExistentes(tx, &models.PresupuestoVenta{}, "plan_negocio_id = ? AND producto_id = ?", planID, productoID)
+	if err != nil {
+		return fmt.Errorf("loading presupuesto_venta for producto %d: %w", productoID, err)
+	}
+	vdExist, err := aniosExistentes(tx, &models.VentasDinero{}, "plan_negocio_id = ? AND producto_id = ?", planID, productoID)
+	if err != nil {
+		return fmt.Errorf("loading ventas_dinero for producto %d: %w", productoID, err)
+	}
+	vExist, err := aniosExistentes(tx, &models.Ventas{}, "plan_negocio_id = ? AND producto_id = ?", planID, productoID)
+	if err != nil {
+		return fmt.Errorf("loading ventas for producto %d: %w", productoID, err)
+	}
+	var cvFilas []periodo
+	if err := tx.Model(&models.CostosVentas{}).Select("anio, mes").
+		Where("plan_negocio_id = ? AND producto_id = ?", planID, productoID).Scan(&cvFilas).Error; err != nil {
+		return fmt.Errorf("loading costos_ventas for producto %d: %w", productoID, err)
+	}
+	cvExist := make(map[periodo]bool, len(cvFilas))
+	for _, f := range cvFilas {
+		cvExist[f] = true
+	}
+
+	var pvs []models.PresupuestoVenta
+	var vds []models.VentasDinero
+	var vs []models.Ventas
+	var cvs []models.CostosVentas
+	for anio := 1; anio <= horizonte; anio++ {
+		if !pvExist[anio] {
+			pvs = append(pvs, models.PresupuestoVenta{PlanNegocioID: planID, ProductoID: productoID, Anio: anio})
+		}
+		if !vdExist[anio] {
+			vds = append(vds, models.VentasDinero{PlanNegocioID: planID, ProductoID: productoID, Anio: anio})
+		}
+		if !vExist[anio] {
+			vs = append(vs, models.Ventas{PlanNegocioID: planID, ProductoID: productoID, Anio: anio})
+		}
+		for mes := 1; mes <= 12; mes++ {
+			if !cvExist[periodo{Anio: anio, Mes: mes}] {
+				cvs = append(cvs, models.CostosVentas{PlanNegocioID: planID, ProductoID: productoID, Anio: anio, Mes: mes})
+			}
+		}
+	}
+	if len(pvs) > 0 {
+		if err := tx.Create(&pvs).Error; err != nil {
+			return fmt.Errorf("creating presupuesto_venta for producto %d: %w", productoID, err)
+		}
+	}
+	if len(vds) > 0 {
+		if err := tx.Create(&vds).Error; err != nil {
+			return fmt.Errorf("creating ventas_dinero for producto %d: %w", productoID, err)
+		}
+	}
+	if len(vs) > 0 {
+		if err := tx.Create(&vs).Error; err != nil {
+			return fmt.Errorf("creating ventas for producto %d: %w", productoID, err)
+		}
+	}
+	if len(cvs) > 0 {
+		if err := tx.Create(&cvs).Error; err != nil {
+			return fmt.Errorf("creating costos_ventas for producto %d: %w", productoID, err)
+		}
+	}
+
+	fuera := []interface{}{
+		&models.PresupuestoVenta{},
+		&models.VentasDinero{},
+		&models.Ventas{},
+		&models.CostosVentas{},
+	}
+	for _, m := range fuera {
+		if err := tx.Where("plan_negocio_id = ? AND producto_id = ? AND anio > ?", planID, productoID, horizonte).Delete(m).Error; err != nil {
+			return fmt.Errorf("deleting years beyond horizon for producto %d: %w", productoID, err)
+		}
+	}
+	return nil
+}
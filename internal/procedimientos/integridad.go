@@ -0,0 +1,59 @@
+package procedimientos
+
+import (
+	"fmt"
+
+	"github.com/JostinAlvaradoS/liveplan_backend_go/internal/model"
+	"gorm.io/gorm"
+)
+
+// IntegridadEstricta hace que Recalcular falle con *ErrorIntegridad cuando los
+// estados generados no pasan VerificarIntegridad. Los estados ya quedaron
+// guardados: el error avisa que no cuadran, no deshace el recálculo (salvo con
+// RecalculoAtomico, donde el error deshace toda la ejecución).
+var IntegridadEstricta bool
+
+// ErrorIntegridad es el error de Recalcular en modo estricto
+type ErrorIntegridad struct {
+	PlanID        uint
+	Discrepancias []model.Discrepancia
+}
+
+func (e *ErrorIntegridad) Error() string {
+	d := e.Discrepancias[0]
+	return fmt.Sprintf("plan %d: %d reglas de integridad no se cumplen (la primera: %s en año %d mes %d, esperado %.2f, actual %.2f)",
+		e.PlanID, len(e.Discrepancias), d.Regla, d.Anio, d.Mes, d.Esperado, d.Actual)
+}
+
+// VerificarIntegridad revisa los estados guardados del plan (resultados, flujo
+// de efectivo y balance) con model.CheckIntegrity
+func VerificarIntegridad(db *gorm.DB, planID uint) ([]model.Discrepancia, error) {
+	horizonte, err := HorizontePlan(db, planID)
+	if err != nil {
+		return nil, err
+	}
+	in := model.IntegrityInputs{Horizonte: horizonte}
+	if err := cargarListas(db, planID,
+		destino{"estado_resultados", &in.EstadoResultados},
+		destino{"flujo_efectivo", &in.FlujoEfectivo},
+		destino{"balance_general", &in.BalanceGeneral},
+	); err != nil {
+		return nil, err
+	}
+	return model.CheckIntegrity(in), nil
+}
+
+// verificarEstricto aplica IntegridadEstricta al final de Recalcular
+func verificarEstricto(db *gorm.DB, planID uint) error {
+	if !IntegridadEstricta {
+		return nil
+	}
+	ds, err := VerificarIntegridad(db, planID)
+	if err != nil {
+		return err
+	}
+	if len(ds) > 0 {
+		return &ErrorIntegridad{PlanID: planID, Discrepancias: ds}
+	}
+	return nil
+}
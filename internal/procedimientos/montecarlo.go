@@ -0,0 +1,113 @@
+package procedimientos
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/JostinAlvaradoS/liveplan_backend_go/internal/model"
+	"gorm.io/gorm"
+)
+
+// Límites y valores por defecto de la simulación Monte Carlo
+const (
+	SimulacionesPorDefecto = 1000
+	MaxSimulaciones        = 20000
+	IntervalosPorDefecto   = 20
+	MaxIntervalos          = 100
+)
+
+// ParametrosMonteCarlo son las distribuciones de las variables (por nombre,
+// ver model.VariablesMonteCarlo), sus correlaciones, el número de
+// simulaciones, los intervalos del histograma y la semilla (aleatoria si es nil)
+type ParametrosMonteCarlo struct {
+	Simulaciones   int                           `json:"simulaciones"`
+	Intervalos     int                           `json:"intervalos"`
+	Semilla        *int64                        `json:"semilla"`
+	Distribuciones map[string]model.Distribution `json:"distribuciones"`
+	Correlaciones  []model.Correlation           `json:"correlaciones"`
+}
+
+// Validar completa los valores por defecto y revisa límites, distribuciones y correlaciones
+func (p *ParametrosMonteCarlo) Validar() error {
+	if p.Simulaciones == 0 {
+		p.Simulaciones = SimulacionesPorDefecto
+	}
+	if p.Simulaciones < 1 || p.Simulaciones > MaxSimulaciones {
+		return fmt.Errorf("simulaciones debe estar entre 1 y %d", MaxSimulaciones)
+	}
+	if p.Intervalos == 0 {
+		p.Intervalos = IntervalosPorDefecto
+	}
+	if p.Intervalos < 1 || p.Intervalos > MaxIntervalos {
+		return fmt.Errorf("intervalos debe estar entre 1 y %d", MaxIntervalos)
+	}
+	_, err := model.NewSampler(p.Distribuciones, p.Correlaciones, 0)
+	return err
+}
+
+// ResultadoMonteCarlo resume los VAN y TIR simulados
+type ResultadoMonteCarlo struct {
+	Simulaciones int   `json:"simulaciones"`
+	Semilla      int64 `json:"semilla"`
+	// ProbabilidadVANNegativo es la fracción de simulaciones con VAN < 0
+	ProbabilidadVANNegativo float64       `json:"probabilidad_van_negativo"`
+	VAN                     model.Summary `json:"van"`
+	TIR                     model.Summary `json:"tir"`
+}
+
+// SimularMonteCarlo carga una sola vez la foto del plan y ejecuta el
+// recálculo en memoria (model.Compute) con cada escenario aleatorio. No
+// escribe nada en la base de datos. Corre con el plazo de EtapaMonteCarlo y
+// se detiene entre simulaciones si el contexto de db se cancela. p ya debe
+// estar validado; si no trae semilla se le asigna la usada.
+func SimularMonteCarlo(db *gorm.DB, planID uint, p *ParametrosMonteCarlo) (ResultadoMonteCarlo, error) {
+	var out ResultadoMonteCarlo
+	err := conPlazo(db, EtapaMonteCarlo, func(db *gorm.DB) error {
+		var err error
+		out, err = simularMonteCarlo(db, planID, p)
+		return err
+	})
+	return out, err
+}
+
+func simularMonteCarlo(db *gorm.DB, planID uint, p *ParametrosMonteCarlo) (ResultadoMonteCarlo, error) {
+	if p.Semilla == nil {
+		semilla := time.Now().UnixNano()
+		p.Semilla = &semilla
+	}
+	out := ResultadoMonteCarlo{Simulaciones: p.Simulaciones, Semilla: *p.Semilla}
+	muestreo, err := model.NewSampler(p.Distribuciones, p.Correlaciones, *p.Semilla)
+	if err != nil {
+		return out, err
+	}
+
+	entradas, err := CargarEntradas(db, planID)
+	if err != nil {
+		return out, fmt.Errorf("error al cargar el plan %d: %w", planID, err)
+	}
+
+	ctx := contexto(db)
+	van := make([]float64, 0, p.Simulaciones)
+	tir := make([]float64, 0, p.Simulaciones)
+	negativos := 0
+	for i := 0; i < p.Simulaciones; i++ {
+		if err := ctx.Err(); err != nil {
+			return out, fmt.Errorf("simulación Monte Carlo cancelada en la corrida %d de %d: %w", i+1, p.Simulaciones, err)
+		}
+		sorteo := muestreo.Next()
+		in, s := model.ApplyDraw(entradas, sorteo)
+		salidas, err := model.Compute(in, s)
+		if err != nil {
+			return out, fmt.Errorf("error en recálculo de la corrida %d (%v): %w", i+1, sorteo, err)
+		}
+		van = append(van, salidas.Evaluacion.VAN)
+		tir = append(tir, salidas.Evaluacion.TIR)
+		if salidas.Evaluacion.VAN < 0 {
+			negativos++
+		}
+	}
+	out.ProbabilidadVANNegativo = float64(negativos) / float64(p.Simulaciones)
+	out.VAN = model.Summarize(van, p.Intervalos)
+	out.TIR = model.Summarize(tir, p.Intervalos)
+	return out, nil
+}
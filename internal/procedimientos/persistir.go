@@ -0,0 +1,17 @@
+package procedimientos
+
+import (
+	"fmt"
+
+	"gorm.io/gorm"
+)
+
+// guardarFilas actualiza las filas que ya tienen ID y crea las nuevas
+func guardarFilas[T any](tx *gorm.DB, tabla string, filas []T) error {
+	for i := range filas {
+		if err := tx.Save(&filas[i]).Error; err != nil {
+			return fmt.Errorf("saving %s: %w", tabla, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,118 @@
+package procedimientos
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// EtapaSensibilidad es el nombre de plazo del cálculo de la matriz de sensibilidad
+const EtapaSensibilidad = "analisis_sensibilidad"
+
+// EtapaMonteCarlo es el nombre de plazo de la simulación Monte Carlo
+const EtapaMonteCarlo = "montecarlo"
+
+// Plazos son los tiempos máximos de cada etapa del recálculo. Una etapa es un
+// procedimiento del grafo (por su Nombre), "integridad", EtapaSensibilidad o
+// EtapaMonteCarlo. Cero es sin plazo.
+type Plazos struct {
+	General time.Duration
+	// PorEtapa sobrescribe General para las etapas indicadas
+	PorEtapa map[string]time.Duration
+}
+
+var (
+	plazosMu sync.RWMutex
+	plazos   Plazos
+)
+
+// ConfigurarPlazos fija los plazos de las etapas; falla si nombra una etapa
+// que no existe
+func ConfigurarPlazos(p Plazos) error {
+	validas := map[string]bool{"integridad": true, EtapaSensibilidad: true, EtapaMonteCarlo: true}
+	for _, proc := range grafoRecalculo.Procedimientos() {
+		validas[proc.Nombre] = true
+	}
+	for etapa, d := range p.PorEtapa {
+		if !validas[etapa] {
+			return fmt.Errorf("plazos: etapa desconocida %q", etapa)
+		}
+		if d < 0 {
+			return fmt.Errorf("plazos: plazo negativo para %s", etapa)
+		}
+	}
+	if p.General < 0 {
+		return errors.New("plazos: plazo general negativo")
+	}
+	plazosMu.Lock()
+	defer plazosMu.Unlock()
+	plazos = p
+	return nil
+}
+
+// ParsearPlazos lee una lista "etapa=duración" separada por comas, p. ej.
+// "balance_general=10s,analisis_sensibilidad=2m"
+func ParsearPlazos(s string) (map[string]time.Duration, error) {
+	out := make(map[string]time.Duration)
+	for _, par := range strings.Split(s, ",") {
+		par = strings.TrimSpace(par)
+		if par == "" {
+			continue
+		}
+		etapa, valor, ok := strings.Cut(par, "=")
+		if !ok {
+			return nil, fmt.Errorf("plazos: se esperaba etapa=duración en %q", par)
+		}
+		d, err := time.ParseDuration(strings.TrimSpace(valor))
+		if err != nil {
+			return nil, fmt.Errorf("plazos: %s: %w", etapa, err)
+		}
+		out[strings.TrimSpace(etapa)] = d
+	}
+	return out, nil
+}
+
+// plazoDe devuelve el plazo de la etapa (0 si no tiene)
+func plazoDe(etapa string) time.Duration {
+	plazosMu.RLock()
+	defer plazosMu.RUnlock()
+	if d, ok := plazos.PorEtapa[etapa]; ok {
+		return d
+	}
+	return plazos.General
+}
+
+// contexto devuelve el contexto ligado a db (Background si no tiene)
+func contexto(db *gorm.DB) context.Context {
+	if db.Statement != nil && db.Statement.Context != nil {
+		return db.Statement.Context
+	}
+	return context.Background()
+}
+
+// conPlazo ejecuta fn con db ligado a un contexto que vence con el plazo de
+// la etapa. Si vence, el error lo indica y sigue envolviendo
+// context.DeadlineExceeded.
+func conPlazo(db *gorm.DB, etapa string, fn func(db *gorm.DB) error) error {
+	d := plazoDe(etapa)
+	if d <= 0 {
+		return fn(db)
+	}
+	ctx, cancel := context.WithTimeout(contexto(db), d)
+	defer cancel()
+	err := fn(db.WithContext(ctx))
+	if err == nil || ctx.Err() == nil || contexto(db).Err() != nil {
+		return err
+	}
+	// venció el plazo de la etapa (no el de la petición); el driver puede
+	// devolver su propio error de cancelación
+	if errors.Is(err, context.DeadlineExceeded) {
+		return fmt.Errorf("plazo de %v vencido: %w", d, err)
+	}
+	return fmt.Errorf("plazo de %v vencido (%v): %w", d, err, context.DeadlineExceeded)
+}
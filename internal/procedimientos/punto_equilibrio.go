@@ -0,0 +1,37 @@
+package procedimientos
+
+import (
+	"github.com/JostinAlvaradoS/liveplan_backend_go/internal/model"
+	"github.com/JostinAlvaradoS/liveplan_backend_go/internal/models"
+	"gorm.io/gorm"
+)
+
+// CalcularPuntoEquilibrio calcula el punto de equilibrio de cada año del plan
+// (model.ComputeBreakEven) con los precios, costos, ventas y cuotas que dejó
+// el último recálculo. No escribe nada en la base de datos.
+func CalcularPuntoEquilibrio(db *gorm.DB, planID uint) ([]model.PuntoEquilibrioAnio, error) {
+	horizonte, err := HorizontePlan(db, planID)
+	if err != nil {
+		return nil, err
+	}
+	in := model.BreakEvenInputs{Horizonte: horizonte, Productos: make(map[uint]string)}
+	var productos []models.ProductoServicio
+	if err := cargarListas(db, planID,
+		destino{"producto_servicio", &productos},
+		destino{"precios_prod_serv", &in.Precios},
+		destino{"costos_prod_serv", &in.Costos},
+		destino{"ventas_dinero", &in.VentasDinero},
+		destino{"gastos_operacion", &in.Gastos},
+		destino{"detalle_inversion_inicial", &in.Detalles},
+		destino{"prestamo_cuotas", &in.Cuotas},
+	); err != nil {
+		return nil, err
+	}
+	if err := cargarDepreciaciones(db, planID, &in.Depreciaciones); err != nil {
+		return nil, err
+	}
+	for _, p := range productos {
+		in.Productos[p.ID] = p.Nombre
+	}
+	return model.ComputeBreakEven(in), nil
+}
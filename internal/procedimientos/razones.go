@@ -0,0 +1,27 @@
+package procedimientos
+
+import (
+	"github.com/JostinAlvaradoS/liveplan_backend_go/internal/model"
+	"gorm.io/gorm"
+)
+
+// CalcularRazones calcula las razones financieras de cada año del plan
+// (model.ComputeRatios) con los estados que dejó el último recálculo y marca
+// las que quedan fuera de umbrales (puede ser nil). No escribe nada en la base
+// de datos.
+func CalcularRazones(db *gorm.DB, planID uint, umbrales map[string]model.Umbral) ([]model.RazonesAnio, error) {
+	horizonte, err := HorizontePlan(db, planID)
+	if err != nil {
+		return nil, err
+	}
+	in := model.RatiosInputs{Horizonte: horizonte, Umbrales: umbrales}
+	if err := cargarListas(db, planID,
+		destino{"estado_resultados", &in.EstadoResultados},
+		destino{"flujo_efectivo", &in.FlujoEfectivo},
+		destino{"balance_general", &in.BalanceGeneral},
+		destino{"prestamo_cuotas", &in.Cuotas},
+	); err != nil {
+		return nil, err
+	}
+	return model.ComputeRatios(in), nil
+}
@@ -1,76 +1,151 @@
 package procedimientos
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"time"
 
+	"github.com/JostinAlvaradoS/liveplan_backend_go/internal/models"
 	"gorm.io/gorm"
 )
 
-// (lockPlan removed) concurrency control for recalculations is handled elsewhere.
-
-// Recalcular ejecuta los procedimientos de cálculo dependientes para un plan:
-//   - CalcularDepreciaciones
-//   - CalcularPresupuestos
-//
-// Se ejecutan en paralelo y si alguno falla, se devuelve un error que concatena
-// los errores ocurridos en ambos procedimientos.
+// Recalcular recalcula el plan a través del coordinador: nunca corren dos
+// recálculos del mismo plan a la vez (tampoco entre réplicas, por el advisory
+// lock) y las peticiones que llegan durante una ejecución se atienden juntas
+// con una sola ejecución posterior. Devuelve el error de esa ejecución.
 func Recalcular(db *gorm.DB, planID uint) error {
-	// Stage 1: try to run precios+costos and composicion in parallel, fallback to sequential on error
-	stage1Tasks := []func() error{
-		func() error { return CalcularPreciosYCostosPorPlan(db, planID) },
-		func() error { return CalcularComposicion(db, planID) },
-	}
-	if err := runAdaptive(stage1Tasks); err != nil {
-		return fmt.Errorf("recalcular (stage1): %w", err)
-	}
+	_, err := coordinador.Solicitar(db, planID)
+	return err
+}
 
-	// Stage 2: calcular depreciaciones y presupuestos en paralelo
-	// Stage 2: depreciaciones + presupuestos (also adaptive)
-	stage2Tasks := []func() error{
-		func() error { return CalcularDepreciaciones(db, planID) },
-		func() error { return CalcularPresupuestos(db, planID) },
-		func() error { return CalcularPrestamo(db, planID) },
-		func() error { return CalcularCostoMateriasPrimas(db, planID) },
-		func() error { return CalcularVentas(db, planID) },
-		func() error { return CalcularCostosVentas(db, planID) },
-	}
-	if err := runAdaptive(stage2Tasks); err != nil {
-		return fmt.Errorf("recalcular (stage2): %w", err)
-	}
+// RecalcularTablas recalcula solo los procedimientos que dependen de las
+// tablas modificadas (ver Grafo.Niveles). Sin tablas recalcula todo.
+func RecalcularTablas(db *gorm.DB, planID uint, tablas ...string) error {
+	_, err := coordinador.Solicitar(db, planID, tablas...)
+	return err
+}
 
-	// Stage 3: calcular depreciaciones y presupuestos en paralelo
-	// Stage 3: depreciaciones + presupuestos (also adaptive)
-	stage3Tasks := []func() error{
-		func() error { return CalcularEstadoResultados(db, planID) },
-	}
-	if err := runAdaptive(stage3Tasks); err != nil {
-		return fmt.Errorf("recalcular (stage3): %w", err)
-	}
+// RecalcularConEjecucion hace lo mismo que RecalcularTablas y devuelve además
+// el registro de recalculo_ejecuciones de la ejecución que atendió la petición
+func RecalcularConEjecucion(db *gorm.DB, planID uint, tablas ...string) (models.RecalculoEjecucion, error) {
+	return coordinador.Solicitar(db, planID, tablas...)
+}
+
+// ErrorEtapa es el error de Recalcular con la etapa en la que falló
+type ErrorEtapa struct {
+	Etapa string
+	Err   error
+}
 
-	// Stage 3: calcular depreciaciones y presupuestos en paralelo
-	// Stage 3: depreciaciones + presupuestos (also adaptive)
-	stage4Tasks := []func() error{
-		func() error { return CalcularFlujoEfectivo(db, planID) },
+func (e *ErrorEtapa) Error() string { return fmt.Sprintf("recalcular (%s): %v", e.Etapa, e.Err) }
+
+func (e *ErrorEtapa) Unwrap() error { return e.Err }
+
+// grafoRecalculo son los procedimientos de Recalcular; el orden y el
+// paralelismo salen de las tablas que declara cada uno
+var grafoRecalculo = mustGrafo([]Procedimiento{
+	procPreciosCostos,
+	procComposicion,
+	procDepreciaciones,
+	procPresupuestos,
+	procPrestamo,
+	procCostoMateriasPrimas,
+	procVentas,
+	procCostosVentas,
+	procEstadoResultados,
+	procFlujoEfectivo,
+	procBalanceGeneral,
+	procEvaluacion,
+})
+
+// ProcedimientosRecalculo devuelve los procedimientos de Recalcular en orden de ejecución
+func ProcedimientosRecalculo() []Procedimiento {
+	return grafoRecalculo.Procedimientos()
+}
+
+// RecalculoAtomico hace que cada ejecución de Recalcular corra en una sola
+// transacción: si un procedimiento (o el modo estricto) falla se deshace todo
+// y los lectores ven el plan como estaba antes o ya recalculado, nunca a
+// medias. Las transacciones de cada procedimiento quedan como savepoints. La
+// transacción usa una sola conexión, así que los procedimientos de un nivel
+// corren uno tras otro, y ante un error reintentable se repite la transacción
+// completa (un statement no se puede reintentar dentro de una transacción
+// abortada).
+var RecalculoAtomico bool
+
+// recalcularEtapas ejecuta, nivel por nivel del grafo, los procedimientos
+// afectados por las tablas (todos si tablas es nil). Los de un mismo nivel
+// corren con un Ejecutor, o uno tras otro en una transacción si
+// RecalculoAtomico. Si alguno falla se devuelve *ErrorEtapa con el nombre del
+// primero que falló y no se ejecutan los niveles siguientes. Cada
+// procedimiento corre con el contexto de db y su plazo (ver ConfigurarPlazos).
+// Devuelve también el tiempo de cada procedimiento ejecutado.
+func recalcularEtapas(db *gorm.DB, planID uint, tablas []string) ([]TiempoTarea, error) {
+	if !RecalculoAtomico {
+		return recalcularNiveles(db, planID, tablas, nuevoEjecutor())
 	}
-	if err := runAdaptive(stage4Tasks); err != nil {
-		return fmt.Errorf("recalcular (stage4): %w", err)
+
+	base := nuevoEjecutor()
+	espera := base.Espera
+	var tiempos []TiempoTarea
+	for intento := 0; ; intento++ {
+		// dentro de la transacción no se reintenta por tarea
+		ej := &Ejecutor{Concurrencia: 1}
+		err := db.Transaction(func(tx *gorm.DB) error {
+			_, err := recalcularNiveles(tx, planID, tablas, ej)
+			return err
+		})
+		tiempos = append(tiempos, ej.Tiempos()...)
+		if err == nil || intento >= base.Reintentos || !ErrorReintentable(err) || contexto(db).Err() != nil {
+			return tiempos, err
+		}
+		select {
+		case <-contexto(db).Done():
+		case <-time.After(espera):
+		}
+		espera *= 2
 	}
+}
 
-	stage5Tasks := []func() error{
-		func() error { return CalcularBalanceGeneral(db, planID) },
+func recalcularNiveles(db *gorm.DB, planID uint, tablas []string, ej *Ejecutor) ([]TiempoTarea, error) {
+	niveles := grafoRecalculo.Niveles(tablas...)
+	for n, nivel := range niveles {
+		tareas := make([]Tarea, len(nivel))
+		for i, p := range nivel {
+			p := p
+			tareas[i] = Tarea{Nombre: p.Nombre, Nivel: n, Ejecutar: func(ctx context.Context) error {
+				return conPlazo(db.WithContext(ctx), p.Nombre, func(db *gorm.DB) error { return p.Ejecutar(db, planID) })
+			}}
+		}
+		if err := ej.Ejecutar(contexto(db), tareas); err != nil {
+			return ej.Tiempos(), errorEtapa(nivel[0].Nombre, err)
+		}
 	}
-	if err := runAdaptive(stage5Tasks); err != nil {
-		return fmt.Errorf("recalcular (stage5): %w", err)
+
+	// Modo estricto: los estados generados deben cuadrar (ver VerificarIntegridad)
+	if IntegridadEstricta {
+		integridad := Tarea{Nombre: "integridad", Nivel: len(niveles), Ejecutar: func(ctx context.Context) error {
+			return conPlazo(db.WithContext(ctx), "integridad", func(db *gorm.DB) error { return verificarEstricto(db, planID) })
+		}}
+		if err := ej.Ejecutar(contexto(db), []Tarea{integridad}); err != nil {
+			return ej.Tiempos(), errorEtapa("integridad", err)
+		}
 	}
 
+	return ej.Tiempos(), nil
+}
 
-	// Stage 3: depreciaciones + presupuestos (also adaptive)
-	stage6Tasks := []func() error{
-		func() error { return CalcularEvaluacion(db, planID) },
+// errorEtapa arma el *ErrorEtapa de un nivel con el nombre de la primera tarea
+// que falló (porDefecto si ninguna llegó a fallar, p. ej. por cancelación).
+// Con una sola tarea fallida el error es el de esa tarea, sin repetir su nombre.
+func errorEtapa(porDefecto string, err error) *ErrorEtapa {
+	var et *ErrorTarea
+	if !errors.As(err, &et) {
+		return &ErrorEtapa{Etapa: porDefecto, Err: err}
 	}
-	if err := runAdaptive(stage6Tasks); err != nil {
-		return fmt.Errorf("recalcular (stage6): %w", err)
+	if j, ok := err.(interface{ Unwrap() []error }); ok && len(j.Unwrap()) == 1 {
+		return &ErrorEtapa{Etapa: et.Tarea, Err: et.Err}
 	}
-
-	return nil
+	return &ErrorEtapa{Etapa: et.Tarea, Err: err}
 }
@@ -0,0 +1,109 @@
+package procedimientos
+
+import (
+	"fmt"
+	"math"
+	"sort"
+
+	"github.com/JostinAlvaradoS/liveplan_backend_go/internal/model"
+	"github.com/JostinAlvaradoS/liveplan_backend_go/internal/models"
+	"gorm.io/gorm"
+)
+
+// BarraTornado es el VAN y la TIR de un plan con una entrada variada -X% (bajo)
+// y +X% (alto). Oscilacion es |alto - bajo|; SinEfecto indica que la entrada
+// no mueve ni el VAN ni la TIR (p. ej. una que el cálculo no usa).
+type BarraTornado struct {
+	Clave         string  `json:"clave"`
+	Nombre        string  `json:"nombre"`
+	VANBajo       float64 `json:"van_bajo"`
+	VANAlto       float64 `json:"van_alto"`
+	OscilacionVAN float64 `json:"oscilacion_van"`
+	TIRBajo       float64 `json:"tir_bajo"`
+	TIRAlto       float64 `json:"tir_alto"`
+	OscilacionTIR float64 `json:"oscilacion_tir"`
+	SinEfecto     bool    `json:"sin_efecto"`
+}
+
+// ResultadoTornado es el análisis de tornado de un plan: las barras van de la
+// que más mueve el VAN a la que menos
+type ResultadoTornado struct {
+	Variacion float64        `json:"variacion"`
+	VANBase   float64        `json:"van_base"`
+	TIRBase   float64        `json:"tir_base"`
+	Barras    []BarraTornado `json:"barras"`
+}
+
+// CalcularTornado varía una por una las entradas de model.TornadoDrivers en
+// ±variacion por ciento con las demás fijas y recalcula el plan en memoria.
+// No escribe nada en la base de datos; se detiene entre entradas si el
+// contexto de db se cancela.
+func CalcularTornado(db *gorm.DB, planID uint, variacion float64) (ResultadoTornado, error) {
+	out := ResultadoTornado{Variacion: variacion}
+	entradas, err := CargarEntradas(db, planID)
+	if err != nil {
+		return out, fmt.Errorf("error al cargar el plan %d: %w", planID, err)
+	}
+
+	var ids []uint
+	for _, c := range entradas.Costos {
+		ids = append(ids, c.CategoriaCostoID)
+	}
+	categorias := make(map[uint]string)
+	if len(ids) > 0 {
+		var filas []models.CategoriaCosto
+		if err := db.Where("id IN ?", ids).Find(&filas).Error; err != nil {
+			return out, fmt.Errorf("error al obtener CategoriaCosto: %w", err)
+		}
+		for _, c := range filas {
+			categorias[c.ID] = c.Nombre
+		}
+	}
+
+	base, err := model.Compute(entradas, model.Shocks{})
+	if err != nil {
+		return out, fmt.Errorf("error en recálculo base: %w", err)
+	}
+	out.VANBase, out.TIRBase = base.Evaluacion.VAN, base.Evaluacion.TIR
+
+	ctx := contexto(db)
+	for _, d := range model.TornadoDrivers(entradas, categorias) {
+		if err := ctx.Err(); err != nil {
+			return out, fmt.Errorf("análisis de tornado cancelado en %s: %w", d.Clave, err)
+		}
+		var evaluaciones [2]models.EvaluacionProyecto
+		for i, pct := range []float64{-variacion, variacion} {
+			in, s := d.Apply(entradas, pct)
+			salidas, err := model.Compute(in, s)
+			if err != nil {
+				return out, fmt.Errorf("error en recálculo de %s %+.2f%%: %w", d.Clave, pct, err)
+			}
+			evaluaciones[i] = salidas.Evaluacion
+		}
+		b := BarraTornado{
+			Clave:   d.Clave,
+			Nombre:  d.Nombre,
+			VANBajo: evaluaciones[0].VAN,
+			VANAlto: evaluaciones[1].VAN,
+			TIRBajo: evaluaciones[0].TIR,
+			TIRAlto: evaluaciones[1].TIR,
+		}
+		b.OscilacionVAN = math.Abs(b.VANAlto - b.VANBajo)
+		b.OscilacionTIR = math.Abs(b.TIRAlto - b.TIRBajo)
+		b.SinEfecto = b.OscilacionVAN == 0 && b.OscilacionTIR == 0
+		out.Barras = append(out.Barras, b)
+	}
+	ordenarBarras(out.Barras)
+	return out, nil
+}
+
+// ordenarBarras ordena de mayor a menor oscilación del VAN y, a igual VAN, de
+// la TIR; las empatadas conservan el orden de model.TornadoDrivers
+func ordenarBarras(barras []BarraTornado) {
+	sort.SliceStable(barras, func(i, j int) bool {
+		if barras[i].OscilacionVAN != barras[j].OscilacionVAN {
+			return barras[i].OscilacionVAN > barras[j].OscilacionVAN
+		}
+		return barras[i].OscilacionTIR > barras[j].OscilacionTIR
+	})
+}
@@ -0,0 +1,27 @@
+package procedimientos
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestOrdenarBarras(t *testing.T) {
+	barras := []BarraTornado{
+		{Clave: "trema", OscilacionVAN: 50, OscilacionTIR: 0},
+		{Clave: "precio", OscilacionVAN: 900, OscilacionTIR: 12},
+		{Clave: "diasxmes", SinEfecto: true},
+		{Clave: "tasa_anual", OscilacionVAN: 50, OscilacionTIR: 0},
+		{Clave: "volumen", OscilacionVAN: 50, OscilacionTIR: 3},
+		{Clave: "gastos_operacion", OscilacionVAN: 400, OscilacionTIR: 5},
+	}
+	ordenarBarras(barras)
+	var got []string
+	for _, b := range barras {
+		got = append(got, b.Clave)
+	}
+	// a igual VAN decide la TIR; los empates conservan el orden original
+	want := []string{"precio", "gastos_operacion", "volumen", "trema", "tasa_anual", "diasxmes"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("orden = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,145 @@
+package reportes
+
+import (
+	"sort"
+
+	"github.com/JostinAlvaradoS/liveplan_backend_go/internal/procedimientos"
+)
+
+// Comparacion son las diferencias entre dos documentos de un plan (b - a):
+// cada renglón de los estados financieros por año, la evaluación y las celdas
+// de la matriz de sensibilidad
+type Comparacion struct {
+	Estados      []EstadoComparado `json:"estados"`
+	Evaluacion   []ValorComparado  `json:"evaluacion"`
+	Sensibilidad []CeldaComparada  `json:"sensibilidad"`
+}
+
+// EstadoComparado es un estado financiero con sus renglones comparados por año
+type EstadoComparado struct {
+	Estado    string             `json:"estado"`
+	Renglones []RenglonComparado `json:"renglones"`
+}
+
+// RenglonComparado es un concepto de un estado con el total de cada año en los
+// dos documentos (saldo de apertura o cierre en las cuentas que son saldos)
+type RenglonComparado struct {
+	Concepto string           `json:"concepto"`
+	Anios    []ValorComparado `json:"anios"`
+}
+
+// ValorComparado es un valor en los dos documentos. Anio es 0 fuera de los
+// estados; Porcentaje es nil si A es 0.
+type ValorComparado struct {
+	Anio       int      `json:"anio,omitempty"`
+	Concepto   string   `json:"concepto,omitempty"`
+	A          float64  `json:"a"`
+	B          float64  `json:"b"`
+	Diferencia float64  `json:"diferencia"`
+	Porcentaje *float64 `json:"porcentaje"`
+}
+
+// CeldaComparada es una celda (volumen, costo) de la matriz de sensibilidad
+type CeldaComparada struct {
+	Volumen float64 `json:"volumen"`
+	Costo   float64 `json:"costo"`
+	ValorComparado
+}
+
+func comparar(a, b float64) ValorComparado {
+	v := ValorComparado{A: a, B: b, Diferencia: b - a}
+	if a != 0 {
+		p := (b - a) / a * 100
+		v.Porcentaje = &p
+	}
+	return v
+}
+
+// Comparar compara dos documentos de ExportarPlan con calculados
+func Comparar(a, b procedimientos.DocumentoPlan) Comparacion {
+	var out Comparacion
+	ea, eb := estadosDe(a), estadosDe(b)
+	for i := range ea {
+		out.Estados = append(out.Estados, compararEstado(ea[i], eb[i]))
+	}
+
+	var evA, evB [3]float64
+	if ev := a.Entradas.EvaluacionProyecto; ev != nil {
+		evA = [3]float64{ev.VAN, ev.TIR, ev.TREMA}
+	}
+	if ev := b.Entradas.EvaluacionProyecto; ev != nil {
+		evB = [3]float64{ev.VAN, ev.TIR, ev.TREMA}
+	}
+	for i, concepto := range []string{"VAN", "TIR", "TREMA"} {
+		v := comparar(evA[i], evB[i])
+		v.Concepto = concepto
+		out.Evaluacion = append(out.Evaluacion, v)
+	}
+
+	type celda struct{ volumen, costo float64 }
+	valores := map[celda][2]float64{}
+	for _, c := range a.Entradas.AnalisisSensibilidad {
+		v := valores[celda{c.Volumen, c.Costo}]
+		v[0] = c.Valor
+		valores[celda{c.Volumen, c.Costo}] = v
+	}
+	for _, c := range b.Entradas.AnalisisSensibilidad {
+		v := valores[celda{c.Volumen, c.Costo}]
+		v[1] = c.Valor
+		valores[celda{c.Volumen, c.Costo}] = v
+	}
+	for k, v := range valores {
+		out.Sensibilidad = append(out.Sensibilidad, CeldaComparada{Volumen: k.volumen, Costo: k.costo, ValorComparado: comparar(v[0], v[1])})
+	}
+	sort.Slice(out.Sensibilidad, func(i, j int) bool {
+		if out.Sensibilidad[i].Volumen != out.Sensibilidad[j].Volumen {
+			return out.Sensibilidad[i].Volumen < out.Sensibilidad[j].Volumen
+		}
+		return out.Sensibilidad[i].Costo < out.Sensibilidad[j].Costo
+	})
+	return out
+}
+
+// estadosDe arma los tres estados del documento (vacíos si no trae calculados)
+func estadosDe(doc procedimientos.DocumentoPlan) []estado {
+	c := doc.Calculados
+	if c == nil {
+		c = &procedimientos.CalculadosPlan{}
+	}
+	return []estado{estadoResultados(c.EstadoResultados), flujoEfectivo(c.FlujoEfectivo), balanceGeneral(c.BalanceGeneral)}
+}
+
+// compararEstado compara los totales anuales de cada concepto; un año que
+// falta en un documento cuenta como 0
+func compararEstado(a, b estado) EstadoComparado {
+	anios := map[int]bool{}
+	for _, anio := range a.anios() {
+		anios[anio] = true
+	}
+	for _, anio := range b.anios() {
+		anios[anio] = true
+	}
+	var orden []int
+	for anio := range anios {
+		orden = append(orden, anio)
+	}
+	sort.Ints(orden)
+
+	out := EstadoComparado{Estado: a.nombre}
+	totalesA := make([][]float64, len(orden))
+	totalesB := make([][]float64, len(orden))
+	for i, anio := range orden {
+		totalesA[i] = a.totalAnual(a.delAnio(anio))
+		totalesB[i] = b.totalAnual(b.delAnio(anio))
+	}
+	for j, c := range a.conceptos {
+		r := RenglonComparado{Concepto: c.etiqueta}
+		for i, anio := range orden {
+			v := comparar(totalesA[i][j], totalesB[i][j])
+			v.Anio = anio
+			r.Anios = append(r.Anios, v)
+		}
+		out.Renglones = append(out.Renglones, r)
+	}
+	return out
+}
@@ -0,0 +1,122 @@
+package reportes
+
+import (
+	"testing"
+
+	"github.com/JostinAlvaradoS/liveplan_backend_go/internal/models"
+	"github.com/JostinAlvaradoS/liveplan_backend_go/internal/procedimientos"
+)
+
+// revisarValor compara un ValorComparado; pct nil significa sin porcentaje
+func revisarValor(t *testing.T, nombre string, got ValorComparado, a, b float64, pct *float64) {
+	t.Helper()
+	if got.A != a || got.B != b || got.Diferencia != b-a {
+		t.Errorf("%s = a %v b %v dif %v, want a %v b %v dif %v", nombre, got.A, got.B, got.Diferencia, a, b, b-a)
+	}
+	switch {
+	case pct == nil && got.Porcentaje != nil:
+		t.Errorf("%s: porcentaje = %v, want nil", nombre, *got.Porcentaje)
+	case pct != nil && (got.Porcentaje == nil || !cercano(*got.Porcentaje, *pct)):
+		t.Errorf("%s: porcentaje = %v, want %v", nombre, got.Porcentaje, *pct)
+	}
+}
+
+func cercano(a, b float64) bool {
+	d := a - b
+	return d < 1e-9 && d > -1e-9
+}
+
+func renglonDe(t *testing.T, c Comparacion, estado, concepto string) RenglonComparado {
+	t.Helper()
+	for _, e := range c.Estados {
+		if e.Estado != estado {
+			continue
+		}
+		for _, r := range e.Renglones {
+			if r.Concepto == concepto {
+				return r
+			}
+		}
+	}
+	t.Fatalf("sin renglón %s %q", estado, concepto)
+	return RenglonComparado{}
+}
+
+func TestComparar(t *testing.T) {
+	a := documentoDePrueba()
+	a.Entradas.AnalisisSensibilidad = []models.AnalisisSensibilidad{
+		{Volumen: 10, Costo: 0, Valor: 200},
+		{Volumen: 0, Costo: 5, Valor: 0},
+	}
+
+	b := documentoDePrueba()
+	b.Calculados = &procedimientos.CalculadosPlan{
+		EstadoResultados: []models.EstadoResultados{
+			{Anio: 1, Mes: 1, Ventas: 1200, UtilidadNeta: 450},
+			{Anio: 1, Mes: 2, Ventas: 1800.25, UtilidadNeta: 675},
+			{Anio: 2, Mes: 1, Ventas: 900},
+		},
+		FlujoEfectivo: []models.FlujoEfectivo{
+			{Anio: 1, Mes: 1, EfectivoInicial: 400, EfectivoFinal: 600},
+			{Anio: 1, Mes: 2, EfectivoInicial: 600, EfectivoFinal: 700},
+		},
+	}
+	b.Entradas.EvaluacionProyecto = &models.EvaluacionProyecto{VAN: 5000, TIR: 12, TREMA: 10}
+	b.Entradas.AnalisisSensibilidad = []models.AnalisisSensibilidad{
+		{Volumen: 10, Costo: 0, Valor: 150},
+		{Volumen: 0, Costo: 5, Valor: 30},
+		{Volumen: -10, Costo: 5, Valor: 80},
+	}
+
+	c := Comparar(a, b)
+	pct := func(p float64) *float64 { return &p }
+
+	// totales anuales por año; el año 2 no existe en a y cuenta como 0
+	ventas := renglonDe(t, c, "EstadoResultados", "Ventas")
+	if len(ventas.Anios) != 2 || ventas.Anios[0].Anio != 1 || ventas.Anios[1].Anio != 2 {
+		t.Fatalf("años = %+v", ventas.Anios)
+	}
+	revisarValor(t, "ventas año 1", ventas.Anios[0], 2500.25, 3000.25, pct(500/2500.25*100))
+	revisarValor(t, "ventas año 2", ventas.Anios[1], 0, 900, nil)
+	revisarValor(t, "utilidad neta año 1", renglonDe(t, c, "EstadoResultados", "Utilidad neta").Anios[0], 1125, 1125, pct(0))
+
+	// los saldos comparan la apertura o el cierre del año, no la suma
+	revisarValor(t, "efectivo inicial", renglonDe(t, c, "FlujoEfectivo", "Efectivo inicial").Anios[0], 500, 400, pct(-20))
+	revisarValor(t, "efectivo final", renglonDe(t, c, "FlujoEfectivo", "Efectivo final").Anios[0], 550, 700, pct(150.0/550*100))
+	// b no trae balance: el año 1 de a se compara contra 0
+	revisarValor(t, "total activo", renglonDe(t, c, "BalanceGeneral", "Total activo").Anios[0], 600, 0, pct(-100))
+
+	// a no trae evaluación: todo parte de 0 y no hay porcentaje
+	if len(c.Evaluacion) != 3 {
+		t.Fatalf("evaluación = %+v", c.Evaluacion)
+	}
+	for i, want := range []struct {
+		concepto string
+		b        float64
+	}{{"VAN", 5000}, {"TIR", 12}, {"TREMA", 10}} {
+		if c.Evaluacion[i].Concepto != want.concepto {
+			t.Errorf("evaluación %d = %q, want %q", i, c.Evaluacion[i].Concepto, want.concepto)
+		}
+		revisarValor(t, want.concepto, c.Evaluacion[i], 0, want.b, nil)
+	}
+
+	// celdas de la unión de las dos matrices, ordenadas por volumen y costo
+	celdas := []struct {
+		volumen, costo, a, b float64
+		pct                  *float64
+	}{
+		{-10, 5, 0, 80, nil}, // solo en b
+		{0, 5, 0, 30, nil},   // valor 0 en a
+		{10, 0, 200, 150, pct(-25)},
+	}
+	if len(c.Sensibilidad) != len(celdas) {
+		t.Fatalf("sensibilidad = %+v", c.Sensibilidad)
+	}
+	for i, want := range celdas {
+		got := c.Sensibilidad[i]
+		if got.Volumen != want.volumen || got.Costo != want.costo {
+			t.Errorf("celda %d = (%v, %v), want (%v, %v)", i, got.Volumen, got.Costo, want.volumen, want.costo)
+		}
+		revisarValor(t, "celda", got.ValorComparado, want.a, want.b, want.pct)
+	}
+}
@@ -0,0 +1,119 @@
+package reportes
+
+import (
+	"sort"
+
+	"github.com/JostinAlvaradoS/liveplan_backend_go/internal/procedimientos"
+)
+
+// ComparacionEscenarios pone lado a lado la base y los escenarios de un plan.
+// Los valores de cada renglón siguen el orden de Escenarios.
+type ComparacionEscenarios struct {
+	Escenarios []ResumenEscenario `json:"escenarios"`
+	Estados    []EstadoEscenarios `json:"estados"`
+}
+
+// ResumenEscenario es la evaluación de un escenario y su diferencia con la
+// base. ID es nil en la base.
+type ResumenEscenario struct {
+	ID            *uint   `json:"id"`
+	Nombre        string  `json:"nombre"`
+	VAN           float64 `json:"van"`
+	TIR           float64 `json:"tir"`
+	TREMA         float64 `json:"trema"`
+	DiferenciaVAN float64 `json:"diferencia_van"`
+	DiferenciaTIR float64 `json:"diferencia_tir"`
+}
+
+// EstadoEscenarios es un estado financiero con los totales anuales de cada escenario
+type EstadoEscenarios struct {
+	Estado    string              `json:"estado"`
+	Renglones []RenglonEscenarios `json:"renglones"`
+}
+
+// RenglonEscenarios es un concepto con sus totales por año en cada escenario
+type RenglonEscenarios struct {
+	Concepto string              `json:"concepto"`
+	Anios    []ValoresEscenarios `json:"anios"`
+}
+
+// ValoresEscenarios son los totales de un año, uno por escenario
+type ValoresEscenarios struct {
+	Anio    int       `json:"anio"`
+	Valores []float64 `json:"valores"`
+}
+
+// CompararEscenarios arma la comparación a partir de CalcularEscenarios; el
+// primer resultado es la base
+func CompararEscenarios(resultados []procedimientos.ResultadoEscenario) ComparacionEscenarios {
+	var out ComparacionEscenarios
+	if len(resultados) == 0 {
+		return out
+	}
+	base := resultados[0].Salidas.Evaluacion
+	estados := make([][]estado, len(resultados))
+	for i, r := range resultados {
+		ev := r.Salidas.Evaluacion
+		resumen := ResumenEscenario{
+			Nombre:        "Base",
+			VAN:           ev.VAN,
+			TIR:           ev.TIR,
+			TREMA:         ev.TREMA,
+			DiferenciaVAN: ev.VAN - base.VAN,
+			DiferenciaTIR: ev.TIR - base.TIR,
+		}
+		if r.Escenario != nil {
+			id := r.Escenario.ID
+			resumen.ID, resumen.Nombre = &id, r.Escenario.Nombre
+		}
+		out.Escenarios = append(out.Escenarios, resumen)
+		s := r.Salidas
+		estados[i] = []estado{estadoResultados(s.EstadoResultados), flujoEfectivo(s.FlujoEfectivo), balanceGeneral(s.BalanceGeneral)}
+	}
+	for k := range estados[0] {
+		porEscenario := make([]estado, len(estados))
+		for i := range estados {
+			porEscenario[i] = estados[i][k]
+		}
+		out.Estados = append(out.Estados, estadoPorEscenario(porEscenario))
+	}
+	return out
+}
+
+// estadoPorEscenario junta los totales anuales del mismo estado en cada
+// escenario; un año que falta en un escenario cuenta como 0
+func estadoPorEscenario(estados []estado) EstadoEscenarios {
+	anios := map[int]bool{}
+	for _, e := range estados {
+		for _, anio := range e.anios() {
+			anios[anio] = true
+		}
+	}
+	var orden []int
+	for anio := range anios {
+		orden = append(orden, anio)
+	}
+	sort.Ints(orden)
+
+	// totales[i][a] son los totales del escenario i en el año orden[a]
+	totales := make([][][]float64, len(estados))
+	for i, e := range estados {
+		totales[i] = make([][]float64, len(orden))
+		for a, anio := range orden {
+			totales[i][a] = e.totalAnual(e.delAnio(anio))
+		}
+	}
+	out := EstadoEscenarios{Estado: estados[0].nombre}
+	for j, c := range estados[0].conceptos {
+		r := RenglonEscenarios{Concepto: c.etiqueta}
+		for a, anio := range orden {
+			v := ValoresEscenarios{Anio: anio, Valores: make([]float64, len(estados))}
+			for i := range estados {
+				v.Valores[i] = totales[i][a][j]
+			}
+			r.Anios = append(r.Anios, v)
+		}
+		out.Renglones = append(out.Renglones, r)
+	}
+	return out
+}
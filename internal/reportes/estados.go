@@ -0,0 +1,228 @@
+// Package reportes arma los reportes descargables de un plan (Excel, PDF) a
+// partir del documento que produce procedimientos.ExportarPlan con calculados.
+package reportes
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/JostinAlvaradoS/liveplan_backend_go/internal/models"
+)
+
+// agregado indica cómo se resume un concepto mensual en el total del año
+type agregado int
+
+const (
+	// sumar: flujos del periodo (ventas, egresos...), como sumas_anuales de los List*ByPlan
+	sumar agregado = iota
+	// inicio: saldo al comienzo del año (efectivo inicial)
+	inicio
+	// cierre: saldo al final del año (efectivo final, cuentas del balance)
+	cierre
+)
+
+// concepto es una fila de un estado financiero
+type concepto struct {
+	etiqueta string
+	agregado agregado
+	// total marca los renglones de subtotal (utilidad bruta, total activo...)
+	total bool
+}
+
+// filaMensual son los valores de todos los conceptos en un mes
+type filaMensual struct {
+	anio, mes int
+	valores   []float64
+}
+
+// estado es un estado financiero mensual listo para presentarse
+type estado struct {
+	nombre    string
+	titulo    string
+	conceptos []concepto
+	filas     []filaMensual
+}
+
+// definicion asocia un concepto con el campo del modelo que lo contiene
+type definicion[T any] struct {
+	concepto
+	valor func(*T) float64
+}
+
+func armarEstado[T any](nombre, titulo string, items []T, periodo func(*T) (int, int), defs []definicion[T]) estado {
+	e := estado{nombre: nombre, titulo: titulo}
+	for _, d := range defs {
+		e.conceptos = append(e.conceptos, d.concepto)
+	}
+	for i := range items {
+		f := filaMensual{valores: make([]float64, len(defs))}
+		f.anio, f.mes = periodo(&items[i])
+		for j, d := range defs {
+			f.valores[j] = d.valor(&items[i])
+		}
+		e.filas = append(e.filas, f)
+	}
+	sort.SliceStable(e.filas, func(i, j int) bool {
+		if e.filas[i].anio != e.filas[j].anio {
+			return e.filas[i].anio < e.filas[j].anio
+		}
+		return e.filas[i].mes < e.filas[j].mes
+	})
+	return e
+}
+
+// anios devuelve los años presentes, ordenados
+func (e estado) anios() []int {
+	var out []int
+	for _, f := range e.filas {
+		if len(out) == 0 || out[len(out)-1] != f.anio {
+			out = append(out, f.anio)
+		}
+	}
+	return out
+}
+
+// delAnio devuelve los meses del año en orden
+func (e estado) delAnio(anio int) []filaMensual {
+	var out []filaMensual
+	for _, f := range e.filas {
+		if f.anio == anio {
+			out = append(out, f)
+		}
+	}
+	return out
+}
+
+// totalAnual resume los meses de un año según el agregado de cada concepto
+func (e estado) totalAnual(meses []filaMensual) []float64 {
+	out := make([]float64, len(e.conceptos))
+	if len(meses) == 0 {
+		return out
+	}
+	for j, c := range e.conceptos {
+		switch c.agregado {
+		case inicio:
+			out[j] = meses[0].valores[j]
+		case cierre:
+			out[j] = meses[len(meses)-1].valores[j]
+		default:
+			for _, m := range meses {
+				out[j] += m.valores[j]
+			}
+		}
+	}
+	return out
+}
+
+func estadoResultados(items []models.EstadoResultados) estado {
+	v := func(etiqueta string, total bool, f func(*models.EstadoResultados) float64) definicion[models.EstadoResultados] {
+		return definicion[models.EstadoResultados]{concepto{etiqueta, sumar, total}, f}
+	}
+	return armarEstado("EstadoResultados", "Estado de resultados", items,
+		func(er *models.EstadoResultados) (int, int) { return er.Anio, er.Mes },
+		[]definicion[models.EstadoResultados]{
+			v("Ventas", false, func(er *models.EstadoResultados) float64 { return er.Ventas }),
+			v("Costo de ventas", false, func(er *models.EstadoResultados) float64 { return er.CostosVentas }),
+			v("Utilidad bruta", true, func(er *models.EstadoResultados) float64 { return er.UtilidadBruta }),
+			v("Gastos de venta y administración", false, func(er *models.EstadoResultados) float64 { return er.GastosVentaAdm }),
+			v("Depreciación", false, func(er *models.EstadoResultados) float64 { return er.Depreciacion }),
+			v("Amortización", false, func(er *models.EstadoResultados) float64 { return er.Amortizacion }),
+			v("Utilidad antes de intereses e impuestos", true, func(er *models.EstadoResultados) float64 { return er.UtilidadprevioIntImp }),
+			v("Gastos financieros", false, func(er *models.EstadoResultados) float64 { return er.GastosFinancieros }),
+			v("Utilidad antes de PTU", true, func(er *models.EstadoResultados) float64 { return er.UtilidadAntesPTU }),
+			v("PTU", false, func(er *models.EstadoResultados) float64 { return er.PTU }),
+			v("Utilidad antes de impuestos", true, func(er *models.EstadoResultados) float64 { return er.UtilidadAntesImpuestos }),
+			v("ISR", false, func(er *models.EstadoResultados) float64 { return er.ISR }),
+			v("Utilidad neta", true, func(er *models.EstadoResultados) float64 { return er.UtilidadNeta }),
+		})
+}
+
+func flujoEfectivo(items []models.FlujoEfectivo) estado {
+	v := func(etiqueta string, ag agregado, total bool, f func(*models.FlujoEfectivo) float64) definicion[models.FlujoEfectivo] {
+		return definicion[models.FlujoEfectivo]{concepto{etiqueta, ag, total}, f}
+	}
+	return armarEstado("FlujoEfectivo", "Flujo de efectivo", items,
+		func(fe *models.FlujoEfectivo) (int, int) { return fe.Anio, fe.Mes },
+		[]definicion[models.FlujoEfectivo]{
+			v("Efectivo inicial", inicio, true, func(fe *models.FlujoEfectivo) float64 { return fe.EfectivoInicial }),
+			v("Ventas de contado", sumar, false, func(fe *models.FlujoEfectivo) float64 { return fe.Ingresos_VentaContado }),
+			v("Cobros de ventas a crédito", sumar, false, func(fe *models.FlujoEfectivo) float64 { return fe.Ingresos_CobrosVentasCredito }),
+			v("Otros ingresos", sumar, false, func(fe *models.FlujoEfectivo) float64 { return fe.Ingresos_OtrosIngresos }),
+			v("Préstamos", sumar, false, func(fe *models.FlujoEfectivo) float64 { return fe.Ingresos_Prestamos }),
+			v("Aportes de capital", sumar, false, func(fe *models.FlujoEfectivo) float64 { return fe.Ingresos_AportesCapital }),
+			v("Total ingresos", sumar, true, func(fe *models.FlujoEfectivo) float64 { return fe.Ingresos }),
+			v("Compras y costos de contado", sumar, false, func(fe *models.FlujoEfectivo) float64 { return fe.Egresos_ComprasCostosContado }),
+			v("Compras y costos a crédito", sumar, false, func(fe *models.FlujoEfectivo) float64 { return fe.Egresos_ComprasCostosCredito }),
+			v("Gastos de operación", sumar, false, func(fe *models.FlujoEfectivo) float64 { return fe.Egresos_GastosOperacion }),
+			v("Intereses", sumar, false, func(fe *models.FlujoEfectivo) float64 { return fe.Egresos_Intereses }),
+			v("Pagos de préstamos", sumar, false, func(fe *models.FlujoEfectivo) float64 { return fe.Egresos_PagosPrestamos }),
+			v("Pagos al SRI", sumar, false, func(fe *models.FlujoEfectivo) float64 { return fe.Egresos_PagosSRI }),
+			v("Pago de PTU", sumar, false, func(fe *models.FlujoEfectivo) float64 { return fe.Egresos_PagoPTU }),
+			v("Total egresos", sumar, true, func(fe *models.FlujoEfectivo) float64 { return fe.Egresos }),
+			v("Aumento de inventarios", sumar, false, func(fe *models.FlujoEfectivo) float64 { return fe.AumentoInventarios }),
+			v("Flujo de caja", sumar, true, func(fe *models.FlujoEfectivo) float64 { return fe.FlujoCaja }),
+			v("Efectivo final", cierre, true, func(fe *models.FlujoEfectivo) float64 { return fe.EfectivoFinal }),
+		})
+}
+
+// balanceGeneral: las cuentas del balance son saldos, el total del año es el
+// saldo al cierre (último mes) y no la suma de los meses
+func balanceGeneral(items []models.BalanceGeneral) estado {
+	v := func(etiqueta string, total bool, f func(*models.BalanceGeneral) float64) definicion[models.BalanceGeneral] {
+		return definicion[models.BalanceGeneral]{concepto{etiqueta, cierre, total}, f}
+	}
+	return armarEstado("BalanceGeneral", "Balance general", items,
+		func(bg *models.BalanceGeneral) (int, int) { return bg.Anio, bg.Mes },
+		[]definicion[models.BalanceGeneral]{
+			v("Efectivo", false, func(bg *models.BalanceGeneral) float64 { return bg.Corrientes_Efectivo }),
+			v("Cuentas por cobrar", false, func(bg *models.BalanceGeneral) float64 { return bg.Corrientes_CuentasxCobrar }),
+			v("Inventarios", false, func(bg *models.BalanceGeneral) float64 { return bg.Corrientes_Inventarios }),
+			v("Otros activos corrientes", false, func(bg *models.BalanceGeneral) float64 { return bg.Corrientes_Otros }),
+			v("Activo corriente", true, func(bg *models.BalanceGeneral) float64 { return bg.Corrientes_Suma }),
+			v("Activo no corriente", true, func(bg *models.BalanceGeneral) float64 { return bg.NoCorrientes_Suma }),
+			v("Total activo", true, func(bg *models.BalanceGeneral) float64 { return bg.TotalActivo }),
+			v("Proveedores", false, func(bg *models.BalanceGeneral) float64 { return bg.PasivoProveedoresCortoPlazo }),
+			v("Préstamos a corto plazo", false, func(bg *models.BalanceGeneral) float64 { return bg.PasivoPrestamosCortoPlazo }),
+			v("Cuentas por pagar", false, func(bg *models.BalanceGeneral) float64 { return bg.PasivoCuentasxPagarCortoPlazo }),
+			v("Otros pasivos a corto plazo", false, func(bg *models.BalanceGeneral) float64 { return bg.PasivoOtrosCortoPlazo }),
+			v("Pasivo a corto plazo", true, func(bg *models.BalanceGeneral) float64 { return bg.PasivoCortoPlazo_Suma }),
+			v("Préstamos a largo plazo", false, func(bg *models.BalanceGeneral) float64 { return bg.PasivoPrestamosLargoPlazo }),
+			v("Otros pasivos a largo plazo", false, func(bg *models.BalanceGeneral) float64 { return bg.PasivoOtrosLargoPlazo }),
+			v("Pasivo a largo plazo", true, func(bg *models.BalanceGeneral) float64 { return bg.PasivoLargoPlazo_Suma }),
+			v("Total pasivo", true, func(bg *models.BalanceGeneral) float64 { return bg.TotalPasivo }),
+			v("Capital social", false, func(bg *models.BalanceGeneral) float64 { return bg.CapitalSocial }),
+			v("Capital adicional", false, func(bg *models.BalanceGeneral) float64 { return bg.CapitalAdicional }),
+			v("Utilidades retenidas", false, func(bg *models.BalanceGeneral) float64 { return bg.UtilidadesRetenidas }),
+			v("Utilidad del ejercicio", false, func(bg *models.BalanceGeneral) float64 { return bg.UtilidadDelEjercicio }),
+			v("Total capital contable", true, func(bg *models.BalanceGeneral) float64 { return bg.TotalCapitalContable }),
+		})
+}
+
+// numero convierte los importes de ConceptosEvaluacion, guardados como texto
+func numero(s string) (float64, bool) {
+	s = strings.TrimSpace(strings.ReplaceAll(s, ",", ""))
+	if s == "" {
+		return 0, false
+	}
+	v, err := strconv.ParseFloat(s, 64)
+	return v, err == nil
+}
+
+// ejes devuelve los valores distintos y ordenados de volumen y costo de la matriz
+func ejes(celdas []models.AnalisisSensibilidad) (volumenes, costos []float64) {
+	vs, cs := map[float64]bool{}, map[float64]bool{}
+	for _, c := range celdas {
+		if !vs[c.Volumen] {
+			vs[c.Volumen] = true
+			volumenes = append(volumenes, c.Volumen)
+		}
+		if !cs[c.Costo] {
+			cs[c.Costo] = true
+			costos = append(costos, c.Costo)
+		}
+	}
+	sort.Float64s(volumenes)
+	sort.Float64s(costos)
+	return volumenes, costos
+}
@@ -0,0 +1,316 @@
+package reportes
+
+import (
+	"fmt"
+
+	"github.com/JostinAlvaradoS/liveplan_backend_go/internal/models"
+	"github.com/JostinAlvaradoS/liveplan_backend_go/internal/procedimientos"
+	"github.com/JostinAlvaradoS/liveplan_backend_go/internal/xlsx"
+)
+
+const sinDatos = "Sin datos: ejecute el recálculo del plan"
+
+// LibroExcel arma el libro con los estados financieros del plan: una hoja por
+// estado (columnas mensuales agrupadas por año, con el total de cada año),
+// la tabla de amortización del préstamo, las depreciaciones, los conceptos de
+// evaluación con VAN/TIR/TREMA y la matriz de sensibilidad. doc debe traer
+// los calculados (procedimientos.ExportarPlan con calculados=true).
+func LibroExcel(doc procedimientos.DocumentoPlan) *xlsx.Libro {
+	c := doc.Calculados
+	if c == nil {
+		c = &procedimientos.CalculadosPlan{}
+	}
+	l := xlsx.Nuevo()
+	hojaEstado(l, doc, estadoResultados(c.EstadoResultados))
+	hojaEstado(l, doc, flujoEfectivo(c.FlujoEfectivo))
+	hojaEstado(l, doc, balanceGeneral(c.BalanceGeneral))
+	hojaPrestamo(l, doc, c.PrestamoCuotas)
+	hojaDepreciacion(l, doc, c.Depreciaciones)
+	hojaEvaluacion(l, doc, c.ConceptosEvaluacion)
+	hojaSensibilidad(l, doc, doc.Entradas.AnalisisSensibilidad)
+	return l
+}
+
+// encabezado escribe el título de la hoja y devuelve la hoja
+func encabezado(l *xlsx.Libro, doc procedimientos.DocumentoPlan, nombre, titulo string) *xlsx.Hoja {
+	h := l.Hoja(nombre)
+	h.Fila(xlsx.Texto(titulo, xlsx.EstiloTitulo))
+	h.Fila(xlsx.Texto(doc.Plan.Problematica, xlsx.EstiloNormal))
+	h.Fila()
+	return h
+}
+
+func etiquetaMes(mes int) string {
+	if mes == 0 {
+		return "Inicial"
+	}
+	return fmt.Sprintf("Mes %d", mes)
+}
+
+// hojaEstado: una fila por concepto; por cada año sus meses y la columna "Total año N"
+func hojaEstado(l *xlsx.Libro, doc procedimientos.DocumentoPlan, e estado) {
+	h := encabezado(l, doc, e.nombre, e.titulo)
+	if len(e.filas) == 0 {
+		h.Fila(xlsx.Texto(sinDatos, xlsx.EstiloNormal))
+		return
+	}
+	h.Ancho(1, 40)
+
+	anios := e.anios()
+	grupos := make([]xlsx.Celda, 0)
+	meses := make([]xlsx.Celda, 0)
+	grupos = append(grupos, xlsx.Texto("Concepto", xlsx.EstiloEncabezado))
+	meses = append(meses, xlsx.Texto("", xlsx.EstiloEncabezado))
+	type rango struct{ desde, hasta int }
+	var combinar []rango
+	columnas := make([][]filaMensual, len(anios))
+	totales := make([][]float64, len(anios))
+	col := 2
+	for i, anio := range anios {
+		columnas[i] = e.delAnio(anio)
+		totales[i] = e.totalAnual(columnas[i])
+		combinar = append(combinar, rango{col, col + len(columnas[i])})
+		for j, m := range columnas[i] {
+			if j == 0 {
+				grupos = append(grupos, xlsx.Texto(fmt.Sprintf("Año %d", anio), xlsx.EstiloEncabezado))
+			} else {
+				grupos = append(grupos, xlsx.Celda{Estilo: xlsx.EstiloEncabezado})
+			}
+			meses = append(meses, xlsx.Texto(etiquetaMes(m.mes), xlsx.EstiloEncabezado))
+			h.Ancho(col, 14)
+			col++
+		}
+		grupos = append(grupos, xlsx.Celda{Estilo: xlsx.EstiloEncabezado})
+		meses = append(meses, xlsx.Texto(fmt.Sprintf("Total año %d", anio), xlsx.EstiloEncabezado))
+		h.Ancho(col, 16)
+		col++
+	}
+	filaGrupos := h.Fila(grupos...)
+	h.Fila(meses...)
+	for _, r := range combinar {
+		h.Combinar(filaGrupos, r.desde, filaGrupos, r.hasta)
+	}
+	h.Combinar(filaGrupos, 1, filaGrupos+1, 1)
+	h.Inmovilizar(filaGrupos+2, 2)
+
+	for j, c := range e.conceptos {
+		etiqueta := xlsx.EstiloNormal
+		if c.total {
+			etiqueta = xlsx.EstiloEncabezado
+		}
+		celdas := []xlsx.Celda{xlsx.Texto(c.etiqueta, etiqueta)}
+		for i := range anios {
+			for _, m := range columnas[i] {
+				celdas = append(celdas, xlsx.Numero(m.valores[j], xlsx.EstiloMoneda))
+			}
+			celdas = append(celdas, xlsx.Numero(totales[i][j], xlsx.EstiloMonedaTotal))
+		}
+		h.Fila(celdas...)
+	}
+}
+
+// hojaPrestamo: una fila por cuota y un subtotal al cerrar cada año
+func hojaPrestamo(l *xlsx.Libro, doc procedimientos.DocumentoPlan, cuotas []models.PrestamoCuotas) {
+	h := encabezado(l, doc, "PrestamoCuotas", "Amortización del préstamo")
+	if len(cuotas) == 0 {
+		h.Fila(xlsx.Texto("El plan no tiene préstamo", xlsx.EstiloNormal))
+		return
+	}
+	titulos := []string{"Periodo", "Año", "Mes", "Saldo inicial", "Interés", "Amortización", "Cuota", "Saldo pendiente"}
+	cab := make([]xlsx.Celda, len(titulos))
+	for i, t := range titulos {
+		cab[i] = xlsx.Texto(t, xlsx.EstiloEncabezado)
+		h.Ancho(i+1, 16)
+	}
+	fila := h.Fila(cab...)
+	h.Inmovilizar(fila+1, 1)
+
+	var interes, amortizacion, cuota float64
+	for i, q := range cuotas {
+		h.Fila(
+			xlsx.Numero(float64(q.PeriodoMes), xlsx.EstiloNormal),
+			xlsx.Numero(float64(q.Anio), xlsx.EstiloNormal),
+			xlsx.Numero(float64(q.Mes), xlsx.EstiloNormal),
+			xlsx.Numero(q.SaldoInicial, xlsx.EstiloMoneda),
+			xlsx.Numero(q.Interes, xlsx.EstiloMoneda),
+			xlsx.Numero(q.Amortizacion, xlsx.EstiloMoneda),
+			xlsx.Numero(q.CuotaTotal, xlsx.EstiloMoneda),
+			xlsx.Numero(q.SaldoPendiente, xlsx.EstiloMoneda),
+		)
+		interes += q.Interes
+		amortizacion += q.Amortizacion
+		cuota += q.CuotaTotal
+		if i == len(cuotas)-1 || cuotas[i+1].Anio != q.Anio {
+			h.Fila(
+				xlsx.Texto(fmt.Sprintf("Total año %d", q.Anio), xlsx.EstiloEncabezado),
+				xlsx.Celda{Estilo: xlsx.EstiloEncabezado},
+				xlsx.Celda{Estilo: xlsx.EstiloEncabezado},
+				xlsx.Celda{Estilo: xlsx.EstiloEncabezado},
+				xlsx.Numero(interes, xlsx.EstiloMonedaTotal),
+				xlsx.Numero(amortizacion, xlsx.EstiloMonedaTotal),
+				xlsx.Numero(cuota, xlsx.EstiloMonedaTotal),
+				xlsx.Numero(q.SaldoPendiente, xlsx.EstiloMonedaTotal),
+			)
+			interes, amortizacion, cuota = 0, 0, 0
+		}
+	}
+}
+
+// hojaDepreciacion: una fila por activo con su depreciación de cada año
+func hojaDepreciacion(l *xlsx.Libro, doc procedimientos.DocumentoPlan, deps []models.Depreciacion) {
+	h := encabezado(l, doc, "Depreciacion", "Depreciación de activos")
+	if len(deps) == 0 {
+		h.Fila(xlsx.Texto(sinDatos, xlsx.EstiloNormal))
+		return
+	}
+	detalles := make(map[uint]models.DetalleInversionInicial, len(doc.Entradas.DetallesInversion))
+	for _, d := range doc.Entradas.DetallesInversion {
+		detalles[d.ID] = d
+	}
+	horizonte := doc.Plan.HorizonteAnios
+	for _, d := range deps {
+		for _, a := range d.Anios {
+			if a.Anio > horizonte {
+				horizonte = a.Anio
+			}
+		}
+	}
+
+	cab := []xlsx.Celda{
+		xlsx.Texto("Elemento", xlsx.EstiloEncabezado),
+		xlsx.Texto("Importe", xlsx.EstiloEncabezado),
+		xlsx.Texto("Vida útil (meses)", xlsx.EstiloEncabezado),
+		xlsx.Texto("Depreciación mensual", xlsx.EstiloEncabezado),
+	}
+	for a := 1; a <= horizonte; a++ {
+		cab = append(cab, xlsx.Texto(fmt.Sprintf("Año %d", a), xlsx.EstiloEncabezado))
+	}
+	cab = append(cab, xlsx.Texto("Valor de rescate", xlsx.EstiloEncabezado))
+	h.Ancho(1, 32)
+	for i := 2; i <= len(cab); i++ {
+		h.Ancho(i, 16)
+	}
+	fila := h.Fila(cab...)
+	h.Inmovilizar(fila+1, 2)
+
+	totales := make([]float64, horizonte+2)
+	for _, d := range deps {
+		det := detalles[d.DetalleInversionID]
+		celdas := []xlsx.Celda{
+			xlsx.Texto(det.Elemento, xlsx.EstiloNormal),
+			xlsx.Numero(det.Importe, xlsx.EstiloMoneda),
+			xlsx.Numero(float64(det.VidaUtil), xlsx.EstiloNormal),
+			opcional(d.DepreciacionMensual),
+		}
+		porAnio := make(map[int]*float64, len(d.Anios))
+		for _, a := range d.Anios {
+			porAnio[a.Anio] = a.Valor
+		}
+		for a := 1; a <= horizonte; a++ {
+			celdas = append(celdas, opcional(porAnio[a]))
+			if v := porAnio[a]; v != nil {
+				totales[a] += *v
+			}
+		}
+		celdas = append(celdas, opcional(d.ValorRescate))
+		if d.ValorRescate != nil {
+			totales[horizonte+1] += *d.ValorRescate
+		}
+		if d.DepreciacionMensual != nil {
+			totales[0] += *d.DepreciacionMensual
+		}
+		h.Fila(celdas...)
+	}
+	celdas := []xlsx.Celda{
+		xlsx.Texto("Total", xlsx.EstiloEncabezado),
+		xlsx.Celda{Estilo: xlsx.EstiloEncabezado},
+		xlsx.Celda{Estilo: xlsx.EstiloEncabezado},
+	}
+	for _, t := range totales {
+		celdas = append(celdas, xlsx.Numero(t, xlsx.EstiloMonedaTotal))
+	}
+	h.Fila(celdas...)
+}
+
+func opcional(v *float64) xlsx.Celda {
+	if v == nil {
+		return xlsx.Celda{Estilo: xlsx.EstiloMoneda}
+	}
+	return xlsx.Numero(*v, xlsx.EstiloMoneda)
+}
+
+// hojaEvaluacion: los flujos por año de ConceptosEvaluacion y el resultado VAN/TIR/TREMA
+func hojaEvaluacion(l *xlsx.Libro, doc procedimientos.DocumentoPlan, conceptos []models.ConceptosEvaluacion) {
+	h := encabezado(l, doc, "ConceptosEvaluacion", "Evaluación del proyecto")
+	if len(conceptos) == 0 {
+		h.Fila(xlsx.Texto(sinDatos, xlsx.EstiloNormal))
+	} else {
+		titulos := []string{"Año", "Flujo de efectivo nominal", "Valor de rescate", "Total flujo de efectivo", "Valor actual"}
+		cab := make([]xlsx.Celda, len(titulos))
+		for i, t := range titulos {
+			cab[i] = xlsx.Texto(t, xlsx.EstiloEncabezado)
+			h.Ancho(i+1, 24)
+		}
+		h.Fila(cab...)
+		for _, c := range conceptos {
+			h.Fila(
+				xlsx.Numero(float64(c.Anio), xlsx.EstiloNormal),
+				importe(c.FlujoEfectivoNominal),
+				importe(c.ValorRescate),
+				importe(c.TotalFlujoEfectivo),
+				importe(c.ValorActualFlujosFuturos),
+			)
+		}
+	}
+	if ev := doc.Entradas.EvaluacionProyecto; ev != nil {
+		h.Fila()
+		h.Fila(xlsx.Texto("VAN", xlsx.EstiloEncabezado), xlsx.Numero(ev.VAN, xlsx.EstiloMonedaTotal))
+		// TIR y TREMA se guardan en porcentaje (12.5 = 12.5 %)
+		h.Fila(xlsx.Texto("TIR", xlsx.EstiloEncabezado), xlsx.Numero(ev.TIR/100, xlsx.EstiloPorcentaje))
+		h.Fila(xlsx.Texto("TREMA", xlsx.EstiloEncabezado), xlsx.Numero(ev.TREMA/100, xlsx.EstiloPorcentaje))
+	}
+}
+
+// importe escribe como número los importes que ConceptosEvaluacion guarda como texto
+func importe(s string) xlsx.Celda {
+	if v, ok := numero(s); ok {
+		return xlsx.Numero(v, xlsx.EstiloMoneda)
+	}
+	return xlsx.Texto(s, xlsx.EstiloNormal)
+}
+
+// hojaSensibilidad: matriz del VAN con los choques de volumen en filas y de costo en columnas
+func hojaSensibilidad(l *xlsx.Libro, doc procedimientos.DocumentoPlan, celdas []models.AnalisisSensibilidad) {
+	h := encabezado(l, doc, "AnalisisSensibilidad", "Análisis de sensibilidad (VAN)")
+	if len(celdas) == 0 {
+		h.Fila(xlsx.Texto("El plan no tiene matriz de sensibilidad", xlsx.EstiloNormal))
+		return
+	}
+	volumenes, costos := ejes(celdas)
+	valor := make(map[[2]float64]float64, len(celdas))
+	for _, c := range celdas {
+		valor[[2]float64{c.Volumen, c.Costo}] = c.Valor
+	}
+
+	cab := []xlsx.Celda{xlsx.Texto("Volumen \\ Costo", xlsx.EstiloEncabezado)}
+	for _, c := range costos {
+		cab = append(cab, xlsx.Numero(c/100, xlsx.EstiloPorcentaje))
+	}
+	h.Ancho(1, 18)
+	for i := range costos {
+		h.Ancho(i+2, 16)
+	}
+	fila := h.Fila(cab...)
+	h.Inmovilizar(fila+1, 2)
+	for _, v := range volumenes {
+		f := []xlsx.Celda{xlsx.Numero(v/100, xlsx.EstiloPorcentaje)}
+		for _, c := range costos {
+			if val, ok := valor[[2]float64{v, c}]; ok {
+				f = append(f, xlsx.Numero(val, xlsx.EstiloMoneda))
+			} else {
+				f = append(f, xlsx.Celda{})
+			}
+		}
+		h.Fila(f...)
+	}
+}
@@ -0,0 +1,154 @@
+package reportes
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"io"
+	"reflect"
+	"strconv"
+	"testing"
+
+	"github.com/JostinAlvaradoS/liveplan_backend_go/internal/models"
+	"github.com/JostinAlvaradoS/liveplan_backend_go/internal/procedimientos"
+)
+
+// formatoMoneda es el numFmtId del formato de moneda de styles.xml
+const formatoMoneda = 164
+
+// documentoDePrueba es un plan de un año con dos meses calculados y un préstamo
+func documentoDePrueba() procedimientos.DocumentoPlan {
+	return procedimientos.DocumentoPlan{
+		Plan: procedimientos.PlanExportado{Problematica: "Cafetería <centro>", HorizonteAnios: 1},
+		Calculados: &procedimientos.CalculadosPlan{
+			EstadoResultados: []models.EstadoResultados{
+				{Anio: 1, Mes: 1, Ventas: 1000, CostosVentas: 400, UtilidadBruta: 600, UtilidadNeta: 450},
+				{Anio: 1, Mes: 2, Ventas: 1500.25, CostosVentas: 600, UtilidadBruta: 900.25, UtilidadNeta: 675},
+			},
+			FlujoEfectivo: []models.FlujoEfectivo{
+				{Anio: 1, Mes: 1, EfectivoInicial: 500, FlujoCaja: 100, EfectivoFinal: 600},
+				{Anio: 1, Mes: 2, EfectivoInicial: 600, FlujoCaja: -50, EfectivoFinal: 550},
+			},
+			BalanceGeneral: []models.BalanceGeneral{{Anio: 1, Mes: 1, Corrientes_Efectivo: 600, TotalActivo: 600}},
+			PrestamoCuotas: []models.PrestamoCuotas{
+				{PeriodoMes: 1, Anio: 1, Mes: 1, SaldoInicial: 1000, Interes: 10, Amortizacion: 90, CuotaTotal: 100, SaldoPendiente: 910},
+			},
+		},
+	}
+}
+
+type celdaXML struct {
+	Ref    string `xml:"r,attr"`
+	Estilo int    `xml:"s,attr"`
+	Tipo   string `xml:"t,attr"`
+	V      string `xml:"v"`
+	Texto  string `xml:"is>t"`
+}
+
+type hojaXML struct {
+	Filas []struct {
+		Celdas []celdaXML `xml:"c"`
+	} `xml:"sheetData>row"`
+}
+
+// leerXML decodifica el archivo nombre del zip en dest
+func leerXML(t *testing.T, z *zip.Reader, nombre string, dest interface{}) {
+	t.Helper()
+	f, err := z.Open(nombre)
+	if err != nil {
+		t.Fatalf("%s: %v", nombre, err)
+	}
+	defer f.Close()
+	raw, err := io.ReadAll(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := xml.Unmarshal(raw, dest); err != nil {
+		t.Fatalf("%s: %v", nombre, err)
+	}
+}
+
+// filaDe devuelve las celdas de la fila cuya primera celda es el texto etiqueta
+func filaDe(t *testing.T, h hojaXML, etiqueta string) []celdaXML {
+	t.Helper()
+	for _, f := range h.Filas {
+		if len(f.Celdas) > 0 && f.Celdas[0].Texto == etiqueta {
+			return f.Celdas[1:]
+		}
+	}
+	t.Fatalf("sin fila %q", etiqueta)
+	return nil
+}
+
+func TestLibroExcel(t *testing.T) {
+	var buf bytes.Buffer
+	if err := LibroExcel(documentoDePrueba()).Escribir(&buf); err != nil {
+		t.Fatal(err)
+	}
+	z, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatalf("el libro no es un zip: %v", err)
+	}
+
+	var libro struct {
+		Hojas []struct {
+			Nombre string `xml:"name,attr"`
+		} `xml:"sheets>sheet"`
+	}
+	leerXML(t, z, "xl/workbook.xml", &libro)
+	var nombres []string
+	for _, h := range libro.Hojas {
+		nombres = append(nombres, h.Nombre)
+	}
+	want := []string{"EstadoResultados", "FlujoEfectivo", "BalanceGeneral", "PrestamoCuotas", "Depreciacion", "ConceptosEvaluacion", "AnalisisSensibilidad"}
+	if !reflect.DeepEqual(nombres, want) {
+		t.Fatalf("hojas = %v, want %v", nombres, want)
+	}
+
+	var estilos struct {
+		Xfs []struct {
+			Formato int `xml:"numFmtId,attr"`
+		} `xml:"cellXfs>xf"`
+	}
+	leerXML(t, z, "xl/styles.xml", &estilos)
+	formato := func(c celdaXML) int {
+		if c.Estilo < 0 || c.Estilo >= len(estilos.Xfs) {
+			t.Fatalf("%s: estilo %d inexistente", c.Ref, c.Estilo)
+		}
+		return estilos.Xfs[c.Estilo].Formato
+	}
+	// los importes son celdas numéricas (<v>, sin t) con formato de moneda
+	importes := func(hoja, etiqueta string, celdas []celdaXML, want []float64) {
+		t.Helper()
+		if len(celdas) != len(want) {
+			t.Fatalf("%s %q: %d celdas, want %d", hoja, etiqueta, len(celdas), len(want))
+		}
+		for i, c := range celdas {
+			v, err := strconv.ParseFloat(c.V, 64)
+			if c.Tipo != "" || err != nil || v != want[i] {
+				t.Errorf("%s %q %s: t=%q v=%q, want número %v", hoja, etiqueta, c.Ref, c.Tipo, c.V, want[i])
+			}
+			if f := formato(c); f != formatoMoneda {
+				t.Errorf("%s %q %s: numFmtId %d, want %d", hoja, etiqueta, c.Ref, f, formatoMoneda)
+			}
+		}
+	}
+
+	var resultados hojaXML
+	leerXML(t, z, "xl/worksheets/sheet1.xml", &resultados)
+	// mes 1, mes 2 y total del año
+	importes("EstadoResultados", "Ventas", filaDe(t, resultados, "Ventas"), []float64{1000, 1500.25, 2500.25})
+	importes("EstadoResultados", "Utilidad neta", filaDe(t, resultados, "Utilidad neta"), []float64{450, 675, 1125})
+	if got := resultados.Filas[1].Celdas[0].Texto; got != "Cafetería <centro>" {
+		t.Errorf("problemática = %q", got)
+	}
+
+	var prestamo hojaXML
+	leerXML(t, z, "xl/worksheets/sheet4.xml", &prestamo)
+	cuota := prestamo.Filas[4].Celdas
+	if len(cuota) != 8 {
+		t.Fatalf("cuota = %+v", cuota)
+	}
+	importes("PrestamoCuotas", "cuota 1", cuota[3:], []float64{1000, 10, 90, 100, 910})
+	importes("PrestamoCuotas", "Total año 1", filaDe(t, prestamo, "Total año 1")[3:], []float64{10, 90, 100, 910})
+}
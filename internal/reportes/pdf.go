@@ -0,0 +1,433 @@
+package reportes
+
+import (
+	"fmt"
+	"math"
+	"strings"
+
+	"github.com/JostinAlvaradoS/liveplan_backend_go/internal/models"
+	"github.com/JostinAlvaradoS/liveplan_backend_go/internal/pdf"
+	"github.com/JostinAlvaradoS/liveplan_backend_go/internal/procedimientos"
+)
+
+const (
+	margen     = 40.0
+	anchoUtil  = pdf.AnchoA4 - 2*margen
+	limiteY    = pdf.AltoA4 - 50
+	altoFila   = 14.0
+	tamTabla   = 8.0
+	tamTexto   = 10.0
+	aniosTabla = 5 // columnas de año por tabla; los horizontes largos se parten
+)
+
+var (
+	colorEncabezado = pdf.Color{R: 0.85, G: 0.88, B: 0.95}
+	colorTotal      = pdf.Color{R: 0.94, G: 0.95, B: 0.98}
+	colorPositivo   = pdf.Color{R: 0.20, G: 0.65, B: 0.30}
+	colorNegativo   = pdf.Color{R: 0.85, G: 0.25, B: 0.25}
+)
+
+// ReportePDF arma el dossier del plan: problemática y descripción, inversión
+// inicial y su financiamiento, estados financieros anuales, evaluación
+// (VAN/TIR/TREMA) y el mapa de calor del análisis de sensibilidad. doc debe
+// traer los calculados (procedimientos.ExportarPlan con calculados=true).
+func ReportePDF(doc procedimientos.DocumentoPlan) *pdf.Documento {
+	c := doc.Calculados
+	if c == nil {
+		c = &procedimientos.CalculadosPlan{}
+	}
+	m := &maqueta{d: pdf.Nuevo(), pie: doc.Plan.Problematica}
+	m.d.Titulo = doc.Plan.Problematica
+	m.nuevaPagina()
+
+	m.d.Texto(margen, m.y+20, "Plan de negocio", pdf.Negrita, 22, pdf.Negro, pdf.Izquierda)
+	m.y += 34
+	m.parrafo(doc.Plan.Problematica, pdf.Negrita, 13)
+	m.parrafo(fmt.Sprintf("Horizonte de proyección: %d años. Generado el %s.",
+		doc.Plan.HorizonteAnios, doc.ExportadoEn.Format("02/01/2006")), pdf.Normal, 9)
+	m.y += 8
+
+	m.titulo("Descripción")
+	if strings.TrimSpace(doc.Plan.Descripcion) == "" {
+		m.parrafo("(sin descripción)", pdf.Normal, tamTexto)
+	} else {
+		m.parrafo(doc.Plan.Descripcion, pdf.Normal, tamTexto)
+	}
+
+	m.titulo("Inversión inicial")
+	seccionInversion(m, doc)
+
+	for _, e := range []estado{
+		estadoResultados(c.EstadoResultados),
+		flujoEfectivo(c.FlujoEfectivo),
+		balanceGeneral(c.BalanceGeneral),
+	} {
+		m.titulo(e.titulo + " (anual)")
+		seccionEstado(m, e)
+	}
+
+	m.titulo("Evaluación del proyecto")
+	seccionEvaluacion(m, doc.Entradas.EvaluacionProyecto, c.ConceptosEvaluacion)
+
+	m.titulo("Análisis de sensibilidad")
+	seccionSensibilidad(m, doc.Entradas.AnalisisSensibilidad)
+	return m.d
+}
+
+// maqueta lleva la posición vertical y agrega páginas cuando no cabe el contenido
+type maqueta struct {
+	d   *pdf.Documento
+	y   float64
+	pie string
+}
+
+func (m *maqueta) nuevaPagina() {
+	m.d.NuevaPagina()
+	m.y = margen
+	pie := fmt.Sprintf("Página %d", m.d.Paginas())
+	m.d.Linea(margen, pdf.AltoA4-35, pdf.AnchoA4-margen, pdf.AltoA4-35, 0.5, pdf.Gris)
+	m.d.Texto(margen, pdf.AltoA4-24, recortarTexto(m.pie, pdf.Normal, 8, anchoUtil-60), pdf.Normal, 8, pdf.Gris, pdf.Izquierda)
+	m.d.Texto(pdf.AnchoA4-margen, pdf.AltoA4-24, pie, pdf.Normal, 8, pdf.Gris, pdf.Derecha)
+}
+
+// espacio asegura alto puntos libres en la página; si no, pasa a la siguiente
+func (m *maqueta) espacio(alto float64) {
+	if m.y+alto > limiteY {
+		m.nuevaPagina()
+	}
+}
+
+func (m *maqueta) titulo(s string) {
+	m.espacio(60)
+	m.y += 14
+	m.d.Texto(margen, m.y+12, s, pdf.Negrita, 14, pdf.Negro, pdf.Izquierda)
+	m.y += 18
+	m.d.Linea(margen, m.y, pdf.AnchoA4-margen, m.y, 0.8, pdf.Negro)
+	m.y += 8
+}
+
+func (m *maqueta) subtitulo(s string) {
+	m.espacio(40)
+	m.y += 4
+	m.d.Texto(margen, m.y+10, s, pdf.Negrita, 10, pdf.Negro, pdf.Izquierda)
+	m.y += 16
+}
+
+// parrafo escribe s ajustando las palabras al ancho de la página; los saltos
+// de línea de s separan párrafos
+func (m *maqueta) parrafo(s string, f pdf.Fuente, tam float64) {
+	interlinea := tam * 1.35
+	for _, p := range strings.Split(strings.ReplaceAll(s, "\r\n", "\n"), "\n") {
+		for _, linea := range ajustar(p, f, tam, anchoUtil) {
+			m.espacio(interlinea)
+			m.d.Texto(margen, m.y+tam, linea, f, tam, pdf.Negro, pdf.Izquierda)
+			m.y += interlinea
+		}
+	}
+	m.y += tam * 0.5
+}
+
+// ajustar parte el texto en líneas que no superan ancho
+func ajustar(s string, f pdf.Fuente, tam, ancho float64) []string {
+	palabras := strings.Fields(s)
+	if len(palabras) == 0 {
+		return []string{""}
+	}
+	var lineas []string
+	actual := ""
+	for _, p := range palabras {
+		candidata := p
+		if actual != "" {
+			candidata = actual + " " + p
+		}
+		if actual != "" && pdf.AnchoTexto(candidata, f, tam) > ancho {
+			lineas = append(lineas, actual)
+			candidata = p
+		}
+		actual = candidata
+	}
+	return append(lineas, actual)
+}
+
+func recortarTexto(s string, f pdf.Fuente, tam, ancho float64) string {
+	if pdf.AnchoTexto(s, f, tam) <= ancho {
+		return s
+	}
+	r := []rune(s)
+	for len(r) > 0 && pdf.AnchoTexto(string(r)+"…", f, tam) > ancho {
+		r = r[:len(r)-1]
+	}
+	return string(r) + "…"
+}
+
+// fila de una tabla del reporte
+type fila struct {
+	celdas []string
+	total  bool
+}
+
+// tabla dibuja una tabla con la primera columna a la izquierda y las demás
+// (importes) a la derecha. Si se parte entre páginas repite el encabezado.
+func (m *maqueta) tabla(encabezados []string, anchoEtiqueta float64, filas []fila) {
+	anchoCol := (anchoUtil - anchoEtiqueta) / float64(len(encabezados)-1)
+	x := func(col int) float64 {
+		if col == 0 {
+			return margen
+		}
+		return margen + anchoEtiqueta + float64(col)*anchoCol
+	}
+	dibujarEncabezado := func() {
+		m.d.Rect(margen, m.y, anchoUtil, altoFila, colorEncabezado)
+		for i, t := range encabezados {
+			if i == 0 {
+				m.d.Texto(x(0)+3, m.y+10, t, pdf.Negrita, tamTabla, pdf.Negro, pdf.Izquierda)
+			} else {
+				m.d.Texto(x(i)-3, m.y+10, t, pdf.Negrita, tamTabla, pdf.Negro, pdf.Derecha)
+			}
+		}
+		m.y += altoFila
+	}
+	m.espacio(3 * altoFila)
+	dibujarEncabezado()
+	for _, f := range filas {
+		if m.y+altoFila > limiteY {
+			m.nuevaPagina()
+			dibujarEncabezado()
+		}
+		fuente := pdf.Normal
+		if f.total {
+			fuente = pdf.Negrita
+			m.d.Rect(margen, m.y, anchoUtil, altoFila, colorTotal)
+		}
+		for i, t := range f.celdas {
+			if i == 0 {
+				m.d.Texto(x(0)+3, m.y+10, recortarTexto(t, fuente, tamTabla, anchoEtiqueta-6), fuente, tamTabla, pdf.Negro, pdf.Izquierda)
+			} else {
+				m.d.Texto(x(i)-3, m.y+10, t, fuente, tamTabla, pdf.Negro, pdf.Derecha)
+			}
+		}
+		m.y += altoFila
+		m.d.Linea(margen, m.y, pdf.AnchoA4-margen, m.y, 0.25, pdf.Color{R: 0.8, G: 0.8, B: 0.8})
+	}
+	m.y += 10
+}
+
+func seccionInversion(m *maqueta, doc procedimientos.DocumentoPlan) {
+	inversiones := doc.Entradas.Inversiones
+	if len(inversiones) == 0 {
+		m.parrafo("El plan no tiene inversión inicial registrada.", pdf.Normal, tamTexto)
+	} else {
+		var filas []fila
+		total := 0.0
+		for _, inv := range inversiones {
+			tipo := ""
+			if inv.Tipo != nil {
+				tipo = inv.Tipo.Tipo
+			}
+			filas = append(filas, fila{celdas: []string{inv.Seccion, tipo, moneda(inv.Importe)}})
+			total += inv.Importe
+		}
+		filas = append(filas, fila{celdas: []string{"Total", "", moneda(total)}, total: true})
+		m.tabla([]string{"Sección", "Tipo", "Importe"}, 240, filas)
+	}
+
+	cf := doc.Entradas.ComposicionFinanciamiento
+	if cf == nil {
+		return
+	}
+	m.subtitulo("Composición del financiamiento")
+	m.tabla([]string{"Fuente", "Porcentaje", "Importe"}, 240, []fila{
+		{celdas: []string{"Capital propio", porcentaje(cf.CapitalPorcentaje), moneda(cf.Total_Inversion * cf.CapitalPorcentaje / 100)}},
+		{celdas: []string{"Deuda", porcentaje(cf.DeudaPorcentaje), moneda(cf.Total_Inversion * cf.DeudaPorcentaje / 100)}},
+		{celdas: []string{"Total inversión", porcentaje(cf.CapitalPorcentaje + cf.DeudaPorcentaje), moneda(cf.Total_Inversion)}, total: true},
+	})
+}
+
+// seccionEstado escribe el estado con una columna por año (total anual), en
+// tablas de a lo sumo aniosTabla años
+func seccionEstado(m *maqueta, e estado) {
+	anios := e.anios()
+	if len(anios) == 0 {
+		m.parrafo(sinDatos+".", pdf.Normal, tamTexto)
+		return
+	}
+	totales := make(map[int][]float64, len(anios))
+	for _, a := range anios {
+		totales[a] = e.totalAnual(e.delAnio(a))
+	}
+	for desde := 0; desde < len(anios); desde += aniosTabla {
+		grupo := anios[desde:min(desde+aniosTabla, len(anios))]
+		encabezados := []string{"Concepto"}
+		for _, a := range grupo {
+			encabezados = append(encabezados, fmt.Sprintf("Año %d", a))
+		}
+		filas := make([]fila, len(e.conceptos))
+		for j, c := range e.conceptos {
+			filas[j] = fila{celdas: []string{c.etiqueta}, total: c.total}
+			for _, a := range grupo {
+				filas[j].celdas = append(filas[j].celdas, moneda(totales[a][j]))
+			}
+		}
+		m.tabla(encabezados, 170, filas)
+	}
+}
+
+func seccionEvaluacion(m *maqueta, ev *models.EvaluacionProyecto, conceptos []models.ConceptosEvaluacion) {
+	if ev == nil {
+		m.parrafo(sinDatos+".", pdf.Normal, tamTexto)
+		return
+	}
+	m.tabla([]string{"Indicador", "Valor"}, 300, []fila{
+		{celdas: []string{"Valor actual neto (VAN)", moneda(ev.VAN)}, total: true},
+		{celdas: []string{"Tasa interna de retorno (TIR)", porcentaje(ev.TIR)}, total: true},
+		{celdas: []string{"Tasa de rendimiento mínima aceptable (TREMA)", porcentaje(ev.TREMA)}},
+	})
+	switch {
+	case math.IsNaN(ev.TIR) || math.IsInf(ev.TIR, 0):
+		m.parrafo("La TIR no está definida para estos flujos (no cambian de signo).", pdf.Normal, tamTexto)
+	case ev.VAN >= 0:
+		m.parrafo(fmt.Sprintf("Con un VAN de %s y una TIR de %s frente a una TREMA de %s, el proyecto es rentable a la tasa exigida.",
+			moneda(ev.VAN), porcentaje(ev.TIR), porcentaje(ev.TREMA)), pdf.Normal, tamTexto)
+	default:
+		m.parrafo(fmt.Sprintf("Con un VAN de %s y una TIR de %s frente a una TREMA de %s, el proyecto no alcanza la rentabilidad exigida.",
+			moneda(ev.VAN), porcentaje(ev.TIR), porcentaje(ev.TREMA)), pdf.Normal, tamTexto)
+	}
+	if len(conceptos) == 0 {
+		return
+	}
+	var filas []fila
+	for _, c := range conceptos {
+		filas = append(filas, fila{celdas: []string{
+			fmt.Sprintf("Año %d", c.Anio), texto(c.FlujoEfectivoNominal), texto(c.ValorRescate),
+			texto(c.TotalFlujoEfectivo), texto(c.ValorActualFlujosFuturos),
+		}})
+	}
+	m.subtitulo("Flujos del proyecto")
+	m.tabla([]string{"Periodo", "Flujo nominal", "Valor de rescate", "Flujo total", "Valor actual"}, 95, filas)
+}
+
+// seccionSensibilidad dibuja la matriz del VAN como mapa de calor: verde para
+// VAN positivo, rojo para negativo, más intenso cuanto mayor el valor absoluto
+func seccionSensibilidad(m *maqueta, celdas []models.AnalisisSensibilidad) {
+	if len(celdas) == 0 {
+		m.parrafo("El plan no tiene matriz de sensibilidad.", pdf.Normal, tamTexto)
+		return
+	}
+	volumenes, costos := ejes(celdas)
+	valor := make(map[[2]float64]float64, len(celdas))
+	maximo := 0.0
+	for _, c := range celdas {
+		valor[[2]float64{c.Volumen, c.Costo}] = c.Valor
+		maximo = math.Max(maximo, math.Abs(c.Valor))
+	}
+	m.parrafo("VAN del proyecto para cada combinación de variación del volumen vendido (filas) y del costo (columnas).",
+		pdf.Normal, tamTexto)
+
+	const etiqueta = 60.0
+	ancho := math.Min(70, (anchoUtil-etiqueta)/float64(len(costos)))
+	alto := 20.0
+	m.espacio(alto * float64(len(volumenes)+2))
+	m.d.Texto(margen+etiqueta+ancho*float64(len(costos))/2, m.y+10, "Costo", pdf.Negrita, tamTabla, pdf.Negro, pdf.Centro)
+	m.y += 14
+	for i, c := range costos {
+		m.d.Texto(margen+etiqueta+ancho*(float64(i)+0.5), m.y+12, porcentajeSigno(c), pdf.Negrita, tamTabla, pdf.Negro, pdf.Centro)
+	}
+	m.d.Texto(margen, m.y+12, "Volumen", pdf.Negrita, tamTabla, pdf.Negro, pdf.Izquierda)
+	m.y += alto
+	for _, v := range volumenes {
+		m.d.Texto(margen+etiqueta-6, m.y+13, porcentajeSigno(v), pdf.Negrita, tamTabla, pdf.Negro, pdf.Derecha)
+		for i, c := range costos {
+			x := margen + etiqueta + ancho*float64(i)
+			val, ok := valor[[2]float64{v, c}]
+			if !ok {
+				continue
+			}
+			fondo := calor(val, maximo)
+			m.d.Rect(x, m.y, ancho, alto, fondo)
+			tinta := pdf.Negro
+			if fondo.R+fondo.G+fondo.B < 1.6 {
+				tinta = pdf.Blanco
+			}
+			m.d.Texto(x+ancho/2, m.y+13, compacto(val), pdf.Normal, 7, tinta, pdf.Centro)
+		}
+		m.y += alto
+	}
+	m.y += 10
+}
+
+// calor interpola entre blanco y verde (positivo) o rojo (negativo)
+func calor(v, maximo float64) pdf.Color {
+	if maximo == 0 {
+		return pdf.Blanco
+	}
+	t := math.Min(math.Abs(v)/maximo, 1)
+	destino := colorPositivo
+	if v < 0 {
+		destino = colorNegativo
+	}
+	return pdf.Color{
+		R: 1 + (destino.R-1)*t,
+		G: 1 + (destino.G-1)*t,
+		B: 1 + (destino.B-1)*t,
+	}
+}
+
+// moneda formatea un importe como $1,234,567.89
+func moneda(v float64) string {
+	if math.IsNaN(v) || math.IsInf(v, 0) {
+		return "n/d"
+	}
+	signo := ""
+	if v < 0 {
+		signo = "-"
+		v = -v
+	}
+	s := fmt.Sprintf("%.2f", v)
+	entero, dec := s[:len(s)-3], s[len(s)-3:]
+	var b strings.Builder
+	for i, d := range entero {
+		if i > 0 && (len(entero)-i)%3 == 0 {
+			b.WriteByte(',')
+		}
+		b.WriteRune(d)
+	}
+	return signo + "$" + b.String() + dec
+}
+
+// compacto abrevia importes grandes para las celdas del mapa de calor
+func compacto(v float64) string {
+	a := math.Abs(v)
+	switch {
+	case a >= 1e6:
+		return fmt.Sprintf("%.1fM", v/1e6)
+	case a >= 1e3:
+		return fmt.Sprintf("%.1fk", v/1e3)
+	}
+	return fmt.Sprintf("%.0f", v)
+}
+
+func porcentaje(v float64) string {
+	if math.IsNaN(v) || math.IsInf(v, 0) {
+		return "n/d"
+	}
+	return fmt.Sprintf("%.2f %%", v)
+}
+
+func porcentajeSigno(v float64) string {
+	return fmt.Sprintf("%+g %%", v)
+}
+
+// texto formatea los importes que ConceptosEvaluacion guarda como texto
+func texto(s string) string {
+	if v, ok := numero(s); ok {
+		return moneda(v)
+	}
+	return s
+}
+
+func min(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,83 @@
+package reportes
+
+import (
+	"bytes"
+	"compress/zlib"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestReportePDF(t *testing.T) {
+	d := ReportePDF(documentoDePrueba())
+	var buf bytes.Buffer
+	if err := d.Escribir(&buf); err != nil {
+		t.Fatal(err)
+	}
+	raw := buf.Bytes()
+	if !bytes.HasPrefix(raw, []byte("%PDF-")) {
+		t.Fatalf("empieza con %q, want %%PDF-", raw[:min(8, len(raw))])
+	}
+	if !bytes.HasSuffix(raw, []byte("%%EOF\n")) {
+		t.Errorf("no termina con %%%%EOF")
+	}
+
+	// startxref apunta a la tabla xref
+	m := regexp.MustCompile(`startxref\n(\d+)\n%%EOF\n$`).FindSubmatch(raw)
+	if m == nil {
+		t.Fatal("sin startxref")
+	}
+	inicio, _ := strconv.Atoi(string(m[1]))
+	if inicio >= len(raw) || !bytes.HasPrefix(raw[inicio:], []byte("xref\n")) {
+		t.Fatalf("startxref %d no apunta a la tabla xref", inicio)
+	}
+	lineas := strings.Split(string(raw[inicio:]), "\n")
+	var desde, n int
+	if _, err := fmt.Sscanf(lineas[1], "%d %d", &desde, &n); err != nil || desde != 0 {
+		t.Fatalf("subsección xref %q", lineas[1])
+	}
+	if lineas[2] != "0000000000 65535 f " {
+		t.Errorf("entrada 0 = %q", lineas[2])
+	}
+	// cada entrada en uso tiene 20 bytes y apunta a "k 0 obj"
+	entrada := regexp.MustCompile(`^(\d{10}) 00000 n $`)
+	for k := 1; k < n; k++ {
+		e := entrada.FindStringSubmatch(lineas[2+k])
+		if e == nil {
+			t.Fatalf("entrada %d = %q", k, lineas[2+k])
+		}
+		off, _ := strconv.Atoi(e[1])
+		if obj := fmt.Sprintf("%d 0 obj\n", k); off >= len(raw) || !bytes.HasPrefix(raw[off:], []byte(obj)) {
+			t.Errorf("entrada %d: el offset %d no apunta a %q", k, off, obj)
+		}
+	}
+	if lineas[2+n] != "trailer" || !strings.Contains(lineas[3+n], fmt.Sprintf("/Size %d ", n)) {
+		t.Errorf("trailer = %q %q, want /Size %d", lineas[2+n], lineas[3+n], n)
+	}
+
+	// una página y un contenido por página después de los 5 objetos fijos
+	if paginas := d.Paginas(); n != 6+2*paginas || !bytes.Contains(raw, []byte(fmt.Sprintf("/Count %d >>", paginas))) {
+		t.Errorf("%d objetos para %d páginas", n-1, paginas)
+	}
+
+	// el contenido de la primera página se descomprime y trae la problemática
+	stream := regexp.MustCompile(`(?s)/Length (\d+) /Filter /FlateDecode >>\nstream\n`).FindSubmatchIndex(raw)
+	if stream == nil {
+		t.Fatal("sin contenido")
+	}
+	largo, _ := strconv.Atoi(string(raw[stream[2]:stream[3]]))
+	zr, err := zlib.NewReader(bytes.NewReader(raw[stream[1] : stream[1]+largo]))
+	if err != nil {
+		t.Fatal(err)
+	}
+	contenido, err := io.ReadAll(zr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Contains(contenido, []byte(`Cafeter\355a <centro>`)) {
+		t.Errorf("la primera página no muestra la problemática")
+	}
+}
@@ -0,0 +1,326 @@
+// Package xlsx escribe libros de Excel (Office Open XML) sencillos sin
+// dependencias externas: hojas con celdas de texto o numéricas, algunos estilos
+// fijos (encabezado, moneda, porcentaje), celdas combinadas, anchos de columna
+// y paneles inmovilizados.
+package xlsx
+
+import (
+	"archive/zip"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Estilo es el índice de formato de una celda (cellXfs de styles.xml)
+type Estilo int
+
+const (
+	EstiloNormal Estilo = iota
+	EstiloEncabezado
+	EstiloMoneda
+	EstiloMonedaTotal
+	EstiloPorcentaje
+	EstiloTitulo
+	EstiloNumero
+)
+
+// Celda es un valor de la hoja: string, float64, int o nil (celda vacía)
+type Celda struct {
+	Valor  interface{}
+	Estilo Estilo
+}
+
+// Texto crea una celda de texto
+func Texto(s string, e Estilo) Celda { return Celda{Valor: s, Estilo: e} }
+
+// Numero crea una celda numérica
+func Numero(v float64, e Estilo) Celda { return Celda{Valor: v, Estilo: e} }
+
+// Hoja es una pestaña del libro
+type Hoja struct {
+	Nombre    string
+	filas     [][]Celda
+	combinar  []string
+	anchos    map[int]float64
+	fijarFila int
+	fijarCol  int
+}
+
+// Fila agrega una fila al final de la hoja y devuelve su número (desde 1)
+func (h *Hoja) Fila(celdas ...Celda) int {
+	h.filas = append(h.filas, celdas)
+	return len(h.filas)
+}
+
+// Combinar une el rango de celdas (fila y columna desde 1)
+func (h *Hoja) Combinar(fila1, col1, fila2, col2 int) {
+	h.combinar = append(h.combinar, Referencia(fila1, col1)+":"+Referencia(fila2, col2))
+}
+
+// Ancho fija el ancho (en caracteres) de la columna col (desde 1)
+func (h *Hoja) Ancho(col int, ancho float64) {
+	if h.anchos == nil {
+		h.anchos = make(map[int]float64)
+	}
+	h.anchos[col] = ancho
+}
+
+// Inmovilizar deja fijas las filas por encima de fila y las columnas a la
+// izquierda de col (ambas desde 1)
+func (h *Hoja) Inmovilizar(fila, col int) {
+	h.fijarFila, h.fijarCol = fila, col
+}
+
+// Libro es un archivo .xlsx en construcción
+type Libro struct {
+	hojas []*Hoja
+}
+
+// Nuevo crea un libro vacío
+func Nuevo() *Libro { return &Libro{} }
+
+// Hoja agrega una hoja con el nombre indicado (se recorta a 31 caracteres y
+// se quitan los caracteres que Excel no admite)
+func (l *Libro) Hoja(nombre string) *Hoja {
+	nombre = strings.Map(func(r rune) rune {
+		if strings.ContainsRune(`[]:*?/\`, r) {
+			return '-'
+		}
+		return r
+	}, nombre)
+	if r := []rune(nombre); len(r) > 31 {
+		nombre = string(r[:31])
+	}
+	h := &Hoja{Nombre: nombre}
+	l.hojas = append(l.hojas, h)
+	return h
+}
+
+// Referencia devuelve la referencia A1 de una celda (fila y columna desde 1)
+func Referencia(fila, col int) string {
+	return Columna(col) + strconv.Itoa(fila)
+}
+
+// Columna devuelve la letra de la columna col (1 = A, 27 = AA)
+func Columna(col int) string {
+	var b []byte
+	for col > 0 {
+		col--
+		b = append([]byte{byte('A' + col%26)}, b...)
+		col /= 26
+	}
+	return string(b)
+}
+
+// Escribir serializa el libro en w
+func (l *Libro) Escribir(w io.Writer) error {
+	if len(l.hojas) == 0 {
+		l.Hoja("Hoja1")
+	}
+	z := zip.NewWriter(w)
+	archivos := []struct {
+		nombre string
+		cont   func(io.Writer) error
+	}{
+		{"[Content_Types].xml", l.tiposContenido},
+		{"_rels/.rels", texto(relsRaiz)},
+		{"xl/workbook.xml", l.libroXML},
+		{"xl/_rels/workbook.xml.rels", l.relsLibro},
+		{"xl/styles.xml", texto(estilosXML)},
+	}
+	for _, a := range archivos {
+		f, err := z.Create(a.nombre)
+		if err != nil {
+			return err
+		}
+		if err := a.cont(f); err != nil {
+			return err
+		}
+	}
+	for i, h := range l.hojas {
+		f, err := z.Create(fmt.Sprintf("xl/worksheets/sheet%d.xml", i+1))
+		if err != nil {
+			return err
+		}
+		if err := h.escribir(f); err != nil {
+			return err
+		}
+	}
+	return z.Close()
+}
+
+func texto(s string) func(io.Writer) error {
+	return func(w io.Writer) error {
+		_, err := io.WriteString(w, s)
+		return err
+	}
+}
+
+const cabeceraXML = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>` + "\n"
+
+const relsRaiz = cabeceraXML + `<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+	`<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" Target="xl/workbook.xml"/>` +
+	`</Relationships>`
+
+// estilosXML define los formatos de Estilo en el mismo orden que las constantes
+const estilosXML = cabeceraXML + `<styleSheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main">` +
+	`<numFmts count="1"><numFmt numFmtId="164" formatCode="&quot;$&quot;#,##0.00;[Red]\-&quot;$&quot;#,##0.00"/></numFmts>` +
+	`<fonts count="3">` +
+	`<font><sz val="11"/><name val="Calibri"/></font>` +
+	`<font><b/><sz val="11"/><name val="Calibri"/></font>` +
+	`<font><b/><sz val="14"/><name val="Calibri"/></font>` +
+	`</fonts>` +
+	`<fills count="3">` +
+	`<fill><patternFill patternType="none"/></fill>` +
+	`<fill><patternFill patternType="gray125"/></fill>` +
+	`<fill><patternFill patternType="solid"><fgColor rgb="FFD9E1F2"/><bgColor indexed="64"/></patternFill></fill>` +
+	`</fills>` +
+	`<borders count="1"><border><left/><right/><top/><bottom/><diagonal/></border></borders>` +
+	`<cellStyleXfs count="1"><xf numFmtId="0" fontId="0" fillId="0" borderId="0"/></cellStyleXfs>` +
+	`<cellXfs count="7">` +
+	`<xf numFmtId="0" fontId="0" fillId="0" borderId="0" xfId="0"/>` +
+	`<xf numFmtId="0" fontId="1" fillId="2" borderId="0" xfId="0" applyFont="1" applyFill="1" applyAlignment="1"><alignment horizontal="center"/></xf>` +
+	`<xf numFmtId="164" fontId="0" fillId="0" borderId="0" xfId="0" applyNumberFormat="1"/>` +
+	`<xf numFmtId="164" fontId="1" fillId="2" borderId="0" xfId="0" applyNumberFormat="1" applyFont="1" applyFill="1"/>` +
+	`<xf numFmtId="10" fontId="0" fillId="0" borderId="0" xfId="0" applyNumberFormat="1"/>` +
+	`<xf numFmtId="0" fontId="2" fillId="0" borderId="0" xfId="0" applyFont="1"/>` +
+	`<xf numFmtId="4" fontId="0" fillId="0" borderId="0" xfId="0" applyNumberFormat="1"/>` +
+	`</cellXfs>` +
+	`<cellStyles count="1"><cellStyle name="Normal" xfId="0" builtinId="0"/></cellStyles>` +
+	`</styleSheet>`
+
+func (l *Libro) tiposContenido(w io.Writer) error {
+	var b strings.Builder
+	b.WriteString(cabeceraXML)
+	b.WriteString(`<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types">`)
+	b.WriteString(`<Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/>`)
+	b.WriteString(`<Default Extension="xml" ContentType="application/xml"/>`)
+	b.WriteString(`<Override PartName="/xl/workbook.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.sheet.main+xml"/>`)
+	b.WriteString(`<Override PartName="/xl/styles.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.styles+xml"/>`)
+	for i := range l.hojas {
+		fmt.Fprintf(&b, `<Override PartName="/xl/worksheets/sheet%d.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.worksheet+xml"/>`, i+1)
+	}
+	b.WriteString(`</Types>`)
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+func (l *Libro) libroXML(w io.Writer) error {
+	var b strings.Builder
+	b.WriteString(cabeceraXML)
+	b.WriteString(`<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships"><sheets>`)
+	for i, h := range l.hojas {
+		fmt.Fprintf(&b, `<sheet name="%s" sheetId="%d" r:id="rId%d"/>`, escapar(h.Nombre), i+1, i+1)
+	}
+	b.WriteString(`</sheets></workbook>`)
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+func (l *Libro) relsLibro(w io.Writer) error {
+	var b strings.Builder
+	b.WriteString(cabeceraXML)
+	b.WriteString(`<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">`)
+	for i := range l.hojas {
+		fmt.Fprintf(&b, `<Relationship Id="rId%d" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/sheet%d.xml"/>`, i+1, i+1)
+	}
+	fmt.Fprintf(&b, `<Relationship Id="rId%d" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/styles" Target="styles.xml"/>`, len(l.hojas)+1)
+	b.WriteString(`</Relationships>`)
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+func (h *Hoja) escribir(w io.Writer) error {
+	var b strings.Builder
+	b.WriteString(cabeceraXML)
+	b.WriteString(`<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships">`)
+	if h.fijarFila > 1 || h.fijarCol > 1 {
+		b.WriteString(`<sheetViews><sheetView workbookViewId="0"><pane`)
+		if h.fijarCol > 1 {
+			fmt.Fprintf(&b, ` xSplit="%d"`, h.fijarCol-1)
+		}
+		if h.fijarFila > 1 {
+			fmt.Fprintf(&b, ` ySplit="%d"`, h.fijarFila-1)
+		}
+		fmt.Fprintf(&b, ` topLeftCell="%s" activePane="bottomRight" state="frozen"/></sheetView></sheetViews>`, Referencia(max(h.fijarFila, 1), max(h.fijarCol, 1)))
+	}
+	if len(h.anchos) > 0 {
+		b.WriteString(`<cols>`)
+		maxCol := 0
+		for c := range h.anchos {
+			if c > maxCol {
+				maxCol = c
+			}
+		}
+		for c := 1; c <= maxCol; c++ {
+			if a, ok := h.anchos[c]; ok {
+				fmt.Fprintf(&b, `<col min="%d" max="%d" width="%s" customWidth="1"/>`, c, c, strconv.FormatFloat(a, 'f', -1, 64))
+			}
+		}
+		b.WriteString(`</cols>`)
+	}
+	b.WriteString(`<sheetData>`)
+	for i, fila := range h.filas {
+		fmt.Fprintf(&b, `<row r="%d">`, i+1)
+		for j, c := range fila {
+			escribirCelda(&b, Referencia(i+1, j+1), c)
+		}
+		b.WriteString(`</row>`)
+	}
+	b.WriteString(`</sheetData>`)
+	if len(h.combinar) > 0 {
+		fmt.Fprintf(&b, `<mergeCells count="%d">`, len(h.combinar))
+		for _, m := range h.combinar {
+			fmt.Fprintf(&b, `<mergeCell ref="%s"/>`, m)
+		}
+		b.WriteString(`</mergeCells>`)
+	}
+	b.WriteString(`</worksheet>`)
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+func escribirCelda(b *strings.Builder, ref string, c Celda) {
+	var num float64
+	switch v := c.Valor.(type) {
+	case nil:
+		if c.Estilo != EstiloNormal {
+			fmt.Fprintf(b, `<c r="%s" s="%d"/>`, ref, c.Estilo)
+		}
+		return
+	case string:
+		fmt.Fprintf(b, `<c r="%s" s="%d" t="inlineStr"><is><t xml:space="preserve">%s</t></is></c>`, ref, c.Estilo, escapar(v))
+		return
+	case float64:
+		num = v
+	case int:
+		num = float64(v)
+	case uint:
+		num = float64(v)
+	default:
+		fmt.Fprintf(b, `<c r="%s" s="%d" t="inlineStr"><is><t>%s</t></is></c>`, ref, c.Estilo, escapar(fmt.Sprint(v)))
+		return
+	}
+	if math.IsNaN(num) || math.IsInf(num, 0) {
+		// Excel no admite NaN/Inf: la celda queda vacía
+		fmt.Fprintf(b, `<c r="%s" s="%d"/>`, ref, c.Estilo)
+		return
+	}
+	fmt.Fprintf(b, `<c r="%s" s="%d"><v>%s</v></c>`, ref, c.Estilo, strconv.FormatFloat(num, 'f', -1, 64))
+}
+
+func escapar(s string) string {
+	var b strings.Builder
+	xml.EscapeText(&b, []byte(s))
+	return b.String()
+}
+
+func max(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
@@ -3,9 +3,14 @@ package main
 import (
 	"log"
 	"net/http"
+	"os"
+	"strconv"
+	"time"
 
+	"github.com/JostinAlvaradoS/liveplan_backend_go/internal/auth"
 	"github.com/JostinAlvaradoS/liveplan_backend_go/internal/db"
 	"github.com/JostinAlvaradoS/liveplan_backend_go/internal/handlers"
+	"github.com/JostinAlvaradoS/liveplan_backend_go/internal/procedimientos"
 )
 
 func main() {
@@ -25,7 +30,67 @@ func main() {
 		log.Fatalf("migration failed: %v", err)
 	}
 
-	app := &handlers.App{DB: dbconn}
+	verifier, err := auth.NewVerifierFromEnv()
+	if err != nil {
+		log.Fatalf("auth config: %v", err)
+	}
+	if verifier == nil {
+		log.Println("WARNING: authentication disabled (AUTH_DISABLED=true)")
+	}
+
+	if os.Getenv("RECALCULO_ESTRICTO") == "true" {
+		procedimientos.IntegridadEstricta = true
+		log.Println("recálculo estricto: Recalcular falla si los estados no pasan /integridad")
+	}
+
+	if os.Getenv("RECALCULO_ATOMICO") == "true" {
+		procedimientos.RecalculoAtomico = true
+		log.Println("recálculo atómico: cada ejecución de Recalcular corre en una sola transacción")
+	}
+
+	if v := os.Getenv("RECALCULO_VENTANA_MS"); v != "" {
+		ms, err := strconv.Atoi(v)
+		if err != nil || ms < 0 {
+			log.Fatalf("RECALCULO_VENTANA_MS inválido: %q", v)
+		}
+		procedimientos.ConfigurarRecalculo(time.Duration(ms) * time.Millisecond)
+	}
+
+	if conc, reint := os.Getenv("RECALCULO_CONCURRENCIA"), os.Getenv("RECALCULO_REINTENTOS"); conc != "" || reint != "" {
+		cfg := procedimientos.ConfiguracionEjecutor()
+		var err error
+		if conc != "" {
+			if cfg.Concurrencia, err = strconv.Atoi(conc); err != nil {
+				log.Fatalf("RECALCULO_CONCURRENCIA inválido: %q", conc)
+			}
+		}
+		if reint != "" {
+			if cfg.Reintentos, err = strconv.Atoi(reint); err != nil {
+				log.Fatalf("RECALCULO_REINTENTOS inválido: %q", reint)
+			}
+		}
+		if err := procedimientos.ConfigurarEjecutor(cfg); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	if general, porEtapa := os.Getenv("RECALCULO_PLAZO"), os.Getenv("RECALCULO_PLAZOS"); general != "" || porEtapa != "" {
+		var p procedimientos.Plazos
+		var err error
+		if general != "" {
+			if p.General, err = time.ParseDuration(general); err != nil {
+				log.Fatalf("RECALCULO_PLAZO inválido: %v", err)
+			}
+		}
+		if p.PorEtapa, err = procedimientos.ParsearPlazos(porEtapa); err != nil {
+			log.Fatalf("RECALCULO_PLAZOS inválido: %v", err)
+		}
+		if err := procedimientos.ConfigurarPlazos(p); err != nil {
+			log.Fatalf("RECALCULO_PLAZOS inválido: %v", err)
+		}
+	}
+
+	app := &handlers.App{DB: dbconn, Auth: verifier}
 	mux := app.Routes()
 
 	log.Println("listening on :8080")